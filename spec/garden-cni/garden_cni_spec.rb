@@ -19,8 +19,19 @@ module Bosh::Template::Test
             'cni_config_dir' => 'meow-config-dir',
             'nat_port_range_start' => 1111,
             'nat_port_range_size' => 5555,
+            'nat_port_ranges' => [{'start' => 2000, 'size' => 100}],
+            'nat_reserved_ports' => [2010],
+            'nat_port_allocation_strategy' => 'random',
             'search_domains' => ['meow', 'woof', 'neopets'],
-            'experimental_proxy_redirect_cidr' => 'some-proxy-cidr'
+            'experimental_proxy_redirect_cidr' => 'some-proxy-cidr',
+            'max_concurrent_requests' => 8,
+            'drain_timeout_seconds' => 20,
+            'metron_port' => 1234,
+            'metrics_sink' => 'statsd',
+            'statsd_address' => '127.0.0.1:8125',
+            'default_cni_chain' => 'some-chain',
+            'cni_config_reload_seconds' => 30,
+            'firewall_backend' => 'nftables'
           }
         end
 
@@ -31,11 +42,24 @@ module Bosh::Template::Test
             'cni_config_dir' => 'meow-config-dir',
             'bind_mount_dir' => '/var/vcap/data/garden-cni/container-netns',
             'state_file' => '/var/vcap/data/garden-cni/external-networker-state.json',
+            'cni_result_cache_dir' => '/var/vcap/data/garden-cni/cni-results',
+            'net_rules_dir' => '/var/vcap/data/garden-cni/net-rules',
             'start_port' => 1111,
             'total_ports' => 5555,
+            'port_ranges' => [{'start' => 2000, 'size' => 100}],
+            'reserved_ports' => [2010],
+            'port_allocation_strategy' => 'random',
             'log_prefix' => 'cfnetworking',
             'search_domains' => ['meow', 'woof', 'neopets'],
             'iptables_lock_file' => '/var/vcap/data/garden-cni/iptables.lock',
+            'max_concurrent_requests' => 8,
+            'drain_timeout_seconds' => 20,
+            'metron_port' => 1234,
+            'metrics_sink' => 'statsd',
+            'statsd_address' => '127.0.0.1:8125',
+            'default_cni_chain' => 'some-chain',
+            'cni_config_reload_seconds' => 30,
+            'firewall_backend' => 'nftables',
             'proxy_redirect_cidr' => 'some-proxy-cidr',
             'proxy_port' => 16001,
             'proxy_uid' => 0,
@@ -53,11 +77,24 @@ module Bosh::Template::Test
             'cni_config_dir' => '/var/vcap/jobs/cni/config/cni',
             'bind_mount_dir' => '/var/vcap/data/garden-cni/container-netns',
             'state_file' => '/var/vcap/data/garden-cni/external-networker-state.json',
+            'cni_result_cache_dir' => '/var/vcap/data/garden-cni/cni-results',
+            'net_rules_dir' => '/var/vcap/data/garden-cni/net-rules',
             'start_port' => 61000,
             'total_ports' => 5000,
+            'port_ranges' => [],
+            'reserved_ports' => [],
+            'port_allocation_strategy' => 'round-robin',
             'log_prefix' => 'cfnetworking',
             'search_domains' => [],
             'iptables_lock_file' => '/var/vcap/data/garden-cni/iptables.lock',
+            'max_concurrent_requests' => 4,
+            'drain_timeout_seconds' => 10,
+            'metron_port' => 3457,
+            'metrics_sink' => 'dropsonde',
+            'statsd_address' => '',
+            'default_cni_chain' => '',
+            'cni_config_reload_seconds' => 5,
+            'firewall_backend' => 'iptables',
             'proxy_redirect_cidr' => '',
             'proxy_port' => 16001,
             'proxy_uid' => 0,
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"bosh-dns-adapter/sdcclient"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	CacheHitsMetric      = "DNSCacheHits"
+	CacheMissesMetric    = "DNSCacheMisses"
+	CacheStaleHitsMetric = "DNSCacheStaleHits"
+)
+
+//go:generate counterfeiter -o fakes/metrics_sender.go --fake-name MetricsSender . MetricsSender
+type MetricsSender interface {
+	IncrementCounter(name string)
+}
+
+type CachingClient struct {
+	client        sdcclient.HostsClient
+	ttl           time.Duration
+	negativeTTL   time.Duration
+	maxStale      time.Duration
+	metricsSender MetricsSender
+	clock         clock.Clock
+	logger        lager.Logger
+	entries       map[string]entry
+	lastSweep     time.Time
+	mutex         sync.Mutex
+}
+
+type entry struct {
+	hosts     []sdcclient.Host
+	expiresAt time.Time
+}
+
+func NewCachingClient(
+	client sdcclient.HostsClient,
+	ttl, negativeTTL, maxStale time.Duration,
+	metricsSender MetricsSender,
+	clock clock.Clock,
+	logger lager.Logger,
+) *CachingClient {
+	return &CachingClient{
+		client:        client,
+		ttl:           ttl,
+		negativeTTL:   negativeTTL,
+		maxStale:      maxStale,
+		metricsSender: metricsSender,
+		clock:         clock,
+		logger:        logger,
+		entries:       map[string]entry{},
+		lastSweep:     clock.Now(),
+	}
+}
+
+func (c *CachingClient) Hosts(infrastructureName string) ([]sdcclient.Host, error) {
+	now := c.clock.Now()
+
+	c.mutex.Lock()
+	cached, found := c.entries[infrastructureName]
+	c.mutex.Unlock()
+
+	if found && now.Before(cached.expiresAt) {
+		c.metricsSender.IncrementCounter(CacheHitsMetric)
+		return shuffledCopy(cached.hosts), nil
+	}
+
+	c.metricsSender.IncrementCounter(CacheMissesMetric)
+
+	hosts, err := c.client.Hosts(infrastructureName)
+	if err != nil {
+		if found && now.Before(cached.expiresAt.Add(c.maxStale)) {
+			c.metricsSender.IncrementCounter(CacheStaleHitsMetric)
+			c.logger.Info("serving-stale-entry", lager.Data{
+				"service-name": infrastructureName,
+				"expired-at":   cached.expiresAt,
+				"error":        err.Error(),
+			})
+			return shuffledCopy(cached.hosts), nil
+		}
+		return hosts, err
+	}
+
+	ttl := c.ttl
+	if len(hosts) == 0 {
+		ttl = c.negativeTTL
+	}
+
+	c.mutex.Lock()
+	if ttl > 0 {
+		c.entries[infrastructureName] = entry{
+			hosts:     append([]sdcclient.Host{}, hosts...),
+			expiresAt: now.Add(ttl),
+		}
+	} else {
+		delete(c.entries, infrastructureName)
+	}
+	c.sweepWithLock(now)
+	c.mutex.Unlock()
+
+	return hosts, nil
+}
+
+// sweepWithLock drops entries that can no longer be served, even as stale
+// answers. It runs at most once per positive TTL to keep misses cheap.
+func (c *CachingClient) sweepWithLock(now time.Time) {
+	if now.Sub(c.lastSweep) < c.ttl {
+		return
+	}
+
+	for name, cached := range c.entries {
+		if !now.Before(cached.expiresAt.Add(c.maxStale)) {
+			delete(c.entries, name)
+		}
+	}
+	c.lastSweep = now
+}
+
+func shuffledCopy(hosts []sdcclient.Host) []sdcclient.Host {
+	shuffled := make([]sdcclient.Host, len(hosts))
+	for i, j := range rand.Perm(len(hosts)) {
+		shuffled[i] = hosts[j]
+	}
+	return shuffled
+}
@@ -0,0 +1,179 @@
+package cache_test
+
+import (
+	"errors"
+	"time"
+
+	"bosh-dns-adapter/cache"
+	"bosh-dns-adapter/cache/fakes"
+	"bosh-dns-adapter/sdcclient"
+	sdcfakes "bosh-dns-adapter/sdcclient/fakes"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("CachingClient", func() {
+	var (
+		client        *cache.CachingClient
+		hostsClient   *sdcfakes.HostsClient
+		metricsSender *fakes.MetricsSender
+		fakeClock     *fakeclock.FakeClock
+		logger        *lagertest.TestLogger
+		hosts         []sdcclient.Host
+	)
+
+	BeforeEach(func() {
+		hostsClient = &sdcfakes.HostsClient{}
+		hosts = []sdcclient.Host{{IP: "192.168.0.1", Zone: "z1"}, {IP: "192.168.0.2", Zone: "z2"}}
+		hostsClient.HostsReturns(hosts, nil)
+		metricsSender = &fakes.MetricsSender{}
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		logger = lagertest.NewTestLogger("test")
+
+		client = cache.NewCachingClient(hostsClient, 10*time.Second, 5*time.Second, 30*time.Second, metricsSender, fakeClock, logger)
+	})
+
+	metricsSent := func() []string {
+		names := []string{}
+		for i := 0; i < metricsSender.IncrementCounterCallCount(); i++ {
+			names = append(names, metricsSender.IncrementCounterArgsForCall(i))
+		}
+		return names
+	}
+
+	It("queries the underlying client on a miss", func() {
+		answer, err := client.Hosts("app-id.apps.internal.")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(answer).To(ConsistOf(hosts))
+
+		Expect(hostsClient.HostsCallCount()).To(Equal(1))
+		Expect(hostsClient.HostsArgsForCall(0)).To(Equal("app-id.apps.internal."))
+		Expect(metricsSent()).To(Equal([]string{"DNSCacheMisses"}))
+	})
+
+	It("serves subsequent requests from the cache until the ttl expires", func() {
+		_, err := client.Hosts("app-id.apps.internal.")
+		Expect(err).NotTo(HaveOccurred())
+
+		fakeClock.Increment(9 * time.Second)
+		answer, err := client.Hosts("app-id.apps.internal.")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(answer).To(ConsistOf(hosts))
+		Expect(hostsClient.HostsCallCount()).To(Equal(1))
+
+		fakeClock.Increment(2 * time.Second)
+		_, err = client.Hosts("app-id.apps.internal.")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hostsClient.HostsCallCount()).To(Equal(2))
+
+		Expect(metricsSent()).To(Equal([]string{"DNSCacheMisses", "DNSCacheHits", "DNSCacheMisses"}))
+	})
+
+	It("caches names independently", func() {
+		_, err := client.Hosts("app-id.apps.internal.")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.Hosts("other-app-id.apps.internal.")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(hostsClient.HostsCallCount()).To(Equal(2))
+		Expect(hostsClient.HostsArgsForCall(1)).To(Equal("other-app-id.apps.internal."))
+	})
+
+	It("does not let callers modify cached answers", func() {
+		answer, err := client.Hosts("app-id.apps.internal.")
+		Expect(err).NotTo(HaveOccurred())
+		answer[0].IP = "10.10.10.10"
+
+		answer, err = client.Hosts("app-id.apps.internal.")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(answer).To(ConsistOf(
+			sdcclient.Host{IP: "192.168.0.1", Zone: "z1"},
+			sdcclient.Host{IP: "192.168.0.2", Zone: "z2"},
+		))
+	})
+
+	Context("when the answer is empty", func() {
+		BeforeEach(func() {
+			hostsClient.HostsReturns([]sdcclient.Host{}, nil)
+		})
+
+		It("caches it for the negative ttl", func() {
+			_, err := client.Hosts("missing.apps.internal.")
+			Expect(err).NotTo(HaveOccurred())
+
+			fakeClock.Increment(4 * time.Second)
+			answer, err := client.Hosts("missing.apps.internal.")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(answer).To(BeEmpty())
+			Expect(hostsClient.HostsCallCount()).To(Equal(1))
+
+			fakeClock.Increment(2 * time.Second)
+			_, err = client.Hosts("missing.apps.internal.")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hostsClient.HostsCallCount()).To(Equal(2))
+		})
+
+		Context("when the negative ttl is zero", func() {
+			BeforeEach(func() {
+				client = cache.NewCachingClient(hostsClient, 10*time.Second, 0, 30*time.Second, metricsSender, fakeClock, logger)
+			})
+
+			It("does not cache it", func() {
+				_, err := client.Hosts("missing.apps.internal.")
+				Expect(err).NotTo(HaveOccurred())
+				_, err = client.Hosts("missing.apps.internal.")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(hostsClient.HostsCallCount()).To(Equal(2))
+			})
+		})
+	})
+
+	Context("when the underlying client fails", func() {
+		BeforeEach(func() {
+			hostsClient.HostsReturnsOnCall(1, nil, errors.New("banana"))
+		})
+
+		Context("and there is an expired entry within the stale window", func() {
+			It("serves the stale entry", func() {
+				_, err := client.Hosts("app-id.apps.internal.")
+				Expect(err).NotTo(HaveOccurred())
+
+				fakeClock.Increment(35 * time.Second)
+				answer, err := client.Hosts("app-id.apps.internal.")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(answer).To(ConsistOf(hosts))
+
+				Expect(metricsSent()).To(Equal([]string{"DNSCacheMisses", "DNSCacheMisses", "DNSCacheStaleHits"}))
+				Expect(logger).To(gbytes.Say("serving-stale-entry.*banana.*app-id.apps.internal"))
+			})
+		})
+
+		Context("and the entry is older than the stale window", func() {
+			It("returns the error", func() {
+				_, err := client.Hosts("app-id.apps.internal.")
+				Expect(err).NotTo(HaveOccurred())
+
+				fakeClock.Increment(41 * time.Second)
+				_, err = client.Hosts("app-id.apps.internal.")
+				Expect(err).To(MatchError("banana"))
+			})
+		})
+
+		Context("and nothing was cached", func() {
+			BeforeEach(func() {
+				hostsClient.HostsReturnsOnCall(0, nil, errors.New("banana"))
+			})
+
+			It("returns the error", func() {
+				_, err := client.Hosts("app-id.apps.internal.")
+				Expect(err).To(MatchError("banana"))
+				Expect(metricsSent()).To(Equal([]string{"DNSCacheMisses"}))
+			})
+		})
+	})
+})
@@ -7,17 +7,25 @@ import (
 )
 
 type Config struct {
-	Address                           string `json:"address" validate:"nonzero"`
-	Port                              string `json:"port" validate:"nonzero"`
-	ServiceDiscoveryControllerAddress string `json:"service_discovery_controller_address" validate:"nonzero"`
-	ServiceDiscoveryControllerPort    string `json:"service_discovery_controller_port" validate:"nonzero"`
-	ClientCert                        string `json:"client_cert" validate:"nonzero"`
-	ClientKey                         string `json:"client_key" validate:"nonzero"`
-	CACert                            string `json:"ca_cert" validate:"nonzero"`
-	MetronPort                        int    `json:"metron_port" validate:"min=1"`
-	MetricsEmitSeconds                int    `json:"metrics_emit_seconds" validate:"min=1"`
-	LogLevelAddress                   string `json:"log_level_address" validate:"nonzero"`
-	LogLevelPort                      int    `json:"log_level_port" validate:"min=1"`
+	Address                           string   `json:"address" validate:"nonzero"`
+	Port                              string   `json:"port" validate:"nonzero"`
+	ServiceDiscoveryControllerAddress string   `json:"service_discovery_controller_address" validate:"nonzero"`
+	ServiceDiscoveryControllerPort    string   `json:"service_discovery_controller_port" validate:"nonzero"`
+	ClientCert                        string   `json:"client_cert" validate:"nonzero"`
+	ClientKey                         string   `json:"client_key" validate:"nonzero"`
+	CACert                            string   `json:"ca_cert" validate:"nonzero"`
+	MetronPort                        int      `json:"metron_port" validate:"min=1"`
+	MetricsEmitSeconds                int      `json:"metrics_emit_seconds" validate:"min=1"`
+	LogLevelAddress                   string   `json:"log_level_address" validate:"nonzero"`
+	LogLevelPort                      int      `json:"log_level_port" validate:"min=1"`
+	CacheTTLSeconds                   int      `json:"cache_ttl_seconds" validate:"min=0"`
+	CacheNegativeTTLSeconds           int      `json:"cache_negative_ttl_seconds" validate:"min=0"`
+	CacheMaxStaleSeconds              int      `json:"cache_max_stale_seconds" validate:"min=0"`
+	AnswerTTLSeconds                  int      `json:"answer_ttl_seconds" validate:"min=0"`
+	DNSServerAddress                  string   `json:"dns_server_address"`
+	DNSServerPort                     int      `json:"dns_server_port" validate:"min=0"`
+	InternalDomains                   []string `json:"internal_domains"`
+	Zone                              string   `json:"zone"`
 }
 
 func NewConfig(configJSON []byte) (*Config, error) {
@@ -32,5 +40,9 @@ func NewConfig(configJSON []byte) (*Config, error) {
 		return nil, fmt.Errorf("invalid config: %s", err)
 	}
 
+	if adapterConfig.DNSServerPort > 0 && len(adapterConfig.InternalDomains) == 0 {
+		return nil, fmt.Errorf("invalid config: InternalDomains: required when dns_server_port is set")
+	}
+
 	return adapterConfig, err
 }
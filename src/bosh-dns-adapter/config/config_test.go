@@ -24,8 +24,15 @@ var _ = Describe("Config", func() {
 				"metrics_emit_seconds": 6,
 				"metron_port": 8080,
 				"log_level_address": "log-level-address",
-				"log_level_port": 9090
-
+				"log_level_port": 9090,
+				"cache_ttl_seconds": 5,
+				"cache_negative_ttl_seconds": 2,
+				"cache_max_stale_seconds": 30,
+				"answer_ttl_seconds": 3,
+				"dns_server_address": "127.0.0.1",
+				"dns_server_port": 53,
+				"internal_domains": ["apps.internal."],
+				"zone": "z1"
 			}`)
 
 			parsedConfig, err := NewConfig(configJSON)
@@ -43,6 +50,14 @@ var _ = Describe("Config", func() {
 			Expect(parsedConfig.MetronPort).To(Equal(8080))
 			Expect(parsedConfig.LogLevelAddress).To(Equal("log-level-address"))
 			Expect(parsedConfig.LogLevelPort).To(Equal(9090))
+			Expect(parsedConfig.CacheTTLSeconds).To(Equal(5))
+			Expect(parsedConfig.CacheNegativeTTLSeconds).To(Equal(2))
+			Expect(parsedConfig.CacheMaxStaleSeconds).To(Equal(30))
+			Expect(parsedConfig.AnswerTTLSeconds).To(Equal(3))
+			Expect(parsedConfig.DNSServerAddress).To(Equal("127.0.0.1"))
+			Expect(parsedConfig.DNSServerPort).To(Equal(53))
+			Expect(parsedConfig.InternalDomains).To(Equal([]string{"apps.internal."}))
+			Expect(parsedConfig.Zone).To(Equal("z1"))
 		})
 	})
 
@@ -93,7 +108,24 @@ var _ = Describe("Config", func() {
 		Entry("invalid ca_cert", "ca_cert", "", "CACert: zero value"),
 		Entry("invalid log_level_address", "log_level_address", "", "LogLevelAddress: zero value"),
 		Entry("invalid log_level_port", "log_level_port", -2, "LogLevelPort: less than min"),
+		Entry("invalid cache_ttl_seconds", "cache_ttl_seconds", -1, "CacheTTLSeconds: less than min"),
+		Entry("invalid cache_negative_ttl_seconds", "cache_negative_ttl_seconds", -1, "CacheNegativeTTLSeconds: less than min"),
+		Entry("invalid cache_max_stale_seconds", "cache_max_stale_seconds", -1, "CacheMaxStaleSeconds: less than min"),
+		Entry("invalid answer_ttl_seconds", "answer_ttl_seconds", -1, "AnswerTTLSeconds: less than min"),
+		Entry("invalid dns_server_port", "dns_server_port", -1, "DNSServerPort: less than min"),
 	)
+
+	Context("when the dns server is enabled without internal domains", func() {
+		It("returns an error", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["dns_server_port"] = 53
+
+			cfgBytes, _ := json.Marshal(cfg)
+			_, err := NewConfig(cfgBytes)
+
+			Expect(err).To(MatchError("invalid config: InternalDomains: required when dns_server_port is set"))
+		})
+	})
 })
 
 func cloneMap(original map[string]interface{}) map[string]interface{} {
@@ -0,0 +1,419 @@
+package dnsserver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"bosh-dns-adapter/sdcclient"
+
+	"code.cloudfoundry.org/lager"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	maxUDPSize        = 512
+	maxTCPSize        = 65535
+	tcpIdleTimeout    = 10 * time.Second
+	typeOPT           = dnsmessage.Type(41)
+	requestFailMetric = "DNSRequestFailures"
+)
+
+//go:generate counterfeiter -o fakes/metrics_sender.go --fake-name MetricsSender . MetricsSender
+type MetricsSender interface {
+	IncrementCounter(name string)
+}
+
+type Server struct {
+	address       string
+	domains       []string
+	ttl           uint32
+	ipsClient     sdcclient.IPsClient
+	metricsSender MetricsSender
+	logger        lager.Logger
+}
+
+func NewServer(address string, domains []string, ttl uint32, ipsClient sdcclient.IPsClient, metricsSender MetricsSender, logger lager.Logger) *Server {
+	fqDomains := make([]string, len(domains))
+	for i, domain := range domains {
+		fqDomains[i] = fqdn(strings.ToLower(domain))
+	}
+
+	return &Server{
+		address:       address,
+		domains:       fqDomains,
+		ttl:           ttl,
+		ipsClient:     ipsClient,
+		metricsSender: metricsSender,
+		logger:        logger,
+	}
+}
+
+func (s *Server) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	udpConn, err := net.ListenPacket("udp", s.address)
+	if err != nil {
+		return fmt.Errorf("listen udp: %s", err)
+	}
+
+	tcpListener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		udpConn.Close()
+		return fmt.Errorf("listen tcp: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		s.serveUDP(udpConn)
+	}()
+	go func() {
+		defer wg.Done()
+		s.serveTCP(tcpListener)
+	}()
+
+	close(ready)
+	s.logger.Info("dns-server-started", lager.Data{"address": s.address})
+
+	<-signals
+	udpConn.Close()
+	tcpListener.Close()
+	wg.Wait()
+	s.logger.Info("dns-server-stopped")
+	return nil
+}
+
+func (s *Server) serveUDP(conn net.PacketConn) {
+	buf := make([]byte, maxTCPSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		query := make([]byte, n)
+		copy(query, buf[:n])
+
+		go func() {
+			response, err := s.Respond(query, true)
+			if err != nil {
+				s.logger.Debug("dropping-udp-request", lager.Data{"error": err.Error()})
+				return
+			}
+
+			_, err = conn.WriteTo(response, addr)
+			if err != nil {
+				s.logger.Error("write-udp-response", err)
+			}
+		}()
+	}
+}
+
+func (s *Server) serveTCP(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handleTCPConn(conn)
+	}
+}
+
+func (s *Server) handleTCPConn(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(tcpIdleTimeout))
+
+		var length uint16
+		err := binary.Read(conn, binary.BigEndian, &length)
+		if err != nil {
+			return
+		}
+
+		query := make([]byte, length)
+		_, err = io.ReadFull(conn, query)
+		if err != nil {
+			return
+		}
+
+		response, err := s.Respond(query, false)
+		if err != nil {
+			s.logger.Debug("dropping-tcp-request", lager.Data{"error": err.Error()})
+			return
+		}
+
+		framed := make([]byte, 2+len(response))
+		binary.BigEndian.PutUint16(framed, uint16(len(response)))
+		copy(framed[2:], response)
+
+		_, err = conn.Write(framed)
+		if err != nil {
+			s.logger.Error("write-tcp-response", err)
+			return
+		}
+	}
+}
+
+// Respond builds the wire-format answer to a single DNS query. UDP
+// responses larger than 512 bytes, or than the EDNS0 payload size the
+// client advertised, are sent back empty with the TC bit set.
+func (s *Server) Respond(query []byte, overUDP bool) ([]byte, error) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil {
+		return nil, fmt.Errorf("parse header: %s", err)
+	}
+
+	responseHeader := dnsmessage.Header{
+		ID:               header.ID,
+		Response:         true,
+		OpCode:           header.OpCode,
+		Authoritative:    true,
+		RecursionDesired: header.RecursionDesired,
+	}
+
+	question, err := parser.Question()
+	if err != nil {
+		responseHeader.RCode = dnsmessage.RCodeFormatError
+		return buildMessage(responseHeader, nil, nil, nil)
+	}
+
+	if header.OpCode != 0 {
+		responseHeader.RCode = dnsmessage.RCodeNotImplemented
+		return buildMessage(responseHeader, &question, nil, nil)
+	}
+
+	maxSize := maxTCPSize
+	if overUDP {
+		maxSize = advertisedUDPSize(&parser)
+	}
+
+	name := strings.ToLower(question.Name.String())
+	hostname := hostnameForQuestion(name, question.Type)
+	if !s.isInternal(hostname) {
+		responseHeader.Authoritative = false
+		responseHeader.RCode = dnsmessage.RCodeRefused
+		return buildMessage(responseHeader, &question, nil, nil)
+	}
+
+	ips, err := s.ipsClient.IPs(hostname)
+	if err != nil {
+		s.metricsSender.IncrementCounter(requestFailMetric)
+		s.logger.Error("could not connect to service discovery controller", err, lager.Data{
+			"service-name": hostname,
+		})
+		responseHeader.RCode = dnsmessage.RCodeServerFailure
+		return buildMessage(responseHeader, &question, nil, nil)
+	}
+
+	s.logger.Debug("success", lager.Data{
+		"ips":          strings.Join(ips, ","),
+		"service-name": hostname,
+		"type":         question.Type.String(),
+	})
+
+	if len(ips) == 0 {
+		responseHeader.RCode = dnsmessage.RCodeNameError
+		return buildMessage(responseHeader, &question, nil, nil)
+	}
+
+	answers, additionals := s.resourcesFor(question, hostname, ips)
+
+	response, err := buildMessage(responseHeader, &question, answers, additionals)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response) > maxSize {
+		responseHeader.Truncated = true
+		return buildMessage(responseHeader, &question, nil, nil)
+	}
+
+	return response, nil
+}
+
+func (s *Server) resourcesFor(question dnsmessage.Question, hostname string, ips []string) ([]resource, []resource) {
+	switch question.Type {
+	case dnsmessage.TypeA, dnsmessage.TypeAAAA:
+		return s.addressResources(question.Name, question.Type, ips), nil
+	case dnsmessage.TypeSRV:
+		target, err := dnsmessage.NewName(hostname)
+		if err != nil {
+			return nil, nil
+		}
+
+		srv := resource{
+			header: s.resourceHeader(question.Name),
+			body: &dnsmessage.SRVResource{
+				Target: target,
+			},
+		}
+
+		additionals := append(
+			s.addressResources(target, dnsmessage.TypeA, ips),
+			s.addressResources(target, dnsmessage.TypeAAAA, ips)...,
+		)
+		return []resource{srv}, additionals
+	}
+
+	return nil, nil
+}
+
+func (s *Server) addressResources(name dnsmessage.Name, rrType dnsmessage.Type, ips []string) []resource {
+	resources := []resource{}
+	for _, ip := range ips {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			continue
+		}
+
+		if ipv4 := parsed.To4(); ipv4 != nil {
+			if rrType != dnsmessage.TypeA {
+				continue
+			}
+			a := &dnsmessage.AResource{}
+			copy(a.A[:], ipv4)
+			resources = append(resources, resource{header: s.resourceHeader(name), body: a})
+		} else {
+			if rrType != dnsmessage.TypeAAAA {
+				continue
+			}
+			aaaa := &dnsmessage.AAAAResource{}
+			copy(aaaa.AAAA[:], parsed.To16())
+			resources = append(resources, resource{header: s.resourceHeader(name), body: aaaa})
+		}
+	}
+	return resources
+}
+
+func (s *Server) resourceHeader(name dnsmessage.Name) dnsmessage.ResourceHeader {
+	return dnsmessage.ResourceHeader{
+		Name:  name,
+		Class: dnsmessage.ClassINET,
+		TTL:   s.ttl,
+	}
+}
+
+func (s *Server) isInternal(hostname string) bool {
+	for _, domain := range s.domains {
+		if hostname == domain || strings.HasSuffix(hostname, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+type resource struct {
+	header dnsmessage.ResourceHeader
+	body   interface{}
+}
+
+func buildMessage(header dnsmessage.Header, question *dnsmessage.Question, answers, additionals []resource) ([]byte, error) {
+	builder := dnsmessage.NewBuilder(make([]byte, 0, maxUDPSize), header)
+	builder.EnableCompression()
+
+	err := builder.StartQuestions()
+	if err != nil {
+		return nil, err
+	}
+
+	if question != nil {
+		err = builder.Question(*question)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = builder.StartAnswers()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, answer := range answers {
+		err = addResource(&builder, answer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = builder.StartAdditionals()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, additional := range additionals {
+		err = addResource(&builder, additional)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return builder.Finish()
+}
+
+func addResource(builder *dnsmessage.Builder, r resource) error {
+	switch body := r.body.(type) {
+	case *dnsmessage.AResource:
+		return builder.AResource(r.header, *body)
+	case *dnsmessage.AAAAResource:
+		return builder.AAAAResource(r.header, *body)
+	case *dnsmessage.SRVResource:
+		return builder.SRVResource(r.header, *body)
+	}
+	return fmt.Errorf("unsupported resource %T", r.body)
+}
+
+// advertisedUDPSize returns the payload size from an EDNS0 OPT record in
+// the additional section, or the classic 512 byte limit without one.
+func advertisedUDPSize(parser *dnsmessage.Parser) int {
+	if parser.SkipAllQuestions() != nil || parser.SkipAllAnswers() != nil || parser.SkipAllAuthorities() != nil {
+		return maxUDPSize
+	}
+
+	for {
+		header, err := parser.AdditionalHeader()
+		if err != nil {
+			return maxUDPSize
+		}
+
+		if header.Type == typeOPT {
+			size := int(header.Class)
+			if size < maxUDPSize {
+				return maxUDPSize
+			}
+			return size
+		}
+
+		if parser.SkipAdditional() != nil {
+			return maxUDPSize
+		}
+	}
+}
+
+// hostnameForQuestion strips the _service._proto labels from SRV
+// questions so that they resolve against the same hostname as A queries.
+func hostnameForQuestion(name string, rrType dnsmessage.Type) string {
+	if rrType != dnsmessage.TypeSRV {
+		return name
+	}
+
+	labels := strings.SplitN(name, ".", 3)
+	if len(labels) == 3 && strings.HasPrefix(labels[0], "_") && strings.HasPrefix(labels[1], "_") {
+		return labels[2]
+	}
+	return name
+}
+
+func fqdn(s string) string {
+	if strings.HasSuffix(s, ".") {
+		return s
+	}
+	return s + "."
+}
@@ -0,0 +1,235 @@
+package dnsserver_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"bosh-dns-adapter/dnsserver"
+	"bosh-dns-adapter/dnsserver/fakes"
+	sdcfakes "bosh-dns-adapter/sdcclient/fakes"
+
+	"code.cloudfoundry.org/cf-networking-helpers/testsupport/ports"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		server        *dnsserver.Server
+		ipsClient     *sdcfakes.IPsClient
+		metricsSender *fakes.MetricsSender
+		logger        *lagertest.TestLogger
+		address       string
+	)
+
+	BeforeEach(func() {
+		ipsClient = &sdcfakes.IPsClient{}
+		ipsClient.IPsReturns([]string{"192.168.0.1", "fd00::1"}, nil)
+		metricsSender = &fakes.MetricsSender{}
+		logger = lagertest.NewTestLogger("test")
+		address = fmt.Sprintf("127.0.0.1:%d", ports.PickAPort())
+
+		server = dnsserver.NewServer(address, []string{"apps.internal"}, 5, ipsClient, metricsSender, logger)
+	})
+
+	Describe("Respond", func() {
+		It("answers A questions with the ipv4 addresses", func() {
+			response := respond(server, buildQuery("app-id.apps.internal.", dnsmessage.TypeA), true)
+
+			Expect(response.Header.RCode).To(Equal(dnsmessage.RCodeSuccess))
+			Expect(response.Header.Authoritative).To(BeTrue())
+			Expect(response.Questions).To(HaveLen(1))
+			Expect(response.Answers).To(HaveLen(1))
+			Expect(response.Answers[0].Header.Name.String()).To(Equal("app-id.apps.internal."))
+			Expect(response.Answers[0].Header.TTL).To(Equal(uint32(5)))
+			Expect(response.Answers[0].Body).To(Equal(&dnsmessage.AResource{A: [4]byte{192, 168, 0, 1}}))
+
+			Expect(ipsClient.IPsArgsForCall(0)).To(Equal("app-id.apps.internal."))
+		})
+
+		It("answers AAAA questions with the ipv6 addresses", func() {
+			response := respond(server, buildQuery("app-id.apps.internal.", dnsmessage.TypeAAAA), true)
+
+			Expect(response.Header.RCode).To(Equal(dnsmessage.RCodeSuccess))
+			Expect(response.Answers).To(HaveLen(1))
+			Expect(response.Answers[0].Body).To(Equal(&dnsmessage.AAAAResource{
+				AAAA: [16]byte{0xfd, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+			}))
+		})
+
+		It("answers SRV questions with the hostname as target and the addresses as additionals", func() {
+			response := respond(server, buildQuery("_http._tcp.app-id.apps.internal.", dnsmessage.TypeSRV), true)
+
+			Expect(response.Header.RCode).To(Equal(dnsmessage.RCodeSuccess))
+			Expect(response.Answers).To(HaveLen(1))
+			Expect(response.Answers[0].Header.Name.String()).To(Equal("_http._tcp.app-id.apps.internal."))
+			srv, ok := response.Answers[0].Body.(*dnsmessage.SRVResource)
+			Expect(ok).To(BeTrue())
+			Expect(srv.Target.String()).To(Equal("app-id.apps.internal."))
+			Expect(response.Additionals).To(HaveLen(2))
+
+			Expect(ipsClient.IPsArgsForCall(0)).To(Equal("app-id.apps.internal."))
+		})
+
+		It("matches internal domains case-insensitively", func() {
+			response := respond(server, buildQuery("App-Id.Apps.Internal.", dnsmessage.TypeA), true)
+
+			Expect(response.Header.RCode).To(Equal(dnsmessage.RCodeSuccess))
+			Expect(ipsClient.IPsArgsForCall(0)).To(Equal("app-id.apps.internal."))
+		})
+
+		Context("when the name has no addresses", func() {
+			BeforeEach(func() {
+				ipsClient.IPsReturns([]string{}, nil)
+			})
+
+			It("returns NXDOMAIN", func() {
+				response := respond(server, buildQuery("unknown.apps.internal.", dnsmessage.TypeA), true)
+
+				Expect(response.Header.RCode).To(Equal(dnsmessage.RCodeNameError))
+				Expect(response.Answers).To(BeEmpty())
+			})
+		})
+
+		Context("when the name is outside the internal domains", func() {
+			It("refuses the question without querying the service discovery controller", func() {
+				response := respond(server, buildQuery("example.com.", dnsmessage.TypeA), true)
+
+				Expect(response.Header.RCode).To(Equal(dnsmessage.RCodeRefused))
+				Expect(ipsClient.IPsCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the service discovery controller fails", func() {
+			BeforeEach(func() {
+				ipsClient.IPsReturns(nil, errors.New("banana"))
+			})
+
+			It("returns SERVFAIL and increments the failure metric", func() {
+				response := respond(server, buildQuery("app-id.apps.internal.", dnsmessage.TypeA), true)
+
+				Expect(response.Header.RCode).To(Equal(dnsmessage.RCodeServerFailure))
+				Expect(metricsSender.IncrementCounterCallCount()).To(Equal(1))
+				Expect(metricsSender.IncrementCounterArgsForCall(0)).To(Equal("DNSRequestFailures"))
+			})
+		})
+
+		Context("when the answer does not fit in a udp response", func() {
+			BeforeEach(func() {
+				ips := []string{}
+				for i := 0; i < 50; i++ {
+					ips = append(ips, fmt.Sprintf("10.0.0.%d", i))
+				}
+				ipsClient.IPsReturns(ips, nil)
+			})
+
+			It("sets the truncated bit and drops the answers", func() {
+				response := respond(server, buildQuery("app-id.apps.internal.", dnsmessage.TypeA), true)
+
+				Expect(response.Header.Truncated).To(BeTrue())
+				Expect(response.Answers).To(BeEmpty())
+			})
+
+			It("answers in full over tcp", func() {
+				response := respond(server, buildQuery("app-id.apps.internal.", dnsmessage.TypeA), false)
+
+				Expect(response.Header.Truncated).To(BeFalse())
+				Expect(response.Answers).To(HaveLen(50))
+			})
+		})
+
+		Context("when the query is malformed", func() {
+			It("returns an error when the header cannot be parsed", func() {
+				_, err := server.Respond([]byte{1, 2, 3}, true)
+				Expect(err).To(MatchError(ContainSubstring("parse header")))
+			})
+		})
+	})
+
+	Describe("Run", func() {
+		var process ifrit.Process
+
+		BeforeEach(func() {
+			process = ifrit.Invoke(server)
+		})
+
+		AfterEach(func() {
+			process.Signal(nil)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+		})
+
+		It("serves queries over udp", func() {
+			conn, err := net.Dial("udp", address)
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+
+			_, err = conn.Write(buildQuery("app-id.apps.internal.", dnsmessage.TypeA))
+			Expect(err).NotTo(HaveOccurred())
+
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			buf := make([]byte, 512)
+			n, err := conn.Read(buf)
+			Expect(err).NotTo(HaveOccurred())
+
+			var response dnsmessage.Message
+			Expect(response.Unpack(buf[:n])).To(Succeed())
+			Expect(response.Answers).To(HaveLen(1))
+		})
+
+		It("serves queries over tcp", func() {
+			conn, err := net.Dial("tcp", address)
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+
+			query := buildQuery("app-id.apps.internal.", dnsmessage.TypeA)
+			framed := make([]byte, 2+len(query))
+			binary.BigEndian.PutUint16(framed, uint16(len(query)))
+			copy(framed[2:], query)
+			_, err = conn.Write(framed)
+			Expect(err).NotTo(HaveOccurred())
+
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			var length uint16
+			Expect(binary.Read(conn, binary.BigEndian, &length)).To(Succeed())
+			buf := make([]byte, length)
+			_, err = io.ReadFull(conn, buf)
+			Expect(err).NotTo(HaveOccurred())
+
+			var response dnsmessage.Message
+			Expect(response.Unpack(buf)).To(Succeed())
+			Expect(response.Answers).To(HaveLen(1))
+		})
+	})
+})
+
+func buildQuery(name string, rrType dnsmessage.Type) []byte {
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: 42, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName(name),
+			Type:  rrType,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	packed, err := query.Pack()
+	Expect(err).NotTo(HaveOccurred())
+	return packed
+}
+
+func respond(server *dnsserver.Server, query []byte, overUDP bool) dnsmessage.Message {
+	packed, err := server.Respond(query, overUDP)
+	Expect(err).NotTo(HaveOccurred())
+
+	var response dnsmessage.Message
+	Expect(response.Unpack(packed)).To(Succeed())
+	Expect(response.Header.ID).To(Equal(uint16(42)))
+	Expect(response.Header.Response).To(BeTrue())
+	return response
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"bosh-dns-adapter/cache"
 	"bosh-dns-adapter/config"
+	"bosh-dns-adapter/dnsserver"
 	"bosh-dns-adapter/sdcclient"
+	"bosh-dns-adapter/topology"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -20,6 +23,7 @@ import (
 	"code.cloudfoundry.org/cf-networking-helpers/lagerlevel"
 	"code.cloudfoundry.org/cf-networking-helpers/metrics"
 	"code.cloudfoundry.org/cf-networking-helpers/middleware"
+	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/lager"
 	"github.com/cloudfoundry/dropsonde"
 	"github.com/tedsuo/ifrit"
@@ -84,6 +88,26 @@ func main() {
 		Logger: logger.Session("bosh-dns-adapter"),
 	}
 
+	var hostsClient sdcclient.HostsClient = sdcClient
+	if config.CacheTTLSeconds > 0 || config.CacheNegativeTTLSeconds > 0 {
+		hostsClient = cache.NewCachingClient(
+			sdcClient,
+			time.Duration(config.CacheTTLSeconds)*time.Second,
+			time.Duration(config.CacheNegativeTTLSeconds)*time.Second,
+			time.Duration(config.CacheMaxStaleSeconds)*time.Second,
+			&metricSender,
+			clock.NewClock(),
+			logger.Session("cache"),
+		)
+	}
+
+	if config.Zone != "" {
+		hostsClient = topology.NewZoneOrderingClient(hostsClient, config.Zone)
+	}
+	ipsClient := sdcclient.HostIPs{Client: hostsClient}
+
+	answerTTL := uint32(config.AnswerTTLSeconds)
+
 	metricsWrap := func(name string, handler http.Handler) http.Handler {
 		metricsWrapper := middleware.MetricWrapper{
 			Name:          name,
@@ -98,7 +122,7 @@ func main() {
 			name := getQueryParam(req, "name", "")
 
 			if dnsType != "1" {
-				writeResponse(resp, dnsmessage.RCodeSuccess, name, dnsType, nil, logger)
+				writeResponse(resp, dnsmessage.RCodeSuccess, name, dnsType, nil, answerTTL, logger)
 				requestLogger.Debug("unsupported record type", lager.Data{
 					"ips":          "",
 					"service-name": name,
@@ -108,7 +132,7 @@ func main() {
 
 			if name == "" {
 				resp.WriteHeader(http.StatusBadRequest)
-				writeResponse(resp, dnsmessage.RCodeServerFailure, name, dnsType, nil, logger)
+				writeResponse(resp, dnsmessage.RCodeServerFailure, name, dnsType, nil, answerTTL, logger)
 				requestLogger.Debug("name parameter empty", lager.Data{
 					"ips":          "",
 					"service-name": "",
@@ -116,7 +140,7 @@ func main() {
 				return
 			}
 
-			ips, err := sdcClient.IPs(name)
+			ips, err := ipsClient.IPs(name)
 			if err != nil {
 				wrappedErr := errors.New(fmt.Sprintf("Error querying Service Discover Controller: %s", err))
 				writeErrorResponse(resp, wrappedErr, logger)
@@ -131,7 +155,7 @@ func main() {
 				return
 			}
 
-			writeResponse(resp, dnsmessage.RCodeSuccess, name, dnsType, ips, logger)
+			writeResponse(resp, dnsmessage.RCodeSuccess, name, dnsType, ips, answerTTL, logger)
 			requestLogger.Debug("success", lager.Data{
 				"ips":          strings.Join(ips, ","),
 				"service-name": name,
@@ -150,6 +174,18 @@ func main() {
 		{"metrics-emitter", metricsEmitter},
 		{"log-level-server", lagerlevel.NewServer(config.LogLevelAddress, config.LogLevelPort, sink, logger.Session("log-level-server"))},
 	}
+
+	if config.DNSServerPort > 0 {
+		dnsServer := dnsserver.NewServer(
+			fmt.Sprintf("%s:%d", config.DNSServerAddress, config.DNSServerPort),
+			config.InternalDomains,
+			answerTTL,
+			ipsClient,
+			&metricSender,
+			logger.Session("dns-server"),
+		)
+		members = append(members, grouper.Member{Name: "dns-server", Runner: dnsServer})
+	}
 	group := grouper.NewOrdered(os.Interrupt, members)
 	monitor := ifrit.Invoke(sigmon.New(group))
 
@@ -187,8 +223,8 @@ func writeErrorResponse(resp http.ResponseWriter, err error, logger lager.Logger
 	}
 }
 
-func writeResponse(resp http.ResponseWriter, dnsResponseStatus dnsmessage.RCode, requestedInfraName string, dnsType string, ips []string, logger lager.Logger) {
-	responseBody, err := buildResponseBody(dnsResponseStatus, requestedInfraName, dnsType, ips)
+func writeResponse(resp http.ResponseWriter, dnsResponseStatus dnsmessage.RCode, requestedInfraName string, dnsType string, ips []string, ttl uint32, logger lager.Logger) {
+	responseBody, err := buildResponseBody(dnsResponseStatus, requestedInfraName, dnsType, ips, ttl)
 	if err != nil {
 		logger.Error("Error building response", err)
 		return
@@ -209,14 +245,14 @@ type Answer struct {
 	Data   string `json:"data"`
 }
 
-func buildResponseBody(dnsResponseStatus dnsmessage.RCode, requestedInfraName string, dnsType string, ips []string) (string, error) {
+func buildResponseBody(dnsResponseStatus dnsmessage.RCode, requestedInfraName string, dnsType string, ips []string, ttl uint32) (string, error) {
 	answers := make([]Answer, len(ips), len(ips))
 	for i, ip := range ips {
 		answers[i] = Answer{
 			Name:   requestedInfraName,
 			RRType: uint16(dnsmessage.TypeA),
 			Data:   ip,
-			TTL:    0,
+			TTL:    ttl,
 		}
 	}
 
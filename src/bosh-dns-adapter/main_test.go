@@ -2,9 +2,11 @@ package main_test
 
 import (
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
@@ -20,6 +22,7 @@ import (
 	"github.com/onsi/gomega/gexec"
 	"github.com/onsi/gomega/ghttp"
 	"github.com/onsi/gomega/types"
+	"golang.org/x/net/dns/dnsmessage"
 )
 
 var _ = Describe("Main", func() {
@@ -33,6 +36,10 @@ var _ = Describe("Main", func() {
 		dnsAdapterPort                         string
 		fakeMetron                             metrics.FakeMetron
 		logLevelPort                           int
+		cacheTTLSeconds                        int
+		answerTTLSeconds                       int
+		dnsServerPort                          int
+		zone                                   string
 	)
 
 	BeforeEach(func() {
@@ -59,6 +66,10 @@ var _ = Describe("Main", func() {
 
 		dnsAdapterPort = fmt.Sprintf("%d", ports.PickAPort())
 		logLevelPort = ports.PickAPort()
+		cacheTTLSeconds = 0
+		answerTTLSeconds = 0
+		dnsServerPort = 0
+		zone = ""
 	})
 
 	JustBeforeEach(func() {
@@ -90,7 +101,14 @@ var _ = Describe("Main", func() {
 			"metron_port": %d,
 			"metrics_emit_seconds": 2,
 			"log_level_port": %d,
-			"log_level_address": "127.0.0.1"
+			"log_level_address": "127.0.0.1",
+			"cache_ttl_seconds": %d,
+			"cache_max_stale_seconds": %d,
+			"answer_ttl_seconds": %d,
+			"dns_server_address": "127.0.0.1",
+			"dns_server_port": %d,
+			"internal_domains": ["internal.local."],
+			"zone": "%s"
 		}`, dnsAdapterAddress,
 			dnsAdapterPort,
 			strings.TrimPrefix(urlParts[1], "//"),
@@ -100,6 +118,11 @@ var _ = Describe("Main", func() {
 			caFileName,
 			fakeMetron.Port(),
 			logLevelPort,
+			cacheTTLSeconds,
+			cacheTTLSeconds,
+			answerTTLSeconds,
+			dnsServerPort,
+			zone,
 		)
 
 		tempConfigFile, err = ioutil.TempFile(os.TempDir(), "sd")
@@ -219,6 +242,134 @@ var _ = Describe("Main", func() {
 		})
 	})
 
+	Context("when response caching is enabled", func() {
+		BeforeEach(func() {
+			cacheTTLSeconds = 60
+			answerTTLSeconds = 5
+		})
+
+		It("answers from the cache with the configured ttl while the service discovery controller is unavailable", func() {
+			Eventually(session).Should(gbytes.Say("bosh-dns-adapter.server-started"))
+
+			url := fmt.Sprintf("http://127.0.0.1:%s?type=1&name=app-id.internal.local.", dnsAdapterPort)
+			makeDNSRequest(url, http.StatusOK)
+
+			fakeServiceDiscoveryControllerServer.Close()
+
+			resp, err := http.Get(url)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			all, err := ioutil.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(all)).To(MatchJSON(`{
+					"Status": 0,
+					"TC": false,
+					"RD": false,
+					"RA": false,
+					"AD": false,
+					"CD": false,
+					"Question":
+					[
+						{
+							"name": "app-id.internal.local.",
+							"type": 1
+						}
+					],
+					"Answer":
+					[
+						{
+							"name": "app-id.internal.local.",
+							"type": 1,
+							"TTL":  5,
+							"data": "192.168.0.1"
+						}
+					],
+					"Additional": [ ],
+					"edns_client_subnet": "0.0.0.0/0"
+				}
+			`))
+
+			Eventually(fakeMetron.AllEvents, "5s").Should(ContainElement(SatisfyAll(
+				metricWithName("DNSCacheHits"),
+				metricWithOrigin("bosh-dns-adapter"),
+			)))
+		})
+	})
+
+	Context("when a zone is configured", func() {
+		BeforeEach(func() {
+			zone = "z1"
+			fakeServiceDiscoveryControllerResponse = []http.HandlerFunc{ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/v1/registration/app-id.internal.local."),
+				ghttp.RespondWith(200, `{
+					"hosts": [
+					{ "ip_address": "192.168.0.1", "tags": {"zone": "z2"} },
+					{ "ip_address": "192.168.0.2", "tags": {"zone": "z1"} },
+					{ "ip_address": "192.168.0.3", "tags": {} }
+					]
+				}`),
+			)}
+		})
+
+		It("answers with the same-zone instances first", func() {
+			Eventually(session).Should(gbytes.Say("bosh-dns-adapter.server-started"))
+
+			url := fmt.Sprintf("http://127.0.0.1:%s?type=1&name=app-id.internal.local.", dnsAdapterPort)
+			resp, err := http.Get(url)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			var dnsResponse struct {
+				Answer []struct {
+					Data string `json:"data"`
+				}
+			}
+			Expect(json.NewDecoder(resp.Body).Decode(&dnsResponse)).To(Succeed())
+			Expect(dnsResponse.Answer).To(HaveLen(3))
+			Expect(dnsResponse.Answer[0].Data).To(Equal("192.168.0.2"))
+		})
+	})
+
+	Context("when the native dns server is enabled", func() {
+		BeforeEach(func() {
+			dnsServerPort = ports.PickAPort()
+		})
+
+		It("answers A queries over udp", func() {
+			Eventually(session).Should(gbytes.Say("bosh-dns-adapter.server-started"))
+
+			query := dnsmessage.Message{
+				Header: dnsmessage.Header{ID: 1},
+				Questions: []dnsmessage.Question{{
+					Name:  dnsmessage.MustNewName("app-id.internal.local."),
+					Type:  dnsmessage.TypeA,
+					Class: dnsmessage.ClassINET,
+				}},
+			}
+			packed, err := query.Pack()
+			Expect(err).NotTo(HaveOccurred())
+
+			conn, err := net.Dial("udp", fmt.Sprintf("127.0.0.1:%d", dnsServerPort))
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+
+			_, err = conn.Write(packed)
+			Expect(err).NotTo(HaveOccurred())
+
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			buf := make([]byte, 512)
+			n, err := conn.Read(buf)
+			Expect(err).NotTo(HaveOccurred())
+
+			var response dnsmessage.Message
+			Expect(response.Unpack(buf[:n])).To(Succeed())
+			Expect(response.Header.RCode).To(Equal(dnsmessage.RCodeSuccess))
+			Expect(response.Answers).To(HaveLen(1))
+			Expect(response.Answers[0].Body).To(Equal(&dnsmessage.AResource{A: [4]byte{192, 168, 0, 1}}))
+		})
+	})
+
 	Context("when a process is already listening on the port", func() {
 		var session2 *gexec.Session
 		JustBeforeEach(func() {
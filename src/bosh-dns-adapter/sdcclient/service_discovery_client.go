@@ -14,6 +14,41 @@ import (
 	"time"
 )
 
+//go:generate counterfeiter -o fakes/ips_client.go --fake-name IPsClient . IPsClient
+type IPsClient interface {
+	IPs(infrastructureName string) ([]string, error)
+}
+
+//go:generate counterfeiter -o fakes/hosts_client.go --fake-name HostsClient . HostsClient
+type HostsClient interface {
+	Hosts(infrastructureName string) ([]Host, error)
+}
+
+// Host is an instance registered for a name, with the availability zone the
+// service discovery controller reported for it, if any.
+type Host struct {
+	IP   string
+	Zone string
+}
+
+// HostIPs answers with the IPs of the hosts that Client returns, in order.
+type HostIPs struct {
+	Client HostsClient
+}
+
+func (h HostIPs) IPs(infrastructureName string) ([]string, error) {
+	hosts, err := h.Client.Hosts(infrastructureName)
+	if err != nil {
+		return []string{}, err
+	}
+
+	ips := make([]string, len(hosts))
+	for i, host := range hosts {
+		ips[i] = host.IP
+	}
+	return ips, nil
+}
+
 type ServiceDiscoveryClient struct {
 	serverURL string
 	client    *http.Client
@@ -24,7 +59,8 @@ type serverResponse struct {
 }
 
 type host struct {
-	IPAddress string `json:"ip_address"`
+	IPAddress string                 `json:"ip_address"`
+	Tags      map[string]interface{} `json:"tags"`
 }
 
 func NewServiceDiscoveryClient(serverURL, caPath, clientCertPath, clientKeyPath string) (*ServiceDiscoveryClient, error) {
@@ -75,6 +111,11 @@ func NewServiceDiscoveryClient(serverURL, caPath, clientCertPath, clientKeyPath
 }
 
 func (s *ServiceDiscoveryClient) IPs(infrastructureName string) ([]string, error) {
+	return HostIPs{Client: s}.IPs(infrastructureName)
+}
+
+// Hosts returns the instances registered for the name, in random order.
+func (s *ServiceDiscoveryClient) Hosts(infrastructureName string) ([]Host, error) {
 	requestUrl := fmt.Sprintf("%s/v1/registration/%s", s.serverURL, infrastructureName)
 
 	var (
@@ -85,7 +126,7 @@ func (s *ServiceDiscoveryClient) IPs(infrastructureName string) ([]string, error
 	for i := 0; i < 4; i++ {
 		httpResp, err = s.client.Get(requestUrl)
 		if err != nil {
-			return []string{}, err
+			return []Host{}, err
 		}
 
 		if httpResp.StatusCode == http.StatusOK {
@@ -99,33 +140,34 @@ func (s *ServiceDiscoveryClient) IPs(infrastructureName string) ([]string, error
 	}
 
 	if httpResp.StatusCode != http.StatusOK {
-		return []string{}, errors.New(fmt.Sprintf("Received non successful response from server: %+v", httpResp))
+		return []Host{}, errors.New(fmt.Sprintf("Received non successful response from server: %+v", httpResp))
 	}
 
 	bytes, err := ioutil.ReadAll(httpResp.Body)
 	httpResp.Body.Close()
 	if err != nil {
-		return []string{}, err
+		return []Host{}, err
 	}
 
 	var serverResponse *serverResponse
 	err = json.Unmarshal(bytes, &serverResponse)
 	if err != nil {
-		return []string{}, err
+		return []Host{}, err
 	}
 
 	numHosts := len(serverResponse.Hosts)
-	ips := make([]string, numHosts, numHosts)
+	hosts := make([]Host, numHosts, numHosts)
 	for i, host := range serverResponse.Hosts {
-		ips[i] = host.IPAddress
+		zone, _ := host.Tags["zone"].(string)
+		hosts[i] = Host{IP: host.IPAddress, Zone: zone}
 	}
 
-	shuffle(ips)
+	shuffle(hosts)
 
-	return ips, nil
+	return hosts, nil
 }
 
-func shuffle(vals []string) {
+func shuffle(vals []Host) {
 	r := rand.New(rand.NewSource(time.Now().UTC().UnixNano()))
 	for len(vals) > 0 {
 		n := len(vals)
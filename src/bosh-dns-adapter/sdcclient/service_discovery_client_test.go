@@ -134,6 +134,34 @@ var _ = Describe("ServiceDiscoveryClient", func() {
 
 		})
 
+		Context("when the server responds with zone tags", func() {
+			BeforeEach(func() {
+				fakeServer.AppendHandlers(ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/v1/registration/app-id.apps.internal.", ""),
+					ghttp.RespondWith(http.StatusOK, `{
+							"Hosts": [
+							{
+								"ip_address": "192.168.0.1",
+								"tags": {"zone": "z1", "cell_id": "cell-1"}
+							},
+							{
+								"ip_address": "192.168.0.2",
+								"tags": {}
+							}]
+						}`)))
+			})
+
+			It("returns the zone of each host", func() {
+				hosts, err := client.Hosts("app-id.apps.internal.")
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(hosts).To(ConsistOf(
+					Host{IP: "192.168.0.1", Zone: "z1"},
+					Host{IP: "192.168.0.2"},
+				))
+			})
+		})
+
 		Context("returned ips order", func() {
 			BeforeEach(func() {
 				fakeServer.RouteToHandler("GET", "/v1/registration/app-id.apps.internal.", func(writer http.ResponseWriter, request *http.Request) {
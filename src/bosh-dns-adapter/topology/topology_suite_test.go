@@ -0,0 +1,13 @@
+package topology_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTopology(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Topology Suite")
+}
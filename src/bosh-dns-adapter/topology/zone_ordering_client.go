@@ -0,0 +1,39 @@
+package topology
+
+import "bosh-dns-adapter/sdcclient"
+
+// ZoneOrderingClient moves instances in the local availability zone to the
+// front of every answer so that clients which pick the first address stay
+// in-zone. Instances in other zones, or without a known zone, follow in
+// their original order so that they are still used when the local zone has
+// none.
+type ZoneOrderingClient struct {
+	client sdcclient.HostsClient
+	zone   string
+}
+
+func NewZoneOrderingClient(client sdcclient.HostsClient, zone string) *ZoneOrderingClient {
+	return &ZoneOrderingClient{
+		client: client,
+		zone:   zone,
+	}
+}
+
+func (c *ZoneOrderingClient) Hosts(infrastructureName string) ([]sdcclient.Host, error) {
+	hosts, err := c.client.Hosts(infrastructureName)
+	if err != nil {
+		return hosts, err
+	}
+
+	ordered := make([]sdcclient.Host, 0, len(hosts))
+	others := []sdcclient.Host{}
+	for _, host := range hosts {
+		if host.Zone == c.zone {
+			ordered = append(ordered, host)
+		} else {
+			others = append(others, host)
+		}
+	}
+
+	return append(ordered, others...), nil
+}
@@ -0,0 +1,72 @@
+package topology_test
+
+import (
+	"errors"
+
+	"bosh-dns-adapter/sdcclient"
+	sdcfakes "bosh-dns-adapter/sdcclient/fakes"
+	"bosh-dns-adapter/topology"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ZoneOrderingClient", func() {
+	var (
+		hostsClient *sdcfakes.HostsClient
+		client      *topology.ZoneOrderingClient
+	)
+
+	BeforeEach(func() {
+		hostsClient = &sdcfakes.HostsClient{}
+		hostsClient.HostsReturns([]sdcclient.Host{
+			{IP: "10.0.1.1", Zone: "z2"},
+			{IP: "10.0.0.1", Zone: "z1"},
+			{IP: "10.0.2.1"},
+			{IP: "10.0.0.2", Zone: "z1"},
+		}, nil)
+
+		client = topology.NewZoneOrderingClient(hostsClient, "z1")
+	})
+
+	It("returns same-zone instances first followed by everything else", func() {
+		hosts, err := client.Hosts("app-id.apps.internal.")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hosts).To(Equal([]sdcclient.Host{
+			{IP: "10.0.0.1", Zone: "z1"},
+			{IP: "10.0.0.2", Zone: "z1"},
+			{IP: "10.0.1.1", Zone: "z2"},
+			{IP: "10.0.2.1"},
+		}))
+
+		Expect(hostsClient.HostsArgsForCall(0)).To(Equal("app-id.apps.internal."))
+	})
+
+	Context("when no instance is in the local zone", func() {
+		BeforeEach(func() {
+			client = topology.NewZoneOrderingClient(hostsClient, "z3")
+		})
+
+		It("falls back to all instances in their original order", func() {
+			hosts, err := client.Hosts("app-id.apps.internal.")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hosts).To(Equal([]sdcclient.Host{
+				{IP: "10.0.1.1", Zone: "z2"},
+				{IP: "10.0.0.1", Zone: "z1"},
+				{IP: "10.0.2.1"},
+				{IP: "10.0.0.2", Zone: "z1"},
+			}))
+		})
+	})
+
+	Context("when the underlying client fails", func() {
+		BeforeEach(func() {
+			hostsClient.HostsReturns(nil, errors.New("banana"))
+		})
+
+		It("returns the error", func() {
+			_, err := client.Hosts("app-id.apps.internal.")
+			Expect(err).To(MatchError("banana"))
+		})
+	})
+})
@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"policy-server/api"
+	"sort"
+	"strings"
+)
+
+//go:generate counterfeiter -o ../fakes/policy_client.go --fake-name PolicyClient . policyClient
+type policyClient interface {
+	GetPolicies(token string) ([]api.Policy, error)
+	GetPoliciesByID(token string, ids ...string) ([]api.Policy, error)
+	AddPolicies(token string, policies []api.Policy) error
+	DeletePolicies(token string, policies []api.Policy) error
+	CleanupPolicies(token string) ([]api.Policy, error)
+	GetTags(token string) ([]api.Tag, error)
+}
+
+//go:generate counterfeiter -o ../fakes/chunker.go --fake-name Chunker . chunker
+type chunker interface {
+	ChunkV1(allPolicies []api.Policy) [][]api.Policy
+}
+
+//go:generate counterfeiter -o ../fakes/token_fetcher.go --fake-name TokenFetcher . tokenFetcher
+type tokenFetcher interface {
+	GetToken() (string, error)
+}
+
+// StaticToken is a token given on the command line, such as the output of
+// `cf oauth-token`, with or without its "bearer" prefix.
+type StaticToken string
+
+func (t StaticToken) GetToken() (string, error) {
+	token := strings.TrimSpace(string(t))
+	if token == "" {
+		return "", fmt.Errorf("token is empty")
+	}
+	return token, nil
+}
+
+// CLI runs the cfnet commands. Data is written to Output in Format, and
+// progress messages to Log, so that the output of list can be fed back to
+// add, delete and diff.
+type CLI struct {
+	Client  policyClient
+	Chunker chunker
+	Tokens  tokenFetcher
+	Input   io.Reader
+	Output  io.Writer
+	Log     io.Writer
+	Format  string
+}
+
+func (c *CLI) token() (string, error) {
+	token, err := c.Tokens.GetToken()
+	if err != nil {
+		return "", fmt.Errorf("get token: %s", err)
+	}
+	token = strings.TrimPrefix(token, "bearer ")
+	token = strings.TrimPrefix(token, "Bearer ")
+	return "Bearer " + token, nil
+}
+
+func (c *CLI) List(ids []string) error {
+	token, err := c.token()
+	if err != nil {
+		return err
+	}
+
+	var policies []api.Policy
+	if len(ids) > 0 {
+		policies, err = c.Client.GetPoliciesByID(token, ids...)
+	} else {
+		policies, err = c.Client.GetPolicies(token)
+	}
+	if err != nil {
+		return fmt.Errorf("get policies: %s", err)
+	}
+	return c.writePolicies(policies)
+}
+
+func (c *CLI) Add(path string) error {
+	return c.apply(path, "add", c.Client.AddPolicies)
+}
+
+func (c *CLI) Delete(path string) error {
+	return c.apply(path, "delete", c.Client.DeletePolicies)
+}
+
+// apply sends the policies in the file at path to the policy server in
+// chunks, so that files larger than the server's request limit can be used.
+func (c *CLI) apply(path, action string, send func(string, []api.Policy) error) error {
+	policies, err := c.readPolicies(path)
+	if err != nil {
+		return err
+	}
+	token, err := c.token()
+	if err != nil {
+		return err
+	}
+
+	done := 0
+	for _, chunk := range c.Chunker.ChunkV1(policies) {
+		err = send(token, chunk)
+		if err != nil {
+			return fmt.Errorf("%s policies (%d of %d done): %s", action, done, len(policies), err)
+		}
+		done += len(chunk)
+	}
+	fmt.Fprintf(c.Log, "%s: %d policies\n", action, done)
+	return nil
+}
+
+// Diff shows the policies in the file at path that are not on the policy
+// server, which add would create, and the policies on the policy server that
+// are not in the file. Tags are ignored.
+func (c *CLI) Diff(path string) error {
+	desired, err := c.readPolicies(path)
+	if err != nil {
+		return err
+	}
+	token, err := c.token()
+	if err != nil {
+		return err
+	}
+	current, err := c.Client.GetPolicies(token)
+	if err != nil {
+		return fmt.Errorf("get policies: %s", err)
+	}
+
+	diff := PolicyDiff{
+		Add:    missingFrom(current, desired),
+		Delete: missingFrom(desired, current),
+	}
+	return c.writeDiff(diff)
+}
+
+func (c *CLI) Cleanup() error {
+	token, err := c.token()
+	if err != nil {
+		return err
+	}
+	policies, err := c.Client.CleanupPolicies(token)
+	if err != nil {
+		return fmt.Errorf("cleanup policies: %s", err)
+	}
+	fmt.Fprintf(c.Log, "cleanup: deleted %d stale policies\n", len(policies))
+	return c.writePolicies(policies)
+}
+
+func (c *CLI) Tags() error {
+	token, err := c.token()
+	if err != nil {
+		return err
+	}
+	tags, err := c.Client.GetTags(token)
+	if err != nil {
+		return fmt.Errorf("get tags: %s", err)
+	}
+	return c.writeTags(tags)
+}
+
+func (c *CLI) readPolicies(path string) ([]api.Policy, error) {
+	reader := c.Input
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open policy file: %s", err)
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	policies, err := ReadPolicies(reader)
+	if err != nil {
+		return nil, fmt.Errorf("read policy file %s: %s", path, err)
+	}
+	return policies, nil
+}
+
+type PolicyDiff struct {
+	Add    []api.Policy `json:"add"`
+	Delete []api.Policy `json:"delete"`
+}
+
+// missingFrom returns the policies in policies that are not in existing.
+func missingFrom(existing, policies []api.Policy) []api.Policy {
+	keys := map[api.Policy]bool{}
+	for _, policy := range existing {
+		keys[policyKey(policy)] = true
+	}
+
+	missing := []api.Policy{}
+	for _, policy := range policies {
+		if !keys[policyKey(policy)] {
+			missing = append(missing, policy)
+		}
+	}
+	sort.Sort(api.PolicySlice(missing))
+	return missing
+}
+
+func policyKey(policy api.Policy) api.Policy {
+	policy.Source.Tag = ""
+	policy.Destination.Tag = ""
+	return policy
+}
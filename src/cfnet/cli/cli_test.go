@@ -0,0 +1,336 @@
+package cli_test
+
+import (
+	"bytes"
+	"cfnet/cli"
+	"cfnet/fakes"
+	"errors"
+	"io/ioutil"
+	"lib/policy_client"
+	"lib/testsupport/policyserver"
+	"net/http"
+	"os"
+	"path/filepath"
+	"policy-server/api"
+	"policy-server/uaa_client"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// requests returns the method and path of every request the policy server
+// served.
+func requests(policyServer *policyserver.FakePolicyServer) []string {
+	served := []string{}
+	for _, request := range policyServer.Requests() {
+		served = append(served, request.Method+" "+request.Path)
+	}
+	return served
+}
+
+// policies returns the policies on the policy server without their tags.
+func policies(policyServer *policyserver.FakePolicyServer) []api.Policy {
+	untagged := []api.Policy{}
+	for _, policy := range policyServer.Policies() {
+		policy.Source.Tag = ""
+		policy.Destination.Tag = ""
+		untagged = append(untagged, policy)
+	}
+	return untagged
+}
+
+func policy(source, destination, protocol string, start, end int) api.Policy {
+	return api.Policy{
+		Source: api.Source{ID: source},
+		Destination: api.Destination{
+			ID:       destination,
+			Protocol: protocol,
+			Ports:    api.Ports{Start: start, End: end},
+		},
+	}
+}
+
+var _ = Describe("CLI", func() {
+	var (
+		uaa          *policyserver.FakeUAA
+		policyServer *policyserver.FakePolicyServer
+		dir          string
+		input        *bytes.Buffer
+		output       *bytes.Buffer
+		log          *bytes.Buffer
+		c            *cli.CLI
+	)
+
+	BeforeEach(func() {
+		uaa = policyserver.NewFakeUAA()
+		uaa.AddToken("some-token", uaa_client.CheckTokenResponse{Scope: []string{"network.admin"}})
+		policyServer = policyserver.NewFakePolicyServer(uaa)
+		policyServer.SetPolicies([]api.Policy{
+			policy("app-a", "app-b", "tcp", 8080, 8080),
+			policy("app-b", "app-c", "udp", 5000, 5010),
+		})
+
+		var err error
+		dir, err = ioutil.TempDir("", "cfnet")
+		Expect(err).NotTo(HaveOccurred())
+
+		input = &bytes.Buffer{}
+		output = &bytes.Buffer{}
+		log = &bytes.Buffer{}
+		c = &cli.CLI{
+			Client:  policy_client.NewExternal(lagertest.NewTestLogger("test"), http.DefaultClient, policyServer.URL),
+			Chunker: &policy_client.SimpleChunker{ChunkSize: 2},
+			Tokens:  cli.StaticToken("bearer some-token\n"),
+			Input:   input,
+			Output:  output,
+			Log:     log,
+			Format:  cli.FormatTable,
+		}
+	})
+
+	AfterEach(func() {
+		policyServer.Close()
+		uaa.Close()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	writeFile := func(contents string) string {
+		path := filepath.Join(dir, "policies")
+		Expect(ioutil.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+		return path
+	}
+
+	Describe("List", func() {
+		It("prints the policies as a table", func() {
+			Expect(c.List(nil)).To(Succeed())
+			Expect(output.String()).To(Equal(`SOURCE  DESTINATION  PROTOCOL  PORTS
+app-a   app-b        tcp       8080
+app-b   app-c        udp       5000-5010
+`))
+		})
+
+		It("lists only the policies of the given apps", func() {
+			c.Format = cli.FormatJSON
+			Expect(c.List([]string{"app-c"})).To(Succeed())
+			Expect(output.String()).To(MatchJSON(`{
+				"policies": [{
+					"source": {"id": "app-b"},
+					"destination": {"id": "app-c", "protocol": "udp", "ports": {"start": 5000, "end": 5010}}
+				}]
+			}`))
+		})
+
+		It("prints the policies as YAML that can be read back", func() {
+			c.Format = cli.FormatYAML
+			Expect(c.List(nil)).To(Succeed())
+			Expect(output.String()).To(ContainSubstring("- destination:\n"))
+
+			read, err := cli.ReadPolicies(output)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(read).To(Equal(policies(policyServer)))
+		})
+
+		Context("when there are no policies", func() {
+			BeforeEach(func() {
+				policyServer.SetPolicies(nil)
+			})
+
+			It("prints an empty list", func() {
+				c.Format = cli.FormatJSON
+				Expect(c.List(nil)).To(Succeed())
+				Expect(output.String()).To(MatchJSON(`{"policies": []}`))
+			})
+		})
+
+		Context("when the token is rejected", func() {
+			BeforeEach(func() {
+				c.Tokens = cli.StaticToken("some-other-token")
+			})
+
+			It("returns the error", func() {
+				err := c.List(nil)
+				Expect(err).To(MatchError(ContainSubstring("get policies: 403 Forbidden")))
+			})
+		})
+
+		Context("when getting the token fails", func() {
+			BeforeEach(func() {
+				tokens := &fakes.TokenFetcher{}
+				tokens.GetTokenReturns("", errors.New("bad client credentials"))
+				c.Tokens = tokens
+			})
+
+			It("returns the error without calling the policy server", func() {
+				err := c.List(nil)
+				Expect(err).To(MatchError("get token: bad client credentials"))
+				Expect(requests(policyServer)).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Add", func() {
+		It("adds the policies in a YAML file in chunks", func() {
+			path := writeFile(`
+policies:
+- source: {id: app-c}
+  destination: {id: app-d, protocol: tcp, ports: {start: 9000, end: 9000}}
+- source: {id: app-d}
+  destination: {id: app-e, protocol: tcp, ports: {start: 9001, end: 9002}}
+- source: {id: app-e}
+  destination: {id: app-f, protocol: udp, ports: {start: 53, end: 53}}
+`)
+			Expect(c.Add(path)).To(Succeed())
+
+			Expect(requests(policyServer)).To(Equal([]string{
+				"POST /networking/v1/external/policies",
+				"POST /networking/v1/external/policies",
+			}))
+			Expect(policies(policyServer)).To(ConsistOf(
+				policy("app-a", "app-b", "tcp", 8080, 8080),
+				policy("app-b", "app-c", "udp", 5000, 5010),
+				policy("app-c", "app-d", "tcp", 9000, 9000),
+				policy("app-d", "app-e", "tcp", 9001, 9002),
+				policy("app-e", "app-f", "udp", 53, 53),
+			))
+			Expect(log.String()).To(Equal("add: 3 policies\n"))
+		})
+
+		It("reads JSON from stdin", func() {
+			input.WriteString(`{"policies": [{"source": {"id": "app-c"}, "destination": {"id": "app-d", "protocol": "tcp", "ports": {"start": 9000, "end": 9000}}}]}`)
+			Expect(c.Add("-")).To(Succeed())
+			Expect(policies(policyServer)).To(ContainElement(policy("app-c", "app-d", "tcp", 9000, 9000)))
+		})
+
+		Context("when the policy server rejects a chunk", func() {
+			It("says how many policies were added", func() {
+				path := writeFile(`{"policies": [
+					{"source": {"id": "app-c"}, "destination": {"id": "app-d", "protocol": "tcp", "ports": {"start": 9000, "end": 9000}}},
+					{"source": {"id": "app-d"}, "destination": {"id": "app-e", "protocol": "tcp", "ports": {"start": 9000, "end": 9000}}},
+					{"source": {"id": "app-e"}, "destination": {"id": "app-f", "protocol": "icmp", "ports": {"start": 9000, "end": 9000}}}
+				]}`)
+				err := c.Add(path)
+				Expect(err).To(MatchError(ContainSubstring("add policies (2 of 3 done): 400 Bad Request")))
+			})
+		})
+
+		Context("when the file does not exist", func() {
+			It("returns an error", func() {
+				err := c.Add(filepath.Join(dir, "missing"))
+				Expect(err).To(MatchError(ContainSubstring("open policy file:")))
+				Expect(requests(policyServer)).To(BeEmpty())
+			})
+		})
+
+		Context("when the file has unknown fields", func() {
+			It("returns an error", func() {
+				path := writeFile(`{"policies": [{"source": {"id": "app-c"}, "destination": {"id": "app-d", "port": 9000}}]}`)
+				err := c.Add(path)
+				Expect(err).To(MatchError(ContainSubstring(`unknown field "port"`)))
+				Expect(requests(policyServer)).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Delete", func() {
+		It("deletes the policies in the file", func() {
+			path := writeFile(`
+policies:
+- source: {id: app-a}
+  destination: {id: app-b, protocol: tcp, ports: {start: 8080, end: 8080}}
+`)
+			Expect(c.Delete(path)).To(Succeed())
+			Expect(requests(policyServer)).To(Equal([]string{"POST /networking/v1/external/policies/delete"}))
+			Expect(policies(policyServer)).To(Equal([]api.Policy{policy("app-b", "app-c", "udp", 5000, 5010)}))
+			Expect(log.String()).To(Equal("delete: 1 policies\n"))
+		})
+	})
+
+	Describe("Diff", func() {
+		var path string
+
+		BeforeEach(func() {
+			path = writeFile(`
+policies:
+- source: {id: app-a}
+  destination: {id: app-b, protocol: tcp, ports: {start: 8080, end: 8080}}
+- source: {id: app-c}
+  destination: {id: app-d, protocol: tcp, ports: {start: 9000, end: 9000}}
+`)
+		})
+
+		It("prints the policies to add and those missing from the file", func() {
+			Expect(c.Diff(path)).To(Succeed())
+			Expect(output.String()).To(Equal(`   SOURCE  DESTINATION  PROTOCOL  PORTS
++  app-c   app-d        tcp       9000
+-  app-b   app-c        udp       5000-5010
+`))
+			Expect(requests(policyServer)).To(Equal([]string{"GET /networking/v1/external/policies"}))
+		})
+
+		It("prints the diff as JSON", func() {
+			c.Format = cli.FormatJSON
+			Expect(c.Diff(path)).To(Succeed())
+			Expect(output.String()).To(MatchJSON(`{
+				"add": [{"source": {"id": "app-c"}, "destination": {"id": "app-d", "protocol": "tcp", "ports": {"start": 9000, "end": 9000}}}],
+				"delete": [{"source": {"id": "app-b"}, "destination": {"id": "app-c", "protocol": "udp", "ports": {"start": 5000, "end": 5010}}}]
+			}`))
+		})
+	})
+
+	Describe("Cleanup", func() {
+		BeforeEach(func() {
+			policyServer.SetPolicies([]api.Policy{
+				policy("app-a", "app-b", "tcp", 8080, 8080),
+				policy("deleted-app", "app-b", "tcp", 8080, 8080),
+			})
+			policyServer.SetDeletedApps("deleted-app")
+		})
+
+		It("triggers the cleanup and prints the deleted policies", func() {
+			Expect(c.Cleanup()).To(Succeed())
+			Expect(requests(policyServer)).To(Equal([]string{"POST /networking/v1/external/policies/cleanup"}))
+			Expect(output.String()).To(Equal(`SOURCE       DESTINATION  PROTOCOL  PORTS
+deleted-app  app-b        tcp       8080
+`))
+			Expect(log.String()).To(Equal("cleanup: deleted 1 stale policies\n"))
+		})
+	})
+
+	Describe("Tags", func() {
+		It("prints the tags", func() {
+			Expect(c.Tags()).To(Succeed())
+			Expect(output.String()).To(Equal(`ID     TAG
+app-a  0001
+app-b  0002
+app-c  0003
+`))
+		})
+
+		It("prints the tags as YAML", func() {
+			c.Format = cli.FormatYAML
+			Expect(c.Tags()).To(Succeed())
+			Expect(output.String()).To(MatchYAML(`
+tags:
+- id: app-a
+  tag: "0001"
+- id: app-b
+  tag: "0002"
+- id: app-c
+  tag: "0003"
+`))
+		})
+	})
+})
+
+var _ = Describe("ValidateFormat", func() {
+	It("accepts the known formats", func() {
+		Expect(cli.ValidateFormat("table")).To(Succeed())
+		Expect(cli.ValidateFormat("json")).To(Succeed())
+		Expect(cli.ValidateFormat("yaml")).To(Succeed())
+	})
+
+	It("rejects other formats", func() {
+		Expect(cli.ValidateFormat("xml")).To(MatchError(`unknown output format "xml": must be table, json or yaml`))
+	})
+})
@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"policy-server/api"
+	"strconv"
+	"text/tabwriter"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatYAML  = "yaml"
+)
+
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatTable, FormatJSON, FormatYAML:
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q: must be %s, %s or %s", format, FormatTable, FormatJSON, FormatYAML)
+}
+
+// ReadPolicies reads policies in the format of the policy server API,
+// {"policies": [...]}, as JSON or YAML.
+func ReadPolicies(reader io.Reader) ([]api.Policy, error) {
+	contents, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := bytes.TrimSpace(contents)
+	if len(trimmed) > 0 && trimmed[0] != '{' {
+		contents, err = yamlToJSON(contents)
+		if err != nil {
+			return nil, fmt.Errorf("yaml: %s", err)
+		}
+	}
+
+	var policies struct {
+		Policies []api.Policy `json:"policies"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&policies)
+	if err != nil {
+		return nil, fmt.Errorf("json: %s", err)
+	}
+	return policies.Policies, nil
+}
+
+// yamlToJSON converts YAML to JSON so that it can be decoded into the API
+// types, which only have JSON tags.
+func yamlToJSON(contents []byte) ([]byte, error) {
+	var data interface{}
+	err := yaml.Unmarshal(contents, &data)
+	if err != nil {
+		return nil, err
+	}
+	data, err = jsonCompatible(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(data)
+}
+
+func jsonCompatible(data interface{}) (interface{}, error) {
+	switch data := data.(type) {
+	case map[interface{}]interface{}:
+		converted := map[string]interface{}{}
+		for key, value := range data {
+			stringKey, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("key %v is not a string", key)
+			}
+			convertedValue, err := jsonCompatible(value)
+			if err != nil {
+				return nil, err
+			}
+			converted[stringKey] = convertedValue
+		}
+		return converted, nil
+	case []interface{}:
+		converted := make([]interface{}, len(data))
+		for i, value := range data {
+			convertedValue, err := jsonCompatible(value)
+			if err != nil {
+				return nil, err
+			}
+			converted[i] = convertedValue
+		}
+		return converted, nil
+	}
+	return data, nil
+}
+
+func (c *CLI) writePolicies(policies []api.Policy) error {
+	if c.Format != FormatTable {
+		return c.writeData(map[string][]api.Policy{"policies": nonNil(policies)})
+	}
+
+	w := tabwriter.NewWriter(c.Output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tDESTINATION\tPROTOCOL\tPORTS")
+	for _, policy := range policies {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", policy.Source.ID, policy.Destination.ID, policy.Destination.Protocol, formatPorts(policy.Destination.Ports))
+	}
+	return w.Flush()
+}
+
+func (c *CLI) writeDiff(diff PolicyDiff) error {
+	if c.Format != FormatTable {
+		return c.writeData(diff)
+	}
+
+	w := tabwriter.NewWriter(c.Output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "\tSOURCE\tDESTINATION\tPROTOCOL\tPORTS")
+	for _, change := range []struct {
+		sign     string
+		policies []api.Policy
+	}{{"+", diff.Add}, {"-", diff.Delete}} {
+		for _, policy := range change.policies {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", change.sign, policy.Source.ID, policy.Destination.ID, policy.Destination.Protocol, formatPorts(policy.Destination.Ports))
+		}
+	}
+	return w.Flush()
+}
+
+func (c *CLI) writeTags(tags []api.Tag) error {
+	if c.Format != FormatTable {
+		if tags == nil {
+			tags = []api.Tag{}
+		}
+		return c.writeData(map[string][]api.Tag{"tags": tags})
+	}
+
+	w := tabwriter.NewWriter(c.Output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTAG")
+	for _, tag := range tags {
+		fmt.Fprintf(w, "%s\t%s\n", tag.ID, tag.Tag)
+	}
+	return w.Flush()
+}
+
+// writeData writes data as indented JSON, or as YAML with the same field
+// names as the JSON.
+func (c *CLI) writeData(data interface{}) error {
+	jsonBytes, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal output: %s", err)
+	}
+	if c.Format == FormatJSON {
+		_, err = fmt.Fprintf(c.Output, "%s\n", jsonBytes)
+		return err
+	}
+
+	var generic interface{}
+	err = json.Unmarshal(jsonBytes, &generic)
+	if err != nil {
+		return fmt.Errorf("marshal output: %s", err)
+	}
+	yamlBytes, err := yaml.Marshal(generic)
+	if err != nil {
+		return fmt.Errorf("marshal output: %s", err)
+	}
+	_, err = c.Output.Write(yamlBytes)
+	return err
+}
+
+func formatPorts(ports api.Ports) string {
+	if ports.Start == ports.End {
+		return strconv.Itoa(ports.Start)
+	}
+	return fmt.Sprintf("%d-%d", ports.Start, ports.End)
+}
+
+func nonNil(policies []api.Policy) []api.Policy {
+	if policies == nil {
+		return []api.Policy{}
+	}
+	return policies
+}
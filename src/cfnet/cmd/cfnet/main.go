@@ -0,0 +1,189 @@
+package main
+
+import (
+	"cfnet/cli"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"lib/nonmutualtls"
+	"lib/policy_client"
+	"net/http"
+	"os"
+	"policy-server/uaa_client"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+const usage = `Usage: cfnet [flags] <command> [args]
+
+Commands:
+  list [APP_GUID...]   list policies, optionally only those of the given apps
+  add FILE             add the policies in FILE
+  delete FILE          delete the policies in FILE
+  diff FILE            show the policies add would create and those not in FILE
+  cleanup              delete policies of apps that no longer exist
+  tags                 list the tags of apps and groups
+
+FILE holds {"policies": [...]} as JSON or YAML, in the format of the output of
+list. Use - to read it from stdin.
+
+Authenticate with -token (or CFNET_TOKEN), such as the output of
+'cf oauth-token', or with UAA client credentials: -uaa-url, -client-id and
+-client-secret (or CFNET_CLIENT_SECRET).
+
+Flags:
+`
+
+func main() {
+	flags := flag.NewFlagSet("cfnet", flag.ExitOnError)
+	api := flags.String("api", os.Getenv("CFNET_API"), "policy server API URL, e.g. https://api.example.com (CFNET_API)")
+	token := flags.String("token", os.Getenv("CFNET_TOKEN"), "UAA access token (CFNET_TOKEN)")
+	uaaURL := flags.String("uaa-url", os.Getenv("CFNET_UAA_URL"), "UAA URL, for client credentials (CFNET_UAA_URL)")
+	clientID := flags.String("client-id", os.Getenv("CFNET_CLIENT_ID"), "UAA client ID (CFNET_CLIENT_ID)")
+	clientSecret := flags.String("client-secret", os.Getenv("CFNET_CLIENT_SECRET"), "UAA client secret (CFNET_CLIENT_SECRET)")
+	caCert := flags.String("ca-cert", "", "path to the CA certificate of the API and UAA")
+	skipSSLValidation := flags.Bool("skip-ssl-validation", false, "skip TLS certificate validation")
+	output := flags.String("output", cli.FormatTable, "output format: table, json or yaml")
+	chunkSize := flags.Int("chunk-size", policy_client.DefaultMaxPolicies, "most policies sent in one request")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout of each request")
+	verbose := flags.Bool("v", false, "log requests and retries to stderr")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	command, args := flags.Arg(0), flags.Args()[1:]
+
+	if err := run(command, args, config{
+		api:               *api,
+		token:             *token,
+		uaaURL:            *uaaURL,
+		clientID:          *clientID,
+		clientSecret:      *clientSecret,
+		caCert:            *caCert,
+		skipSSLValidation: *skipSSLValidation,
+		output:            *output,
+		chunkSize:         *chunkSize,
+		timeout:           *timeout,
+		verbose:           *verbose,
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "cfnet %s: %s\n", command, err)
+		os.Exit(1)
+	}
+}
+
+type config struct {
+	api               string
+	token             string
+	uaaURL            string
+	clientID          string
+	clientSecret      string
+	caCert            string
+	skipSSLValidation bool
+	output            string
+	chunkSize         int
+	timeout           time.Duration
+	verbose           bool
+}
+
+func run(command string, args []string, conf config) error {
+	if conf.api == "" {
+		return fmt.Errorf("missing -api")
+	}
+	if err := cli.ValidateFormat(conf.output); err != nil {
+		return err
+	}
+
+	logger := lager.NewLogger("cfnet")
+	logLevel := lager.ERROR
+	if conf.verbose {
+		logLevel = lager.DEBUG
+	}
+	logger.RegisterSink(lager.NewWriterSink(os.Stderr, logLevel))
+
+	var tlsConfig *tls.Config
+	if conf.skipSSLValidation {
+		tlsConfig = &tls.Config{InsecureSkipVerify: true}
+	} else if conf.caCert != "" {
+		var err error
+		tlsConfig, err = nonmutualtls.NewClientTLSConfig(conf.caCert)
+		if err != nil {
+			return fmt.Errorf("create tls config: %s", err)
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	httpClient := &http.Client{
+		Timeout:   conf.timeout,
+		Transport: transport,
+	}
+
+	var tokens interface {
+		GetToken() (string, error)
+	}
+	switch {
+	case conf.token != "":
+		tokens = cli.StaticToken(conf.token)
+	case conf.uaaURL != "" && conf.clientID != "":
+		tokens = &uaa_client.Client{
+			BaseURL:    conf.uaaURL,
+			Name:       conf.clientID,
+			Secret:     conf.clientSecret,
+			HTTPClient: httpClient,
+			Logger:     logger,
+		}
+	default:
+		return fmt.Errorf("missing -token, or -uaa-url and -client-id")
+	}
+
+	c := &cli.CLI{
+		Client:  policy_client.NewExternal(logger, httpClient, conf.api),
+		Chunker: &policy_client.SimpleChunker{ChunkSize: conf.chunkSize},
+		Tokens:  tokens,
+		Input:   os.Stdin,
+		Output:  os.Stdout,
+		Log:     os.Stderr,
+		Format:  conf.output,
+	}
+
+	switch command {
+	case "list":
+		return c.List(args)
+	case "add":
+		path, err := policyFile(args)
+		if err != nil {
+			return err
+		}
+		return c.Add(path)
+	case "delete":
+		path, err := policyFile(args)
+		if err != nil {
+			return err
+		}
+		return c.Delete(path)
+	case "diff":
+		path, err := policyFile(args)
+		if err != nil {
+			return err
+		}
+		return c.Diff(path)
+	case "cleanup":
+		return c.Cleanup()
+	case "tags":
+		return c.Tags()
+	}
+	return fmt.Errorf("unknown command, run cfnet -h for usage")
+}
+
+func policyFile(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expected a single policy file")
+	}
+	return args[0], nil
+}
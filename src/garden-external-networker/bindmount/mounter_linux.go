@@ -2,6 +2,7 @@ package bindmount
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"syscall"
@@ -44,3 +45,21 @@ func (m *Mounter) RemoveMount(target string) error {
 
 	return nil
 }
+
+func (m *Mounter) ListMounts(root string) ([]string, error) {
+	entries, err := ioutil.ReadDir(root)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading bind mount root: %s", err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
@@ -139,4 +139,32 @@ var _ = Describe("Mounter", func() {
 			Expect(targetDir).To(BeADirectory())
 		})
 	})
+
+	Describe("ListMounts", func() {
+		It("returns the names of the bind mounts under the root", func() {
+			Expect(mounter.IdempotentlyMount(sourceFile, targetFile)).To(Succeed())
+			Expect(mounter.IdempotentlyMount(sourceFile, filepath.Join(targetDir, "some-sub-dir", "other-target"))).To(Succeed())
+
+			mounts, err := mounter.ListMounts(filepath.Join(targetDir, "some-sub-dir"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mounts).To(ConsistOf("the-target", "other-target"))
+
+			Expect(mounter.RemoveMount(targetFile)).To(Succeed())
+			Expect(mounter.RemoveMount(filepath.Join(targetDir, "some-sub-dir", "other-target"))).To(Succeed())
+		})
+
+		It("ignores directories", func() {
+			mounts, err := mounter.ListMounts(targetDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mounts).To(BeEmpty())
+		})
+
+		Context("when the root does not exist", func() {
+			It("returns no mounts", func() {
+				mounts, err := mounter.ListMounts(filepath.Join(targetDir, "does-not-exist"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mounts).To(BeEmpty())
+			})
+		})
+	})
 })
@@ -9,3 +9,7 @@ func (m *Mounter) IdempotentlyMount(source, target string) error {
 func (m *Mounter) RemoveMount(target string) error {
 	return nil
 }
+
+func (m *Mounter) ListMounts(root string) ([]string, error) {
+	return []string{}, nil
+}
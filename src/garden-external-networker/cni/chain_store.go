@@ -0,0 +1,76 @@
+package cni
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/libcni"
+)
+
+// ChainStore records a copy of the network config list each container was
+// brought up with, so that CHECK, update and DEL use the same chain even
+// after it was changed or removed from the config dir.
+type ChainStore struct {
+	Dir string
+}
+
+func (s *ChainStore) Save(handle string, networkConfigList *libcni.NetworkConfigList) error {
+	if len(networkConfigList.Bytes) == 0 {
+		return fmt.Errorf("chain '%s' has no config to record", networkConfigList.Name)
+	}
+
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return fmt.Errorf("create chain dir: %s", err)
+	}
+
+	tempFile, err := ioutil.TempFile(s.Dir, handle+".tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file: %s", err)
+	}
+	defer os.Remove(tempFile.Name()) // not tested
+
+	if _, err := tempFile.Write(networkConfigList.Bytes); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("write temp file: %s", err) // not tested
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("close temp file: %s", err) // not tested
+	}
+
+	if err := os.Rename(tempFile.Name(), s.path(handle)); err != nil {
+		return fmt.Errorf("rename temp file: %s", err) // not tested
+	}
+	return nil
+}
+
+// Load returns the recorded chain of a container, or nil if none was
+// recorded.
+func (s *ChainStore) Load(handle string) (*libcni.NetworkConfigList, error) {
+	chainBytes, err := ioutil.ReadFile(s.path(handle))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read chain: %s", err)
+	}
+
+	networkConfigList, err := libcni.ConfListFromBytes(chainBytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse chain: %s", err)
+	}
+	return networkConfigList, nil
+}
+
+func (s *ChainStore) Delete(handle string) error {
+	err := os.Remove(s.path(handle))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *ChainStore) path(handle string) string {
+	return filepath.Join(s.Dir, handle+".chain")
+}
@@ -0,0 +1,99 @@
+package cni_test
+
+import (
+	"garden-external-networker/cni"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/libcni"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ChainStore", func() {
+	var (
+		storeDir          string
+		store             *cni.ChainStore
+		networkConfigList *libcni.NetworkConfigList
+	)
+
+	BeforeEach(func() {
+		var err error
+		storeDir, err = ioutil.TempDir("", "cni-chain-store-")
+		Expect(err).NotTo(HaveOccurred())
+
+		store = &cni.ChainStore{Dir: filepath.Join(storeDir, "chains")}
+
+		networkConfigList, err = libcni.ConfListFromBytes([]byte(`{
+			"name": "some-chain",
+			"cniVersion": "0.3.1",
+			"plugins": [{"type": "some-plugin"}]
+		}`))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(storeDir)).To(Succeed())
+	})
+
+	It("saves and loads a copy of the chain of a container", func() {
+		Expect(store.Save("some-handle", networkConfigList)).To(Succeed())
+
+		chain, err := store.Load("some-handle")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(chain.Name).To(Equal("some-chain"))
+		Expect(chain.CNIVersion).To(Equal("0.3.1"))
+		Expect(chain.Plugins).To(HaveLen(1))
+		Expect(chain.Plugins[0].Network.Type).To(Equal("some-plugin"))
+		Expect(chain.Bytes).To(MatchJSON(networkConfigList.Bytes))
+	})
+
+	It("loads no chain for a container without one", func() {
+		chain, err := store.Load("some-handle")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(chain).To(BeNil())
+	})
+
+	Context("when the chain has no config", func() {
+		It("returns an error without recording it", func() {
+			err := store.Save("some-handle", &libcni.NetworkConfigList{Name: "some-chain"})
+			Expect(err).To(MatchError("chain 'some-chain' has no config to record"))
+
+			chain, err := store.Load("some-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(chain).To(BeNil())
+		})
+	})
+
+	It("deletes the chain of a container", func() {
+		Expect(store.Save("some-handle", networkConfigList)).To(Succeed())
+		Expect(store.Delete("some-handle")).To(Succeed())
+
+		chain, err := store.Load("some-handle")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(chain).To(BeNil())
+	})
+
+	It("does not fail to delete a chain that was never saved", func() {
+		Expect(store.Delete("some-handle")).To(Succeed())
+	})
+
+	Context("when the recorded chain is invalid", func() {
+		It("returns an error", func() {
+			Expect(os.MkdirAll(store.Dir, 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(store.Dir, "some-handle.chain"), []byte("{ bad"), 0600)).To(Succeed())
+
+			_, err := store.Load("some-handle")
+			Expect(err).To(MatchError(HavePrefix("parse chain:")))
+		})
+	})
+
+	Context("when the store dir cannot be created", func() {
+		It("returns an error", func() {
+			store.Dir = "/proc/0/foo"
+			err := store.Save("some-handle", networkConfigList)
+			Expect(err).To(MatchError(HavePrefix("create chain dir:")))
+		})
+	})
+})
@@ -2,6 +2,8 @@ package cni
 
 import (
 	"fmt"
+	"net"
+	"sync"
 
 	"github.com/containernetworking/cni/libcni"
 	"github.com/containernetworking/cni/pkg/types"
@@ -12,51 +14,110 @@ type cniLibrary interface {
 	libcni.CNI
 }
 
+//go:generate counterfeiter -o ../fakes/cni_checker.go --fake-name CNIChecker . cniChecker
+type cniChecker interface {
+	CheckNetworkList(list *libcni.NetworkConfigList, rt *libcni.RuntimeConf) error
+}
+
+//go:generate counterfeiter -o ../fakes/result_cache.go --fake-name ResultCache . resultCache
+type resultCache interface {
+	Save(handle string, result types.Result) error
+	Load(handle string) (map[string]interface{}, error)
+	Delete(handle string) error
+}
+
+//go:generate counterfeiter -o ../fakes/chain_store.go --fake-name ChainStore . chainStore
+type chainStore interface {
+	Save(handle string, networkConfigList *libcni.NetworkConfigList) error
+	Load(handle string) (*libcni.NetworkConfigList, error)
+	Delete(handle string) error
+}
+
+// PropertyChain is the container property that selects one of the
+// NetworkConfigLists by name.
+const PropertyChain = "cni.chain"
+
 type CNIController struct {
 	CNIConfig         libcni.CNI
 	NetworkConfigList *libcni.NetworkConfigList
+	Checker           cniChecker
+	ResultCache       resultCache
+
+	// NetworkConfigLists are the chains a container can select with
+	// PropertyChain. Containers that don't select one use NetworkConfigList.
+	NetworkConfigLists map[string]*libcni.NetworkConfigList
+	Chains             chainStore
+
+	mutex sync.RWMutex
+}
+
+// SetNetworkConfigs replaces the loaded chains. Calls in flight keep the
+// chain they started with.
+func (c *CNIController) SetNetworkConfigs(networkConfigList *libcni.NetworkConfigList, networkConfigLists map[string]*libcni.NetworkConfigList) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.NetworkConfigList = networkConfigList
+	c.NetworkConfigLists = networkConfigLists
 }
 
 func (c *CNIController) Up(namespacePath, handle string, metadata map[string]interface{}, legacyNetConf map[string]interface{}) (types.Result, error) {
 	var result types.Result
-	var err error
 
-	if c.NetworkConfigList == nil {
+	chain, err := chainFromProperties(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	networkConfigList, err := c.networkConfigList(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	if networkConfigList == nil {
 		return result, nil
 	}
 
+	// recorded before ADD so that the DEL after a failed ADD uses this chain too
+	if err := c.Chains.Save(handle, networkConfigList); err != nil {
+		return nil, fmt.Errorf("recording cni chain: %s", err)
+	}
+
 	runtimeConfig := &libcni.RuntimeConf{
 		ContainerID: handle,
 		NetNS:       namespacePath,
 		IfName:      "eth0",
 	}
 
-	extraKeys := map[string]interface{}{}
-	if len(metadata) > 0 {
-		extraKeys["metadata"] = metadata
-	}
-	if len(legacyNetConf) > 0 {
-		extraKeys["runtimeConfig"] = legacyNetConf
-	}
-
-	for i, networkConfig := range c.NetworkConfigList.Plugins {
-		networkConfig, err = libcni.InjectConf(networkConfig, extraKeys)
-		if err != nil {
-			return nil, fmt.Errorf("adding extra data to CNI config: %s", err)
-		}
-		c.NetworkConfigList.Plugins[i] = networkConfig
+	addConfigList, err := configListWith(networkConfigList, extraKeys(metadata, legacyNetConf))
+	if err != nil {
+		return nil, fmt.Errorf("adding extra data to CNI config: %s", err)
 	}
 
-	result, err = c.CNIConfig.AddNetworkList(c.NetworkConfigList, runtimeConfig)
+	result, err = c.CNIConfig.AddNetworkList(addConfigList, runtimeConfig)
 	if err != nil {
 		return nil, fmt.Errorf("add network list failed: %s", err)
 	}
 
+	if result != nil {
+		if err := c.ResultCache.Save(handle, result); err != nil {
+			// without the cached result CHECK can't work, so undo the ADD
+			// rather than leave the container's network behind
+			if delErr := c.CNIConfig.DelNetworkList(addConfigList, runtimeConfig); delErr != nil {
+				return nil, fmt.Errorf("caching result: %s (del network failed: %s)", err, delErr)
+			}
+			return nil, fmt.Errorf("caching result: %s", err)
+		}
+	}
+
 	return result, nil
 }
 
 func (c *CNIController) Down(namespacePath, handle string) error {
-	var err error
+	networkConfigList, err := c.recordedNetworkConfigList(handle)
+	if err != nil {
+		return err
+	}
 
 	runtimeConfig := &libcni.RuntimeConf{
 		ContainerID: handle,
@@ -64,11 +125,206 @@ func (c *CNIController) Down(namespacePath, handle string) error {
 		IfName:      "eth0",
 	}
 
-	err = c.CNIConfig.DelNetworkList(c.NetworkConfigList, runtimeConfig)
+	err = c.CNIConfig.DelNetworkList(networkConfigList, runtimeConfig)
 
 	if err != nil {
 		return fmt.Errorf("del network failed: %s", err)
 	}
 
+	if err := c.ResultCache.Delete(handle); err != nil {
+		return fmt.Errorf("removing cached result: %s", err)
+	}
+
+	if err := c.Chains.Delete(handle); err != nil {
+		return fmt.Errorf("removing cni chain: %s", err)
+	}
+
 	return nil
 }
+
+func (c *CNIController) Check(namespacePath, handle string) error {
+	networkConfigList, err := c.recordedNetworkConfigList(handle)
+	if err != nil {
+		return err
+	}
+
+	if networkConfigList == nil {
+		return nil
+	}
+
+	prevResult, err := c.ResultCache.Load(handle)
+	if err != nil {
+		return fmt.Errorf("loading cached result: %s", err)
+	}
+
+	runtimeConfig := &libcni.RuntimeConf{
+		ContainerID: handle,
+		NetNS:       namespacePath,
+		IfName:      "eth0",
+	}
+
+	checkConfigList, err := configListWith(networkConfigList, map[string]interface{}{"prevResult": prevResult})
+	if err != nil {
+		return fmt.Errorf("adding prevResult to CNI config: %s", err)
+	}
+
+	err = c.Checker.CheckNetworkList(checkConfigList, runtimeConfig)
+	if err != nil {
+		return fmt.Errorf("check network list failed: %s", err)
+	}
+
+	return nil
+}
+
+// Update re-creates the network of a running container with a new
+// runtimeConfig. CNI has no command that changes a network in place, so the
+// plugins are run with DEL and then ADD, as on Down and Up. The IPAM plugin
+// is asked for the address the container had through the IP CNI_ARG, which
+// host-local honours, so that the container keeps it where possible.
+func (c *CNIController) Update(namespacePath, handle string, metadata map[string]interface{}, legacyNetConf map[string]interface{}) (types.Result, error) {
+	networkConfigList, err := c.recordedNetworkConfigList(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	if networkConfigList == nil {
+		return nil, nil
+	}
+
+	runtimeConfig := &libcni.RuntimeConf{
+		ContainerID: handle,
+		NetNS:       namespacePath,
+		IfName:      "eth0",
+	}
+
+	// the previous address is only a hint, so a missing cached result is fine
+	prevResult, _ := c.ResultCache.Load(handle)
+
+	err = c.CNIConfig.DelNetworkList(networkConfigList, runtimeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("del network failed: %s", err)
+	}
+
+	addRuntimeConfig := &libcni.RuntimeConf{
+		ContainerID: handle,
+		NetNS:       namespacePath,
+		IfName:      "eth0",
+	}
+	if ip := firstIP(prevResult); ip != "" {
+		addRuntimeConfig.Args = [][2]string{{"IgnoreUnknown", "1"}, {"IP", ip}}
+	}
+
+	addConfigList, err := configListWith(networkConfigList, extraKeys(metadata, legacyNetConf))
+	if err != nil {
+		return nil, fmt.Errorf("adding extra data to CNI config: %s", err)
+	}
+
+	result, err := c.CNIConfig.AddNetworkList(addConfigList, addRuntimeConfig)
+	if err != nil {
+		// plugins expect a DEL after a failed ADD to clean up what they did set up
+		c.CNIConfig.DelNetworkList(addConfigList, addRuntimeConfig)
+		return nil, fmt.Errorf("add network list failed: %s", err)
+	}
+
+	if result != nil {
+		if err := c.ResultCache.Save(handle, result); err != nil {
+			return nil, fmt.Errorf("caching result: %s", err)
+		}
+	}
+
+	return result, nil
+}
+
+// recordedNetworkConfigList returns the copy of the chain the container was
+// brought up with. Containers without a recorded chain use the default one.
+func (c *CNIController) recordedNetworkConfigList(handle string) (*libcni.NetworkConfigList, error) {
+	networkConfigList, err := c.Chains.Load(handle)
+	if err != nil {
+		return nil, fmt.Errorf("loading cni chain: %s", err)
+	}
+
+	if networkConfigList == nil {
+		return c.networkConfigList("")
+	}
+
+	return networkConfigList, nil
+}
+
+func (c *CNIController) networkConfigList(chain string) (*libcni.NetworkConfigList, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if chain == "" {
+		return c.NetworkConfigList, nil
+	}
+
+	if networkConfigList, ok := c.NetworkConfigLists[chain]; ok {
+		return networkConfigList, nil
+	}
+
+	if c.NetworkConfigList != nil && c.NetworkConfigList.Name == chain {
+		return c.NetworkConfigList, nil
+	}
+
+	return nil, fmt.Errorf("unknown cni chain '%s'", chain)
+}
+
+// extraKeys are the keys added to the plugin configs on ADD.
+func extraKeys(metadata, legacyNetConf map[string]interface{}) map[string]interface{} {
+	keys := map[string]interface{}{}
+	if len(metadata) > 0 {
+		keys["metadata"] = metadata
+	}
+	if len(legacyNetConf) > 0 {
+		keys["runtimeConfig"] = legacyNetConf
+	}
+	return keys
+}
+
+// firstIP returns the first address of a cached CNI result, without its
+// prefix length, or "" if there is none.
+func firstIP(result map[string]interface{}) string {
+	ips, _ := result["ips"].([]interface{})
+	if len(ips) == 0 {
+		return ""
+	}
+	ipConfig, _ := ips[0].(map[string]interface{})
+	address, _ := ipConfig["address"].(string)
+	ip, _, err := net.ParseCIDR(address)
+	if err != nil {
+		return ""
+	}
+	return ip.String()
+}
+
+func chainFromProperties(properties map[string]interface{}) (string, error) {
+	value, ok := properties[PropertyChain]
+	if !ok {
+		return "", nil
+	}
+
+	chain, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("invalid property '%s': must be a string", PropertyChain)
+	}
+
+	return chain, nil
+}
+
+// configListWith returns a copy of the network config list with extra keys
+// injected into every plugin config, leaving the shared list untouched.
+func configListWith(networkConfigList *libcni.NetworkConfigList, extraKeys map[string]interface{}) (*libcni.NetworkConfigList, error) {
+	configList := &libcni.NetworkConfigList{
+		Name:       networkConfigList.Name,
+		CNIVersion: networkConfigList.CNIVersion,
+		Bytes:      networkConfigList.Bytes,
+	}
+	for _, networkConfig := range networkConfigList.Plugins {
+		networkConfig, err := libcni.InjectConf(networkConfig, extraKeys)
+		if err != nil {
+			return nil, err
+		}
+		configList.Plugins = append(configList.Plugins, networkConfig)
+	}
+	return configList, nil
+}
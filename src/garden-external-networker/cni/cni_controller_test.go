@@ -16,6 +16,10 @@ var _ = Describe("CniController", func() {
 	var (
 		controller     cni.CNIController
 		fakeCNILibrary *fakes.CNILibrary
+		checker        *fakes.CNIChecker
+		resultCache    *fakes.ResultCache
+		chains         *fakes.ChainStore
+		otherConfig    *libcni.NetworkConfigList
 		expectedResult *types020.Result
 		testConfig     *libcni.NetworkConfigList
 	)
@@ -39,10 +43,34 @@ var _ = Describe("CniController", func() {
 		expectedResult = &types020.Result{}
 		fakeCNILibrary.AddNetworkListReturns(expectedResult, nil)
 		fakeCNILibrary.DelNetworkListReturns(nil)
+		checker = &fakes.CNIChecker{}
+		resultCache = &fakes.ResultCache{}
+		chains = &fakes.ChainStore{}
+
+		otherConfig = &libcni.NetworkConfigList{
+			Name:       "other-net-list",
+			CNIVersion: "some-version",
+			Plugins: []*libcni.NetworkConfig{
+				{
+					Network: &types.NetConf{
+						CNIVersion: "some-version",
+						Type:       "other-plugin",
+					},
+					Bytes: []byte(`{"cniVersion":"some-version", "type": "other-plugin"}`),
+				},
+			},
+		}
 
 		controller = cni.CNIController{
-			CNIConfig: fakeCNILibrary,
+			CNIConfig:         fakeCNILibrary,
 			NetworkConfigList: testConfig,
+			Checker:           checker,
+			ResultCache:       resultCache,
+			NetworkConfigLists: map[string]*libcni.NetworkConfigList{
+				"net-list-name":  testConfig,
+				"other-net-list": otherConfig,
+			},
+			Chains: chains,
 		}
 	})
 
@@ -117,12 +145,123 @@ var _ = Describe("CniController", func() {
 			})
 		})
 
+		It("does not modify the shared network config list", func() {
+			_, err := controller.Up("/some/namespace/path", "some-handle", metadata, legacyNetConf)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(testConfig.Plugins[0].Bytes).To(MatchJSON(`{"cniVersion":"some-version", "type": "some-plugin"}`))
+		})
+
+		It("uses the chains set by SetNetworkConfigs", func() {
+			controller.SetNetworkConfigs(otherConfig, map[string]*libcni.NetworkConfigList{"other-net-list": otherConfig})
+
+			_, err := controller.Up("/some/namespace/path", "some-handle", metadata, legacyNetConf)
+			Expect(err).NotTo(HaveOccurred())
+
+			netc, _ := fakeCNILibrary.AddNetworkListArgsForCall(0)
+			Expect(netc.Name).To(Equal("other-net-list"))
+		})
+
+		It("caches the result for the container", func() {
+			_, err := controller.Up("/some/namespace/path", "some-handle", metadata, legacyNetConf)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(resultCache.SaveCallCount()).To(Equal(1))
+			handle, result := resultCache.SaveArgsForCall(0)
+			Expect(handle).To(Equal("some-handle"))
+			Expect(result).To(BeIdenticalTo(expectedResult))
+		})
+
+		It("records the default chain for the container", func() {
+			_, err := controller.Up("/some/namespace/path", "some-handle", metadata, legacyNetConf)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(chains.SaveCallCount()).To(Equal(1))
+			handle, chain := chains.SaveArgsForCall(0)
+			Expect(handle).To(Equal("some-handle"))
+			Expect(chain).To(BeIdenticalTo(testConfig))
+		})
+
+		Context("when the container selects a chain", func() {
+			BeforeEach(func() {
+				metadata[cni.PropertyChain] = "other-net-list"
+			})
+
+			It("adds the container to that chain and records it", func() {
+				_, err := controller.Up("/some/namespace/path", "some-handle", metadata, legacyNetConf)
+				Expect(err).NotTo(HaveOccurred())
+
+				netc, _ := fakeCNILibrary.AddNetworkListArgsForCall(0)
+				Expect(netc.Name).To(Equal("other-net-list"))
+				Expect(netc.Plugins[0].Network.Type).To(Equal("other-plugin"))
+
+				_, chain := chains.SaveArgsForCall(0)
+				Expect(chain).To(BeIdenticalTo(otherConfig))
+			})
+
+			Context("when the chain does not exist", func() {
+				It("returns a meaningful error", func() {
+					metadata[cni.PropertyChain] = "missing-net-list"
+
+					_, err := controller.Up("/some/namespace/path", "some-handle", metadata, legacyNetConf)
+					Expect(err).To(MatchError("unknown cni chain 'missing-net-list'"))
+					Expect(fakeCNILibrary.AddNetworkListCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the property is not a string", func() {
+				It("returns a meaningful error", func() {
+					metadata[cni.PropertyChain] = 42
+
+					_, err := controller.Up("/some/namespace/path", "some-handle", metadata, legacyNetConf)
+					Expect(err).To(MatchError("invalid property 'cni.chain': must be a string"))
+				})
+			})
+		})
+
+		Context("when recording the chain fails", func() {
+			It("returns a meaningful error", func() {
+				chains.SaveReturns(fmt.Errorf("patato"))
+
+				_, err := controller.Up("/some/namespace/path", "some-handle", metadata, legacyNetConf)
+				Expect(err).To(MatchError("recording cni chain: patato"))
+				Expect(fakeCNILibrary.AddNetworkListCallCount()).To(Equal(0))
+			})
+		})
+
 		Context("when the AddNetworkList returns an error", func() {
 			It("return a meaningful error", func() {
 				fakeCNILibrary.AddNetworkListReturns(nil, fmt.Errorf("patato"))
 
 				_, err := controller.Up("/some/namespace/path", "some-handle", metadata, legacyNetConf)
 				Expect(err).To(MatchError("add network list failed: patato"))
+				Expect(resultCache.SaveCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when caching the result fails", func() {
+			BeforeEach(func() {
+				resultCache.SaveReturns(fmt.Errorf("patato"))
+			})
+
+			It("deletes the network it added and returns a meaningful error", func() {
+				_, err := controller.Up("/some/namespace/path", "some-handle", metadata, legacyNetConf)
+				Expect(err).To(MatchError("caching result: patato"))
+
+				Expect(fakeCNILibrary.DelNetworkListCallCount()).To(Equal(1))
+				addNetc, addRunc := fakeCNILibrary.AddNetworkListArgsForCall(0)
+				delNetc, delRunc := fakeCNILibrary.DelNetworkListArgsForCall(0)
+				Expect(delNetc).To(Equal(addNetc))
+				Expect(delRunc).To(Equal(addRunc))
+			})
+
+			Context("when deleting the network fails too", func() {
+				It("returns both errors", func() {
+					fakeCNILibrary.DelNetworkListReturns(fmt.Errorf("potato"))
+
+					_, err := controller.Up("/some/namespace/path", "some-handle", metadata, legacyNetConf)
+					Expect(err).To(MatchError("caching result: patato (del network failed: potato)"))
+				})
 			})
 		})
 	})
@@ -139,12 +278,303 @@ var _ = Describe("CniController", func() {
 			Expect(netc.Plugins[0].Network.Type).To(Equal("some-plugin"))
 		})
 
+		It("removes the cached result", func() {
+			err := controller.Down("/some/namespace/path", "some-handle")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(resultCache.DeleteCallCount()).To(Equal(1))
+			Expect(resultCache.DeleteArgsForCall(0)).To(Equal("some-handle"))
+		})
+
+		It("removes the recorded chain", func() {
+			err := controller.Down("/some/namespace/path", "some-handle")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(chains.LoadArgsForCall(0)).To(Equal("some-handle"))
+			Expect(chains.DeleteCallCount()).To(Equal(1))
+			Expect(chains.DeleteArgsForCall(0)).To(Equal("some-handle"))
+		})
+
+		Context("when the container was brought up with another chain", func() {
+			It("deletes the container from that chain", func() {
+				chains.LoadReturns(otherConfig, nil)
+
+				err := controller.Down("/some/namespace/path", "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+
+				netc, _ := fakeCNILibrary.DelNetworkListArgsForCall(0)
+				Expect(netc.Name).To(Equal("other-net-list"))
+			})
+		})
+
+		Context("when the chain was removed since the container was brought up", func() {
+			It("deletes the container from the recorded copy of the chain", func() {
+				removedConfig := &libcni.NetworkConfigList{
+					Name:    "removed-net-list",
+					Plugins: otherConfig.Plugins,
+				}
+				chains.LoadReturns(removedConfig, nil)
+
+				err := controller.Down("/some/namespace/path", "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+
+				netc, _ := fakeCNILibrary.DelNetworkListArgsForCall(0)
+				Expect(netc).To(BeIdenticalTo(removedConfig))
+			})
+		})
+
+		Context("when loading the chain fails", func() {
+			It("returns a meaningful error", func() {
+				chains.LoadReturns(nil, fmt.Errorf("patato"))
+
+				err := controller.Down("/some/namespace/path", "some-handle")
+				Expect(err).To(MatchError("loading cni chain: patato"))
+				Expect(fakeCNILibrary.DelNetworkListCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when removing the chain fails", func() {
+			It("returns a meaningful error", func() {
+				chains.DeleteReturns(fmt.Errorf("patato"))
+
+				err := controller.Down("/some/namespace/path", "some-handle")
+				Expect(err).To(MatchError("removing cni chain: patato"))
+			})
+		})
+
 		Context("when the DelNetwork returns an error", func() {
 			It("return a meaningful error", func() {
 				fakeCNILibrary.DelNetworkListReturns(fmt.Errorf("patato"))
 
 				err := controller.Down("/some/namespace/path", "some-handle")
 				Expect(err).To(MatchError("del network failed: patato"))
+				Expect(resultCache.DeleteCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when removing the cached result fails", func() {
+			It("return a meaningful error", func() {
+				resultCache.DeleteReturns(fmt.Errorf("patato"))
+
+				err := controller.Down("/some/namespace/path", "some-handle")
+				Expect(err).To(MatchError("removing cached result: patato"))
+			})
+		})
+	})
+
+	Describe("Check", func() {
+		BeforeEach(func() {
+			resultCache.LoadReturns(map[string]interface{}{
+				"cniVersion": "some-version",
+				"ips":        []interface{}{map[string]interface{}{"address": "10.255.0.2/32"}},
+			}, nil)
+		})
+
+		It("checks the network list with the cached result as prevResult", func() {
+			err := controller.Check("/some/namespace/path", "some-handle")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(resultCache.LoadArgsForCall(0)).To(Equal("some-handle"))
+
+			Expect(checker.CheckNetworkListCallCount()).To(Equal(1))
+			netc, runc := checker.CheckNetworkListArgsForCall(0)
+			Expect(runc.ContainerID).To(Equal("some-handle"))
+			Expect(runc.NetNS).To(Equal("/some/namespace/path"))
+			Expect(runc.IfName).To(Equal("eth0"))
+			Expect(netc.Name).To(Equal("net-list-name"))
+			Expect(netc.CNIVersion).To(Equal("some-version"))
+			Expect(netc.Plugins).To(HaveLen(1))
+			Expect(netc.Plugins[0].Bytes).To(MatchJSON(`{
+				"cniVersion": "some-version",
+				"type": "some-plugin",
+				"prevResult": {
+					"cniVersion": "some-version",
+					"ips": [{"address": "10.255.0.2/32"}]
+				}
+			}`))
+		})
+
+		It("does not modify the shared network config list", func() {
+			err := controller.Check("/some/namespace/path", "some-handle")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(testConfig.Plugins[0].Bytes).To(MatchJSON(`{"cniVersion":"some-version", "type": "some-plugin"}`))
+		})
+
+		It("checks the chain the container was brought up with", func() {
+			chains.LoadReturns(otherConfig, nil)
+
+			err := controller.Check("/some/namespace/path", "some-handle")
+			Expect(err).NotTo(HaveOccurred())
+
+			netc, _ := checker.CheckNetworkListArgsForCall(0)
+			Expect(netc.Name).To(Equal("other-net-list"))
+		})
+
+		Context("when there is no cached result", func() {
+			It("return a meaningful error", func() {
+				resultCache.LoadReturns(nil, fmt.Errorf("patato"))
+
+				err := controller.Check("/some/namespace/path", "some-handle")
+				Expect(err).To(MatchError("loading cached result: patato"))
+				Expect(checker.CheckNetworkListCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the check fails", func() {
+			It("return a meaningful error", func() {
+				checker.CheckNetworkListReturns(fmt.Errorf("patato"))
+
+				err := controller.Check("/some/namespace/path", "some-handle")
+				Expect(err).To(MatchError("check network list failed: patato"))
+			})
+		})
+	})
+
+	Describe("Update", func() {
+		var (
+			metadata      map[string]interface{}
+			runtimeConfig map[string]interface{}
+		)
+
+		BeforeEach(func() {
+			resultCache.LoadReturns(map[string]interface{}{
+				"cniVersion": "some-version",
+				"ips":        []interface{}{map[string]interface{}{"address": "10.255.0.2/32"}},
+			}, nil)
+			metadata = map[string]interface{}{"policy_group_id": "some-group-id"}
+			runtimeConfig = map[string]interface{}{
+				"portMappings": []interface{}{
+					map[string]interface{}{"hostPort": 61000, "containerPort": 8080, "protocol": "tcp"},
+				},
+			}
+		})
+
+		It("deletes the network and adds it again with the new runtime config", func() {
+			result, err := controller.Update("/some/namespace/path", "some-handle", metadata, runtimeConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(expectedResult))
+
+			Expect(fakeCNILibrary.DelNetworkListCallCount()).To(Equal(1))
+			netc, runc := fakeCNILibrary.DelNetworkListArgsForCall(0)
+			Expect(netc).To(Equal(testConfig))
+			Expect(runc.ContainerID).To(Equal("some-handle"))
+			Expect(runc.NetNS).To(Equal("/some/namespace/path"))
+			Expect(runc.IfName).To(Equal("eth0"))
+
+			Expect(fakeCNILibrary.AddNetworkListCallCount()).To(Equal(1))
+			netc, runc = fakeCNILibrary.AddNetworkListArgsForCall(0)
+			Expect(runc.ContainerID).To(Equal("some-handle"))
+			Expect(runc.NetNS).To(Equal("/some/namespace/path"))
+			Expect(runc.IfName).To(Equal("eth0"))
+			Expect(netc.Name).To(Equal("net-list-name"))
+			Expect(netc.Plugins).To(HaveLen(1))
+			Expect(netc.Plugins[0].Bytes).To(MatchJSON(`{
+				"cniVersion": "some-version",
+				"type": "some-plugin",
+				"metadata": {"policy_group_id": "some-group-id"},
+				"runtimeConfig": {
+					"portMappings": [{"hostPort": 61000, "containerPort": 8080, "protocol": "tcp"}]
+				}
+			}`))
+		})
+
+		It("asks for the address the container had", func() {
+			_, err := controller.Update("/some/namespace/path", "some-handle", metadata, runtimeConfig)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(resultCache.LoadArgsForCall(0)).To(Equal("some-handle"))
+			_, runc := fakeCNILibrary.AddNetworkListArgsForCall(0)
+			Expect(runc.Args).To(Equal([][2]string{{"IgnoreUnknown", "1"}, {"IP", "10.255.0.2"}}))
+		})
+
+		It("caches the new result", func() {
+			_, err := controller.Update("/some/namespace/path", "some-handle", metadata, runtimeConfig)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(resultCache.SaveCallCount()).To(Equal(1))
+			handle, result := resultCache.SaveArgsForCall(0)
+			Expect(handle).To(Equal("some-handle"))
+			Expect(result).To(Equal(expectedResult))
+		})
+
+		It("does not modify the shared network config list", func() {
+			_, err := controller.Update("/some/namespace/path", "some-handle", metadata, runtimeConfig)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(testConfig.Plugins[0].Bytes).To(MatchJSON(`{"cniVersion":"some-version", "type": "some-plugin"}`))
+		})
+
+		It("updates the chain the container was brought up with", func() {
+			chains.LoadReturns(otherConfig, nil)
+
+			_, err := controller.Update("/some/namespace/path", "some-handle", metadata, runtimeConfig)
+			Expect(err).NotTo(HaveOccurred())
+
+			netc, _ := fakeCNILibrary.AddNetworkListArgsForCall(0)
+			Expect(netc.Name).To(Equal("other-net-list"))
+		})
+
+		Context("when the recorded chain was removed since", func() {
+			It("updates the recorded copy of the chain", func() {
+				chains.LoadReturns(&libcni.NetworkConfigList{
+					Name:    "removed-net-list",
+					Plugins: otherConfig.Plugins,
+				}, nil)
+
+				_, err := controller.Update("/some/namespace/path", "some-handle", metadata, runtimeConfig)
+				Expect(err).NotTo(HaveOccurred())
+
+				netc, _ := fakeCNILibrary.AddNetworkListArgsForCall(0)
+				Expect(netc.Name).To(Equal("removed-net-list"))
+				Expect(netc.Plugins[0].Network.Type).To(Equal("other-plugin"))
+			})
+		})
+
+		Context("when there is no cached result", func() {
+			It("adds the network without asking for an address", func() {
+				resultCache.LoadReturns(nil, fmt.Errorf("patato"))
+
+				_, err := controller.Update("/some/namespace/path", "some-handle", metadata, runtimeConfig)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, runc := fakeCNILibrary.AddNetworkListArgsForCall(0)
+				Expect(runc.Args).To(BeEmpty())
+			})
+		})
+
+		Context("when the delete fails", func() {
+			It("returns a meaningful error without adding the network", func() {
+				fakeCNILibrary.DelNetworkListReturns(fmt.Errorf("patato"))
+
+				_, err := controller.Update("/some/namespace/path", "some-handle", metadata, runtimeConfig)
+				Expect(err).To(MatchError("del network failed: patato"))
+				Expect(fakeCNILibrary.AddNetworkListCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the add fails", func() {
+			It("cleans up after the plugins and returns a meaningful error", func() {
+				fakeCNILibrary.AddNetworkListReturns(nil, fmt.Errorf("patato"))
+
+				_, err := controller.Update("/some/namespace/path", "some-handle", metadata, runtimeConfig)
+				Expect(err).To(MatchError("add network list failed: patato"))
+
+				Expect(fakeCNILibrary.DelNetworkListCallCount()).To(Equal(2))
+				addNetc, addRunc := fakeCNILibrary.AddNetworkListArgsForCall(0)
+				delNetc, delRunc := fakeCNILibrary.DelNetworkListArgsForCall(1)
+				Expect(delNetc).To(Equal(addNetc))
+				Expect(delRunc).To(Equal(addRunc))
+				Expect(resultCache.SaveCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when caching the result fails", func() {
+			It("returns a meaningful error", func() {
+				resultCache.SaveReturns(fmt.Errorf("patato"))
+
+				_, err := controller.Update("/some/namespace/path", "some-handle", metadata, runtimeConfig)
+				Expect(err).To(MatchError("caching result: patato"))
 			})
 		})
 	})
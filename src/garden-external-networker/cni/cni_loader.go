@@ -6,23 +6,131 @@ import (
 	"path/filepath"
 	"strings"
 
+	"code.cloudfoundry.org/lager"
 	"github.com/containernetworking/cni/libcni"
-	"io"
-	"time"
 )
 
 type CNILoader struct {
 	PluginDir string
 	ConfigDir string
-	Logger io.Writer
+	Logger    lager.Logger
 }
 
 func (l *CNILoader) GetCNIConfig() *libcni.CNIConfig {
 	return &libcni.CNIConfig{Path: []string{l.PluginDir}}
 }
 
-func (l *CNILoader) GetNetworkConfig() (*libcni.NetworkConfigList, error) {
+// Load loads and validates every chain in ConfigDir, along with the default
+// chain: defaultChain, or the first conflist (or conf) when that is empty.
+// Chains that are invalid, or named like a chain loaded before them, are
+// logged and skipped; only a broken default chain fails the load. The default
+// is nil when ConfigDir holds no config at all.
+func (l *CNILoader) Load(defaultChain string) (*libcni.NetworkConfigList, map[string]*libcni.NetworkConfigList, error) {
+	confFilePaths, confListFilePaths, err := l.configFilePaths()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	networkConfigLists := map[string]*libcni.NetworkConfigList{}
+	skipped := map[string]error{}
+	first := ""
+	for _, path := range append(confListFilePaths, confFilePaths...) {
+		confList, err := confListFromFile(path)
+		if err == nil {
+			err = l.validate(confList)
+		}
+		if err != nil {
+			if defaultChain == "" && first == "" {
+				return nil, nil, fmt.Errorf("default chain: %s", err)
+			}
+			if confList != nil {
+				skipped[confList.Name] = err
+			}
+			l.logSkipped(path, err)
+			continue
+		}
+
+		if _, ok := networkConfigLists[confList.Name]; ok {
+			l.logSkipped(path, fmt.Errorf("duplicate network name '%s' in %s", confList.Name, path))
+			continue
+		}
 
+		networkConfigLists[confList.Name] = confList
+		if first == "" {
+			first = confList.Name
+		}
+	}
+
+	if defaultChain == "" {
+		defaultChain = first
+	}
+	if defaultChain == "" {
+		return nil, networkConfigLists, nil
+	}
+
+	networkConfigList, ok := networkConfigLists[defaultChain]
+	if !ok {
+		if err, ok := skipped[defaultChain]; ok {
+			return nil, nil, fmt.Errorf("default chain: %s", err)
+		}
+		return nil, nil, fmt.Errorf("default chain '%s' not found in %s", defaultChain, l.ConfigDir)
+	}
+
+	return networkConfigList, networkConfigLists, nil
+}
+
+func (l *CNILoader) logSkipped(path string, err error) {
+	l.Logger.Error("skipping-cni-config", err, lager.Data{"file": path})
+}
+
+// validate catches the mistakes that libcni only reports when a plugin is
+// run: nameless networks, plugins without a type and missing plugin binaries.
+func (l *CNILoader) validate(networkConfigList *libcni.NetworkConfigList) error {
+	if networkConfigList.Name == "" {
+		return fmt.Errorf("invalid cni config: network without a name")
+	}
+
+	for i, networkConfig := range networkConfigList.Plugins {
+		pluginType := networkConfig.Network.Type
+		if pluginType == "" {
+			return fmt.Errorf("invalid cni config '%s': plugin %d has no type", networkConfigList.Name, i)
+		}
+
+		if _, err := os.Stat(filepath.Join(l.PluginDir, pluginType)); err != nil {
+			return fmt.Errorf("invalid cni config '%s': plugin '%s' not found in %s", networkConfigList.Name, pluginType, l.PluginDir)
+		}
+	}
+
+	return nil
+}
+
+func confListFromFile(path string) (*libcni.NetworkConfigList, error) {
+	if strings.HasSuffix(path, ".conflist") {
+		confList, err := libcni.ConfListFromFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load config from %s: %s", path, err)
+		}
+		return confList, nil
+	}
+	return confListFromConfFile(path)
+}
+
+func confListFromConfFile(path string) (*libcni.NetworkConfigList, error) {
+	conf, err := libcni.ConfFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load config from %s: %s", path, err)
+	}
+
+	confList, err := libcni.ConfListFromConf(conf)
+	if err != nil {
+		// untested, unable to cause failure case.
+		return nil, fmt.Errorf("unable to upconvert from conf to conf list %s: %s", path, err)
+	}
+
+	return confList, nil
+}
+
+func (l *CNILoader) configFilePaths() ([]string, []string, error) {
 	var (
 		confFilePaths     []string
 		confListFilePaths []string
@@ -47,40 +155,8 @@ func (l *CNILoader) GetNetworkConfig() (*libcni.NetworkConfigList, error) {
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("error loading config: %s", err)
-	}
-
-	var toReturn *libcni.NetworkConfigList
-
-	if len(confListFilePaths) > 0 {
-		path := confListFilePaths[0]
-		confList, err := libcni.ConfListFromFile(path)
-		if err != nil {
-			return confList, fmt.Errorf("unable to load config from %s: %s", path, err)
-		}
-
-		toReturn = confList
-	} else if len(confFilePaths) > 0 {
-		path := confFilePaths[0]
-		conf, err := libcni.ConfFromFile(path)
-		if err != nil {
-			return nil, fmt.Errorf("unable to load config from %s: %s", path, err)
-		}
-
-		confList, err := libcni.ConfListFromConf(conf)
-		if err != nil {
-			// untested, unable to cause failure case.
-			return nil, fmt.Errorf("unable to upconvert from conf to conf list %s: %s", path, err)
-		}
-
-		toReturn = confList
-	}
-
-	if (len(confListFilePaths) + len(confFilePaths)) > 1 {
-		fmt.Fprintf(l.Logger, `%s - Only one CNI config file or conflist (chain) will be executed. 
-							If a conf and conflist file are both present, then the conflist will be executed. 
-							If multiple CNI config files are present, behavior is undefined.`, time.Now().Format(time.RFC3339))
+		return nil, nil, fmt.Errorf("error loading config: %s", err)
 	}
 
-	return toReturn, nil
+	return confFilePaths, confListFilePaths, nil
 }
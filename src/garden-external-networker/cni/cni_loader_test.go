@@ -1,130 +1,189 @@
 package cni_test
 
 import (
-	"bytes"
+	"fmt"
 	"garden-external-networker/cni"
 	"io/ioutil"
 	"path/filepath"
+	"regexp"
 
+	"code.cloudfoundry.org/lager/lagertest"
 	"github.com/containernetworking/cni/pkg/types"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 )
 
-var _ = Describe("GetNetworkConfig", func() {
+var _ = Describe("Load", func() {
 	var (
-		cniLoader             *cni.CNILoader
-		dir                   string
-		err                   error
-		expectedBridgeNetwork *types.NetConf
-		expectedVxlanNetwork  *types.NetConf
-		logger                *bytes.Buffer
+		cniLoader *cni.CNILoader
+		dir       string
+		logger    *lagertest.TestLogger
 	)
 
 	BeforeEach(func() {
+		var err error
 		dir, err = ioutil.TempDir("", "test-cni-dir")
 		Expect(err).NotTo(HaveOccurred())
-		logger = &bytes.Buffer{}
+		logger = lagertest.NewTestLogger("test")
+
+		pluginDir, err := ioutil.TempDir("", "test-cni-plugin-dir")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.WriteFile(filepath.Join(pluginDir, "bridge"), []byte{}, 0700)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(pluginDir, "vxlan"), []byte{}, 0700)).To(Succeed())
 
 		cniLoader = &cni.CNILoader{
-			PluginDir: "",
+			PluginDir: pluginDir,
 			ConfigDir: dir,
 			Logger:    logger,
 		}
 
-		expectedBridgeNetwork = &types.NetConf{
-			Name: "mynet",
-			Type: "bridge",
-		}
-		expectedVxlanNetwork = &types.NetConf{
-			Name: "mynet2",
-			Type: "vxlan",
-		}
+		Expect(ioutil.WriteFile(filepath.Join(dir, "aaa.conf"), []byte(`{ "name": "mynet", "type": "bridge" }`), 0600)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "bbb.conflist"), []byte(`{
+			"name": "mynetlist",
+			"plugins": [
+				{ "name": "mynet2", "type": "vxlan" },
+				{ "name": "mynet", "type": "bridge" }
+			]
+		}`), 0600)).To(Succeed())
 	})
 
-	Context("when the config dir does not exist", func() {
-		BeforeEach(func() {
-			cniLoader.ConfigDir = "/thisdoesnot/exist"
-		})
+	It("loads every config and config list by network name", func() {
+		_, netListCfgs, err := cniLoader.Load("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(netListCfgs).To(HaveLen(2))
+
+		Expect(netListCfgs["mynet"].Plugins).To(HaveLen(1))
+		Expect(*netListCfgs["mynet"].Plugins[0].Network).To(Equal(types.NetConf{Name: "mynet", Type: "bridge"}))
+
+		Expect(netListCfgs["mynetlist"].Plugins).To(HaveLen(2))
+		Expect(*netListCfgs["mynetlist"].Plugins[0].Network).To(Equal(types.NetConf{Name: "mynet2", Type: "vxlan"}))
+		Expect(*netListCfgs["mynetlist"].Plugins[1].Network).To(Equal(types.NetConf{Name: "mynet", Type: "bridge"}))
+	})
+
+	It("returns the first sorted conflist as the default", func() {
+		Expect(ioutil.WriteFile(filepath.Join(dir, "ccc.conflist"), []byte(`{ "name": "otherlist", "plugins": [{ "type": "bridge" }] }`), 0600)).To(Succeed())
+
+		defaultList, _, err := cniLoader.Load("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(defaultList.Name).To(Equal("mynetlist"))
+	})
+
+	It("returns the first sorted conf as the default when there is no conflist", func() {
+		cniLoader.ConfigDir, _ = ioutil.TempDir("", "test-cni-dir")
+		Expect(ioutil.WriteFile(filepath.Join(cniLoader.ConfigDir, "bbb.conf"), []byte(`{ "name": "othernet", "type": "vxlan" }`), 0600)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(cniLoader.ConfigDir, "aaa.conf"), []byte(`{ "name": "mynet", "type": "bridge" }`), 0600)).To(Succeed())
+
+		defaultList, netListCfgs, err := cniLoader.Load("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(defaultList.Name).To(Equal("mynet"))
+		Expect(netListCfgs).To(HaveLen(2))
+	})
+
+	It("uses the given default chain", func() {
+		defaultList, _, err := cniLoader.Load("mynet")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(defaultList.Name).To(Equal("mynet"))
+	})
 
+	Context("when the default chain does not exist", func() {
 		It("returns a meaningful error", func() {
-			_, err := cniLoader.GetNetworkConfig()
-			Expect(err).To(MatchError(HavePrefix("error loading config:")))
+			_, _, err := cniLoader.Load("missing")
+			Expect(err).To(MatchError(fmt.Sprintf("default chain 'missing' not found in %s", dir)))
 		})
 	})
 
-	Context("when no config files exist in dir", func() {
-		It("does not load any netconfig", func() {
-			netListCfgs, err := cniLoader.GetNetworkConfig()
+	Context("when there is no config", func() {
+		It("returns no default chain", func() {
+			cniLoader.ConfigDir, _ = ioutil.TempDir("", "test-cni-dir")
+
+			defaultList, netListCfgs, err := cniLoader.Load("")
 			Expect(err).NotTo(HaveOccurred())
-			Expect(netListCfgs).To(BeNil())
+			Expect(defaultList).To(BeNil())
+			Expect(netListCfgs).To(BeEmpty())
 		})
 	})
 
-	Context("when a valid config file exists", func() {
-		BeforeEach(func() {
-			err = ioutil.WriteFile(filepath.Join(dir, "foo.conf"), []byte(`{ "name": "mynet", "type": "bridge" }`), 0600)
-			Expect(err).NotTo(HaveOccurred())
+	Context("when the config dir does not exist", func() {
+		It("returns a meaningful error", func() {
+			cniLoader.ConfigDir = "/thisdoesnot/exist"
+			_, _, err := cniLoader.Load("")
+			Expect(err).To(MatchError(HavePrefix("error loading config:")))
 		})
+	})
+
+	Context("when two files have the same network name", func() {
+		It("keeps the first and logs the other one", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "ccc.conflist"), []byte(`{ "name": "mynet", "plugins": [{ "type": "vxlan" }] }`), 0600)).To(Succeed())
 
-		It("loads a single network config", func() {
-			netListCfg, err := cniLoader.GetNetworkConfig()
+			_, netListCfgs, err := cniLoader.Load("")
 			Expect(err).NotTo(HaveOccurred())
-			Expect(netListCfg.Name).To(Equal("mynet"))
-			Expect(netListCfg.Plugins).To(HaveLen(1))
-			Expect(*netListCfg.Plugins[0].Network).To(Equal(*expectedBridgeNetwork))
+			Expect(netListCfgs["mynet"].Plugins[0].Network.Type).To(Equal("vxlan"))
+			aaaConf := regexp.QuoteMeta(filepath.Join(dir, "aaa.conf"))
+			Expect(logger).To(gbytes.Say(`"message":"test.skipping-cni-config".*"error":"duplicate network name 'mynet' in %s","file":"%s"`, aaaConf, aaaConf))
 		})
 	})
 
-	Context("when a valid config list files exists", func() {
-		BeforeEach(func() {
-			err = ioutil.WriteFile(filepath.Join(dir, "foo.conflist"), []byte(`{
-				"name": "mynetlist",
-				"plugins": [
-			    { "name": "mynet2", "type": "vxlan" },
-			    { "name" : "mynet", "type" : "bridge" }
-			  ]
-			}`), 0600)
+	Context("when a file cannot be parsed", func() {
+		It("skips it and logs the error", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "ccc.conflist"), []byte(`not json`), 0600)).To(Succeed())
+
+			_, netListCfgs, err := cniLoader.Load("")
 			Expect(err).NotTo(HaveOccurred())
+			Expect(netListCfgs).To(HaveLen(2))
+			cccConflist := regexp.QuoteMeta(filepath.Join(dir, "ccc.conflist"))
+			Expect(logger).To(gbytes.Say(`"message":"test.skipping-cni-config".*"error":"unable to load config from %s.*","file":"%s"`, cccConflist, cccConflist))
 		})
+	})
 
-		It("loads all network configs", func() {
-			netListCfg, err := cniLoader.GetNetworkConfig()
+	Context("when a plugin has no type", func() {
+		It("skips the chain and logs the error", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "ccc.conf"), []byte(`{ "name": "typeless" }`), 0600)).To(Succeed())
+
+			_, netListCfgs, err := cniLoader.Load("")
 			Expect(err).NotTo(HaveOccurred())
-			Expect(netListCfg.Name).To(Equal("mynetlist"))
-			Expect(netListCfg.Plugins).To(HaveLen(2))
-			Expect(*netListCfg.Plugins[0].Network).To(Equal(*expectedVxlanNetwork))
-			Expect(*netListCfg.Plugins[1].Network).To(Equal(*expectedBridgeNetwork))
+			Expect(netListCfgs).NotTo(HaveKey("typeless"))
+			Expect(logger).To(gbytes.Say(`"message":"test.skipping-cni-config".*"error":"invalid cni config 'typeless': plugin 0 has no type","file":".*ccc.conf"`))
 		})
 	})
 
-	Context("when multiple valid config and config list files exists", func() {
-		BeforeEach(func() {
-			err = ioutil.WriteFile(filepath.Join(dir, "aaa.conf"), []byte(`{ "name": "mynet", "type": "bridge" }`), 0600)
-			Expect(err).NotTo(HaveOccurred())
+	Context("when a plugin binary is missing", func() {
+		It("skips the chain and logs the error", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "ccc.conf"), []byte(`{ "name": "typo", "type": "brigde" }`), 0600)).To(Succeed())
 
-			err = ioutil.WriteFile(filepath.Join(dir, "zzz.conf"), []byte(`{ "name": "barnet", "type": "dummy" }`), 0600)
+			_, netListCfgs, err := cniLoader.Load("")
 			Expect(err).NotTo(HaveOccurred())
+			Expect(netListCfgs).NotTo(HaveKey("typo"))
+			Expect(logger).To(gbytes.Say(`"message":"test.skipping-cni-config".*"error":"invalid cni config 'typo': plugin 'brigde' not found in %s"`, regexp.QuoteMeta(cniLoader.PluginDir)))
+		})
+	})
 
-			err = ioutil.WriteFile(filepath.Join(dir, "ccc.conflist"), []byte(`{ "name": "nopelist", "plugins": [{ "name": "badnet", "type": "bridge" }] }`), 0600)
-			Expect(err).NotTo(HaveOccurred())
+	Context("when a network has no name", func() {
+		It("skips the chain and logs the error", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "ccc.conf"), []byte(`{ "type": "bridge" }`), 0600)).To(Succeed())
 
-			err = ioutil.WriteFile(filepath.Join(dir, "bbb.conflist"), []byte(`{ "name": "mynetlist", "plugins": [{ "name": "mynet2", "type": "vxlan" }] }`), 0600)
+			_, netListCfgs, err := cniLoader.Load("")
 			Expect(err).NotTo(HaveOccurred())
+			Expect(netListCfgs).To(HaveLen(2))
+			Expect(logger).To(gbytes.Say(`"message":"test.skipping-cni-config".*"error":"invalid cni config: network without a name","file":".*ccc.conf"`))
 		})
+	})
 
-		It("prefers the first sorted conflist over other lists or conf files", func() {
-			netListCfg, err := cniLoader.GetNetworkConfig()
-			Expect(err).NotTo(HaveOccurred())
-			Expect(netListCfg.Name).To(Equal("mynetlist"))
-			Expect(netListCfg.Plugins).To(HaveLen(1))
-			Expect(*netListCfg.Plugins[0].Network).To(Equal(*expectedVxlanNetwork))
+	Context("when the default chain is invalid", func() {
+		It("returns a meaningful error", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "ccc.conf"), []byte(`{ "name": "typo", "type": "brigde" }`), 0600)).To(Succeed())
+
+			_, _, err := cniLoader.Load("typo")
+			Expect(err).To(MatchError(fmt.Sprintf("default chain: invalid cni config 'typo': plugin 'brigde' not found in %s", cniLoader.PluginDir)))
 		})
 
-		It("logs warning for the skipped files", func() {
-			_, err := cniLoader.GetNetworkConfig()
-			Expect(err).NotTo(HaveOccurred())
-			Expect(logger.String()).To(ContainSubstring("Only one CNI config file or conflist (chain) will be executed"))
+		Context("when the default chain is the first conflist", func() {
+			It("returns a meaningful error", func() {
+				Expect(ioutil.WriteFile(filepath.Join(dir, "aaa.conflist"), []byte(`not json`), 0600)).To(Succeed())
+
+				_, _, err := cniLoader.Load("")
+				Expect(err).To(MatchError(HavePrefix("default chain: unable to load config from " + filepath.Join(dir, "aaa.conflist"))))
+			})
 		})
 	})
 })
@@ -0,0 +1,98 @@
+package cni
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/containernetworking/cni/libcni"
+)
+
+//go:generate counterfeiter -o ../fakes/config_loader.go --fake-name ConfigLoader . configLoader
+type configLoader interface {
+	Load(defaultChain string) (*libcni.NetworkConfigList, map[string]*libcni.NetworkConfigList, error)
+}
+
+//go:generate counterfeiter -o ../fakes/network_configs_setter.go --fake-name NetworkConfigsSetter . networkConfigsSetter
+type networkConfigsSetter interface {
+	SetNetworkConfigs(networkConfigList *libcni.NetworkConfigList, networkConfigLists map[string]*libcni.NetworkConfigList)
+}
+
+// ConfigReloader reloads the CNI config dir every Interval and hands the
+// chains to the controller when they change. When the default chain fails to
+// load, the error is logged and the chains already in use are kept.
+type ConfigReloader struct {
+	Loader       configLoader
+	Controller   networkConfigsSetter
+	DefaultChain string
+	Interval     time.Duration
+	Logger       lager.Logger
+
+	fingerprint string
+	lastError   string
+}
+
+func (r *ConfigReloader) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	logger := r.Logger.Session("cni-config-reloader")
+	r.reload(logger)
+	close(ready)
+
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-signals:
+			return nil
+		case <-ticker.C:
+			r.reload(logger)
+		}
+	}
+}
+
+func (r *ConfigReloader) reload(logger lager.Logger) {
+	networkConfigList, networkConfigLists, err := r.Loader.Load(r.DefaultChain)
+	if err != nil {
+		// logged once, not on every tick until the config is fixed
+		if err.Error() != r.lastError {
+			logger.Error("load-failed", err)
+			r.lastError = err.Error()
+		}
+		return
+	}
+	r.lastError = ""
+
+	fingerprint := fingerprint(networkConfigList, networkConfigLists)
+	if fingerprint == r.fingerprint {
+		return
+	}
+
+	r.Controller.SetNetworkConfigs(networkConfigList, networkConfigLists)
+	if r.fingerprint != "" {
+		logger.Info("reloaded", lager.Data{"chains": chainNames(networkConfigLists)})
+	}
+	r.fingerprint = fingerprint
+}
+
+func fingerprint(networkConfigList *libcni.NetworkConfigList, networkConfigLists map[string]*libcni.NetworkConfigList) string {
+	parts := []string{}
+	if networkConfigList != nil {
+		parts = append(parts, networkConfigList.Name)
+	}
+	for _, name := range chainNames(networkConfigLists) {
+		parts = append(parts, name, string(networkConfigLists[name].Bytes))
+	}
+	// never empty, so that the first load always counts as a change
+	return "default:" + strings.Join(parts, "\x00")
+}
+
+func chainNames(networkConfigLists map[string]*libcni.NetworkConfigList) []string {
+	names := []string{}
+	for name := range networkConfigLists {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,118 @@
+package cni_test
+
+import (
+	"errors"
+	"garden-external-networker/cni"
+	"garden-external-networker/fakes"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/containernetworking/cni/libcni"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("ConfigReloader", func() {
+	var (
+		loader     *fakes.ConfigLoader
+		controller *fakes.NetworkConfigsSetter
+		logger     *lagertest.TestLogger
+		reloader   *cni.ConfigReloader
+		process    ifrit.Process
+
+		firstList  *libcni.NetworkConfigList
+		secondList *libcni.NetworkConfigList
+
+		mutex      sync.Mutex
+		loadResult *libcni.NetworkConfigList
+		loadErr    error
+	)
+
+	loadReturns := func(networkConfigList *libcni.NetworkConfigList, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		loadResult = networkConfigList
+		loadErr = err
+	}
+
+	BeforeEach(func() {
+		loader = &fakes.ConfigLoader{}
+		controller = &fakes.NetworkConfigsSetter{}
+		logger = lagertest.NewTestLogger("test")
+
+		firstList = &libcni.NetworkConfigList{Name: "some-net", Bytes: []byte(`{"name": "some-net"}`)}
+		secondList = &libcni.NetworkConfigList{Name: "some-net", Bytes: []byte(`{"name": "some-net", "cniVersion": "0.4.0"}`)}
+		loadReturns(firstList, nil)
+		loader.LoadStub = func(string) (*libcni.NetworkConfigList, map[string]*libcni.NetworkConfigList, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if loadErr != nil {
+				return nil, nil, loadErr
+			}
+			return loadResult, map[string]*libcni.NetworkConfigList{loadResult.Name: loadResult}, nil
+		}
+
+		reloader = &cni.ConfigReloader{
+			Loader:       loader,
+			Controller:   controller,
+			DefaultChain: "some-net",
+			Interval:     10 * time.Millisecond,
+			Logger:       logger,
+		}
+	})
+
+	JustBeforeEach(func() {
+		process = ifrit.Invoke(reloader)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+	})
+
+	It("loads the config with the default chain when it starts", func() {
+		Expect(loader.LoadArgsForCall(0)).To(Equal("some-net"))
+		Expect(controller.SetNetworkConfigsCallCount()).To(Equal(1))
+
+		networkConfigList, networkConfigLists := controller.SetNetworkConfigsArgsForCall(0)
+		Expect(networkConfigList).To(Equal(firstList))
+		Expect(networkConfigLists).To(HaveKeyWithValue("some-net", firstList))
+	})
+
+	It("does not replace the config while it is unchanged", func() {
+		Eventually(loader.LoadCallCount).Should(BeNumerically(">", 3))
+		Expect(controller.SetNetworkConfigsCallCount()).To(Equal(1))
+	})
+
+	Context("when the config changes", func() {
+		It("replaces the config and logs the chains", func() {
+			loadReturns(secondList, nil)
+
+			Eventually(controller.SetNetworkConfigsCallCount).Should(Equal(2))
+			networkConfigList, _ := controller.SetNetworkConfigsArgsForCall(1)
+			Expect(networkConfigList).To(Equal(secondList))
+			Expect(logger).To(gbytes.Say(`"message":"test.cni-config-reloader.reloaded".*"chains":\["some-net"\]`))
+		})
+	})
+
+	Context("when the config fails to load", func() {
+		It("keeps the current config and logs the error once", func() {
+			loadReturns(nil, errors.New("invalid cni config 'some-net': plugin 'bridge' not found in /plugins"))
+
+			Eventually(loader.LoadCallCount).Should(BeNumerically(">", 3))
+			Expect(controller.SetNetworkConfigsCallCount()).To(Equal(1))
+
+			logs := 0
+			for _, message := range logger.LogMessages() {
+				if message == "test.cni-config-reloader.load-failed" {
+					logs++
+				}
+			}
+			Expect(logs).To(Equal(1))
+		})
+	})
+})
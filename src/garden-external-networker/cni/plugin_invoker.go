@@ -0,0 +1,56 @@
+package cni
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/invoke"
+)
+
+// PluginInvoker runs the plugins of a network configuration list for CHECK,
+// which the vendored libcni does not support.
+type PluginInvoker struct {
+	PluginDirs []string
+}
+
+func (p *PluginInvoker) CheckNetworkList(list *libcni.NetworkConfigList, rt *libcni.RuntimeConf) error {
+	if !supportsCheck(list.CNIVersion) {
+		return fmt.Errorf("configuration version %q does not support the CHECK command", list.CNIVersion)
+	}
+
+	return p.execNetworkList("CHECK", list, rt)
+}
+
+func (p *PluginInvoker) execNetworkList(command string, list *libcni.NetworkConfigList, rt *libcni.RuntimeConf) error {
+	for _, net := range list.Plugins {
+		pluginPath, err := invoke.FindInPath(net.Network.Type, p.PluginDirs)
+		if err != nil {
+			return err
+		}
+
+		args := &invoke.Args{
+			Command:     command,
+			ContainerID: rt.ContainerID,
+			NetNS:       rt.NetNS,
+			PluginArgs:  rt.Args,
+			IfName:      rt.IfName,
+			Path:        strings.Join(p.PluginDirs, string(os.PathListSeparator)),
+		}
+
+		if err := invoke.ExecPluginWithoutResult(pluginPath, net.Bytes, args); err != nil {
+			return fmt.Errorf("plugin %s: %s", net.Network.Type, err)
+		}
+	}
+
+	return nil
+}
+
+func supportsCheck(cniVersion string) bool {
+	var major, minor int
+	if _, err := fmt.Sscanf(cniVersion, "%d.%d", &major, &minor); err != nil {
+		return false
+	}
+	return major > 0 || minor >= 4
+}
@@ -0,0 +1,108 @@
+package cni_test
+
+import (
+	"fmt"
+	"garden-external-networker/cni"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PluginInvoker", func() {
+	var (
+		pluginDir     string
+		invoker       *cni.PluginInvoker
+		configList    *libcni.NetworkConfigList
+		runtimeConfig *libcni.RuntimeConf
+	)
+
+	writePlugin := func(name, script string) {
+		path := filepath.Join(pluginDir, name)
+		Expect(ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script), 0700)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		pluginDir, err = ioutil.TempDir("", "cni-plugins-")
+		Expect(err).NotTo(HaveOccurred())
+
+		writePlugin("plugin-a", fmt.Sprintf(`env > %s/plugin-a.env; cat > %s/plugin-a.stdin`, pluginDir, pluginDir))
+		writePlugin("plugin-b", fmt.Sprintf(`env > %s/plugin-b.env`, pluginDir))
+
+		invoker = &cni.PluginInvoker{PluginDirs: []string{pluginDir}}
+		configList = &libcni.NetworkConfigList{
+			Name:       "some-net",
+			CNIVersion: "0.4.0",
+			Plugins: []*libcni.NetworkConfig{
+				{Network: &types.NetConf{Type: "plugin-a"}, Bytes: []byte(`{"cniVersion": "0.4.0", "type": "plugin-a"}`)},
+				{Network: &types.NetConf{Type: "plugin-b"}, Bytes: []byte(`{"cniVersion": "0.4.0", "type": "plugin-b"}`)},
+			},
+		}
+		runtimeConfig = &libcni.RuntimeConf{
+			ContainerID: "some-handle",
+			NetNS:       "/some/namespace/path",
+			IfName:      "eth0",
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(pluginDir)).To(Succeed())
+	})
+
+	Describe("CheckNetworkList", func() {
+		It("runs CHECK on every plugin in the list", func() {
+			Expect(invoker.CheckNetworkList(configList, runtimeConfig)).To(Succeed())
+
+			for _, plugin := range []string{"plugin-a", "plugin-b"} {
+				env, err := ioutil.ReadFile(filepath.Join(pluginDir, plugin+".env"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(env)).To(ContainSubstring("CNI_COMMAND=CHECK"))
+				Expect(string(env)).To(ContainSubstring("CNI_CONTAINERID=some-handle"))
+				Expect(string(env)).To(ContainSubstring("CNI_NETNS=/some/namespace/path"))
+				Expect(string(env)).To(ContainSubstring("CNI_IFNAME=eth0"))
+			}
+
+			Expect(ioutil.ReadFile(filepath.Join(pluginDir, "plugin-a.stdin"))).To(MatchJSON(`{"cniVersion": "0.4.0", "type": "plugin-a"}`))
+		})
+
+		Context("when a plugin reports an error", func() {
+			BeforeEach(func() {
+				writePlugin("plugin-a", `echo '{"code": 100, "msg": "interface eth0 is missing"}'; exit 1`)
+			})
+
+			It("returns the error without checking the remaining plugins", func() {
+				err := invoker.CheckNetworkList(configList, runtimeConfig)
+				Expect(err).To(MatchError(ContainSubstring("plugin plugin-a: interface eth0 is missing")))
+				Expect(filepath.Join(pluginDir, "plugin-b.env")).NotTo(BeAnExistingFile())
+			})
+		})
+
+		Context("when a plugin cannot be found", func() {
+			BeforeEach(func() {
+				configList.Plugins[1].Network.Type = "plugin-c"
+			})
+
+			It("returns an error", func() {
+				err := invoker.CheckNetworkList(configList, runtimeConfig)
+				Expect(err).To(MatchError(ContainSubstring("plugin-c")))
+			})
+		})
+
+		Context("when the configuration version does not support CHECK", func() {
+			BeforeEach(func() {
+				configList.CNIVersion = "0.3.1"
+			})
+
+			It("returns an error without running the plugins", func() {
+				err := invoker.CheckNetworkList(configList, runtimeConfig)
+				Expect(err).To(MatchError(`configuration version "0.3.1" does not support the CHECK command`))
+				Expect(filepath.Join(pluginDir, "plugin-a.env")).NotTo(BeAnExistingFile())
+			})
+		})
+	})
+})
@@ -0,0 +1,75 @@
+package cni
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/pkg/types"
+)
+
+// ResultCache keeps the result of CNI ADD for each container, so that it can
+// be passed to the plugins as prevResult on CHECK.
+type ResultCache struct {
+	Dir string
+}
+
+func (r *ResultCache) Save(handle string, result types.Result) error {
+	resultBytes, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("marshal result: %s", err) // not tested
+	}
+
+	if err := os.MkdirAll(r.Dir, 0700); err != nil {
+		return fmt.Errorf("create cache dir: %s", err)
+	}
+
+	tempFile, err := ioutil.TempFile(r.Dir, handle+".tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file: %s", err)
+	}
+	defer os.Remove(tempFile.Name()) // not tested
+
+	if _, err := tempFile.Write(resultBytes); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("write temp file: %s", err) // not tested
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("close temp file: %s", err) // not tested
+	}
+
+	if err := os.Rename(tempFile.Name(), r.path(handle)); err != nil {
+		return fmt.Errorf("rename temp file: %s", err) // not tested
+	}
+	return nil
+}
+
+func (r *ResultCache) Load(handle string) (map[string]interface{}, error) {
+	resultBytes, err := ioutil.ReadFile(r.path(handle))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no cached result for %s", handle)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read cached result: %s", err)
+	}
+
+	result := map[string]interface{}{}
+	if err := json.Unmarshal(resultBytes, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal cached result: %s", err)
+	}
+	return result, nil
+}
+
+func (r *ResultCache) Delete(handle string) error {
+	err := os.Remove(r.path(handle))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (r *ResultCache) path(handle string) string {
+	return filepath.Join(r.Dir, handle+".json")
+}
@@ -0,0 +1,85 @@
+package cni_test
+
+import (
+	"garden-external-networker/cni"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/containernetworking/cni/pkg/types/020"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ResultCache", func() {
+	var (
+		cacheDir string
+		cache    *cni.ResultCache
+		result   *types020.Result
+	)
+
+	BeforeEach(func() {
+		var err error
+		cacheDir, err = ioutil.TempDir("", "cni-result-cache-")
+		Expect(err).NotTo(HaveOccurred())
+
+		cache = &cni.ResultCache{Dir: filepath.Join(cacheDir, "results")}
+		result = &types020.Result{
+			CNIVersion: "0.2.0",
+			IP4: &types020.IPConfig{
+				IP: net.IPNet{
+					IP:   net.ParseIP("10.255.0.2"),
+					Mask: net.IPv4Mask(255, 255, 255, 255),
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(cacheDir)).To(Succeed())
+	})
+
+	It("saves and loads the result of a container", func() {
+		Expect(cache.Save("some-handle", result)).To(Succeed())
+
+		loaded, err := cache.Load("some-handle")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded).To(HaveKeyWithValue("cniVersion", "0.2.0"))
+		Expect(loaded).To(HaveKeyWithValue("ip4", HaveKeyWithValue("ip", "10.255.0.2/32")))
+
+		files, err := ioutil.ReadDir(cache.Dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+	})
+
+	It("deletes the result of a container", func() {
+		Expect(cache.Save("some-handle", result)).To(Succeed())
+		Expect(cache.Delete("some-handle")).To(Succeed())
+
+		_, err := cache.Load("some-handle")
+		Expect(err).To(MatchError("no cached result for some-handle"))
+	})
+
+	It("does not fail to delete a result that was never saved", func() {
+		Expect(cache.Delete("some-handle")).To(Succeed())
+	})
+
+	Context("when the cached result is corrupt", func() {
+		It("returns an error", func() {
+			Expect(os.MkdirAll(cache.Dir, 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(cache.Dir, "some-handle.json"), []byte("garbage"), 0600)).To(Succeed())
+
+			_, err := cache.Load("some-handle")
+			Expect(err).To(MatchError(HavePrefix("unmarshal cached result:")))
+		})
+	})
+
+	Context("when the cache dir cannot be created", func() {
+		It("returns an error", func() {
+			cache.Dir = "/proc/0/foo"
+			err := cache.Save("some-handle", result)
+			Expect(err).To(MatchError(HavePrefix("create cache dir:")))
+		})
+	})
+})
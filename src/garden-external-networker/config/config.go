@@ -20,6 +20,31 @@ type Config struct {
 	ProxyRedirectCIDR string   `json:"proxy_redirect_cidr"`
 	ProxyPort         int      `json:"proxy_port"`
 	ProxyUID          *int     `json:"proxy_uid"`
+	CniResultCacheDir string   `json:"cni_result_cache_dir"`
+	NetRulesDir       string   `json:"net_rules_dir"`
+
+	PortRanges             []PortRange `json:"port_ranges"`
+	ReservedPorts          []int       `json:"reserved_ports"`
+	PortAllocationStrategy string      `json:"port_allocation_strategy"`
+
+	MaxConcurrentRequests int `json:"max_concurrent_requests"`
+	DrainTimeoutSeconds   int `json:"drain_timeout_seconds"`
+	MetronPort            int `json:"metron_port"`
+	MetricsEmitSeconds    int `json:"metrics_emit_seconds"`
+
+	MetricsSink   string `json:"metrics_sink"`
+	StatsdAddress string `json:"statsd_address"`
+
+	DefaultCniChain        string `json:"default_cni_chain"`
+	CniConfigReloadSeconds int    `json:"cni_config_reload_seconds"`
+
+	FirewallBackend string `json:"firewall_backend"`
+}
+
+// PortRange is a range of host ports that can be allocated to containers.
+type PortRange struct {
+	Start int `json:"start"`
+	Size  int `json:"size"`
 }
 
 func New(configFilePath string) (Config, error) {
@@ -55,12 +80,36 @@ func New(configFilePath string) (Config, error) {
 		return cfg, fmt.Errorf("missing required config 'state_file'")
 	}
 
-	if cfg.StartPort == 0 {
-		return cfg, fmt.Errorf("missing required config 'start_port'")
+	if len(cfg.PortRanges) == 0 {
+		if cfg.StartPort == 0 {
+			return cfg, fmt.Errorf("missing required config 'start_port'")
+		}
+
+		if cfg.TotalPorts == 0 {
+			return cfg, fmt.Errorf("missing required config 'total_ports'")
+		}
+
+		cfg.PortRanges = []PortRange{{Start: cfg.StartPort, Size: cfg.TotalPorts}}
+	}
+
+	for _, portRange := range cfg.PortRanges {
+		if portRange.Start < 1 || portRange.Size < 1 || portRange.Start+portRange.Size-1 > 65535 {
+			return cfg, fmt.Errorf("invalid config 'port_ranges': %d-%d is not a valid port range", portRange.Start, portRange.Start+portRange.Size-1)
+		}
 	}
 
-	if cfg.TotalPorts == 0 {
-		return cfg, fmt.Errorf("missing required config 'total_ports'")
+	for _, port := range cfg.ReservedPorts {
+		if port < 1 || port > 65535 {
+			return cfg, fmt.Errorf("invalid config 'reserved_ports': %d is not a valid port", port)
+		}
+	}
+
+	switch cfg.PortAllocationStrategy {
+	case "":
+		cfg.PortAllocationStrategy = "round-robin"
+	case "round-robin", "random":
+	default:
+		return cfg, fmt.Errorf("invalid config 'port_allocation_strategy': must be round-robin or random")
 	}
 
 	if cfg.LogPrefix == "" {
@@ -79,5 +128,49 @@ func New(configFilePath string) (Config, error) {
 		return cfg, fmt.Errorf("missing required config 'proxy_uid'")
 	}
 
+	if cfg.CniResultCacheDir == "" {
+		return cfg, fmt.Errorf("missing required config 'cni_result_cache_dir'")
+	}
+
+	if cfg.NetRulesDir == "" {
+		return cfg, fmt.Errorf("missing required config 'net_rules_dir'")
+	}
+
+	if cfg.MaxConcurrentRequests < 0 {
+		return cfg, fmt.Errorf("invalid config 'max_concurrent_requests': must not be negative")
+	}
+
+	if cfg.DrainTimeoutSeconds < 0 {
+		return cfg, fmt.Errorf("invalid config 'drain_timeout_seconds': must not be negative")
+	}
+
+	if cfg.MetricsEmitSeconds == 0 {
+		cfg.MetricsEmitSeconds = 30
+	}
+
+	if cfg.CniConfigReloadSeconds < 0 {
+		return cfg, fmt.Errorf("invalid config 'cni_config_reload_seconds': must not be negative")
+	}
+
+	switch cfg.MetricsSink {
+	case "":
+		cfg.MetricsSink = "dropsonde"
+	case "dropsonde", "log", "none":
+	case "statsd":
+		if cfg.StatsdAddress == "" {
+			return cfg, fmt.Errorf("missing required config 'statsd_address'")
+		}
+	default:
+		return cfg, fmt.Errorf("invalid config 'metrics_sink': must be dropsonde, statsd, log or none")
+	}
+
+	switch cfg.FirewallBackend {
+	case "":
+		cfg.FirewallBackend = "iptables"
+	case "iptables", "nftables":
+	default:
+		return cfg, fmt.Errorf("invalid config 'firewall_backend': must be iptables or nftables")
+	}
+
 	return cfg, nil
 }
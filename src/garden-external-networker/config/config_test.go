@@ -38,6 +38,17 @@ var _ = Describe("Config", func() {
 					"proxy_redirect_cidr": "some-cidr",
 					"proxy_port": 1111,
 					"proxy_uid": 1,
+					"cni_result_cache_dir": "some/cache/dir",
+					"net_rules_dir": "some/rules/dir",
+					"max_concurrent_requests": 8,
+					"drain_timeout_seconds": 20,
+					"metron_port": 3457,
+					"metrics_emit_seconds": 10,
+					"metrics_sink": "statsd",
+					"statsd_address": "127.0.0.1:8125",
+					"default_cni_chain": "some-chain",
+					"cni_config_reload_seconds": 5,
+					"firewall_backend": "nftables",
 					"search_domains": [
 						"pivotal.io",
 						"foo.bar",
@@ -58,6 +69,17 @@ var _ = Describe("Config", func() {
 				Expect(c.ProxyRedirectCIDR).To(Equal("some-cidr"))
 				Expect(c.ProxyPort).To(Equal(1111))
 				Expect(*c.ProxyUID).To(Equal(1))
+				Expect(c.CniResultCacheDir).To(Equal("some/cache/dir"))
+				Expect(c.NetRulesDir).To(Equal("some/rules/dir"))
+				Expect(c.MaxConcurrentRequests).To(Equal(8))
+				Expect(c.DrainTimeoutSeconds).To(Equal(20))
+				Expect(c.MetronPort).To(Equal(3457))
+				Expect(c.MetricsEmitSeconds).To(Equal(10))
+				Expect(c.MetricsSink).To(Equal("statsd"))
+				Expect(c.StatsdAddress).To(Equal("127.0.0.1:8125"))
+				Expect(c.DefaultCniChain).To(Equal("some-chain"))
+				Expect(c.CniConfigReloadSeconds).To(Equal(5))
+				Expect(c.FirewallBackend).To(Equal("nftables"))
 			})
 		})
 
@@ -93,17 +115,19 @@ var _ = Describe("Config", func() {
 		DescribeTable("when config file is missing a member",
 			func(missingFlag string) {
 				allData := map[string]interface{}{
-					"cni_plugin_dir":      "/some/plugin/dir",
-					"cni_config_dir":      "/some/config/dir",
-					"bind_mount_dir":      "/some/mount/dir",
-					"state_file":          "/some/state/file",
-					"start_port":          50000,
-					"total_ports":         10000,
-					"log_prefix":          "prefix",
-					"iptables_lock_file":  "some-lock-file",
-					"proxy_redirect_cidr": "some-cidr", // optional
-					"proxy_port":          1111,
-					"proxy_uid":           1,
+					"cni_plugin_dir":       "/some/plugin/dir",
+					"cni_config_dir":       "/some/config/dir",
+					"bind_mount_dir":       "/some/mount/dir",
+					"state_file":           "/some/state/file",
+					"start_port":           50000,
+					"total_ports":          10000,
+					"log_prefix":           "prefix",
+					"iptables_lock_file":   "some-lock-file",
+					"proxy_redirect_cidr":  "some-cidr", // optional
+					"proxy_port":           1111,
+					"proxy_uid":            1,
+					"cni_result_cache_dir": "/some/cache/dir",
+					"net_rules_dir":        "/some/rules/dir",
 				}
 				delete(allData, missingFlag)
 				Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
@@ -121,6 +145,118 @@ var _ = Describe("Config", func() {
 			Entry("missing iptables_lock_file", "iptables_lock_file"),
 			Entry("missing proxy_port", "proxy_port"),
 			Entry("missing proxy_uid", "proxy_uid"),
+			Entry("missing cni_result_cache_dir", "cni_result_cache_dir"),
+			Entry("missing net_rules_dir", "net_rules_dir"),
+		)
+
+		Context("when the optional members are missing", func() {
+			It("uses their defaults", func() {
+				file.WriteString(`{
+					"cni_plugin_dir": "foo",
+					"cni_config_dir": "bar",
+					"bind_mount_dir": "baz",
+					"state_file": "some/path",
+					"start_port": 1234,
+					"total_ports": 56,
+					"log_prefix": "prefix",
+					"iptables_lock_file": "some-lock-file",
+					"proxy_port": 1111,
+					"proxy_uid": 1,
+					"cni_result_cache_dir": "some/cache/dir",
+					"net_rules_dir": "some/rules/dir"
+				}`)
+				c, err := config.New(file.Name())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(c.MaxConcurrentRequests).To(Equal(0))
+				Expect(c.DrainTimeoutSeconds).To(Equal(0))
+				Expect(c.MetronPort).To(Equal(0))
+				Expect(c.MetricsEmitSeconds).To(Equal(30))
+				Expect(c.PortAllocationStrategy).To(Equal("round-robin"))
+				Expect(c.MetricsSink).To(Equal("dropsonde"))
+				Expect(c.FirewallBackend).To(Equal("iptables"))
+			})
+
+			It("uses start_port and total_ports as the only port range", func() {
+				file.WriteString(`{
+					"cni_plugin_dir": "foo",
+					"cni_config_dir": "bar",
+					"bind_mount_dir": "baz",
+					"state_file": "some/path",
+					"start_port": 1234,
+					"total_ports": 56,
+					"log_prefix": "prefix",
+					"iptables_lock_file": "some-lock-file",
+					"proxy_port": 1111,
+					"proxy_uid": 1,
+					"cni_result_cache_dir": "some/cache/dir",
+					"net_rules_dir": "some/rules/dir"
+				}`)
+				c, err := config.New(file.Name())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(c.PortRanges).To(Equal([]config.PortRange{{Start: 1234, Size: 56}}))
+			})
+		})
+
+		Context("when port ranges are given", func() {
+			It("uses them instead of start_port and total_ports", func() {
+				file.WriteString(`{
+					"cni_plugin_dir": "foo",
+					"cni_config_dir": "bar",
+					"bind_mount_dir": "baz",
+					"state_file": "some/path",
+					"port_ranges": [{"start": 61000, "size": 100}, {"start": 62000, "size": 50}],
+					"reserved_ports": [61010, 61011],
+					"port_allocation_strategy": "random",
+					"log_prefix": "prefix",
+					"iptables_lock_file": "some-lock-file",
+					"proxy_port": 1111,
+					"proxy_uid": 1,
+					"cni_result_cache_dir": "some/cache/dir",
+					"net_rules_dir": "some/rules/dir"
+				}`)
+				c, err := config.New(file.Name())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(c.PortRanges).To(Equal([]config.PortRange{
+					{Start: 61000, Size: 100},
+					{Start: 62000, Size: 50},
+				}))
+				Expect(c.ReservedPorts).To(Equal([]int{61010, 61011}))
+				Expect(c.PortAllocationStrategy).To(Equal("random"))
+			})
+		})
+
+		DescribeTable("when config file has an invalid member",
+			func(member string, value interface{}, expectedError string) {
+				allData := map[string]interface{}{
+					"cni_plugin_dir":       "/some/plugin/dir",
+					"cni_config_dir":       "/some/config/dir",
+					"bind_mount_dir":       "/some/mount/dir",
+					"state_file":           "/some/state/file",
+					"start_port":           50000,
+					"total_ports":          10000,
+					"log_prefix":           "prefix",
+					"iptables_lock_file":   "some-lock-file",
+					"proxy_port":           1111,
+					"proxy_uid":            1,
+					"cni_result_cache_dir": "/some/cache/dir",
+					"net_rules_dir":        "/some/rules/dir",
+				}
+				allData[member] = value
+				Expect(json.NewEncoder(file).Encode(allData)).To(Succeed())
+
+				_, err = config.New(file.Name())
+				Expect(err).To(MatchError(expectedError))
+			},
+			Entry("negative max_concurrent_requests", "max_concurrent_requests", -1, "invalid config 'max_concurrent_requests': must not be negative"),
+			Entry("negative drain_timeout_seconds", "drain_timeout_seconds", -1, "invalid config 'drain_timeout_seconds': must not be negative"),
+			Entry("empty port range", "port_ranges", []map[string]int{{"start": 61000, "size": 0}}, "invalid config 'port_ranges': 61000-60999 is not a valid port range"),
+			Entry("port range past the last port", "port_ranges", []map[string]int{{"start": 65000, "size": 1000}}, "invalid config 'port_ranges': 65000-65999 is not a valid port range"),
+			Entry("invalid reserved port", "reserved_ports", []int{70000}, "invalid config 'reserved_ports': 70000 is not a valid port"),
+			Entry("unknown port_allocation_strategy", "port_allocation_strategy", "lowest", "invalid config 'port_allocation_strategy': must be round-robin or random"),
+			Entry("negative cni_config_reload_seconds", "cni_config_reload_seconds", -1, "invalid config 'cni_config_reload_seconds': must not be negative"),
+			Entry("unknown metrics_sink", "metrics_sink", "graphite", "invalid config 'metrics_sink': must be dropsonde, statsd, log or none"),
+			Entry("statsd metrics_sink without statsd_address", "metrics_sink", "statsd", "missing required config 'statsd_address'"),
+			Entry("unknown firewall_backend", "firewall_backend", "pf", "invalid config 'firewall_backend': must be iptables or nftables"),
 		)
 	})
 })
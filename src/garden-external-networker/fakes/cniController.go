@@ -36,6 +36,34 @@ type CNIController struct {
 	downReturnsOnCall map[int]struct {
 		result1 error
 	}
+	CheckStub        func(namespacePath, handle string) error
+	checkMutex       sync.RWMutex
+	checkArgsForCall []struct {
+		namespacePath string
+		handle        string
+	}
+	checkReturns struct {
+		result1 error
+	}
+	checkReturnsOnCall map[int]struct {
+		result1 error
+	}
+	UpdateStub        func(namespacePath, handle string, metadata map[string]interface{}, legacyNetConf map[string]interface{}) (types.Result, error)
+	updateMutex       sync.RWMutex
+	updateArgsForCall []struct {
+		namespacePath string
+		handle        string
+		metadata      map[string]interface{}
+		legacyNetConf map[string]interface{}
+	}
+	updateReturns struct {
+		result1 types.Result
+		result2 error
+	}
+	updateReturnsOnCall map[int]struct {
+		result1 types.Result
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -143,6 +171,109 @@ func (fake *CNIController) DownReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *CNIController) Check(namespacePath string, handle string) error {
+	fake.checkMutex.Lock()
+	ret, specificReturn := fake.checkReturnsOnCall[len(fake.checkArgsForCall)]
+	fake.checkArgsForCall = append(fake.checkArgsForCall, struct {
+		namespacePath string
+		handle        string
+	}{namespacePath, handle})
+	fake.recordInvocation("Check", []interface{}{namespacePath, handle})
+	fake.checkMutex.Unlock()
+	if fake.CheckStub != nil {
+		return fake.CheckStub(namespacePath, handle)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.checkReturns.result1
+}
+
+func (fake *CNIController) CheckCallCount() int {
+	fake.checkMutex.RLock()
+	defer fake.checkMutex.RUnlock()
+	return len(fake.checkArgsForCall)
+}
+
+func (fake *CNIController) CheckArgsForCall(i int) (string, string) {
+	fake.checkMutex.RLock()
+	defer fake.checkMutex.RUnlock()
+	return fake.checkArgsForCall[i].namespacePath, fake.checkArgsForCall[i].handle
+}
+
+func (fake *CNIController) CheckReturns(result1 error) {
+	fake.CheckStub = nil
+	fake.checkReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *CNIController) CheckReturnsOnCall(i int, result1 error) {
+	fake.CheckStub = nil
+	if fake.checkReturnsOnCall == nil {
+		fake.checkReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.checkReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *CNIController) Update(namespacePath string, handle string, metadata map[string]interface{}, legacyNetConf map[string]interface{}) (types.Result, error) {
+	fake.updateMutex.Lock()
+	ret, specificReturn := fake.updateReturnsOnCall[len(fake.updateArgsForCall)]
+	fake.updateArgsForCall = append(fake.updateArgsForCall, struct {
+		namespacePath string
+		handle        string
+		metadata      map[string]interface{}
+		legacyNetConf map[string]interface{}
+	}{namespacePath, handle, metadata, legacyNetConf})
+	fake.recordInvocation("Update", []interface{}{namespacePath, handle, metadata, legacyNetConf})
+	fake.updateMutex.Unlock()
+	if fake.UpdateStub != nil {
+		return fake.UpdateStub(namespacePath, handle, metadata, legacyNetConf)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.updateReturns.result1, fake.updateReturns.result2
+}
+
+func (fake *CNIController) UpdateCallCount() int {
+	fake.updateMutex.RLock()
+	defer fake.updateMutex.RUnlock()
+	return len(fake.updateArgsForCall)
+}
+
+func (fake *CNIController) UpdateArgsForCall(i int) (string, string, map[string]interface{}, map[string]interface{}) {
+	fake.updateMutex.RLock()
+	defer fake.updateMutex.RUnlock()
+	return fake.updateArgsForCall[i].namespacePath, fake.updateArgsForCall[i].handle, fake.updateArgsForCall[i].metadata, fake.updateArgsForCall[i].legacyNetConf
+}
+
+func (fake *CNIController) UpdateReturns(result1 types.Result, result2 error) {
+	fake.UpdateStub = nil
+	fake.updateReturns = struct {
+		result1 types.Result
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *CNIController) UpdateReturnsOnCall(i int, result1 types.Result, result2 error) {
+	fake.UpdateStub = nil
+	if fake.updateReturnsOnCall == nil {
+		fake.updateReturnsOnCall = make(map[int]struct {
+			result1 types.Result
+			result2 error
+		})
+	}
+	fake.updateReturnsOnCall[i] = struct {
+		result1 types.Result
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *CNIController) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -150,6 +281,10 @@ func (fake *CNIController) Invocations() map[string][][]interface{} {
 	defer fake.upMutex.RUnlock()
 	fake.downMutex.RLock()
 	defer fake.downMutex.RUnlock()
+	fake.checkMutex.RLock()
+	defer fake.checkMutex.RUnlock()
+	fake.updateMutex.RLock()
+	defer fake.updateMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -29,6 +29,19 @@ type Mounter struct {
 	removeMountReturnsOnCall map[int]struct {
 		result1 error
 	}
+	ListMountsStub        func(root string) ([]string, error)
+	listMountsMutex       sync.RWMutex
+	listMountsArgsForCall []struct {
+		root string
+	}
+	listMountsReturns struct {
+		result1 []string
+		result2 error
+	}
+	listMountsReturnsOnCall map[int]struct {
+		result1 []string
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -130,6 +143,57 @@ func (fake *Mounter) RemoveMountReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *Mounter) ListMounts(root string) ([]string, error) {
+	fake.listMountsMutex.Lock()
+	ret, specificReturn := fake.listMountsReturnsOnCall[len(fake.listMountsArgsForCall)]
+	fake.listMountsArgsForCall = append(fake.listMountsArgsForCall, struct {
+		root string
+	}{root})
+	fake.recordInvocation("ListMounts", []interface{}{root})
+	fake.listMountsMutex.Unlock()
+	if fake.ListMountsStub != nil {
+		return fake.ListMountsStub(root)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.listMountsReturns.result1, fake.listMountsReturns.result2
+}
+
+func (fake *Mounter) ListMountsCallCount() int {
+	fake.listMountsMutex.RLock()
+	defer fake.listMountsMutex.RUnlock()
+	return len(fake.listMountsArgsForCall)
+}
+
+func (fake *Mounter) ListMountsArgsForCall(i int) string {
+	fake.listMountsMutex.RLock()
+	defer fake.listMountsMutex.RUnlock()
+	return fake.listMountsArgsForCall[i].root
+}
+
+func (fake *Mounter) ListMountsReturns(result1 []string, result2 error) {
+	fake.ListMountsStub = nil
+	fake.listMountsReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Mounter) ListMountsReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.ListMountsStub = nil
+	if fake.listMountsReturnsOnCall == nil {
+		fake.listMountsReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.listMountsReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *Mounter) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -137,6 +201,8 @@ func (fake *Mounter) Invocations() map[string][][]interface{} {
 	defer fake.idempotentlyMountMutex.RUnlock()
 	fake.removeMountMutex.RLock()
 	defer fake.removeMountMutex.RUnlock()
+	fake.listMountsMutex.RLock()
+	defer fake.listMountsMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -31,6 +31,29 @@ type PortAllocator struct {
 	releaseAllPortsReturnsOnCall map[int]struct {
 		result1 error
 	}
+	ReleasePortStub        func(handle string, port int) error
+	releasePortMutex       sync.RWMutex
+	releasePortArgsForCall []struct {
+		handle string
+		port   int
+	}
+	releasePortReturns struct {
+		result1 error
+	}
+	releasePortReturnsOnCall map[int]struct {
+		result1 error
+	}
+	AllocatedHandlesStub        func() ([]string, error)
+	allocatedHandlesMutex       sync.RWMutex
+	allocatedHandlesArgsForCall []struct{}
+	allocatedHandlesReturns     struct {
+		result1 []string
+		result2 error
+	}
+	allocatedHandlesReturnsOnCall map[int]struct {
+		result1 []string
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -135,6 +158,98 @@ func (fake *PortAllocator) ReleaseAllPortsReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *PortAllocator) ReleasePort(handle string, port int) error {
+	fake.releasePortMutex.Lock()
+	ret, specificReturn := fake.releasePortReturnsOnCall[len(fake.releasePortArgsForCall)]
+	fake.releasePortArgsForCall = append(fake.releasePortArgsForCall, struct {
+		handle string
+		port   int
+	}{handle, port})
+	fake.recordInvocation("ReleasePort", []interface{}{handle, port})
+	fake.releasePortMutex.Unlock()
+	if fake.ReleasePortStub != nil {
+		return fake.ReleasePortStub(handle, port)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.releasePortReturns.result1
+}
+
+func (fake *PortAllocator) ReleasePortCallCount() int {
+	fake.releasePortMutex.RLock()
+	defer fake.releasePortMutex.RUnlock()
+	return len(fake.releasePortArgsForCall)
+}
+
+func (fake *PortAllocator) ReleasePortArgsForCall(i int) (string, int) {
+	fake.releasePortMutex.RLock()
+	defer fake.releasePortMutex.RUnlock()
+	return fake.releasePortArgsForCall[i].handle, fake.releasePortArgsForCall[i].port
+}
+
+func (fake *PortAllocator) ReleasePortReturns(result1 error) {
+	fake.ReleasePortStub = nil
+	fake.releasePortReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *PortAllocator) ReleasePortReturnsOnCall(i int, result1 error) {
+	fake.ReleasePortStub = nil
+	if fake.releasePortReturnsOnCall == nil {
+		fake.releasePortReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.releasePortReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *PortAllocator) AllocatedHandles() ([]string, error) {
+	fake.allocatedHandlesMutex.Lock()
+	ret, specificReturn := fake.allocatedHandlesReturnsOnCall[len(fake.allocatedHandlesArgsForCall)]
+	fake.allocatedHandlesArgsForCall = append(fake.allocatedHandlesArgsForCall, struct{}{})
+	fake.recordInvocation("AllocatedHandles", []interface{}{})
+	fake.allocatedHandlesMutex.Unlock()
+	if fake.AllocatedHandlesStub != nil {
+		return fake.AllocatedHandlesStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.allocatedHandlesReturns.result1, fake.allocatedHandlesReturns.result2
+}
+
+func (fake *PortAllocator) AllocatedHandlesCallCount() int {
+	fake.allocatedHandlesMutex.RLock()
+	defer fake.allocatedHandlesMutex.RUnlock()
+	return len(fake.allocatedHandlesArgsForCall)
+}
+
+func (fake *PortAllocator) AllocatedHandlesReturns(result1 []string, result2 error) {
+	fake.AllocatedHandlesStub = nil
+	fake.allocatedHandlesReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *PortAllocator) AllocatedHandlesReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.AllocatedHandlesStub = nil
+	if fake.allocatedHandlesReturnsOnCall == nil {
+		fake.allocatedHandlesReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.allocatedHandlesReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *PortAllocator) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -142,6 +257,10 @@ func (fake *PortAllocator) Invocations() map[string][][]interface{} {
 	defer fake.allocatePortMutex.RUnlock()
 	fake.releaseAllPortsMutex.RLock()
 	defer fake.releaseAllPortsMutex.RUnlock()
+	fake.releasePortMutex.RLock()
+	defer fake.releasePortMutex.RUnlock()
+	fake.allocatedHandlesMutex.RLock()
+	defer fake.allocatedHandlesMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
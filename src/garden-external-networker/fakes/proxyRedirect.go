@@ -2,40 +2,109 @@
 package fakes
 
 import (
+	"garden-external-networker/proxy"
 	"sync"
 )
 
 type ProxyRedirect struct {
-	ApplyStub        func(containerNamespace string) error
+	SettingsStub        func(properties map[string]interface{}) (proxy.Settings, error)
+	settingsMutex       sync.RWMutex
+	settingsArgsForCall []struct {
+		properties map[string]interface{}
+	}
+	settingsReturns struct {
+		result1 proxy.Settings
+		result2 error
+	}
+	settingsReturnsOnCall map[int]struct {
+		result1 proxy.Settings
+		result2 error
+	}
+	ApplyStub        func(containerNamespace string, settings proxy.Settings) ([]string, error)
 	applyMutex       sync.RWMutex
 	applyArgsForCall []struct {
 		containerNamespace string
+		settings           proxy.Settings
 	}
 	applyReturns struct {
-		result1 error
+		result1 []string
+		result2 error
 	}
 	applyReturnsOnCall map[int]struct {
-		result1 error
+		result1 []string
+		result2 error
 	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *ProxyRedirect) Apply(containerNamespace string) error {
+func (fake *ProxyRedirect) Settings(properties map[string]interface{}) (proxy.Settings, error) {
+	fake.settingsMutex.Lock()
+	ret, specificReturn := fake.settingsReturnsOnCall[len(fake.settingsArgsForCall)]
+	fake.settingsArgsForCall = append(fake.settingsArgsForCall, struct {
+		properties map[string]interface{}
+	}{properties})
+	fake.recordInvocation("Settings", []interface{}{properties})
+	fake.settingsMutex.Unlock()
+	if fake.SettingsStub != nil {
+		return fake.SettingsStub(properties)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.settingsReturns.result1, fake.settingsReturns.result2
+}
+
+func (fake *ProxyRedirect) SettingsCallCount() int {
+	fake.settingsMutex.RLock()
+	defer fake.settingsMutex.RUnlock()
+	return len(fake.settingsArgsForCall)
+}
+
+func (fake *ProxyRedirect) SettingsArgsForCall(i int) map[string]interface{} {
+	fake.settingsMutex.RLock()
+	defer fake.settingsMutex.RUnlock()
+	return fake.settingsArgsForCall[i].properties
+}
+
+func (fake *ProxyRedirect) SettingsReturns(result1 proxy.Settings, result2 error) {
+	fake.SettingsStub = nil
+	fake.settingsReturns = struct {
+		result1 proxy.Settings
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ProxyRedirect) SettingsReturnsOnCall(i int, result1 proxy.Settings, result2 error) {
+	fake.SettingsStub = nil
+	if fake.settingsReturnsOnCall == nil {
+		fake.settingsReturnsOnCall = make(map[int]struct {
+			result1 proxy.Settings
+			result2 error
+		})
+	}
+	fake.settingsReturnsOnCall[i] = struct {
+		result1 proxy.Settings
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ProxyRedirect) Apply(containerNamespace string, settings proxy.Settings) ([]string, error) {
 	fake.applyMutex.Lock()
 	ret, specificReturn := fake.applyReturnsOnCall[len(fake.applyArgsForCall)]
 	fake.applyArgsForCall = append(fake.applyArgsForCall, struct {
 		containerNamespace string
-	}{containerNamespace})
-	fake.recordInvocation("Apply", []interface{}{containerNamespace})
+		settings           proxy.Settings
+	}{containerNamespace, settings})
+	fake.recordInvocation("Apply", []interface{}{containerNamespace, settings})
 	fake.applyMutex.Unlock()
 	if fake.ApplyStub != nil {
-		return fake.ApplyStub(containerNamespace)
+		return fake.ApplyStub(containerNamespace, settings)
 	}
 	if specificReturn {
-		return ret.result1
+		return ret.result1, ret.result2
 	}
-	return fake.applyReturns.result1
+	return fake.applyReturns.result1, fake.applyReturns.result2
 }
 
 func (fake *ProxyRedirect) ApplyCallCount() int {
@@ -44,34 +113,39 @@ func (fake *ProxyRedirect) ApplyCallCount() int {
 	return len(fake.applyArgsForCall)
 }
 
-func (fake *ProxyRedirect) ApplyArgsForCall(i int) string {
+func (fake *ProxyRedirect) ApplyArgsForCall(i int) (string, proxy.Settings) {
 	fake.applyMutex.RLock()
 	defer fake.applyMutex.RUnlock()
-	return fake.applyArgsForCall[i].containerNamespace
+	return fake.applyArgsForCall[i].containerNamespace, fake.applyArgsForCall[i].settings
 }
 
-func (fake *ProxyRedirect) ApplyReturns(result1 error) {
+func (fake *ProxyRedirect) ApplyReturns(result1 []string, result2 error) {
 	fake.ApplyStub = nil
 	fake.applyReturns = struct {
-		result1 error
-	}{result1}
+		result1 []string
+		result2 error
+	}{result1, result2}
 }
 
-func (fake *ProxyRedirect) ApplyReturnsOnCall(i int, result1 error) {
+func (fake *ProxyRedirect) ApplyReturnsOnCall(i int, result1 []string, result2 error) {
 	fake.ApplyStub = nil
 	if fake.applyReturnsOnCall == nil {
 		fake.applyReturnsOnCall = make(map[int]struct {
-			result1 error
+			result1 []string
+			result2 error
 		})
 	}
 	fake.applyReturnsOnCall[i] = struct {
-		result1 error
-	}{result1}
+		result1 []string
+		result2 error
+	}{result1, result2}
 }
 
 func (fake *ProxyRedirect) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.settingsMutex.RLock()
+	defer fake.settingsMutex.RUnlock()
 	fake.applyMutex.RLock()
 	defer fake.applyMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
@@ -33,6 +33,19 @@ type Tracker struct {
 	releaseAllReturnsOnCall map[int]struct {
 		result1 error
 	}
+	ReleaseOneStub        func(pool *port_allocator.Pool, handle string, port int) error
+	releaseOneMutex       sync.RWMutex
+	releaseOneArgsForCall []struct {
+		pool   *port_allocator.Pool
+		handle string
+		port   int
+	}
+	releaseOneReturns struct {
+		result1 error
+	}
+	releaseOneReturnsOnCall map[int]struct {
+		result1 error
+	}
 	InRangeStub        func(port int) bool
 	inRangeMutex       sync.RWMutex
 	inRangeArgsForCall []struct {
@@ -149,6 +162,56 @@ func (fake *Tracker) ReleaseAllReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *Tracker) ReleaseOne(pool *port_allocator.Pool, handle string, port int) error {
+	fake.releaseOneMutex.Lock()
+	ret, specificReturn := fake.releaseOneReturnsOnCall[len(fake.releaseOneArgsForCall)]
+	fake.releaseOneArgsForCall = append(fake.releaseOneArgsForCall, struct {
+		pool   *port_allocator.Pool
+		handle string
+		port   int
+	}{pool, handle, port})
+	fake.recordInvocation("ReleaseOne", []interface{}{pool, handle, port})
+	fake.releaseOneMutex.Unlock()
+	if fake.ReleaseOneStub != nil {
+		return fake.ReleaseOneStub(pool, handle, port)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.releaseOneReturns.result1
+}
+
+func (fake *Tracker) ReleaseOneCallCount() int {
+	fake.releaseOneMutex.RLock()
+	defer fake.releaseOneMutex.RUnlock()
+	return len(fake.releaseOneArgsForCall)
+}
+
+func (fake *Tracker) ReleaseOneArgsForCall(i int) (*port_allocator.Pool, string, int) {
+	fake.releaseOneMutex.RLock()
+	defer fake.releaseOneMutex.RUnlock()
+	return fake.releaseOneArgsForCall[i].pool, fake.releaseOneArgsForCall[i].handle, fake.releaseOneArgsForCall[i].port
+}
+
+func (fake *Tracker) ReleaseOneReturns(result1 error) {
+	fake.ReleaseOneStub = nil
+	fake.releaseOneReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Tracker) ReleaseOneReturnsOnCall(i int, result1 error) {
+	fake.ReleaseOneStub = nil
+	if fake.releaseOneReturnsOnCall == nil {
+		fake.releaseOneReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.releaseOneReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *Tracker) InRange(port int) bool {
 	fake.inRangeMutex.Lock()
 	ret, specificReturn := fake.inRangeReturnsOnCall[len(fake.inRangeArgsForCall)]
@@ -204,6 +267,8 @@ func (fake *Tracker) Invocations() map[string][][]interface{} {
 	defer fake.acquireOneMutex.RUnlock()
 	fake.releaseAllMutex.RLock()
 	defer fake.releaseAllMutex.RUnlock()
+	fake.releaseOneMutex.RLock()
+	defer fake.releaseOneMutex.RUnlock()
 	fake.inRangeMutex.RLock()
 	defer fake.inRangeMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
@@ -109,6 +109,8 @@ var _ = Describe("Garden External Networker", func() {
 		fakeLogDir             string
 		expectedNetNSPath      string
 		bindMountRoot          string
+		cniResultCacheDir      string
+		netRulesDir            string
 		stateFilePath          string
 		containerHandle        string
 		containerNetNS         ns.NetNS
@@ -150,6 +152,12 @@ var _ = Describe("Garden External Networker", func() {
 
 		expectedNetNSPath = filepath.Join(bindMountRoot, containerHandle)
 
+		cniResultCacheDir, err = ioutil.TempDir("", "cni-result-cache")
+		Expect(err).NotTo(HaveOccurred())
+
+		netRulesDir, err = ioutil.TempDir("", "net-rules")
+		Expect(err).NotTo(HaveOccurred())
+
 		stateFile, err := ioutil.TempFile("", "external-networker-state.json")
 		Expect(err).NotTo(HaveOccurred())
 		Expect(stateFile.Close()).To(Succeed())
@@ -165,17 +173,19 @@ var _ = Describe("Garden External Networker", func() {
 		proxyRedirectCIDR = "10.255.0.0/16"
 
 		config = map[string]interface{}{
-			"cni_plugin_dir":      paths.CniPluginDir,
-			"cni_config_dir":      cniConfigDir,
-			"bind_mount_dir":      bindMountRoot,
-			"iptables_lock_file":  GlobalIPTablesLockFile,
-			"proxy_redirect_cidr": "",
-			"proxy_port":          9999,
-			"proxy_uid":           42,
-			"state_file":          stateFilePath,
-			"start_port":          60000,
-			"total_ports":         56,
-			"log_prefix":          "cfnetworking",
+			"cni_plugin_dir":       paths.CniPluginDir,
+			"cni_config_dir":       cniConfigDir,
+			"bind_mount_dir":       bindMountRoot,
+			"iptables_lock_file":   GlobalIPTablesLockFile,
+			"proxy_redirect_cidr":  "",
+			"proxy_port":           9999,
+			"proxy_uid":            42,
+			"state_file":           stateFilePath,
+			"cni_result_cache_dir": cniResultCacheDir,
+			"net_rules_dir":        netRulesDir,
+			"start_port":           60000,
+			"total_ports":          56,
+			"log_prefix":           "cfnetworking",
 			"search_domains": []string{
 				"pivotal.io",
 				"foo.bar",
@@ -250,6 +260,8 @@ var _ = Describe("Garden External Networker", func() {
 		Expect(os.Remove(fakeConfigFilePath)).To(Succeed())
 		Expect(os.RemoveAll(cniConfigDir)).To(Succeed())
 		Expect(os.RemoveAll(fakeLogDir)).To(Succeed())
+		Expect(os.RemoveAll(cniResultCacheDir)).To(Succeed())
+		Expect(os.RemoveAll(netRulesDir)).To(Succeed())
 		Expect(fakeProcess.Kill()).To(Succeed())
 	})
 
@@ -283,6 +295,7 @@ var _ = Describe("Garden External Networker", func() {
 		Expect(upSession.Out.Contents()).To(MatchJSON(`{
 			"properties": {
 				"garden.network.container-ip": "169.254.1.2",
+				"garden.network.container-ips": "[\"169.254.1.2\"]",
 				"garden.network.host-ip": "255.255.255.255",
 				"garden.network.mapped-ports": "[{\"HostPort\":12345,\"ContainerPort\":7000},{\"HostPort\":60000,\"ContainerPort\":7000}]"
 			},
@@ -361,6 +374,7 @@ var _ = Describe("Garden External Networker", func() {
 			Expect(upSession.Out.Contents()).To(MatchJSON(`{
 			"properties": {
 				"garden.network.container-ip": "169.254.1.2",
+				"garden.network.container-ips": "[\"169.254.1.2\"]",
 				"garden.network.host-ip": "255.255.255.255",
 				"garden.network.mapped-ports": "[{\"HostPort\":12345,\"ContainerPort\":7000},{\"HostPort\":60000,\"ContainerPort\":7000}]"
 			},
@@ -390,6 +404,7 @@ var _ = Describe("Garden External Networker", func() {
 			Expect(upSession.Out.Contents()).To(MatchJSON(`{
 			"properties": {
 				"garden.network.container-ip": "169.254.1.2",
+				"garden.network.container-ips": "[\"169.254.1.2\"]",
 				"garden.network.host-ip": "255.255.255.255",
 				"garden.network.mapped-ports": "[{\"HostPort\":12345,\"ContainerPort\":7000},{\"HostPort\":60000,\"ContainerPort\":7000}]"
 			},
@@ -501,6 +516,7 @@ var _ = Describe("Garden External Networker", func() {
 				],
 				"properties": {
 					"garden.network.container-ip": "169.254.1.2",
+					"garden.network.container-ips": "[\"169.254.1.2\"]",
 					"garden.network.host-ip": "255.255.255.255",
 					"garden.network.mapped-ports": "[{\"HostPort\":12345,\"ContainerPort\":7000},{\"HostPort\":60000,\"ContainerPort\":7000}]"
 				},
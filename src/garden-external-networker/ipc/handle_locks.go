@@ -0,0 +1,54 @@
+package ipc
+
+import "sync"
+
+// handleLocks serializes requests for the same container. Requests without a
+// handle, such as reconcile, act on every container and so exclude all others.
+type handleLocks struct {
+	all   sync.RWMutex
+	mutex sync.Mutex
+	locks map[string]*handleLock
+}
+
+type handleLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock blocks until the handle can be acted on and returns the func that
+// releases it.
+func (l *handleLocks) lock(handle string) func() {
+	if handle == "" {
+		l.all.Lock()
+		return l.all.Unlock
+	}
+
+	l.all.RLock()
+
+	l.mutex.Lock()
+	if l.locks == nil {
+		l.locks = map[string]*handleLock{}
+	}
+	hl, ok := l.locks[handle]
+	if !ok {
+		hl = &handleLock{}
+		l.locks[handle] = hl
+	}
+	hl.refs++
+	l.mutex.Unlock()
+
+	hl.Lock()
+
+	return func() {
+		hl.Unlock()
+
+		l.mutex.Lock()
+		hl.refs--
+		if hl.refs == 0 {
+			delete(l.locks, handle)
+		}
+		l.mutex.Unlock()
+
+		l.all.RUnlock()
+	}
+}
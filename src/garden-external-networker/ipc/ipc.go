@@ -10,15 +10,44 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
+	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/netplugin-shim/message"
 	"golang.org/x/sys/unix"
 )
 
+//go:generate counterfeiter -o ../fakes/metrics_sender.go --fake-name MetricsSender . metricsSender
+type metricsSender interface {
+	IncrementCounter(name string)
+	SendDuration(name string, duration time.Duration)
+}
+
 type Mux struct {
-	Up   func(handle string, inputs manager.UpInputs, netNSFD *uintptr) (*manager.UpOutputs, error)
-	Down func(handle string) error
+	Up        func(handle string, inputs manager.UpInputs, netNSFD *uintptr) (*manager.UpOutputs, error)
+	Down      func(handle string) error
+	Reconcile func(inputs manager.ReconcileInputs) (*manager.ReconcileSummary, error)
+	Check     func(handle string) (*manager.CheckOutputs, error)
+	Update    func(handle string, inputs manager.UpdateInputs) (*manager.UpdateOutputs, error)
+
+	// MaxConcurrentRequests limits how many socket connections are served
+	// at once. Defaults to 1.
+	MaxConcurrentRequests int
+	// DrainTimeout limits how long Serve waits for requests in flight once
+	// Drain is called. Zero waits for as long as they take.
+	DrainTimeout  time.Duration
+	MetricsSender metricsSender
+
+	locks    handleLocks
+	inFlight sync.WaitGroup
+	queued   int64
+
+	listenerMutex sync.Mutex
+	listener      net.Listener
+	draining      bool
 }
 
 func (m *Mux) Handle(action string, handle string, stdin io.Reader, stdout io.Writer) error {
@@ -26,7 +55,7 @@ func (m *Mux) Handle(action string, handle string, stdin io.Reader, stdout io.Wr
 }
 
 func (m *Mux) handle(action string, handle string, netNSFD *uintptr, stdin io.Reader, stdout io.Writer) error {
-	if handle == "" {
+	if handle == "" && RequiresHandle(action) {
 		return fmt.Errorf("missing handle")
 	}
 
@@ -49,46 +78,223 @@ func (m *Mux) handle(action string, handle string, netNSFD *uintptr, stdin io.Re
 			return err
 		}
 		io.WriteString(stdout, "{}")
+	case "update":
+		var inputs manager.UpdateInputs
+		if err := json.NewDecoder(stdin).Decode(&inputs); err != nil {
+			return err
+		}
+		outputs, err := m.Update(handle, inputs)
+		if err != nil {
+			return err
+		}
+		if err := json.NewEncoder(stdout).Encode(outputs); err != nil {
+			return err
+		}
+	case "reconcile":
+		var inputs manager.ReconcileInputs
+		if err := json.NewDecoder(stdin).Decode(&inputs); err != nil {
+			return err
+		}
+		summary, err := m.Reconcile(inputs)
+		if err != nil {
+			return err
+		}
+		if err := json.NewEncoder(stdout).Encode(summary); err != nil {
+			return err
+		}
+	case "check":
+		outputs, err := m.Check(handle)
+		if err != nil {
+			return err
+		}
+		if err := json.NewEncoder(stdout).Encode(outputs); err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("unrecognized action: %s", action)
 	}
 	return nil
 }
 
-func (m *Mux) HandleWithSocket(logger io.Writer, socketPath string) error {
-	fmt.Fprint(logger, "handle with socket")
+// RequiresHandle reports whether an action operates on a single container.
+// check without a handle checks every container.
+func RequiresHandle(action string) bool {
+	return action != "reconcile" && action != "check"
+}
+
+func (m *Mux) HandleWithSocket(logger lager.Logger, socketPath string) error {
+	logger.Info("handle-with-socket", lager.Data{"socket": socketPath})
 	listener, err := net.Listen("unix", socketPath)
 	if err != nil {
 		return err
 	}
-	closeOnKill(logger, listener)
+	m.drainOnKill()
+
+	return m.Serve(logger, listener)
+}
+
+const (
+	minAcceptRetryDelay = 5 * time.Millisecond
+	maxAcceptRetryDelay = time.Second
+)
+
+// Serve handles connections from the listener until Drain is called, and
+// then waits for the requests in flight. Requests for the same container are
+// handled one at a time. Like net/http, Serve retries temporary accept
+// errors with a growing delay and returns any other accept error once the
+// requests in flight are done.
+func (m *Mux) Serve(logger lager.Logger, listener net.Listener) error {
+	m.listenerMutex.Lock()
+	m.listener = listener
+	draining := m.draining
+	m.listenerMutex.Unlock()
+	if draining {
+		listener.Close()
+	}
+
+	maxConcurrentRequests := m.MaxConcurrentRequests
+	if maxConcurrentRequests < 1 {
+		maxConcurrentRequests = 1
+	}
+	slots := make(chan struct{}, maxConcurrentRequests)
 
+	var retryDelay time.Duration
 	for {
-		if err := m.handleOne(listener); err != nil {
-			fmt.Fprintf(logger, "%v", err)
-			continue
+		connection, err := listener.Accept()
+		if err != nil {
+			if m.isDraining() {
+				return m.waitForRequests()
+			}
+
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				if retryDelay == 0 {
+					retryDelay = minAcceptRetryDelay
+				} else {
+					retryDelay *= 2
+				}
+				if retryDelay > maxAcceptRetryDelay {
+					retryDelay = maxAcceptRetryDelay
+				}
+				logger.Error("accept", err, lager.Data{"retry-in": retryDelay.String()})
+				time.Sleep(retryDelay)
+				continue
+			}
+
+			logger.Error("accept", err)
+			if waitErr := m.waitForRequests(); waitErr != nil {
+				logger.Error("wait-for-requests", waitErr)
+			}
+			return fmt.Errorf("accept: %s", err)
 		}
+		retryDelay = 0
+
+		atomic.AddInt64(&m.queued, 1)
+		m.inFlight.Add(1)
+		go func() {
+			defer m.inFlight.Done()
+			m.handleConnection(logger, connection, slots)
+		}()
 	}
 }
 
-func (m *Mux) handleOne(listener net.Listener) error {
-	connection, err := listener.Accept()
-	if err != nil {
-		return err
+// Drain stops Serve from accepting connections.
+func (m *Mux) Drain() {
+	m.listenerMutex.Lock()
+	defer m.listenerMutex.Unlock()
+
+	m.draining = true
+	if m.listener != nil {
+		m.listener.Close()
 	}
+}
+
+// QueueDepth is the number of accepted connections that are not being handled
+// yet, because their request is still being read or they wait for their
+// container or for a free slot.
+func (m *Mux) QueueDepth() (float64, error) {
+	return float64(atomic.LoadInt64(&m.queued)), nil
+}
+
+func (m *Mux) isDraining() bool {
+	m.listenerMutex.Lock()
+	defer m.listenerMutex.Unlock()
+	return m.draining
+}
+
+func (m *Mux) waitForRequests() error {
+	done := make(chan struct{})
+	go func() {
+		m.inFlight.Wait()
+		close(done)
+	}()
+
+	if m.DrainTimeout == 0 {
+		<-done
+		return nil
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(m.DrainTimeout):
+		return fmt.Errorf("drain timed out after %s with requests in flight", m.DrainTimeout)
+	}
+}
+
+// handleConnection reads the request and waits for its container before it
+// takes one of the slots, so that requests queued behind another request for
+// the same container don't keep requests for other containers waiting.
+func (m *Mux) handleConnection(logger lager.Logger, connection net.Conn, slots chan struct{}) {
 	defer connection.Close()
+	dequeue := func() { atomic.AddInt64(&m.queued, -1) }
 
 	nsFD, err := readNsFileDescriptor(connection)
 	if err != nil {
-		return err
+		dequeue()
+		logger.Error("read-ns-file-descriptor", err)
+		return
 	}
 
 	msg, err := decodeMsg(connection)
 	if err != nil {
-		return err
+		dequeue()
+		logger.Error("decode-message", err)
+		return
+	}
+
+	action := string(msg.Command)
+	handle := string(msg.Handle)
+	logger = logger.Session("request", lager.Data{"action": action, "handle": handle})
+
+	unlock := m.locks.lock(handle)
+	defer unlock()
+
+	slots <- struct{}{}
+	defer func() { <-slots }()
+	dequeue()
+
+	start := time.Now()
+	err = m.handle(action, handle, newUintptr(nsFD), bytes.NewBuffer(msg.Data), connection)
+	duration := time.Since(start)
+	m.recordRequest(action, duration, err)
+
+	if err != nil {
+		logger.Error("failed", err, lager.Data{"duration": duration.String()})
+		return
 	}
+	logger.Info("complete", lager.Data{"duration": duration.String()})
+}
 
-	return m.handle(string(msg.Command), string(msg.Handle), newUintptr(nsFD), bytes.NewBuffer(msg.Data), connection)
+func (m *Mux) recordRequest(action string, duration time.Duration, err error) {
+	if m.MetricsSender == nil {
+		return
+	}
+
+	m.MetricsSender.SendDuration(action+"RequestTime", duration)
+	m.MetricsSender.IncrementCounter(action + "RequestCount")
+	if err != nil {
+		m.MetricsSender.IncrementCounter(action + "RequestFailures")
+	}
 }
 
 func readNsFileDescriptor(conn net.Conn) (uintptr, error) {
@@ -171,14 +377,11 @@ func newUintptr(u uintptr) *uintptr {
 	return &u
 }
 
-func closeOnKill(logger io.Writer, closer io.Closer) {
+func (m *Mux) drainOnKill() {
 	signalChannel := make(chan os.Signal, 1)
 	signal.Notify(signalChannel, syscall.SIGTERM, syscall.SIGINT)
 	go func() {
 		<-signalChannel
-		if err := closer.Close(); err != nil {
-			fmt.Fprintln(logger, err)
-		}
-		os.Exit(0)
+		m.Drain()
 	}()
 }
@@ -0,0 +1,13 @@
+package ipc_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestIpc(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "IPC Suite")
+}
@@ -0,0 +1,386 @@
+package ipc_test
+
+import (
+	"encoding/json"
+	"errors"
+	"garden-external-networker/fakes"
+	"garden-external-networker/ipc"
+	"garden-external-networker/manager"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/netplugin-shim/message"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"golang.org/x/sys/unix"
+)
+
+var _ = Describe("Mux", func() {
+	Describe("Serve", func() {
+		var (
+			mux           *ipc.Mux
+			metricsSender *fakes.MetricsSender
+			logger        *lagertest.TestLogger
+			tmpDir        string
+			socketPath    string
+			listener      net.Listener
+			serveErr      chan error
+			serveDone     chan struct{}
+
+			release   chan struct{}
+			mutex     sync.Mutex
+			active    map[string]int
+			maxActive int
+		)
+
+		enter := func(key string) {
+			mutex.Lock()
+			active[key]++
+			total := 0
+			for _, n := range active {
+				total += n
+			}
+			if total > maxActive {
+				maxActive = total
+			}
+			mutex.Unlock()
+		}
+
+		leave := func(key string) {
+			mutex.Lock()
+			active[key]--
+			mutex.Unlock()
+		}
+
+		activeCount := func(key string) func() int {
+			return func() int {
+				mutex.Lock()
+				defer mutex.Unlock()
+				if key == "" {
+					total := 0
+					for _, n := range active {
+						total += n
+					}
+					return total
+				}
+				return active[key]
+			}
+		}
+
+		BeforeEach(func() {
+			var err error
+			tmpDir, err = ioutil.TempDir("", "ipc-")
+			Expect(err).NotTo(HaveOccurred())
+			socketPath = filepath.Join(tmpDir, "networker.sock")
+
+			release = make(chan struct{})
+			active = map[string]int{}
+			maxActive = 0
+
+			metricsSender = &fakes.MetricsSender{}
+			logger = lagertest.NewTestLogger("test")
+			mux = &ipc.Mux{
+				Up: func(handle string, inputs manager.UpInputs, netNSFD *uintptr) (*manager.UpOutputs, error) {
+					enter(handle)
+					defer leave(handle)
+					<-release
+					return &manager.UpOutputs{}, nil
+				},
+				Down: func(handle string) error {
+					enter(handle)
+					defer leave(handle)
+					<-release
+					return errors.New("potato")
+				},
+				Reconcile: func(inputs manager.ReconcileInputs) (*manager.ReconcileSummary, error) {
+					enter("reconcile")
+					defer leave("reconcile")
+					<-release
+					return &manager.ReconcileSummary{}, nil
+				},
+				MaxConcurrentRequests: 2,
+				MetricsSender:         metricsSender,
+			}
+
+			listener, err = net.Listen("unix", socketPath)
+			Expect(err).NotTo(HaveOccurred())
+
+			serveErr = make(chan error, 1)
+			serveDone = make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				serveErr <- mux.Serve(logger, listener)
+				close(serveDone)
+			}()
+		})
+
+		AfterEach(func() {
+			select {
+			case <-release:
+			default:
+				close(release)
+			}
+			mux.Drain()
+			Eventually(serveDone).Should(BeClosed())
+			Expect(os.RemoveAll(tmpDir)).To(Succeed())
+		})
+
+		It("handles requests for different containers concurrently up to the limit", func() {
+			conn1 := sendRequest(socketPath, "up", "handle-1", manager.UpInputs{Pid: 1})
+			conn2 := sendRequest(socketPath, "up", "handle-2", manager.UpInputs{Pid: 1})
+			conn3 := sendRequest(socketPath, "up", "handle-3", manager.UpInputs{Pid: 1})
+
+			Eventually(activeCount("")).Should(Equal(2))
+			Eventually(mux.QueueDepth).Should(Equal(float64(1)))
+			Consistently(activeCount(""), "100ms").Should(Equal(2))
+
+			close(release)
+
+			for _, conn := range []net.Conn{conn1, conn2, conn3} {
+				Eventually(readResponse(conn)).Should(ContainSubstring("properties"))
+			}
+			Expect(maxActive).To(Equal(2))
+			Expect(mux.QueueDepth()).To(Equal(float64(0)))
+		})
+
+		It("handles requests for the same container one at a time", func() {
+			conn1 := sendRequest(socketPath, "up", "handle-1", manager.UpInputs{Pid: 1})
+			conn2 := sendRequest(socketPath, "down", "handle-1", nil)
+
+			Eventually(activeCount("handle-1")).Should(Equal(1))
+			Consistently(activeCount("handle-1"), "100ms").Should(Equal(1))
+
+			close(release)
+
+			Eventually(readResponse(conn1)).Should(ContainSubstring("properties"))
+			readResponse(conn2)
+			Expect(maxActive).To(Equal(1))
+		})
+
+		It("does not let requests waiting for their container take a slot", func() {
+			conn1 := sendRequest(socketPath, "up", "handle-1", manager.UpInputs{Pid: 1})
+			Eventually(activeCount("handle-1")).Should(Equal(1))
+			conn2 := sendRequest(socketPath, "down", "handle-1", nil)
+			Eventually(mux.QueueDepth).Should(Equal(float64(1)))
+
+			conn3 := sendRequest(socketPath, "up", "handle-2", manager.UpInputs{Pid: 1})
+			Eventually(activeCount("handle-2")).Should(Equal(1))
+
+			close(release)
+
+			Eventually(readResponse(conn1)).Should(ContainSubstring("properties"))
+			readResponse(conn2)
+			Eventually(readResponse(conn3)).Should(ContainSubstring("properties"))
+			Expect(maxActive).To(Equal(2))
+		})
+
+		It("handles requests without a handle exclusively", func() {
+			conn1 := sendRequest(socketPath, "up", "handle-1", manager.UpInputs{Pid: 1})
+			Eventually(activeCount("handle-1")).Should(Equal(1))
+
+			conn2 := sendRequest(socketPath, "reconcile", "", manager.ReconcileInputs{Handles: []string{}})
+			Consistently(activeCount("reconcile"), "100ms").Should(Equal(0))
+
+			close(release)
+
+			Eventually(readResponse(conn1)).Should(ContainSubstring("properties"))
+			Eventually(readResponse(conn2)).Should(ContainSubstring("deleted_networks"))
+			Expect(maxActive).To(Equal(1))
+		})
+
+		It("records the latency, count and failures of each action", func() {
+			close(release)
+
+			readResponse(sendRequest(socketPath, "up", "handle-1", manager.UpInputs{Pid: 1}))
+			readResponse(sendRequest(socketPath, "down", "handle-1", nil))
+
+			Eventually(metricsSender.SendDurationCallCount).Should(Equal(2))
+			durationNames := []string{}
+			for i := 0; i < 2; i++ {
+				name, _ := metricsSender.SendDurationArgsForCall(i)
+				durationNames = append(durationNames, name)
+			}
+			Expect(durationNames).To(ConsistOf("upRequestTime", "downRequestTime"))
+
+			Eventually(metricsSender.IncrementCounterCallCount).Should(Equal(3))
+			counterNames := []string{}
+			for i := 0; i < 3; i++ {
+				counterNames = append(counterNames, metricsSender.IncrementCounterArgsForCall(i))
+			}
+			Expect(counterNames).To(ConsistOf("upRequestCount", "downRequestCount", "downRequestFailures"))
+		})
+
+		It("logs each request with its action and handle", func() {
+			close(release)
+
+			readResponse(sendRequest(socketPath, "up", "handle-1", manager.UpInputs{Pid: 1}))
+			Eventually(logger).Should(gbytes.Say(`"message":"test.request.complete".*"action":"up",.*"handle":"handle-1"`))
+
+			readResponse(sendRequest(socketPath, "down", "handle-1", nil))
+			Eventually(logger).Should(gbytes.Say(`"message":"test.request.failed".*"action":"down",.*"error":"potato","handle":"handle-1"`))
+		})
+
+		Describe("Drain", func() {
+			It("stops accepting connections and waits for requests in flight", func() {
+				conn := sendRequest(socketPath, "up", "handle-1", manager.UpInputs{Pid: 1})
+				Eventually(activeCount("handle-1")).Should(Equal(1))
+
+				mux.Drain()
+
+				_, err := net.Dial("unix", socketPath)
+				Expect(err).To(HaveOccurred())
+				Consistently(serveErr, "100ms").ShouldNot(Receive())
+
+				close(release)
+
+				Eventually(readResponse(conn)).Should(ContainSubstring("properties"))
+				Eventually(serveErr).Should(Receive(BeNil()))
+			})
+
+			Context("when the requests in flight outlast the drain timeout", func() {
+				BeforeEach(func() {
+					mux.DrainTimeout = 50 * time.Millisecond
+				})
+
+				It("returns an error", func() {
+					sendRequest(socketPath, "up", "handle-1", manager.UpInputs{Pid: 1})
+					Eventually(activeCount("handle-1")).Should(Equal(1))
+
+					mux.Drain()
+
+					Eventually(serveErr).Should(Receive(MatchError("drain timed out after 50ms with requests in flight")))
+				})
+			})
+		})
+	})
+
+	Describe("Serve when accepting fails", func() {
+		var (
+			mux        *ipc.Mux
+			logger     *lagertest.TestLogger
+			tmpDir     string
+			socketPath string
+			listener   *failingListener
+			serveErr   chan error
+		)
+
+		BeforeEach(func() {
+			var err error
+			tmpDir, err = ioutil.TempDir("", "ipc-")
+			Expect(err).NotTo(HaveOccurred())
+			socketPath = filepath.Join(tmpDir, "networker.sock")
+
+			unixListener, err := net.Listen("unix", socketPath)
+			Expect(err).NotTo(HaveOccurred())
+			listener = &failingListener{Listener: unixListener}
+
+			logger = lagertest.NewTestLogger("test")
+			mux = &ipc.Mux{
+				Up: func(handle string, inputs manager.UpInputs, netNSFD *uintptr) (*manager.UpOutputs, error) {
+					return &manager.UpOutputs{}, nil
+				},
+			}
+			serveErr = make(chan error, 1)
+		})
+
+		JustBeforeEach(func() {
+			go func() {
+				defer GinkgoRecover()
+				serveErr <- mux.Serve(logger, listener)
+			}()
+		})
+
+		AfterEach(func() {
+			mux.Drain()
+			Expect(os.RemoveAll(tmpDir)).To(Succeed())
+		})
+
+		Context("when the error is temporary", func() {
+			BeforeEach(func() {
+				listener.errs = []error{temporaryError{}, temporaryError{}, temporaryError{}}
+			})
+
+			It("retries with a growing delay", func() {
+				Eventually(readResponse(sendRequest(socketPath, "up", "handle-1", manager.UpInputs{Pid: 1}))).Should(ContainSubstring("properties"))
+
+				Expect(logger).To(gbytes.Say(`"message":"test.accept".*"error":"too many open files","retry-in":"5ms"`))
+				Expect(logger).To(gbytes.Say(`"message":"test.accept".*"error":"too many open files","retry-in":"10ms"`))
+				Expect(logger).To(gbytes.Say(`"message":"test.accept".*"error":"too many open files","retry-in":"20ms"`))
+				Consistently(serveErr).ShouldNot(Receive())
+			})
+		})
+
+		Context("when the error is not temporary", func() {
+			BeforeEach(func() {
+				listener.errs = []error{errors.New("potato")}
+			})
+
+			It("returns it", func() {
+				Eventually(serveErr).Should(Receive(MatchError("accept: potato")))
+				Expect(logger).To(gbytes.Say(`"message":"test.accept".*"error":"potato"`))
+			})
+		})
+	})
+})
+
+// failingListener returns errs from Accept before it accepts connections.
+type failingListener struct {
+	net.Listener
+	mutex sync.Mutex
+	errs  []error
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	l.mutex.Lock()
+	if len(l.errs) > 0 {
+		err := l.errs[0]
+		l.errs = l.errs[1:]
+		l.mutex.Unlock()
+		return nil, err
+	}
+	l.mutex.Unlock()
+	return l.Listener.Accept()
+}
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "too many open files" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+func sendRequest(socketPath, action, handle string, inputs interface{}) net.Conn {
+	conn, err := net.Dial("unix", socketPath)
+	Expect(err).NotTo(HaveOccurred())
+
+	netNS, err := os.Open("/dev/null")
+	Expect(err).NotTo(HaveOccurred())
+	defer netNS.Close()
+
+	_, _, err = conn.(*net.UnixConn).WriteMsgUnix(nil, unix.UnixRights(int(netNS.Fd())), nil)
+	Expect(err).NotTo(HaveOccurred())
+
+	data, err := json.Marshal(inputs)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(json.NewEncoder(conn).Encode(message.Message{
+		Command: []byte(action),
+		Handle:  []byte(handle),
+		Data:    data,
+	})).To(Succeed())
+
+	return conn
+}
+
+// readResponse returns what was written to the connection before the mux
+// closed it. Failed requests close the connection without a response, which
+// may reset it, so read errors are ignored.
+func readResponse(conn net.Conn) string {
+	defer conn.Close()
+	response, _ := ioutil.ReadAll(conn)
+	return string(response)
+}
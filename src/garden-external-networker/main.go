@@ -9,17 +9,25 @@ import (
 	"garden-external-networker/config"
 	"garden-external-networker/ipc"
 	"garden-external-networker/manager"
+	"garden-external-networker/metrics_sink"
+	"garden-external-networker/netrules"
 	"garden-external-networker/port_allocator"
 	"garden-external-networker/proxy"
 	"io"
 	"lib/rules"
-	"lib/serial"
+	"lib/rules/nftables"
+	"math/rand"
 	"os"
 	"sync"
+	"time"
 
+	"github.com/cloudfoundry/dropsonde"
 	"github.com/coreos/go-iptables/iptables"
+	"github.com/tedsuo/ifrit"
 
+	"code.cloudfoundry.org/cf-networking-helpers/metrics"
 	"code.cloudfoundry.org/filelock"
+	"code.cloudfoundry.org/lager"
 )
 
 var (
@@ -30,6 +38,11 @@ var (
 	socketPath string
 )
 
+type metricsSender interface {
+	IncrementCounter(name string)
+	SendDuration(name string, duration time.Duration)
+}
+
 func parseArgs(allArgs []string) error {
 	var configFilePath string
 
@@ -59,7 +72,7 @@ func parseArgs(allArgs []string) error {
 	}
 
 	if socketPath == "" {
-		if handle == "" {
+		if handle == "" && ipc.RequiresHandle(action) {
 			return fmt.Errorf("missing required flag 'handle'")
 		}
 
@@ -91,13 +104,21 @@ func mainWithError(logger io.Writer) error {
 		return fmt.Errorf("parse args: %s", err)
 	}
 
+	lagerLogger := lager.NewLogger(fmt.Sprintf("%s.garden-external-networker", cfg.LogPrefix))
+	lagerLogger.RegisterSink(lager.NewWriterSink(logger, lager.INFO))
+
+	sender, err := newMetricsSender(lagerLogger)
+	if err != nil {
+		return err
+	}
+
 	cniLoader := &cni.CNILoader{
 		PluginDir: cfg.CniPluginDir,
 		ConfigDir: cfg.CniConfigDir,
-		Logger:    logger,
+		Logger:    lagerLogger.Session("cni-loader"),
 	}
 
-	networkConfigList, err := cniLoader.GetNetworkConfig()
+	networkConfigList, networkConfigLists, err := cniLoader.Load(cfg.DefaultCniChain)
 	if err != nil {
 		return fmt.Errorf("load cni config: %s", err)
 	}
@@ -105,42 +126,44 @@ func mainWithError(logger io.Writer) error {
 	cniController := &cni.CNIController{
 		CNIConfig:         cniLoader.GetCNIConfig(),
 		NetworkConfigList: networkConfigList,
+		Checker:           &cni.PluginInvoker{PluginDirs: []string{cfg.CniPluginDir}},
+		ResultCache:       &cni.ResultCache{Dir: cfg.CniResultCacheDir},
+
+		NetworkConfigLists: networkConfigLists,
+		Chains:             &cni.ChainStore{Dir: cfg.CniResultCacheDir},
 	}
 
 	mounter := &bindmount.Mounter{}
 
-	locker := filelock.NewLocker(cfg.StateFilePath)
+	// The state file is replaced on every save, so the lock is held on a
+	// separate file that stays put.
+	locker := filelock.NewLocker(cfg.StateFilePath + ".lock")
+	portRanges := []port_allocator.PortRange{}
+	for _, portRange := range cfg.PortRanges {
+		portRanges = append(portRanges, port_allocator.PortRange{Start: portRange.Start, Size: portRange.Size})
+	}
 	tracker := &port_allocator.Tracker{
-		StartPort: cfg.StartPort,
-		Capacity:  cfg.TotalPorts,
+		Ranges:   portRanges,
+		Reserved: cfg.ReservedPorts,
+		Strategy: cfg.PortAllocationStrategy,
+		Rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
 	}
-	serializer := &serial.Serial{}
 	portAllocator := &port_allocator.PortAllocator{
-		Tracker:    tracker,
-		Serializer: serializer,
-		Locker:     locker,
+		Tracker: tracker,
+		Store:   port_allocator.NewStateFile(cfg.StateFilePath),
+		Locker:  locker,
+		Logger:  lagerLogger.Session("port-allocator"),
 	}
 
-	ipt, err := iptables.New()
+	ipTablesAdapter, err := newIPTablesAdapter()
 	if err != nil {
 		panic(err)
 	}
 
-	iptLocker := &filelock.Locker{
-		FileLocker: filelock.NewLocker(cfg.IPTablesLockFile),
-		Mutex:      &sync.Mutex{},
-	}
-	restorer := &rules.Restorer{}
-	lockedIPTables := &rules.LockedIPTables{
-		IPTables: ipt,
-		Locker:   iptLocker,
-		Restorer: restorer,
-	}
-
 	namespaceAdapter := &adapter.NamespaceAdapter{}
 
 	proxyRedirect := &proxy.Redirect{
-		IPTables:         lockedIPTables,
+		IPTables:         ipTablesAdapter,
 		NamespaceAdapter: namespaceAdapter,
 		RedirectCIDR:     cfg.ProxyRedirectCIDR,
 		ProxyPort:        cfg.ProxyPort,
@@ -148,22 +171,107 @@ func mainWithError(logger io.Writer) error {
 	}
 
 	manager := &manager.Manager{
-		Logger:        logger,
+		Logger:        lagerLogger,
+		MetricsSender: sender,
 		CNIController: cniController,
 		Mounter:       mounter,
 		ProxyRedirect: proxyRedirect,
 		BindMountRoot: cfg.BindMountDir,
 		PortAllocator: portAllocator,
 		SearchDomains: cfg.SearchDomains,
+		NetRules:      &netrules.Store{Dir: cfg.NetRulesDir},
+		Firewall:      &netrules.IPTables{IPTables: ipTablesAdapter},
 	}
 
 	mux := ipc.Mux{
-		Up:   manager.Up,
-		Down: manager.Down,
+		Up:        manager.Up,
+		Down:      manager.Down,
+		Reconcile: manager.Reconcile,
+		Check:     manager.Check,
+		Update:    manager.Update,
+
+		MaxConcurrentRequests: cfg.MaxConcurrentRequests,
+		DrainTimeout:          time.Duration(cfg.DrainTimeoutSeconds) * time.Second,
+		MetricsSender:         sender,
 	}
 
 	if socketPath != "" {
-		return mux.HandleWithSocket(logger, socketPath)
+		if cfg.MetricsSink == "dropsonde" && cfg.MetronPort > 0 {
+			startMetricsEmitter(lagerLogger, &mux)
+		}
+		if cfg.CniConfigReloadSeconds > 0 {
+			ifrit.Background(&cni.ConfigReloader{
+				Loader:       cniLoader,
+				Controller:   cniController,
+				DefaultChain: cfg.DefaultCniChain,
+				Interval:     time.Duration(cfg.CniConfigReloadSeconds) * time.Second,
+				Logger:       lagerLogger,
+			})
+		}
+		return mux.HandleWithSocket(lagerLogger, socketPath)
 	}
 	return mux.Handle(action, handle, os.Stdin, os.Stdout)
 }
+
+// newMetricsSender returns the sender for the configured metrics sink, or nil
+// when metrics are turned off.
+func newMetricsSender(logger lager.Logger) (metricsSender, error) {
+	switch cfg.MetricsSink {
+	case "dropsonde":
+		if cfg.MetronPort == 0 {
+			return nil, nil
+		}
+		metronAddress := fmt.Sprintf("127.0.0.1:%d", cfg.MetronPort)
+		err := dropsonde.Initialize(metronAddress, "garden-external-networker")
+		if err != nil {
+			return nil, fmt.Errorf("initializing dropsonde: %s", err)
+		}
+		return &metrics.MetricsSender{Logger: logger}, nil
+	case "statsd":
+		return metrics_sink.NewStatsd(cfg.StatsdAddress, "garden-external-networker")
+	case "log":
+		return &metrics_sink.Log{Logger: logger.Session("metrics")}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// newIPTablesAdapter returns the adapter for the configured firewall backend.
+func newIPTablesAdapter() (rules.IPTablesAdapter, error) {
+	iptLocker := &filelock.Locker{
+		FileLocker: filelock.NewLocker(cfg.IPTablesLockFile),
+		Mutex:      &sync.Mutex{},
+	}
+
+	if cfg.FirewallBackend == "nftables" {
+		return &nftables.Adapter{
+			Conn:   &nftables.NetlinkConn{},
+			Locker: iptLocker,
+		}, nil
+	}
+
+	ipt, err := iptables.New()
+	if err != nil {
+		return nil, err
+	}
+	return &rules.LockedIPTables{
+		IPTables: ipt,
+		Locker:   iptLocker,
+		Restorer: &rules.Restorer{},
+	}, nil
+}
+
+func startMetricsEmitter(metricsLogger lager.Logger, mux *ipc.Mux) {
+	queueDepthSource := metrics.MetricSource{
+		Name:   "queueDepth",
+		Unit:   "requests",
+		Getter: mux.QueueDepth,
+	}
+	metricsEmitter := metrics.NewMetricsEmitter(
+		metricsLogger,
+		time.Duration(cfg.MetricsEmitSeconds)*time.Second,
+		metrics.NewUptimeSource(),
+		queueDepthSource,
+	)
+	ifrit.Background(metricsEmitter)
+}
@@ -4,45 +4,76 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"garden-external-networker/netrules"
+	"garden-external-networker/proxy"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
 	"github.com/containernetworking/cni/pkg/types"
-	"github.com/containernetworking/cni/pkg/types/020"
+	"github.com/containernetworking/cni/pkg/types/current"
 )
 
 //go:generate counterfeiter -o ../fakes/proxyRedirect.go --fake-name ProxyRedirect . proxyRedirect
 type proxyRedirect interface {
-	Apply(containerNamespace string) error
+	Settings(properties map[string]interface{}) (proxy.Settings, error)
+	Apply(containerNamespace string, settings proxy.Settings) ([]string, error)
 }
 
 //go:generate counterfeiter -o ../fakes/cniController.go --fake-name CNIController . cniController
 type cniController interface {
 	Up(namespacePath, handle string, metadata map[string]interface{}, legacyNetConf map[string]interface{}) (types.Result, error)
 	Down(namespacePath, handle string) error
+	Check(namespacePath, handle string) error
+	Update(namespacePath, handle string, metadata map[string]interface{}, legacyNetConf map[string]interface{}) (types.Result, error)
 }
 
 //go:generate counterfeiter -o ../fakes/mounter.go --fake-name Mounter . mounter
 type mounter interface {
 	IdempotentlyMount(source, target string) error
 	RemoveMount(target string) error
+	ListMounts(root string) ([]string, error)
 }
 
 //go:generate counterfeiter -o ../fakes/portAllocator.go --fake-name PortAllocator . portAllocator
 type portAllocator interface {
 	AllocatePort(handle string, port int) (int, error)
 	ReleaseAllPorts(handle string) error
+	ReleasePort(handle string, port int) error
+	AllocatedHandles() ([]string, error)
+}
+
+//go:generate counterfeiter -o ../fakes/firewall.go --fake-name Firewall . firewall
+type firewall interface {
+	Apply(handle string, rules netrules.Rules) error
+	Remove(handle string) error
+}
+
+//go:generate counterfeiter -o ../fakes/netRules.go --fake-name NetRules . netRules
+type netRules interface {
+	Save(handle string, rules netrules.Rules) error
+	Load(handle string) (netrules.Rules, error)
+	Delete(handle string) error
+}
+
+type metricsSender interface {
+	SendDuration(name string, duration time.Duration)
 }
 
 type Manager struct {
-	Logger        io.Writer
+	Logger        lager.Logger
+	MetricsSender metricsSender
 	CNIController cniController
 	Mounter       mounter
 	BindMountRoot string
 	PortAllocator portAllocator
 	SearchDomains []string
 	ProxyRedirect proxyRedirect
+	NetRules      netRules
+	Firewall      firewall
 }
 
 type UpInputs struct {
@@ -54,13 +85,58 @@ type UpInputs struct {
 type UpOutputs struct {
 	Properties struct {
 		ContainerIP      string `json:"garden.network.container-ip"`
+		ContainerIPv6    string `json:"garden.network.container-ipv6,omitempty"`
+		ContainerIPs     string `json:"garden.network.container-ips"`
+		Interfaces       string `json:"garden.network.interfaces,omitempty"`
+		Routes           string `json:"garden.network.routes,omitempty"`
 		DeprecatedHostIP string `json:"garden.network.host-ip"`
 		MappedPorts      string `json:"garden.network.mapped-ports"`
+		ProxyRules       string `json:"garden.network.proxy-redirect-rules,omitempty"`
 	} `json:"properties"`
 	DNSServers    []string `json:"dns_servers,omitempty"`
 	SearchDomains []string `json:"search_domains,omitempty"`
 }
 
+// UpdateInputs are the new rules of a container. Properties default to the
+// ones the container was brought up with, and can only be changed by
+// re-creating the network with RecreateNetwork.
+type UpdateInputs struct {
+	NetOut          []garden.NetOutRule    `json:"netout_rules"`
+	NetIn           []garden.NetIn         `json:"netin"`
+	Properties      map[string]interface{} `json:"properties,omitempty"`
+	RecreateNetwork bool                   `json:"recreate_network,omitempty"`
+}
+
+type UpdateOutputs struct {
+	Properties struct {
+		MappedPorts   string `json:"garden.network.mapped-ports"`
+		ContainerIP   string `json:"garden.network.container-ip,omitempty"`
+		ContainerIPv6 string `json:"garden.network.container-ipv6,omitempty"`
+		ContainerIPs  string `json:"garden.network.container-ips,omitempty"`
+	} `json:"properties"`
+}
+
+type ReconcileInputs struct {
+	Handles []string `json:"handles"`
+}
+
+type ReconcileSummary struct {
+	DeletedNetworks   []string `json:"deleted_networks"`
+	RemovedBindMounts []string `json:"removed_bind_mounts"`
+	ReleasedHandles   []string `json:"released_handles"`
+	Errors            []string `json:"errors,omitempty"`
+}
+
+type CheckOutputs struct {
+	Containers []ContainerHealth `json:"containers"`
+}
+
+type ContainerHealth struct {
+	Handle  string `json:"handle"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
 func (m *Manager) Up(containerHandle string, inputs UpInputs, nsFD *uintptr) (*UpOutputs, error) {
 	if inputs.Pid == 0 {
 		return nil, errors.New("up missing pid")
@@ -69,6 +145,16 @@ func (m *Manager) Up(containerHandle string, inputs UpInputs, nsFD *uintptr) (*U
 		return nil, errors.New("up missing container handle")
 	}
 
+	logger := m.Logger.Session("up", lager.Data{"handle": containerHandle})
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	// bad properties are rejected before any state exists for the container
+	proxySettings, err := m.ProxyRedirect.Settings(inputs.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("proxy redirect: %s", err)
+	}
+
 	procNsPath := fmt.Sprintf("/proc/%d/ns/net", inputs.Pid)
 	if nsFD != nil {
 		procNsPath = fmt.Sprintf("/proc/self/fd/%d", *nsFD)
@@ -76,16 +162,20 @@ func (m *Manager) Up(containerHandle string, inputs UpInputs, nsFD *uintptr) (*U
 
 	bindMountPath := filepath.Join(m.BindMountRoot, containerHandle)
 
-	err := m.Mounter.IdempotentlyMount(procNsPath, bindMountPath)
+	done := m.startPhase(logger, "up", "bind-mount")
+	err = m.Mounter.IdempotentlyMount(procNsPath, bindMountPath)
+	done()
 	if err != nil {
 		return nil, fmt.Errorf("failed mounting %s to %s: %s", procNsPath, bindMountPath, err)
 	}
 
+	done = m.startPhase(logger, "up", "port-allocation")
 	mappedPorts := []garden.PortMapping{}
 	for i := range inputs.NetIn {
 		if inputs.NetIn[i].HostPort == 0 {
 			hostPort, err := m.PortAllocator.AllocatePort(containerHandle, int(inputs.NetIn[i].HostPort))
 			if err != nil {
+				done()
 				return nil, fmt.Errorf("allocating port: %s", err)
 			}
 			inputs.NetIn[i].HostPort = uint32(hostPort)
@@ -96,7 +186,9 @@ func (m *Manager) Up(containerHandle string, inputs UpInputs, nsFD *uintptr) (*U
 			ContainerPort: inputs.NetIn[i].ContainerPort,
 		})
 	}
+	done()
 
+	done = m.startPhase(logger, "up", "cni-add")
 	result, err := m.CNIController.Up(
 		bindMountPath,
 		containerHandle,
@@ -106,6 +198,7 @@ func (m *Manager) Up(containerHandle string, inputs UpInputs, nsFD *uintptr) (*U
 			"netOutRules":  inputs.NetOut,
 		},
 	)
+	done()
 	if err != nil {
 		return nil, fmt.Errorf("cni up failed: %s", err)
 	}
@@ -114,52 +207,500 @@ func (m *Manager) Up(containerHandle string, inputs UpInputs, nsFD *uintptr) (*U
 		return nil, errors.New("cni up failed: no ip allocated")
 	}
 
-	result020, err := result.GetAsVersion("0.2.0")
+	currentResult, err := current.NewResultFromResult(result)
 	if err != nil {
 		return nil, fmt.Errorf("cni plugin result version incompatible: %s", err) // not tested
 	}
 
-	err = m.ProxyRedirect.Apply(bindMountPath)
+	if len(currentResult.IPs) == 0 {
+		return nil, errors.New("cni up failed: no ip allocated")
+	}
+
+	done = m.startPhase(logger, "up", "proxy-redirect")
+	proxyRules, err := m.ProxyRedirect.Apply(bindMountPath, proxySettings)
+	done()
 	if err != nil {
 		return nil, fmt.Errorf("proxy redirect apply: %s", err)
 	}
 
-	containerIP := result020.(*types020.Result).IP4.IP.IP
+	containerIPv4, containerIPv6, containerIPs := splitContainerIPs(currentResult.IPs)
+
+	done = m.startPhase(logger, "up", "net-rules")
+	err = m.NetRules.Save(containerHandle, netrules.Rules{
+		NetIn:       inputs.NetIn,
+		NetOut:      inputs.NetOut,
+		Properties:  inputs.Properties,
+		UpNetIn:     inputs.NetIn,
+		UpNetOut:    inputs.NetOut,
+		ContainerIP: containerIPv4,
+	})
+	done()
+	if err != nil {
+		return nil, fmt.Errorf("saving net rules: %s", err)
+	}
 
 	outputs := UpOutputs{}
 	outputs.Properties.MappedPorts = toJson(mappedPorts)
-	outputs.Properties.ContainerIP = containerIP.String()
+	outputs.Properties.ContainerIP = containerIPv4
+	if containerIPv4 == "" {
+		outputs.Properties.ContainerIP = containerIPv6
+	}
+	outputs.Properties.ContainerIPv6 = containerIPv6
+	outputs.Properties.ContainerIPs = toJson(containerIPs)
+	if len(currentResult.Interfaces) > 0 {
+		outputs.Properties.Interfaces = toJson(currentResult.Interfaces)
+	}
+	if len(currentResult.Routes) > 0 {
+		outputs.Properties.Routes = toJson(currentResult.Routes)
+	}
+	if len(proxyRules) > 0 {
+		outputs.Properties.ProxyRules = toJson(proxyRules)
+	}
 	outputs.Properties.DeprecatedHostIP = "255.255.255.255"
-	outputs.DNSServers = result020.(*types020.Result).DNS.Nameservers
+	outputs.DNSServers = currentResult.DNS.Nameservers
 	outputs.SearchDomains = m.SearchDomains
 	return &outputs, nil
 }
 
+// splitContainerIPs returns the first IPv4 and the first IPv6 address of a
+// CNI result, along with every address in the order the plugins reported them.
+func splitContainerIPs(ipConfigs []*current.IPConfig) (string, string, []string) {
+	var ipv4, ipv6 string
+	all := []string{}
+
+	for _, ipConfig := range ipConfigs {
+		ip := ipConfig.Address.IP
+		all = append(all, ip.String())
+
+		if ip.To4() != nil {
+			if ipv4 == "" {
+				ipv4 = ip.String()
+			}
+		} else if ipv6 == "" {
+			ipv6 = ip.String()
+		}
+	}
+
+	return ipv4, ipv6, all
+}
+
 func (m *Manager) Down(containerHandle string) error {
 	if containerHandle == "" {
 		return errors.New("down missing container handle")
 	}
 
+	logger := m.Logger.Session("down", lager.Data{"handle": containerHandle})
+	logger.Info("starting")
+	defer logger.Info("complete")
+
 	bindMountPath := filepath.Join(m.BindMountRoot, containerHandle)
 
+	done := m.startPhase(logger, "down", "cni-del")
 	err := m.CNIController.Down(bindMountPath, containerHandle)
+	done()
 	if err != nil {
 		return fmt.Errorf("cni down: %s", err)
 	}
 
-	if err = m.Mounter.RemoveMount(bindMountPath); err != nil {
-		fmt.Fprintf(m.Logger, "removing bind mount %s: %s\n", bindMountPath, err)
+	done = m.startPhase(logger, "down", "bind-mount")
+	err = m.Mounter.RemoveMount(bindMountPath)
+	done()
+	if err != nil {
+		logger.Error("removing-bind-mount", err, lager.Data{"path": bindMountPath})
 	}
 
-	if err = m.PortAllocator.ReleaseAllPorts(containerHandle); err != nil {
-		fmt.Fprintf(m.Logger, "releasing ports: %s\n", err)
+	done = m.startPhase(logger, "down", "port-release")
+	err = m.PortAllocator.ReleaseAllPorts(containerHandle)
+	done()
+	if err != nil {
+		logger.Error("releasing-ports", err)
+	}
+
+	done = m.startPhase(logger, "down", "net-rules")
+	err = m.Firewall.Remove(containerHandle)
+	if err != nil {
+		logger.Error("removing-firewall-rules", err)
+	}
+	err = m.NetRules.Delete(containerHandle)
+	done()
+	if err != nil {
+		logger.Error("removing-net-rules", err)
 	}
 
 	return nil
 }
 
-func toJson(mappedPorts []garden.PortMapping) string {
-	bytes, err := json.Marshal(mappedPorts)
+// startPhase times one phase of an action. The returned func logs the
+// duration and sends it as <action><Phase>Time, e.g. upCniAddTime.
+func (m *Manager) startPhase(logger lager.Logger, action, phase string) func() {
+	start := time.Now()
+	return func() {
+		duration := time.Since(start)
+		logger.Info("phase-complete", lager.Data{"phase": phase, "duration": duration.String()})
+
+		if m.MetricsSender != nil {
+			m.MetricsSender.SendDuration(action+camelCase(phase)+"Time", duration)
+		}
+	}
+}
+
+func camelCase(phase string) string {
+	words := strings.Split(phase, "-")
+	for i, word := range words {
+		if word != "" {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// Update replaces the port mappings and egress rules of a running container.
+// Host ports are allocated for new mappings and released for removed ones; a
+// mapping without a host port keeps the host port it already had for the same
+// container port. The change is applied in place by the Firewall, on top of
+// the rules the CNI plugins set up on up, so that the container keeps its
+// network, its address and its connections. If that fails the old rules are
+// applied again and the new host ports are released. Containers without
+// saved rules, such as those created by an older version, are treated as
+// having none.
+//
+// With RecreateNetwork the CNI plugins re-create the network with the new
+// rules and properties instead. That interrupts the traffic of the container
+// and may give it a new address, so it is only done when asked for.
+func (m *Manager) Update(containerHandle string, inputs UpdateInputs) (*UpdateOutputs, error) {
+	if containerHandle == "" {
+		return nil, errors.New("update missing container handle")
+	}
+
+	logger := m.Logger.Session("update", lager.Data{"handle": containerHandle})
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	oldRules, err := m.NetRules.Load(containerHandle)
+	if err != nil {
+		return nil, fmt.Errorf("loading net rules: %s", err)
+	}
+
+	if inputs.Properties != nil && !inputs.RecreateNetwork && toJson(inputs.Properties) != toJson(oldRules.Properties) {
+		return nil, errors.New("changing properties requires recreate_network")
+	}
+
+	newNetIn, allocatedPorts, err := m.resolveHostPorts(logger, containerHandle, oldRules.NetIn, inputs.NetIn)
+	if err != nil {
+		return nil, err
+	}
+	newRules := oldRules
+	newRules.NetIn = newNetIn
+	newRules.NetOut = inputs.NetOut
+
+	outputs := UpdateOutputs{}
+	rollback := m.rollbackUpdate
+	if inputs.RecreateNetwork {
+		rollback = m.rollbackRecreate
+		if inputs.Properties != nil {
+			newRules.Properties = inputs.Properties
+		}
+		newRules, err = m.recreateNetwork(logger, containerHandle, newRules, &outputs)
+		if err != nil {
+			rollback(logger, containerHandle, oldRules, allocatedPorts)
+			return nil, fmt.Errorf("cni update failed: %s", err)
+		}
+	} else {
+		err = m.Firewall.Apply(containerHandle, newRules)
+		if err != nil {
+			rollback(logger, containerHandle, oldRules, allocatedPorts)
+			return nil, fmt.Errorf("applying net rules: %s", err)
+		}
+	}
+
+	err = m.NetRules.Save(containerHandle, newRules)
+	if err != nil {
+		rollback(logger, containerHandle, oldRules, allocatedPorts)
+		return nil, fmt.Errorf("saving net rules: %s", err)
+	}
+
+	keptPorts := map[uint32]bool{}
+	for _, netIn := range newRules.NetIn {
+		keptPorts[netIn.HostPort] = true
+	}
+	for _, netIn := range oldRules.NetIn {
+		if keptPorts[netIn.HostPort] {
+			continue
+		}
+		if err := m.PortAllocator.ReleasePort(containerHandle, int(netIn.HostPort)); err != nil {
+			logger.Error("releasing-port", err, lager.Data{"port": netIn.HostPort})
+		}
+	}
+
+	mappedPorts := []garden.PortMapping{}
+	for _, netIn := range newRules.NetIn {
+		mappedPorts = append(mappedPorts, garden.PortMapping{
+			HostPort:      netIn.HostPort,
+			ContainerPort: netIn.ContainerPort,
+		})
+	}
+	outputs.Properties.MappedPorts = toJson(mappedPorts)
+	return &outputs, nil
+}
+
+// recreateNetwork re-creates the network of a container with the new rules
+// through DEL and ADD of its CNI chain, after which the plugins enforce them
+// on their own.
+func (m *Manager) recreateNetwork(logger lager.Logger, containerHandle string, newRules netrules.Rules, outputs *UpdateOutputs) (netrules.Rules, error) {
+	bindMountPath := filepath.Join(m.BindMountRoot, containerHandle)
+
+	result, err := m.CNIController.Update(bindMountPath, containerHandle, newRules.Properties, toLegacyNetConf(newRules.NetIn, newRules.NetOut))
+	if err != nil {
+		return newRules, err
+	}
+
+	newRules.UpNetIn = newRules.NetIn
+	newRules.UpNetOut = newRules.NetOut
+
+	// the plugins may have given the container a new address
+	if result != nil {
+		currentResult, err := current.NewResultFromResult(result)
+		if err != nil {
+			return newRules, fmt.Errorf("plugin result version incompatible: %s", err) // not tested
+		}
+		containerIPv4, containerIPv6, containerIPs := splitContainerIPs(currentResult.IPs)
+		newRules.ContainerIP = containerIPv4
+		outputs.Properties.ContainerIP = containerIPv4
+		if containerIPv4 == "" {
+			outputs.Properties.ContainerIP = containerIPv6
+		}
+		outputs.Properties.ContainerIPv6 = containerIPv6
+		outputs.Properties.ContainerIPs = toJson(containerIPs)
+	}
+
+	// the plugins now enforce the new rules themselves
+	if err := m.Firewall.Remove(containerHandle); err != nil {
+		logger.Error("removing-firewall-rules", err)
+	}
+	return newRules, nil
+}
+
+// resolveHostPorts fills in the host port of every mapping that doesn't have
+// one, reusing the host port of an old mapping for the same container port
+// before allocating a new one. The newly allocated ports are returned so that
+// they can be released on rollback.
+func (m *Manager) resolveHostPorts(logger lager.Logger, containerHandle string, oldNetIn, netIn []garden.NetIn) ([]garden.NetIn, []int, error) {
+	reusable := map[uint32][]uint32{}
+	for _, old := range oldNetIn {
+		reusable[old.ContainerPort] = append(reusable[old.ContainerPort], old.HostPort)
+	}
+	for _, in := range netIn {
+		if in.HostPort != 0 {
+			reusable[in.ContainerPort] = without(reusable[in.ContainerPort], in.HostPort)
+		}
+	}
+
+	resolved := []garden.NetIn{}
+	allocated := []int{}
+	for _, in := range netIn {
+		if in.HostPort == 0 {
+			if hostPorts := reusable[in.ContainerPort]; len(hostPorts) > 0 {
+				in.HostPort = hostPorts[0]
+				reusable[in.ContainerPort] = hostPorts[1:]
+			} else {
+				hostPort, err := m.PortAllocator.AllocatePort(containerHandle, 0)
+				if err != nil {
+					m.releasePorts(logger, containerHandle, allocated)
+					return nil, nil, fmt.Errorf("allocating port: %s", err)
+				}
+				in.HostPort = uint32(hostPort)
+				allocated = append(allocated, hostPort)
+			}
+		}
+		resolved = append(resolved, in)
+	}
+
+	return resolved, allocated, nil
+}
+
+func (m *Manager) rollbackUpdate(logger lager.Logger, containerHandle string, oldRules netrules.Rules, allocatedPorts []int) {
+	if err := m.Firewall.Apply(containerHandle, oldRules); err != nil {
+		logger.Error("rolling-back-net-rules", err)
+	}
+
+	m.releasePorts(logger, containerHandle, allocatedPorts)
+}
+
+// rollbackRecreate re-creates the network once more with the rules the
+// plugins had before, so that the container isn't left without one, and
+// applies the old rules on top of them again.
+func (m *Manager) rollbackRecreate(logger lager.Logger, containerHandle string, oldRules netrules.Rules, allocatedPorts []int) {
+	bindMountPath := filepath.Join(m.BindMountRoot, containerHandle)
+	_, err := m.CNIController.Update(bindMountPath, containerHandle, oldRules.Properties, toLegacyNetConf(oldRules.UpNetIn, oldRules.UpNetOut))
+	if err != nil {
+		logger.Error("rolling-back-cni-update", err)
+	}
+
+	m.rollbackUpdate(logger, containerHandle, oldRules, allocatedPorts)
+}
+
+func (m *Manager) releasePorts(logger lager.Logger, containerHandle string, ports []int) {
+	for _, port := range ports {
+		if err := m.PortAllocator.ReleasePort(containerHandle, port); err != nil {
+			logger.Error("releasing-port", err, lager.Data{"port": port})
+		}
+	}
+}
+
+// toLegacyNetConf returns the runtimeConfig the plugins get for rules, the
+// same as on up.
+func toLegacyNetConf(netIn []garden.NetIn, netOut []garden.NetOutRule) map[string]interface{} {
+	return map[string]interface{}{
+		"portMappings": netIn,
+		"netOutRules":  netOut,
+	}
+}
+
+func without(list []uint32, value uint32) []uint32 {
+	result := []uint32{}
+	for _, v := range list {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// Check asks the CNI plugins whether the networking of a container is still
+// intact. When no handle is given every bind-mounted container is checked.
+func (m *Manager) Check(containerHandle string) (*CheckOutputs, error) {
+	handles := []string{containerHandle}
+	if containerHandle == "" {
+		var err error
+		handles, err = m.Mounter.ListMounts(m.BindMountRoot)
+		if err != nil {
+			return nil, fmt.Errorf("listing bind mounts: %s", err)
+		}
+		sort.Strings(handles)
+	}
+
+	logger := m.Logger.Session("check")
+
+	outputs := &CheckOutputs{Containers: []ContainerHealth{}}
+	for _, handle := range handles {
+		health := ContainerHealth{Handle: handle, Healthy: true}
+
+		err := m.CNIController.Check(filepath.Join(m.BindMountRoot, handle), handle)
+		if err != nil {
+			logger.Error("cni-check", err, lager.Data{"handle": handle})
+			health.Healthy = false
+			health.Error = err.Error()
+		}
+
+		outputs.Containers = append(outputs.Containers, health)
+	}
+
+	return outputs, nil
+}
+
+// Reconcile cleans up the networking state of containers that are not in
+// inputs.Handles, such as those left behind when garden exits between up and
+// down. A handle whose CNI DEL fails keeps its bind mount and ports so that a
+// later reconcile can retry it.
+func (m *Manager) Reconcile(inputs ReconcileInputs) (*ReconcileSummary, error) {
+	if inputs.Handles == nil {
+		return nil, errors.New("reconcile missing live handles")
+	}
+
+	mounts, err := m.Mounter.ListMounts(m.BindMountRoot)
+	if err != nil {
+		return nil, fmt.Errorf("listing bind mounts: %s", err)
+	}
+
+	portHandles, err := m.PortAllocator.AllocatedHandles()
+	if err != nil {
+		return nil, fmt.Errorf("listing allocated ports: %s", err)
+	}
+
+	live := map[string]bool{}
+	for _, handle := range inputs.Handles {
+		live[handle] = true
+	}
+
+	mounted := map[string]bool{}
+	orphans := map[string]bool{}
+	for _, handle := range mounts {
+		mounted[handle] = true
+		if !live[handle] {
+			orphans[handle] = true
+		}
+	}
+	allocated := map[string]bool{}
+	for _, handle := range portHandles {
+		allocated[handle] = true
+		if !live[handle] {
+			orphans[handle] = true
+		}
+	}
+
+	orphanHandles := []string{}
+	for handle := range orphans {
+		orphanHandles = append(orphanHandles, handle)
+	}
+	sort.Strings(orphanHandles)
+
+	summary := &ReconcileSummary{
+		DeletedNetworks:   []string{},
+		RemovedBindMounts: []string{},
+		ReleasedHandles:   []string{},
+	}
+
+	logger := m.Logger.Session("reconcile")
+
+	for _, handle := range orphanHandles {
+		handleLogger := logger.WithData(lager.Data{"handle": handle})
+		handleLogger.Info("cleaning-up")
+
+		if mounted[handle] {
+			bindMountPath := filepath.Join(m.BindMountRoot, handle)
+
+			if err := m.CNIController.Down(bindMountPath, handle); err != nil {
+				handleLogger.Error("cni-down", err)
+				summary.Errors = append(summary.Errors, fmt.Sprintf("cni down %s: %s", handle, err))
+				continue
+			}
+			summary.DeletedNetworks = append(summary.DeletedNetworks, handle)
+
+			if err := m.Firewall.Remove(handle); err != nil {
+				handleLogger.Error("removing-firewall-rules", err)
+				summary.Errors = append(summary.Errors, fmt.Sprintf("removing firewall rules %s: %s", handle, err))
+			}
+
+			if err := m.NetRules.Delete(handle); err != nil {
+				handleLogger.Error("removing-net-rules", err)
+				summary.Errors = append(summary.Errors, fmt.Sprintf("removing net rules %s: %s", handle, err))
+			}
+
+			if err := m.Mounter.RemoveMount(bindMountPath); err != nil {
+				handleLogger.Error("removing-bind-mount", err, lager.Data{"path": bindMountPath})
+				summary.Errors = append(summary.Errors, fmt.Sprintf("removing bind mount %s: %s", bindMountPath, err))
+			} else {
+				summary.RemovedBindMounts = append(summary.RemovedBindMounts, handle)
+			}
+		}
+
+		if !allocated[handle] {
+			continue
+		}
+
+		if err := m.PortAllocator.ReleaseAllPorts(handle); err != nil {
+			handleLogger.Error("releasing-ports", err)
+			summary.Errors = append(summary.Errors, fmt.Sprintf("releasing ports for %s: %s", handle, err))
+		} else {
+			summary.ReleasedHandles = append(summary.ReleasedHandles, handle)
+		}
+	}
+
+	return summary, nil
+}
+
+func toJson(value interface{}) string {
+	bytes, err := json.Marshal(value)
 	if err != nil {
 		panic(err) // untested, should never happen
 	}
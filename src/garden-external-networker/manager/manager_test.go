@@ -1,7 +1,6 @@
 package manager_test
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"net"
@@ -11,11 +10,16 @@ import (
 
 	"garden-external-networker/fakes"
 	"garden-external-networker/manager"
+	"garden-external-networker/netrules"
+	"garden-external-networker/proxy"
 
+	"code.cloudfoundry.org/lager/lagertest"
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/containernetworking/cni/pkg/types/020"
+	"github.com/containernetworking/cni/pkg/types/current"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 )
 
 var _ = Describe("Manager", func() {
@@ -29,19 +33,25 @@ var _ = Describe("Manager", func() {
 		expectedMetadata      map[string]interface{}
 		expectedLegacyNetConf map[string]interface{}
 		portAllocator         *fakes.PortAllocator
+		netRules              *fakes.NetRules
+		firewall              *fakes.Firewall
 		netInRules            []garden.NetIn
 		netOutRules           []garden.NetOutRule
-		logger                *bytes.Buffer
+		logger                *lagertest.TestLogger
+		metricsSender         *fakes.MetricsSender
 		containerHandle       string
 	)
 
 	BeforeEach(func() {
-		logger = &bytes.Buffer{}
+		logger = lagertest.NewTestLogger("test")
+		metricsSender = &fakes.MetricsSender{}
 		containerHandle = "some-container-handle"
 		mounter = &fakes.Mounter{}
 		cniController = &fakes.CNIController{}
 		portAllocator = &fakes.PortAllocator{}
 		proxyRedirect = &fakes.ProxyRedirect{}
+		netRules = &fakes.NetRules{}
+		firewall = &fakes.Firewall{}
 
 		cniController.UpReturns(&types020.Result{
 			IP4: &types020.IPConfig{
@@ -57,12 +67,15 @@ var _ = Describe("Manager", func() {
 
 		mgr = &manager.Manager{
 			Logger:        logger,
+			MetricsSender: metricsSender,
 			CNIController: cniController,
 			Mounter:       mounter,
 			BindMountRoot: "some/fake/path",
 			PortAllocator: portAllocator,
 			SearchDomains: []string{"pivotal.io", "foo.bar", "baz.me"},
 			ProxyRedirect: proxyRedirect,
+			NetRules:      netRules,
+			Firewall:      firewall,
 		}
 
 		netInRules = []garden.NetIn{
@@ -105,6 +118,54 @@ var _ = Describe("Manager", func() {
 	})
 
 	Describe("Up", func() {
+		It("times each phase", func() {
+			_, err := mgr.Up(containerHandle, upInputs, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			names := []string{}
+			for i := 0; i < metricsSender.SendDurationCallCount(); i++ {
+				name, _ := metricsSender.SendDurationArgsForCall(i)
+				names = append(names, name)
+			}
+			Expect(names).To(Equal([]string{
+				"upBindMountTime",
+				"upPortAllocationTime",
+				"upCniAddTime",
+				"upProxyRedirectTime",
+				"upNetRulesTime",
+			}))
+		})
+
+		It("logs each phase with the container handle", func() {
+			_, err := mgr.Up(containerHandle, upInputs, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(logger).To(gbytes.Say(`"message":"test.up.starting".*"handle":"some-container-handle"`))
+			Expect(logger).To(gbytes.Say(`"message":"test.up.phase-complete".*"handle":"some-container-handle","phase":"bind-mount"`))
+			Expect(logger).To(gbytes.Say(`"message":"test.up.phase-complete".*"handle":"some-container-handle","phase":"cni-add"`))
+			Expect(logger).To(gbytes.Say(`"message":"test.up.complete".*"handle":"some-container-handle"`))
+		})
+
+		Context("when a phase fails", func() {
+			It("still times it", func() {
+				cniController.UpReturns(nil, errors.New("potato"))
+				_, err := mgr.Up(containerHandle, upInputs, nil)
+				Expect(err).To(HaveOccurred())
+
+				Expect(metricsSender.SendDurationCallCount()).To(Equal(3))
+				name, _ := metricsSender.SendDurationArgsForCall(2)
+				Expect(name).To(Equal("upCniAddTime"))
+			})
+		})
+
+		Context("when there is no metrics sender", func() {
+			It("still succeeds", func() {
+				mgr.MetricsSender = nil
+				_, err := mgr.Up(containerHandle, upInputs, nil)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
 		It("should ensure that the netNS is mounted to the provided path", func() {
 			_, err := mgr.Up(containerHandle, upInputs, nil)
 			Expect(err).NotTo(HaveOccurred())
@@ -119,11 +180,30 @@ var _ = Describe("Manager", func() {
 			_, err := mgr.Up(containerHandle, upInputs, nil)
 			Expect(err).NotTo(HaveOccurred())
 
+			Expect(proxyRedirect.SettingsCallCount()).To(Equal(1))
+			Expect(proxyRedirect.SettingsArgsForCall(0)).To(Equal(gardenProperties))
+
 			Expect(proxyRedirect.ApplyCallCount()).To(Equal(1))
-			actualContainerNamespace := proxyRedirect.ApplyArgsForCall(0)
+			actualContainerNamespace, _ := proxyRedirect.ApplyArgsForCall(0)
 			Expect(actualContainerNamespace).To(Equal(filepath.Join("some", "fake", "path", containerHandle)))
 		})
 
+		It("should return the applied proxy redirect rules as a property", func() {
+			proxyRedirect.ApplyReturns([]string{"-t nat -A OUTPUT -d 10.255.0.0/16 -p tcp -j REDIRECT --to-port 15001"}, nil)
+
+			out, err := mgr.Up(containerHandle, upInputs, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(out.Properties.ProxyRules).To(MatchJSON(`["-t nat -A OUTPUT -d 10.255.0.0/16 -p tcp -j REDIRECT --to-port 15001"]`))
+		})
+
+		It("omits the proxy redirect rules when none were applied", func() {
+			out, err := mgr.Up(containerHandle, upInputs, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(out.Properties.ProxyRules).To(BeEmpty())
+		})
+
 		It("should return the IP address in the CNI result as a property", func() {
 			out, err := mgr.Up(containerHandle, upInputs, nil)
 			Expect(err).NotTo(HaveOccurred())
@@ -132,6 +212,99 @@ var _ = Describe("Manager", func() {
 			Expect(out.Properties.DeprecatedHostIP).To(Equal("255.255.255.255"))
 		})
 
+		It("should return every container IP as a property", func() {
+			out, err := mgr.Up(containerHandle, upInputs, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(out.Properties.ContainerIPs).To(MatchJSON(`["169.254.1.2"]`))
+			Expect(out.Properties.ContainerIPv6).To(BeEmpty())
+			Expect(out.Properties.Interfaces).To(BeEmpty())
+			Expect(out.Properties.Routes).To(BeEmpty())
+		})
+
+		Context("when the CNI result is dual-stack with several interfaces", func() {
+			BeforeEach(func() {
+				_, defaultRoute, _ := net.ParseCIDR("0.0.0.0/0")
+				_, defaultRoute6, _ := net.ParseCIDR("::/0")
+				cniController.UpReturns(&current.Result{
+					CNIVersion: "0.3.1",
+					Interfaces: []*current.Interface{
+						{Name: "eth0", Mac: "ee:ee:0a:ff:00:02", Sandbox: "/some/netns"},
+						{Name: "eth1", Sandbox: "/some/netns"},
+					},
+					IPs: []*current.IPConfig{
+						{
+							Version:   "6",
+							Interface: current.Int(0),
+							Address:   net.IPNet{IP: net.ParseIP("fd00::2"), Mask: net.CIDRMask(64, 128)},
+						},
+						{
+							Version:   "4",
+							Interface: current.Int(0),
+							Address:   net.IPNet{IP: net.ParseIP("10.255.0.2"), Mask: net.CIDRMask(32, 32)},
+							Gateway:   net.ParseIP("169.254.0.1"),
+						},
+						{
+							Version:   "4",
+							Interface: current.Int(1),
+							Address:   net.IPNet{IP: net.ParseIP("192.168.5.2"), Mask: net.CIDRMask(24, 32)},
+						},
+					},
+					Routes: []*types.Route{
+						{Dst: *defaultRoute, GW: net.ParseIP("169.254.0.1")},
+						{Dst: *defaultRoute6},
+					},
+					DNS: types.DNS{Nameservers: []string{"8.8.8.8"}},
+				}, nil)
+			})
+
+			It("keeps the first IPv4 address as the container ip", func() {
+				out, err := mgr.Up(containerHandle, upInputs, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(out.Properties.ContainerIP).To(Equal("10.255.0.2"))
+				Expect(out.Properties.ContainerIPv6).To(Equal("fd00::2"))
+				Expect(out.DNSServers).To(Equal([]string{"8.8.8.8"}))
+			})
+
+			It("reports every ip, interface and route", func() {
+				out, err := mgr.Up(containerHandle, upInputs, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(out.Properties.ContainerIPs).To(MatchJSON(`["fd00::2", "10.255.0.2", "192.168.5.2"]`))
+				Expect(out.Properties.Interfaces).To(MatchJSON(`[
+					{"name": "eth0", "mac": "ee:ee:0a:ff:00:02", "sandbox": "/some/netns"},
+					{"name": "eth1", "sandbox": "/some/netns"}
+				]`))
+				Expect(out.Properties.Routes).To(MatchJSON(`[
+					{"dst": "0.0.0.0/0", "gw": "169.254.0.1"},
+					{"dst": "::/0"}
+				]`))
+			})
+		})
+
+		Context("when the CNI result only has an IPv6 address", func() {
+			BeforeEach(func() {
+				cniController.UpReturns(&current.Result{
+					CNIVersion: "0.3.1",
+					IPs: []*current.IPConfig{
+						{
+							Version: "6",
+							Address: net.IPNet{IP: net.ParseIP("fd00::2"), Mask: net.CIDRMask(64, 128)},
+						},
+					},
+				}, nil)
+			})
+
+			It("uses it as the container ip", func() {
+				out, err := mgr.Up(containerHandle, upInputs, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(out.Properties.ContainerIP).To(Equal("fd00::2"))
+				Expect(out.Properties.ContainerIPv6).To(Equal("fd00::2"))
+			})
+		})
+
 		It("should return the DNS nameservers info as a separate key in the up ouput", func() {
 			out, err := mgr.Up(containerHandle, upInputs, nil)
 			Expect(err).NotTo(HaveOccurred())
@@ -243,6 +416,17 @@ var _ = Describe("Manager", func() {
 			})
 		})
 
+		Context("when the CNI result has no ips", func() {
+			BeforeEach(func() {
+				cniController.UpReturns(&current.Result{CNIVersion: "0.3.1"}, nil)
+			})
+			It("returns an error", func() {
+				_, err := mgr.Up("container-handle", upInputs, nil)
+				Expect(err).To(MatchError("cni up failed: no ip allocated"))
+				Expect(proxyRedirect.ApplyCallCount()).To(Equal(0))
+			})
+		})
+
 		Context("when missing args", func() {
 			It("should return a friendly error", func() {
 				_, err := mgr.Up(containerHandle, manager.UpInputs{
@@ -294,14 +478,82 @@ var _ = Describe("Manager", func() {
 
 		Context("when the proxy redirect fails", func() {
 			It("should return the error", func() {
-				proxyRedirect.ApplyReturns(errors.New("bang"))
+				proxyRedirect.ApplyReturns(nil, errors.New("bang"))
 				_, err := mgr.Up(containerHandle, upInputs, nil)
 				Expect(err).To(MatchError("proxy redirect apply: bang"))
 			})
 		})
+
+		Context("when the proxy properties are invalid", func() {
+			It("returns the error before setting anything up", func() {
+				upInputs.NetIn = append(upInputs.NetIn, garden.NetIn{ContainerPort: 8080})
+				proxyRedirect.SettingsReturns(proxy.Settings{}, errors.New("invalid property proxy.enabled: must be true or false"))
+
+				_, err := mgr.Up(containerHandle, upInputs, nil)
+				Expect(err).To(MatchError("proxy redirect: invalid property proxy.enabled: must be true or false"))
+
+				Expect(mounter.IdempotentlyMountCallCount()).To(Equal(0))
+				Expect(portAllocator.AllocatePortCallCount()).To(Equal(0))
+				Expect(cniController.UpCallCount()).To(Equal(0))
+				Expect(netRules.SaveCallCount()).To(Equal(0))
+			})
+		})
+
+		It("saves the rules of the container, with the allocated host ports", func() {
+			upInputs.NetIn = append(upInputs.NetIn, garden.NetIn{ContainerPort: 8080})
+			portAllocator.AllocatePortReturns(61000, nil)
+
+			_, err := mgr.Up(containerHandle, upInputs, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(netRules.SaveCallCount()).To(Equal(1))
+			handle, rules := netRules.SaveArgsForCall(0)
+			Expect(handle).To(Equal(containerHandle))
+			Expect(rules.NetIn).To(Equal([]garden.NetIn{
+				{HostPort: 12345, ContainerPort: 7000},
+				{HostPort: 23456, ContainerPort: 7001},
+				{HostPort: 61000, ContainerPort: 8080},
+			}))
+			Expect(rules.NetOut).To(Equal(netOutRules))
+			Expect(rules.Properties).To(Equal(gardenProperties))
+		})
+
+		It("records the rules the plugins were given and the container ip, for updates", func() {
+			_, err := mgr.Up(containerHandle, upInputs, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, rules := netRules.SaveArgsForCall(0)
+			Expect(rules.UpNetIn).To(Equal(netInRules))
+			Expect(rules.UpNetOut).To(Equal(netOutRules))
+			Expect(rules.ContainerIP).To(Equal("169.254.1.2"))
+		})
+
+		Context("when saving the rules fails", func() {
+			It("should return the error", func() {
+				netRules.SaveReturns(errors.New("bang"))
+				_, err := mgr.Up(containerHandle, upInputs, nil)
+				Expect(err).To(MatchError("saving net rules: bang"))
+			})
+		})
 	})
 
 	Describe("Down", func() {
+		It("times each phase", func() {
+			Expect(mgr.Down(containerHandle)).To(Succeed())
+
+			names := []string{}
+			for i := 0; i < metricsSender.SendDurationCallCount(); i++ {
+				name, _ := metricsSender.SendDurationArgsForCall(i)
+				names = append(names, name)
+			}
+			Expect(names).To(Equal([]string{
+				"downCniDelTime",
+				"downBindMountTime",
+				"downPortReleaseTime",
+				"downNetRulesTime",
+			}))
+		})
+
 		It("should ensure that the netNS is unmounted", func() {
 			Expect(mgr.Down(containerHandle)).To(Succeed())
 			Expect(mounter.RemoveMountCallCount()).To(Equal(1))
@@ -352,7 +604,7 @@ var _ = Describe("Manager", func() {
 				mounter.RemoveMountReturns(errors.New("boom"))
 				err := mgr.Down(containerHandle)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(logger.String()).To(ContainSubstring(fmt.Sprintf("removing bind mount %s: boom\n", filepath.Join("some", "fake", "path", containerHandle))))
+				Expect(logger).To(gbytes.Say(`"message":"test.down.removing-bind-mount".*"error":"boom","handle":"some-container-handle","path":"some/fake/path/some-container-handle"`))
 
 				Expect(portAllocator.ReleaseAllPortsCallCount()).To(Equal(1))
 			})
@@ -363,7 +615,641 @@ var _ = Describe("Manager", func() {
 				portAllocator.ReleaseAllPortsReturns(errors.New("potato"))
 				err := mgr.Down(containerHandle)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(logger.String()).To(ContainSubstring("releasing ports: potato\n"))
+				Expect(logger).To(gbytes.Say(`"message":"test.down.releasing-ports".*"error":"potato","handle":"some-container-handle"`))
+			})
+		})
+
+		It("should delete the rules of the container", func() {
+			Expect(mgr.Down(containerHandle)).To(Succeed())
+			Expect(netRules.DeleteCallCount()).To(Equal(1))
+			Expect(netRules.DeleteArgsForCall(0)).To(Equal(containerHandle))
+		})
+
+		It("should remove the firewall rules of the container", func() {
+			Expect(mgr.Down(containerHandle)).To(Succeed())
+			Expect(firewall.RemoveCallCount()).To(Equal(1))
+			Expect(firewall.RemoveArgsForCall(0)).To(Equal(containerHandle))
+		})
+
+		Context("when removing the firewall rules fails", func() {
+			It("logs the error and succeeds", func() {
+				firewall.RemoveReturns(errors.New("potato"))
+				err := mgr.Down(containerHandle)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(logger).To(gbytes.Say(`"message":"test.down.removing-firewall-rules".*"error":"potato","handle":"some-container-handle"`))
+			})
+		})
+
+		Context("when deleting the rules fails", func() {
+			It("logs the error and succeeds", func() {
+				netRules.DeleteReturns(errors.New("potato"))
+				err := mgr.Down(containerHandle)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(logger).To(gbytes.Say(`"message":"test.down.removing-net-rules".*"error":"potato","handle":"some-container-handle"`))
+			})
+		})
+	})
+
+	Describe("Update", func() {
+		var (
+			updateInputs manager.UpdateInputs
+			oldRules     netrules.Rules
+		)
+
+		BeforeEach(func() {
+			oldRules = netrules.Rules{
+				NetIn: []garden.NetIn{
+					{HostPort: 61000, ContainerPort: 8080},
+					{HostPort: 61001, ContainerPort: 9090},
+				},
+				NetOut:     netOutRules,
+				Properties: gardenProperties,
+				UpNetIn: []garden.NetIn{
+					{HostPort: 61000, ContainerPort: 8080},
+					{HostPort: 61001, ContainerPort: 9090},
+				},
+				UpNetOut:    netOutRules,
+				ContainerIP: "169.254.1.2",
+			}
+			netRules.LoadReturns(oldRules, nil)
+
+			updateInputs = manager.UpdateInputs{
+				NetIn: []garden.NetIn{
+					{HostPort: 0, ContainerPort: 8080},
+					{HostPort: 0, ContainerPort: 7000},
+				},
+				NetOut: []garden.NetOutRule{
+					{Protocol: garden.ProtocolUDP},
+				},
+			}
+			portAllocator.AllocatePortReturns(61002, nil)
+		})
+
+		It("loads the current rules of the container", func() {
+			_, err := mgr.Update(containerHandle, updateInputs)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(netRules.LoadCallCount()).To(Equal(1))
+			Expect(netRules.LoadArgsForCall(0)).To(Equal(containerHandle))
+		})
+
+		It("keeps the host port of a container port that is still mapped and allocates the others", func() {
+			outputs, err := mgr.Update(containerHandle, updateInputs)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(portAllocator.AllocatePortCallCount()).To(Equal(1))
+			handle, port := portAllocator.AllocatePortArgsForCall(0)
+			Expect(handle).To(Equal(containerHandle))
+			Expect(port).To(Equal(0))
+
+			Expect(outputs.Properties.MappedPorts).To(MatchJSON(`[
+				{"HostPort": 61000, "ContainerPort": 8080},
+				{"HostPort": 61002, "ContainerPort": 7000}
+			]`))
+		})
+
+		It("applies the new rules in place, on top of the ones the plugins set up", func() {
+			_, err := mgr.Update(containerHandle, updateInputs)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(firewall.ApplyCallCount()).To(Equal(1))
+			handle, rules := firewall.ApplyArgsForCall(0)
+			Expect(handle).To(Equal(containerHandle))
+			Expect(rules.NetIn).To(Equal([]garden.NetIn{
+				{HostPort: 61000, ContainerPort: 8080},
+				{HostPort: 61002, ContainerPort: 7000},
+			}))
+			Expect(rules.NetOut).To(Equal(updateInputs.NetOut))
+			Expect(rules.UpNetIn).To(Equal(oldRules.UpNetIn))
+			Expect(rules.UpNetOut).To(Equal(oldRules.UpNetOut))
+			Expect(rules.ContainerIP).To(Equal("169.254.1.2"))
+		})
+
+		It("does not re-create the network", func() {
+			outputs, err := mgr.Update(containerHandle, updateInputs)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(cniController.UpdateCallCount()).To(Equal(0))
+			Expect(outputs.Properties.ContainerIP).To(BeEmpty())
+		})
+
+		Context("when the container has no saved rules", func() {
+			BeforeEach(func() {
+				netRules.LoadReturns(netrules.Rules{}, nil)
+			})
+
+			It("allocates host ports for every mapping and releases none", func() {
+				outputs, err := mgr.Update(containerHandle, updateInputs)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(portAllocator.AllocatePortCallCount()).To(Equal(2))
+				Expect(portAllocator.ReleasePortCallCount()).To(Equal(0))
+				Expect(outputs.Properties.MappedPorts).To(MatchJSON(`[
+					{"HostPort": 61002, "ContainerPort": 8080},
+					{"HostPort": 61002, "ContainerPort": 7000}
+				]`))
+			})
+		})
+
+		It("saves the new rules", func() {
+			_, err := mgr.Update(containerHandle, updateInputs)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(netRules.SaveCallCount()).To(Equal(1))
+			handle, rules := netRules.SaveArgsForCall(0)
+			Expect(handle).To(Equal(containerHandle))
+			Expect(rules).To(Equal(netrules.Rules{
+				NetIn: []garden.NetIn{
+					{HostPort: 61000, ContainerPort: 8080},
+					{HostPort: 61002, ContainerPort: 7000},
+				},
+				NetOut:      updateInputs.NetOut,
+				Properties:  gardenProperties,
+				UpNetIn:     oldRules.UpNetIn,
+				UpNetOut:    oldRules.UpNetOut,
+				ContainerIP: "169.254.1.2",
+			}))
+		})
+
+		It("releases the host ports of removed mappings", func() {
+			_, err := mgr.Update(containerHandle, updateInputs)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(portAllocator.ReleasePortCallCount()).To(Equal(1))
+			handle, port := portAllocator.ReleasePortArgsForCall(0)
+			Expect(handle).To(Equal(containerHandle))
+			Expect(port).To(Equal(61001))
+		})
+
+		Context("when a host port is given", func() {
+			BeforeEach(func() {
+				updateInputs.NetIn = []garden.NetIn{
+					{HostPort: 61001, ContainerPort: 8080},
+				}
+			})
+
+			It("uses it without allocating one", func() {
+				outputs, err := mgr.Update(containerHandle, updateInputs)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(portAllocator.AllocatePortCallCount()).To(Equal(0))
+				Expect(outputs.Properties.MappedPorts).To(MatchJSON(`[{"HostPort": 61001, "ContainerPort": 8080}]`))
+
+				Expect(portAllocator.ReleasePortCallCount()).To(Equal(1))
+				_, port := portAllocator.ReleasePortArgsForCall(0)
+				Expect(port).To(Equal(61000))
+			})
+		})
+
+		Context("when other properties are given", func() {
+			It("returns an error without updating", func() {
+				updateInputs.Properties = map[string]interface{}{"policy_group_id": "other-group-id"}
+				_, err := mgr.Update(containerHandle, updateInputs)
+				Expect(err).To(MatchError("changing properties requires recreate_network"))
+				Expect(firewall.ApplyCallCount()).To(Equal(0))
+				Expect(portAllocator.AllocatePortCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the same properties are given", func() {
+			It("updates the rules", func() {
+				updateInputs.Properties = map[string]interface{}{"policy_group_id": "some-group-id"}
+				_, err := mgr.Update(containerHandle, updateInputs)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(firewall.ApplyCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when missing args", func() {
+			It("should return a friendly error", func() {
+				_, err := mgr.Update("", updateInputs)
+				Expect(err).To(MatchError("update missing container handle"))
+			})
+		})
+
+		Context("when loading the rules fails", func() {
+			It("returns the error without updating", func() {
+				netRules.LoadReturns(netrules.Rules{}, errors.New("potato"))
+				_, err := mgr.Update(containerHandle, updateInputs)
+				Expect(err).To(MatchError("loading net rules: potato"))
+				Expect(firewall.ApplyCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when allocating a port fails", func() {
+			BeforeEach(func() {
+				updateInputs.NetIn = append(updateInputs.NetIn, garden.NetIn{ContainerPort: 7001})
+				portAllocator.AllocatePortStub = func(string, int) (int, error) {
+					if portAllocator.AllocatePortCallCount() > 1 {
+						return -1, errors.New("potato")
+					}
+					return 61002, nil
+				}
+			})
+
+			It("releases the ports it allocated and returns the error", func() {
+				_, err := mgr.Update(containerHandle, updateInputs)
+				Expect(err).To(MatchError("allocating port: potato"))
+
+				Expect(portAllocator.ReleasePortCallCount()).To(Equal(1))
+				_, port := portAllocator.ReleasePortArgsForCall(0)
+				Expect(port).To(Equal(61002))
+				Expect(firewall.ApplyCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when applying the new rules fails", func() {
+			BeforeEach(func() {
+				firewall.ApplyReturnsOnCall(0, errors.New("bang"))
+			})
+
+			It("applies the old rules again", func() {
+				_, err := mgr.Update(containerHandle, updateInputs)
+				Expect(err).To(MatchError("applying net rules: bang"))
+
+				Expect(firewall.ApplyCallCount()).To(Equal(2))
+				_, rules := firewall.ApplyArgsForCall(1)
+				Expect(rules).To(Equal(oldRules))
+				Expect(cniController.UpdateCallCount()).To(Equal(0))
+			})
+
+			It("releases the newly allocated ports and keeps the old ones", func() {
+				_, err := mgr.Update(containerHandle, updateInputs)
+				Expect(err).To(HaveOccurred())
+
+				Expect(portAllocator.ReleasePortCallCount()).To(Equal(1))
+				_, port := portAllocator.ReleasePortArgsForCall(0)
+				Expect(port).To(Equal(61002))
+			})
+
+			It("does not save the new rules", func() {
+				_, err := mgr.Update(containerHandle, updateInputs)
+				Expect(err).To(HaveOccurred())
+				Expect(netRules.SaveCallCount()).To(Equal(0))
+			})
+
+			Context("when the rollback fails too", func() {
+				BeforeEach(func() {
+					firewall.ApplyReturns(errors.New("bang"))
+				})
+
+				It("logs the rollback error", func() {
+					_, err := mgr.Update(containerHandle, updateInputs)
+					Expect(err).To(MatchError("applying net rules: bang"))
+					Expect(logger).To(gbytes.Say(`"message":"test.update.rolling-back-net-rules".*"error":"bang","handle":"some-container-handle"`))
+				})
+			})
+		})
+
+		Context("when saving the rules fails", func() {
+			BeforeEach(func() {
+				netRules.SaveReturns(errors.New("potato"))
+			})
+
+			It("rolls back the update", func() {
+				_, err := mgr.Update(containerHandle, updateInputs)
+				Expect(err).To(MatchError("saving net rules: potato"))
+
+				Expect(firewall.ApplyCallCount()).To(Equal(2))
+				_, rules := firewall.ApplyArgsForCall(1)
+				Expect(rules).To(Equal(oldRules))
+				Expect(portAllocator.ReleasePortCallCount()).To(Equal(1))
+				_, port := portAllocator.ReleasePortArgsForCall(0)
+				Expect(port).To(Equal(61002))
+			})
+		})
+
+		Context("when releasing a removed port fails", func() {
+			It("logs the error and succeeds", func() {
+				portAllocator.ReleasePortReturns(errors.New("potato"))
+				_, err := mgr.Update(containerHandle, updateInputs)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(logger).To(gbytes.Say(`"message":"test.update.releasing-port".*"error":"potato","handle":"some-container-handle","port":61001`))
+			})
+		})
+
+		Context("when the network is to be re-created", func() {
+			BeforeEach(func() {
+				updateInputs.RecreateNetwork = true
+				cniController.UpdateReturns(&current.Result{
+					CNIVersion: "0.3.1",
+					IPs:        []*current.IPConfig{{Version: "4", Address: net.IPNet{IP: net.ParseIP("169.254.1.3"), Mask: net.CIDRMask(32, 32)}}},
+				}, nil)
+			})
+
+			It("re-creates the network with the new rules and the saved properties", func() {
+				_, err := mgr.Update(containerHandle, updateInputs)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(cniController.UpdateCallCount()).To(Equal(1))
+				namespacePath, handle, metadata, runtimeConfig := cniController.UpdateArgsForCall(0)
+				Expect(namespacePath).To(Equal(filepath.Join("some", "fake", "path", containerHandle)))
+				Expect(handle).To(Equal(containerHandle))
+				Expect(metadata).To(Equal(gardenProperties))
+				Expect(runtimeConfig).To(Equal(map[string]interface{}{
+					"portMappings": []garden.NetIn{
+						{HostPort: 61000, ContainerPort: 8080},
+						{HostPort: 61002, ContainerPort: 7000},
+					},
+					"netOutRules": updateInputs.NetOut,
+				}))
+			})
+
+			It("re-creates the network with the given properties", func() {
+				updateInputs.Properties = map[string]interface{}{"policy_group_id": "other-group-id"}
+				_, err := mgr.Update(containerHandle, updateInputs)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, metadata, _ := cniController.UpdateArgsForCall(0)
+				Expect(metadata).To(Equal(updateInputs.Properties))
+			})
+
+			It("returns the addresses of the re-created network", func() {
+				outputs, err := mgr.Update(containerHandle, updateInputs)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(outputs.Properties.ContainerIP).To(Equal("169.254.1.3"))
+				Expect(outputs.Properties.ContainerIPs).To(MatchJSON(`["169.254.1.3"]`))
+			})
+
+			It("removes the rules applied on top of the plugins and saves the new rules as the plugins' own", func() {
+				_, err := mgr.Update(containerHandle, updateInputs)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(firewall.ApplyCallCount()).To(Equal(0))
+				Expect(firewall.RemoveCallCount()).To(Equal(1))
+				Expect(firewall.RemoveArgsForCall(0)).To(Equal(containerHandle))
+
+				_, rules := netRules.SaveArgsForCall(0)
+				Expect(rules.UpNetIn).To(Equal(rules.NetIn))
+				Expect(rules.UpNetOut).To(Equal(updateInputs.NetOut))
+				Expect(rules.ContainerIP).To(Equal("169.254.1.3"))
+			})
+
+			Context("when the cni Update fails", func() {
+				BeforeEach(func() {
+					cniController.UpdateStub = func(string, string, map[string]interface{}, map[string]interface{}) (types.Result, error) {
+						if cniController.UpdateCallCount() == 1 {
+							return nil, errors.New("bang")
+						}
+						return nil, nil
+					}
+				})
+
+				It("re-creates the network with the rules the plugins had and applies the old rules on top", func() {
+					_, err := mgr.Update(containerHandle, updateInputs)
+					Expect(err).To(MatchError("cni update failed: bang"))
+
+					Expect(cniController.UpdateCallCount()).To(Equal(2))
+					_, _, metadata, runtimeConfig := cniController.UpdateArgsForCall(1)
+					Expect(metadata).To(Equal(gardenProperties))
+					Expect(runtimeConfig).To(Equal(map[string]interface{}{
+						"portMappings": oldRules.UpNetIn,
+						"netOutRules":  oldRules.UpNetOut,
+					}))
+
+					Expect(firewall.ApplyCallCount()).To(Equal(1))
+					_, rules := firewall.ApplyArgsForCall(0)
+					Expect(rules).To(Equal(oldRules))
+				})
+
+				It("releases the newly allocated ports and does not save the new rules", func() {
+					_, err := mgr.Update(containerHandle, updateInputs)
+					Expect(err).To(HaveOccurred())
+
+					Expect(portAllocator.ReleasePortCallCount()).To(Equal(1))
+					_, port := portAllocator.ReleasePortArgsForCall(0)
+					Expect(port).To(Equal(61002))
+					Expect(netRules.SaveCallCount()).To(Equal(0))
+				})
+
+				Context("when the rollback fails too", func() {
+					BeforeEach(func() {
+						cniController.UpdateStub = nil
+						cniController.UpdateReturns(nil, errors.New("bang"))
+					})
+
+					It("logs the rollback error", func() {
+						_, err := mgr.Update(containerHandle, updateInputs)
+						Expect(err).To(MatchError("cni update failed: bang"))
+						Expect(logger).To(gbytes.Say(`"message":"test.update.rolling-back-cni-update".*"error":"bang","handle":"some-container-handle"`))
+					})
+				})
+			})
+		})
+	})
+
+	Describe("Check", func() {
+		It("checks the container network through its bind mount", func() {
+			outputs, err := mgr.Check(containerHandle)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(cniController.CheckCallCount()).To(Equal(1))
+			namespacePath, handle := cniController.CheckArgsForCall(0)
+			Expect(namespacePath).To(Equal(filepath.Join("some", "fake", "path", containerHandle)))
+			Expect(handle).To(Equal(containerHandle))
+
+			Expect(outputs.Containers).To(Equal([]manager.ContainerHealth{
+				{Handle: containerHandle, Healthy: true},
+			}))
+			Expect(mounter.ListMountsCallCount()).To(Equal(0))
+		})
+
+		Context("when the check fails", func() {
+			It("reports the container as unhealthy", func() {
+				cniController.CheckReturns(errors.New("bang"))
+				outputs, err := mgr.Check(containerHandle)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(outputs.Containers).To(Equal([]manager.ContainerHealth{
+					{Handle: containerHandle, Healthy: false, Error: "bang"},
+				}))
+			})
+		})
+
+		Context("when no handle is given", func() {
+			BeforeEach(func() {
+				mounter.ListMountsReturns([]string{"handle-b", "handle-a"}, nil)
+				cniController.CheckStub = func(namespacePath, handle string) error {
+					if handle == "handle-b" {
+						return errors.New("bang")
+					}
+					return nil
+				}
+			})
+
+			It("checks every bind-mounted container", func() {
+				outputs, err := mgr.Check("")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(mounter.ListMountsArgsForCall(0)).To(Equal(filepath.Join("some", "fake", "path")))
+				Expect(cniController.CheckCallCount()).To(Equal(2))
+				Expect(outputs.Containers).To(Equal([]manager.ContainerHealth{
+					{Handle: "handle-a", Healthy: true},
+					{Handle: "handle-b", Healthy: false, Error: "bang"},
+				}))
+			})
+
+			Context("when listing the bind mounts fails", func() {
+				It("returns the error", func() {
+					mounter.ListMountsReturns(nil, errors.New("boom"))
+					_, err := mgr.Check("")
+					Expect(err).To(MatchError("listing bind mounts: boom"))
+				})
+			})
+		})
+	})
+
+	Describe("Reconcile", func() {
+		var reconcileInputs manager.ReconcileInputs
+
+		BeforeEach(func() {
+			reconcileInputs = manager.ReconcileInputs{
+				Handles: []string{"live-handle"},
+			}
+			mounter.ListMountsReturns([]string{"live-handle", "leaked-handle"}, nil)
+			portAllocator.AllocatedHandlesReturns([]string{"live-handle", "leaked-handle", "ports-only-handle"}, nil)
+		})
+
+		It("lists the bind mounts under the bind mount root", func() {
+			_, err := mgr.Reconcile(reconcileInputs)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(mounter.ListMountsCallCount()).To(Equal(1))
+			Expect(mounter.ListMountsArgsForCall(0)).To(Equal(filepath.Join("some", "fake", "path")))
+		})
+
+		It("calls CNI Down and removes the bind mount for leaked bind mounts", func() {
+			_, err := mgr.Reconcile(reconcileInputs)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(cniController.DownCallCount()).To(Equal(1))
+			namespacePath, handle := cniController.DownArgsForCall(0)
+			Expect(namespacePath).To(Equal(filepath.Join("some", "fake", "path", "leaked-handle")))
+			Expect(handle).To(Equal("leaked-handle"))
+
+			Expect(mounter.RemoveMountCallCount()).To(Equal(1))
+			Expect(mounter.RemoveMountArgsForCall(0)).To(Equal(filepath.Join("some", "fake", "path", "leaked-handle")))
+		})
+
+		It("deletes the rules of leaked bind mounts", func() {
+			_, err := mgr.Reconcile(reconcileInputs)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(netRules.DeleteCallCount()).To(Equal(1))
+			Expect(netRules.DeleteArgsForCall(0)).To(Equal("leaked-handle"))
+
+			Expect(firewall.RemoveCallCount()).To(Equal(1))
+			Expect(firewall.RemoveArgsForCall(0)).To(Equal("leaked-handle"))
+		})
+
+		It("releases the ports of unknown handles", func() {
+			_, err := mgr.Reconcile(reconcileInputs)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(portAllocator.ReleaseAllPortsCallCount()).To(Equal(2))
+			Expect(portAllocator.ReleaseAllPortsArgsForCall(0)).To(Equal("leaked-handle"))
+			Expect(portAllocator.ReleaseAllPortsArgsForCall(1)).To(Equal("ports-only-handle"))
+		})
+
+		It("returns a summary of what was cleaned up", func() {
+			summary, err := mgr.Reconcile(reconcileInputs)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary).To(Equal(&manager.ReconcileSummary{
+				DeletedNetworks:   []string{"leaked-handle"},
+				RemovedBindMounts: []string{"leaked-handle"},
+				ReleasedHandles:   []string{"leaked-handle", "ports-only-handle"},
+			}))
+		})
+
+		Context("when every handle is live", func() {
+			BeforeEach(func() {
+				reconcileInputs.Handles = []string{"live-handle", "leaked-handle", "ports-only-handle"}
+			})
+
+			It("does not clean anything up", func() {
+				summary, err := mgr.Reconcile(reconcileInputs)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(cniController.DownCallCount()).To(Equal(0))
+				Expect(mounter.RemoveMountCallCount()).To(Equal(0))
+				Expect(portAllocator.ReleaseAllPortsCallCount()).To(Equal(0))
+				Expect(summary.DeletedNetworks).To(BeEmpty())
+				Expect(summary.RemovedBindMounts).To(BeEmpty())
+				Expect(summary.ReleasedHandles).To(BeEmpty())
+			})
+		})
+
+		Context("when the live handles are missing", func() {
+			It("returns an error without cleaning anything up", func() {
+				_, err := mgr.Reconcile(manager.ReconcileInputs{})
+				Expect(err).To(MatchError("reconcile missing live handles"))
+
+				Expect(cniController.DownCallCount()).To(Equal(0))
+				Expect(portAllocator.ReleaseAllPortsCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when listing the bind mounts fails", func() {
+			It("returns the error", func() {
+				mounter.ListMountsReturns(nil, errors.New("boom"))
+				_, err := mgr.Reconcile(reconcileInputs)
+				Expect(err).To(MatchError("listing bind mounts: boom"))
+			})
+		})
+
+		Context("when listing the allocated ports fails", func() {
+			It("returns the error", func() {
+				portAllocator.AllocatedHandlesReturns(nil, errors.New("potato"))
+				_, err := mgr.Reconcile(reconcileInputs)
+				Expect(err).To(MatchError("listing allocated ports: potato"))
+			})
+		})
+
+		Context("when the cni Down fails", func() {
+			BeforeEach(func() {
+				cniController.DownReturns(errors.New("bang"))
+			})
+
+			It("keeps the bind mount and ports of that handle so it can be retried", func() {
+				summary, err := mgr.Reconcile(reconcileInputs)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(mounter.RemoveMountCallCount()).To(Equal(0))
+				Expect(portAllocator.ReleaseAllPortsCallCount()).To(Equal(1))
+				Expect(portAllocator.ReleaseAllPortsArgsForCall(0)).To(Equal("ports-only-handle"))
+
+				Expect(summary.DeletedNetworks).To(BeEmpty())
+				Expect(summary.Errors).To(Equal([]string{"cni down leaked-handle: bang"}))
+				Expect(logger).To(gbytes.Say(`"message":"test.reconcile.cni-down".*"error":"bang","handle":"leaked-handle"`))
+			})
+		})
+
+		Context("when removing the bind mount fails", func() {
+			It("reports the error and still releases the ports", func() {
+				mounter.RemoveMountReturns(errors.New("boom"))
+				summary, err := mgr.Reconcile(reconcileInputs)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(summary.RemovedBindMounts).To(BeEmpty())
+				Expect(summary.ReleasedHandles).To(Equal([]string{"leaked-handle", "ports-only-handle"}))
+				Expect(summary.Errors).To(Equal([]string{
+					fmt.Sprintf("removing bind mount %s: boom", filepath.Join("some", "fake", "path", "leaked-handle")),
+				}))
+			})
+		})
+
+		Context("when releasing ports fails", func() {
+			It("reports the error", func() {
+				portAllocator.ReleaseAllPortsReturns(errors.New("potato"))
+				summary, err := mgr.Reconcile(reconcileInputs)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(summary.ReleasedHandles).To(BeEmpty())
+				Expect(summary.Errors).To(Equal([]string{
+					"releasing ports for leaked-handle: potato",
+					"releasing ports for ports-only-handle: potato",
+				}))
 			})
 		})
 	})
@@ -0,0 +1,21 @@
+package metrics_sink
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// Log writes metrics as log lines, for deployments without a metrics
+// backend.
+type Log struct {
+	Logger lager.Logger
+}
+
+func (l *Log) IncrementCounter(name string) {
+	l.Logger.Info("counter", lager.Data{"name": name, "delta": 1})
+}
+
+func (l *Log) SendDuration(name string, duration time.Duration) {
+	l.Logger.Info("duration", lager.Data{"name": name, "milliseconds": float64(duration) / float64(time.Millisecond)})
+}
@@ -0,0 +1,13 @@
+package metrics_sink_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestMetricsSink(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "MetricsSink Suite")
+}
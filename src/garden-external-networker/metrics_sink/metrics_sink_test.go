@@ -0,0 +1,89 @@
+package metrics_sink_test
+
+import (
+	"garden-external-networker/metrics_sink"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+type datagramWriter struct {
+	datagrams []string
+}
+
+func (w *datagramWriter) Write(p []byte) (int, error) {
+	w.datagrams = append(w.datagrams, string(p))
+	return len(p), nil
+}
+
+var _ = Describe("Statsd", func() {
+	var (
+		writer *datagramWriter
+		sink   *metrics_sink.Statsd
+	)
+
+	BeforeEach(func() {
+		writer = &datagramWriter{}
+		sink = &metrics_sink.Statsd{Writer: writer, Prefix: "garden-external-networker"}
+	})
+
+	It("writes counters", func() {
+		sink.IncrementCounter("upRequestCount")
+		Expect(writer.datagrams).To(Equal([]string{"garden-external-networker.upRequestCount:1|c"}))
+	})
+
+	It("writes durations in milliseconds", func() {
+		sink.SendDuration("upCniAddTime", 1500*time.Microsecond)
+		Expect(writer.datagrams).To(Equal([]string{"garden-external-networker.upCniAddTime:1.5|ms"}))
+	})
+
+	Context("when there is no prefix", func() {
+		It("writes the bare metric name", func() {
+			sink.Prefix = ""
+			sink.IncrementCounter("upRequestCount")
+			Expect(writer.datagrams).To(Equal([]string{"upRequestCount:1|c"}))
+		})
+	})
+
+	Describe("NewStatsd", func() {
+		It("sends metrics to the address over udp", func() {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+
+			sink, err := metrics_sink.NewStatsd(conn.LocalAddr().String(), "gen")
+			Expect(err).NotTo(HaveOccurred())
+			sink.IncrementCounter("downRequestCount")
+
+			buf := make([]byte, 1024)
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			n, _, err := conn.ReadFrom(buf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(buf[:n])).To(Equal("gen.downRequestCount:1|c"))
+		})
+
+		Context("when the address is invalid", func() {
+			It("returns a meaningful error", func() {
+				_, err := metrics_sink.NewStatsd("not-an-address", "gen")
+				Expect(err).To(MatchError(HavePrefix("dialing statsd: ")))
+			})
+		})
+	})
+})
+
+var _ = Describe("Log", func() {
+	It("logs counters and durations", func() {
+		logger := lagertest.NewTestLogger("test")
+		sink := &metrics_sink.Log{Logger: logger}
+
+		sink.IncrementCounter("upRequestCount")
+		sink.SendDuration("upCniAddTime", 2*time.Millisecond)
+
+		Expect(logger).To(gbytes.Say(`"message":"test.counter".*"name":"upRequestCount"`))
+		Expect(logger).To(gbytes.Say(`"message":"test.duration".*"milliseconds":2.*"name":"upCniAddTime"`))
+	})
+})
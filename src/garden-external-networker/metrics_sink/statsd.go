@@ -0,0 +1,45 @@
+package metrics_sink
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Statsd writes metrics in the statsd line format, one metric per write so
+// that each one is sent as its own datagram.
+type Statsd struct {
+	Writer io.Writer
+	Prefix string
+}
+
+func NewStatsd(address, prefix string) (*Statsd, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("dialing statsd: %s", err)
+	}
+
+	return &Statsd{Writer: conn, Prefix: prefix}, nil
+}
+
+func (s *Statsd) IncrementCounter(name string) {
+	s.send(fmt.Sprintf("%s:1|c", s.name(name)))
+}
+
+func (s *Statsd) SendDuration(name string, duration time.Duration) {
+	milliseconds := float64(duration) / float64(time.Millisecond)
+	s.send(fmt.Sprintf("%s:%g|ms", s.name(name), milliseconds))
+}
+
+func (s *Statsd) name(name string) string {
+	if s.Prefix == "" {
+		return name
+	}
+	return s.Prefix + "." + name
+}
+
+func (s *Statsd) send(line string) {
+	// metrics are best effort, a lost datagram is not worth failing a request
+	s.Writer.Write([]byte(line))
+}
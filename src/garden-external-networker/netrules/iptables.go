@@ -0,0 +1,284 @@
+package netrules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lib/rules"
+
+	"code.cloudfoundry.org/garden"
+)
+
+const (
+	chainPrefix        = "update--"
+	maxChainNameLength = 28
+)
+
+// IPTables applies the changes an update makes to the rules of a running
+// container on top of the rules its CNI plugins set up on up, so that the
+// container keeps its network and address. New port mappings are forwarded
+// from a chain in the nat table. A chain in the filter table accepts the
+// current port mappings and egress rules of the container and rejects the
+// removed ones. Both chains are jumped to before any other rule, and are
+// removed once the container is back to the rules it was brought up with.
+type IPTables struct {
+	IPTables rules.IPTablesAdapter
+}
+
+// Apply makes the firewall of the container match rules.
+func (t *IPTables) Apply(handle string, r Rules) error {
+	natRules, filterRules := updateRules(r)
+	if (len(natRules) > 0 || len(filterRules) > 0) && r.ContainerIP == "" {
+		return errors.New("container has no known IPv4 address")
+	}
+
+	chain := ChainName(handle)
+	if err := t.sync("nat", "PREROUTING", chain, natRules); err != nil {
+		return err
+	}
+	return t.sync("filter", "FORWARD", chain, filterRules)
+}
+
+// Remove removes the chains of the container, if it has any.
+func (t *IPTables) Remove(handle string) error {
+	chain := ChainName(handle)
+	if err := t.remove("nat", "PREROUTING", chain); err != nil {
+		return err
+	}
+	return t.remove("filter", "FORWARD", chain)
+}
+
+// ChainName returns the name of the chains of a container, which iptables
+// limits to 28 characters.
+func ChainName(handle string) string {
+	name := chainPrefix + handle
+	if len(name) > maxChainNameLength {
+		name = name[:maxChainNameLength]
+	}
+	return name
+}
+
+func (t *IPTables) sync(table, parent, chain string, desired []rules.IPTablesRule) error {
+	if len(desired) == 0 {
+		return t.remove(table, parent, chain)
+	}
+
+	if _, err := t.IPTables.List(table, chain); err != nil {
+		if err := t.IPTables.NewChain(table, chain); err != nil {
+			return fmt.Errorf("creating chain %s: %s", chain, err)
+		}
+	}
+
+	if _, err := t.IPTables.SyncChain(table, chain, desired); err != nil {
+		return fmt.Errorf("syncing chain %s: %s", chain, err)
+	}
+
+	jump := rules.IPTablesRule{"--jump", chain}
+	exists, err := t.IPTables.Exists(table, parent, jump)
+	if err != nil {
+		return fmt.Errorf("checking jump to %s: %s", chain, err)
+	}
+	if !exists {
+		if err := t.IPTables.BulkInsert(table, parent, 1, jump); err != nil {
+			return fmt.Errorf("inserting jump to %s: %s", chain, err)
+		}
+	}
+	return nil
+}
+
+func (t *IPTables) remove(table, parent, chain string) error {
+	if _, err := t.IPTables.List(table, chain); err != nil {
+		// there is no chain to remove
+		return nil
+	}
+
+	jump := rules.IPTablesRule{"--jump", chain}
+	exists, err := t.IPTables.Exists(table, parent, jump)
+	if err != nil {
+		return fmt.Errorf("checking jump to %s: %s", chain, err)
+	}
+	if exists {
+		if err := t.IPTables.Delete(table, parent, jump); err != nil {
+			return fmt.Errorf("deleting jump to %s: %s", chain, err)
+		}
+	}
+
+	if err := t.IPTables.ClearChain(table, chain); err != nil {
+		return fmt.Errorf("clearing chain %s: %s", chain, err)
+	}
+	if err := t.IPTables.DeleteChain(table, chain); err != nil {
+		return fmt.Errorf("deleting chain %s: %s", chain, err)
+	}
+	return nil
+}
+
+// updateRules returns the rules of the nat and filter chains of a container
+// whose rules differ from the ones it was brought up with. Accepts come
+// before rejects, so that a removed egress rule only rejects the traffic
+// that no current rule allows. Connections the container already has, and
+// the replies to connections made to it, are left alone by removed egress
+// rules.
+func updateRules(r Rules) ([]rules.IPTablesRule, []rules.IPTablesRule) {
+	natRules, accepts, rejects := []rules.IPTablesRule{}, []rules.IPTablesRule{}, []rules.IPTablesRule{}
+	netOutRejects := []rules.IPTablesRule{}
+
+	for _, netIn := range r.NetIn {
+		if containsNetIn(r.UpNetIn, netIn) {
+			continue
+		}
+		natRules = append(natRules, newPortForwardingRule(r.ContainerIP, netIn))
+		accepts = append(accepts, newForwardedPortRule(r.ContainerIP, netIn, acceptTarget))
+	}
+	for _, netIn := range r.UpNetIn {
+		if !containsNetIn(r.NetIn, netIn) {
+			rejects = append(rejects, newForwardedPortRule(r.ContainerIP, netIn, rejectTarget))
+		}
+	}
+
+	if !sameNetOut(r.UpNetOut, r.NetOut) {
+		for _, netOut := range r.NetOut {
+			accepts = append(accepts, newNetOutRules(r.ContainerIP, netOut, acceptTarget)...)
+		}
+		for _, netOut := range r.UpNetOut {
+			if !containsNetOut(r.NetOut, netOut) {
+				netOutRejects = append(netOutRejects, newNetOutRules(r.ContainerIP, netOut, rejectTarget)...)
+			}
+		}
+	}
+	if len(netOutRejects) > 0 {
+		established := append(rules.IPTablesRule{"-s", r.ContainerIP}, rules.NewNetOutRelatedEstablishedRule()...)
+		accepts = append([]rules.IPTablesRule{established}, accepts...)
+		rejects = append(rejects, netOutRejects...)
+	}
+
+	return natRules, append(accepts, rejects...)
+}
+
+var (
+	acceptTarget = rules.IPTablesRule{"--jump", "ACCEPT"}
+	rejectTarget = rules.IPTablesRule{"--jump", "REJECT", "--reject-with", "icmp-port-unreachable"}
+)
+
+// newPortForwardingRule forwards the host port of a mapping to the container,
+// for traffic to any address of the host.
+func newPortForwardingRule(containerIP string, netIn garden.NetIn) rules.IPTablesRule {
+	return rules.IPTablesRule{
+		"-p", "tcp",
+		"-m", "addrtype", "--dst-type", "LOCAL",
+		"-m", "tcp", "--dport", fmt.Sprint(netIn.HostPort),
+		"--jump", "DNAT",
+		"--to-destination", fmt.Sprintf("%s:%d", containerIP, netIn.ContainerPort),
+	}
+}
+
+// newForwardedPortRule matches the traffic that reached the container through
+// the host port of a mapping.
+func newForwardedPortRule(containerIP string, netIn garden.NetIn, target rules.IPTablesRule) rules.IPTablesRule {
+	rule := rules.IPTablesRule{
+		"-d", containerIP,
+		"-p", "tcp",
+		"-m", "tcp", "--dport", fmt.Sprint(netIn.ContainerPort),
+		"-m", "conntrack", "--ctorigdstport", fmt.Sprint(netIn.HostPort),
+	}
+	return append(rule, target...)
+}
+
+// newNetOutRules matches the traffic from the container that an egress rule
+// allows, with one rule per network and port range.
+func newNetOutRules(containerIP string, netOut garden.NetOutRule, target rules.IPTablesRule) []rules.IPTablesRule {
+	networks := netOut.Networks
+	if len(networks) == 0 {
+		networks = []garden.IPRange{{}}
+	}
+
+	matches := []rules.IPTablesRule{}
+	for _, network := range networks {
+		match := rules.IPTablesRule{"-s", containerIP, "-m", "iprange", "--dst-range", ipRange(network)}
+
+		switch netOut.Protocol {
+		case garden.ProtocolTCP, garden.ProtocolUDP:
+			protocol := "tcp"
+			if netOut.Protocol == garden.ProtocolUDP {
+				protocol = "udp"
+			}
+			match = append(match, "-p", protocol)
+			if len(netOut.Ports) == 0 {
+				matches = append(matches, match)
+			}
+			for _, ports := range netOut.Ports {
+				end := ports.End
+				if end == 0 {
+					end = ports.Start
+				}
+				matches = append(matches, append(append(rules.IPTablesRule{}, match...),
+					"-m", protocol, "--dport", fmt.Sprintf("%d:%d", ports.Start, end)))
+			}
+		case garden.ProtocolICMP:
+			match = append(match, "-p", "icmp")
+			if icmps := netOut.ICMPs; icmps != nil {
+				icmpType := fmt.Sprint(icmps.Type)
+				if icmps.Code != nil {
+					icmpType = fmt.Sprintf("%d/%d", icmps.Type, *icmps.Code)
+				}
+				match = append(match, "-m", "icmp", "--icmp-type", icmpType)
+			}
+			matches = append(matches, match)
+		default:
+			matches = append(matches, match)
+		}
+	}
+
+	result := []rules.IPTablesRule{}
+	for _, match := range matches {
+		result = append(result, append(append(rules.IPTablesRule{}, match...), target...))
+	}
+	return result
+}
+
+func ipRange(network garden.IPRange) string {
+	start, end := "0.0.0.0", "255.255.255.255"
+	if network.Start != nil {
+		start, end = network.Start.String(), network.Start.String()
+	}
+	if network.End != nil {
+		end = network.End.String()
+	}
+	return start + "-" + end
+}
+
+func containsNetIn(list []garden.NetIn, netIn garden.NetIn) bool {
+	for _, in := range list {
+		if in == netIn {
+			return true
+		}
+	}
+	return false
+}
+
+// egress rules are compared by their JSON form, as they are saved with it
+func containsNetOut(list []garden.NetOutRule, netOut garden.NetOutRule) bool {
+	key := netOutKey(netOut)
+	for _, out := range list {
+		if netOutKey(out) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func sameNetOut(a, b []garden.NetOutRule) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if netOutKey(a[i]) != netOutKey(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func netOutKey(netOut garden.NetOutRule) string {
+	bytes, _ := json.Marshal(netOut)
+	return string(bytes)
+}
@@ -0,0 +1,230 @@
+package netrules_test
+
+import (
+	"errors"
+	"garden-external-networker/netrules"
+	lib_fakes "lib/fakes"
+	"lib/rules"
+	"net"
+
+	"code.cloudfoundry.org/garden"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IPTables", func() {
+	var (
+		iptablesAdapter *lib_fakes.IPTablesAdapter
+		firewall        *netrules.IPTables
+		upNetOut        garden.NetOutRule
+		newNetOut       garden.NetOutRule
+		containerRules  netrules.Rules
+	)
+
+	syncedRules := func(table string) []rules.IPTablesRule {
+		for i := 0; i < iptablesAdapter.SyncChainCallCount(); i++ {
+			syncedTable, chain, desired := iptablesAdapter.SyncChainArgsForCall(i)
+			if syncedTable == table {
+				Expect(chain).To(Equal("update--some-handle"))
+				return desired
+			}
+		}
+		return nil
+	}
+
+	BeforeEach(func() {
+		iptablesAdapter = &lib_fakes.IPTablesAdapter{}
+		firewall = &netrules.IPTables{IPTables: iptablesAdapter}
+
+		upNetOut = garden.NetOutRule{
+			Protocol: garden.ProtocolTCP,
+			Networks: []garden.IPRange{{Start: net.ParseIP("10.0.0.1"), End: net.ParseIP("10.0.0.9")}},
+			Ports:    []garden.PortRange{{Start: 80, End: 90}},
+		}
+		newNetOut = garden.NetOutRule{
+			Protocol: garden.ProtocolICMP,
+			Networks: []garden.IPRange{{Start: net.ParseIP("10.0.1.1")}},
+		}
+		containerRules = netrules.Rules{
+			NetIn:       []garden.NetIn{{HostPort: 61000, ContainerPort: 8080}},
+			NetOut:      []garden.NetOutRule{upNetOut},
+			UpNetIn:     []garden.NetIn{{HostPort: 61000, ContainerPort: 8080}},
+			UpNetOut:    []garden.NetOutRule{upNetOut},
+			ContainerIP: "10.255.0.2",
+		}
+	})
+
+	Describe("Apply", func() {
+		Context("when port mappings were added and removed", func() {
+			BeforeEach(func() {
+				containerRules.NetIn = []garden.NetIn{{HostPort: 61001, ContainerPort: 9090}}
+			})
+
+			It("forwards the new host ports and rejects traffic through the old ones", func() {
+				Expect(firewall.Apply("some-handle", containerRules)).To(Succeed())
+
+				Expect(syncedRules("nat")).To(Equal([]rules.IPTablesRule{
+					{"-p", "tcp", "-m", "addrtype", "--dst-type", "LOCAL", "-m", "tcp", "--dport", "61001",
+						"--jump", "DNAT", "--to-destination", "10.255.0.2:9090"},
+				}))
+				Expect(syncedRules("filter")).To(Equal([]rules.IPTablesRule{
+					{"-d", "10.255.0.2", "-p", "tcp", "-m", "tcp", "--dport", "9090",
+						"-m", "conntrack", "--ctorigdstport", "61001", "--jump", "ACCEPT"},
+					{"-d", "10.255.0.2", "-p", "tcp", "-m", "tcp", "--dport", "8080",
+						"-m", "conntrack", "--ctorigdstport", "61000", "--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
+				}))
+			})
+
+			It("jumps to the chains of the container first", func() {
+				Expect(firewall.Apply("some-handle", containerRules)).To(Succeed())
+
+				Expect(iptablesAdapter.BulkInsertCallCount()).To(Equal(2))
+				table, chain, pos, jump := iptablesAdapter.BulkInsertArgsForCall(0)
+				Expect(table).To(Equal("nat"))
+				Expect(chain).To(Equal("PREROUTING"))
+				Expect(pos).To(Equal(1))
+				Expect(jump).To(Equal([]rules.IPTablesRule{{"--jump", "update--some-handle"}}))
+				table, chain, _, _ = iptablesAdapter.BulkInsertArgsForCall(1)
+				Expect(table).To(Equal("filter"))
+				Expect(chain).To(Equal("FORWARD"))
+			})
+
+			Context("when the container already jumps to its chains", func() {
+				BeforeEach(func() {
+					iptablesAdapter.ExistsReturns(true, nil)
+				})
+
+				It("does not add another jump", func() {
+					Expect(firewall.Apply("some-handle", containerRules)).To(Succeed())
+					Expect(iptablesAdapter.BulkInsertCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the chains don't exist yet", func() {
+				BeforeEach(func() {
+					iptablesAdapter.ListReturns(nil, errors.New("no chain"))
+				})
+
+				It("creates them", func() {
+					Expect(firewall.Apply("some-handle", containerRules)).To(Succeed())
+
+					Expect(iptablesAdapter.NewChainCallCount()).To(Equal(2))
+					table, chain := iptablesAdapter.NewChainArgsForCall(0)
+					Expect(table).To(Equal("nat"))
+					Expect(chain).To(Equal("update--some-handle"))
+				})
+			})
+
+			Context("when the container has no known address", func() {
+				BeforeEach(func() {
+					containerRules.ContainerIP = ""
+				})
+
+				It("returns an error", func() {
+					err := firewall.Apply("some-handle", containerRules)
+					Expect(err).To(MatchError("container has no known IPv4 address"))
+					Expect(iptablesAdapter.SyncChainCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when syncing a chain fails", func() {
+				BeforeEach(func() {
+					iptablesAdapter.SyncChainReturns(rules.SyncSummary{}, errors.New("banana"))
+				})
+
+				It("returns an error", func() {
+					err := firewall.Apply("some-handle", containerRules)
+					Expect(err).To(MatchError("syncing chain update--some-handle: banana"))
+				})
+			})
+		})
+
+		Context("when egress rules were added and removed", func() {
+			BeforeEach(func() {
+				containerRules.NetOut = []garden.NetOutRule{newNetOut}
+			})
+
+			It("accepts the current rules before rejecting the removed ones", func() {
+				Expect(firewall.Apply("some-handle", containerRules)).To(Succeed())
+
+				Expect(syncedRules("filter")).To(Equal([]rules.IPTablesRule{
+					{"-s", "10.255.0.2", "-m", "state", "--state", "RELATED,ESTABLISHED", "--jump", "ACCEPT"},
+					{"-s", "10.255.0.2", "-m", "iprange", "--dst-range", "10.0.1.1-10.0.1.1", "-p", "icmp", "--jump", "ACCEPT"},
+					{"-s", "10.255.0.2", "-m", "iprange", "--dst-range", "10.0.0.1-10.0.0.9", "-p", "tcp",
+						"-m", "tcp", "--dport", "80:90", "--jump", "REJECT", "--reject-with", "icmp-port-unreachable"},
+				}))
+			})
+
+			It("removes the nat chain it does not need", func() {
+				Expect(firewall.Apply("some-handle", containerRules)).To(Succeed())
+
+				Expect(syncedRules("nat")).To(BeNil())
+				Expect(iptablesAdapter.DeleteChainCallCount()).To(Equal(1))
+				table, chain := iptablesAdapter.DeleteChainArgsForCall(0)
+				Expect(table).To(Equal("nat"))
+				Expect(chain).To(Equal("update--some-handle"))
+			})
+		})
+
+		Context("when the rules are the ones the container was brought up with", func() {
+			BeforeEach(func() {
+				iptablesAdapter.ExistsReturns(true, nil)
+			})
+
+			It("removes the chains of the container", func() {
+				Expect(firewall.Apply("some-handle", containerRules)).To(Succeed())
+
+				Expect(iptablesAdapter.SyncChainCallCount()).To(Equal(0))
+				Expect(iptablesAdapter.DeleteCallCount()).To(Equal(2))
+				Expect(iptablesAdapter.DeleteChainCallCount()).To(Equal(2))
+			})
+		})
+	})
+
+	Describe("Remove", func() {
+		BeforeEach(func() {
+			iptablesAdapter.ExistsReturns(true, nil)
+		})
+
+		It("removes the jumps to the chains and the chains", func() {
+			Expect(firewall.Remove("some-handle")).To(Succeed())
+
+			table, chain, jump := iptablesAdapter.DeleteArgsForCall(0)
+			Expect(table).To(Equal("nat"))
+			Expect(chain).To(Equal("PREROUTING"))
+			Expect(jump).To(Equal(rules.IPTablesRule{"--jump", "update--some-handle"}))
+			table, chain = iptablesAdapter.ClearChainArgsForCall(1)
+			Expect(table).To(Equal("filter"))
+			Expect(chain).To(Equal("update--some-handle"))
+			Expect(iptablesAdapter.DeleteChainCallCount()).To(Equal(2))
+		})
+
+		Context("when the container has no chains", func() {
+			BeforeEach(func() {
+				iptablesAdapter.ListReturns(nil, errors.New("no chain"))
+			})
+
+			It("does nothing", func() {
+				Expect(firewall.Remove("some-handle")).To(Succeed())
+				Expect(iptablesAdapter.DeleteCallCount()).To(Equal(0))
+				Expect(iptablesAdapter.DeleteChainCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when deleting a chain fails", func() {
+			BeforeEach(func() {
+				iptablesAdapter.DeleteChainReturns(errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				Expect(firewall.Remove("some-handle")).To(MatchError("deleting chain update--some-handle: banana"))
+			})
+		})
+	})
+
+	Describe("ChainName", func() {
+		It("truncates long handles", func() {
+			Expect(netrules.ChainName("0123456789abcdef0123456789abcdef")).To(Equal("update--0123456789abcdef0123"))
+		})
+	})
+})
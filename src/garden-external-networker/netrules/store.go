@@ -0,0 +1,92 @@
+package netrules
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/garden"
+)
+
+// Rules are the port mappings, egress rules and properties a container was
+// last set up with. NetIn host ports are the ones that were actually
+// allocated. UpNetIn and UpNetOut are the rules the CNI plugins set up for
+// the container, which updates are applied on top of, and ContainerIP is
+// its IPv4 address.
+type Rules struct {
+	NetIn       []garden.NetIn         `json:"netin"`
+	NetOut      []garden.NetOutRule    `json:"netout_rules"`
+	Properties  map[string]interface{} `json:"properties,omitempty"`
+	UpNetIn     []garden.NetIn         `json:"up_netin,omitempty"`
+	UpNetOut    []garden.NetOutRule    `json:"up_netout_rules,omitempty"`
+	ContainerIP string                 `json:"container_ip,omitempty"`
+}
+
+// Store keeps the rules of each container, so that an update can work out
+// which rules and host ports were added or removed.
+type Store struct {
+	Dir string
+}
+
+func (s *Store) Save(handle string, rules Rules) error {
+	rulesBytes, err := json.Marshal(rules)
+	if err != nil {
+		return fmt.Errorf("marshal rules: %s", err) // not tested
+	}
+
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return fmt.Errorf("create rules dir: %s", err)
+	}
+
+	tempFile, err := ioutil.TempFile(s.Dir, handle+".tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file: %s", err)
+	}
+	defer os.Remove(tempFile.Name()) // not tested
+
+	if _, err := tempFile.Write(rulesBytes); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("write temp file: %s", err) // not tested
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("close temp file: %s", err) // not tested
+	}
+
+	if err := os.Rename(tempFile.Name(), s.path(handle)); err != nil {
+		return fmt.Errorf("rename temp file: %s", err) // not tested
+	}
+	return nil
+}
+
+// Load returns the saved rules of a container, or no rules if none were
+// saved, as for containers created before rules were kept.
+func (s *Store) Load(handle string) (Rules, error) {
+	rules := Rules{}
+
+	rulesBytes, err := ioutil.ReadFile(s.path(handle))
+	if os.IsNotExist(err) {
+		return rules, nil
+	}
+	if err != nil {
+		return rules, fmt.Errorf("read rules: %s", err)
+	}
+
+	if err := json.Unmarshal(rulesBytes, &rules); err != nil {
+		return rules, fmt.Errorf("unmarshal rules: %s", err)
+	}
+	return rules, nil
+}
+
+func (s *Store) Delete(handle string) error {
+	err := os.Remove(s.path(handle))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) path(handle string) string {
+	return filepath.Join(s.Dir, handle+".json")
+}
@@ -0,0 +1,98 @@
+package netrules_test
+
+import (
+	"garden-external-networker/netrules"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/garden"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store", func() {
+	var (
+		rulesDir string
+		store    *netrules.Store
+		rules    netrules.Rules
+	)
+
+	BeforeEach(func() {
+		var err error
+		rulesDir, err = ioutil.TempDir("", "net-rules-")
+		Expect(err).NotTo(HaveOccurred())
+
+		store = &netrules.Store{Dir: filepath.Join(rulesDir, "rules")}
+		rules = netrules.Rules{
+			NetIn: []garden.NetIn{
+				{HostPort: 61000, ContainerPort: 8080},
+			},
+			NetOut: []garden.NetOutRule{
+				{
+					Protocol: garden.ProtocolTCP,
+					Networks: []garden.IPRange{{Start: net.ParseIP("10.0.0.1"), End: net.ParseIP("10.0.0.1")}},
+				},
+			},
+			Properties: map[string]interface{}{"policy_group_id": "some-group-id"},
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(rulesDir)).To(Succeed())
+	})
+
+	It("saves and loads the rules of a container", func() {
+		Expect(store.Save("some-handle", rules)).To(Succeed())
+
+		loaded, err := store.Load("some-handle")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.NetIn).To(Equal(rules.NetIn))
+		Expect(loaded.NetOut).To(HaveLen(1))
+		Expect(loaded.NetOut[0].Protocol).To(Equal(garden.ProtocolTCP))
+		Expect(loaded.NetOut[0].Networks[0].Start.String()).To(Equal("10.0.0.1"))
+		Expect(loaded.Properties).To(Equal(rules.Properties))
+
+		files, err := ioutil.ReadDir(store.Dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+	})
+
+	It("deletes the rules of a container", func() {
+		Expect(store.Save("some-handle", rules)).To(Succeed())
+		Expect(store.Delete("some-handle")).To(Succeed())
+
+		loaded, err := store.Load("some-handle")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded).To(Equal(netrules.Rules{}))
+	})
+
+	It("loads no rules for containers whose rules were never saved", func() {
+		loaded, err := store.Load("other-handle")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded).To(Equal(netrules.Rules{}))
+	})
+
+	It("does not fail to delete rules that were never saved", func() {
+		Expect(store.Delete("some-handle")).To(Succeed())
+	})
+
+	Context("when the saved rules are corrupt", func() {
+		It("returns an error", func() {
+			Expect(os.MkdirAll(store.Dir, 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(store.Dir, "some-handle.json"), []byte("garbage"), 0600)).To(Succeed())
+
+			_, err := store.Load("some-handle")
+			Expect(err).To(MatchError(HavePrefix("unmarshal rules:")))
+		})
+	})
+
+	Context("when the rules dir cannot be created", func() {
+		It("returns an error", func() {
+			store.Dir = "/proc/0/foo"
+			err := store.Save("some-handle", rules)
+			Expect(err).To(MatchError(HavePrefix("create rules dir:")))
+		})
+	})
+})
@@ -3,51 +3,107 @@ package port_allocator
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
 )
 
 var ErrorPortPoolExhausted = errors.New("port pool exhausted")
 
+// poolVersion is the version of the state file written by MarshalJSON.
+// Version 1 files only had acquired_ports and are read as is.
+const poolVersion = 2
+
+// maxRecentlyReleased bounds how many released ports random allocation
+// keeps out of circulation.
+const maxRecentlyReleased = 100
+
 type Pool struct {
 	AcquiredPorts map[int]string
+	// LastAllocatedPort is where round-robin allocation continues from.
+	LastAllocatedPort int
+	// RecentlyReleased lists released ports, oldest first, that random
+	// allocation only hands out again when no other port is free.
+	RecentlyReleased []int
 }
 
 func (p *Pool) MarshalJSON() ([]byte, error) {
 	var jsonData struct {
-		AcquiredPorts map[string][]int `json:"acquired_ports"`
+		Version           int              `json:"version"`
+		AcquiredPorts     map[string][]int `json:"acquired_ports"`
+		LastAllocatedPort int              `json:"last_allocated_port,omitempty"`
+		RecentlyReleased  []int            `json:"recently_released,omitempty"`
 	}
+	jsonData.Version = poolVersion
 	jsonData.AcquiredPorts = make(map[string][]int)
+	jsonData.LastAllocatedPort = p.LastAllocatedPort
+	jsonData.RecentlyReleased = p.RecentlyReleased
 
 	for port, handle := range p.AcquiredPorts {
 		jsonData.AcquiredPorts[handle] = append(jsonData.AcquiredPorts[handle], port)
 	}
+	for _, ports := range jsonData.AcquiredPorts {
+		sort.Ints(ports)
+	}
 	return json.Marshal(jsonData)
 }
 
 func (p *Pool) UnmarshalJSON(bytes []byte) error {
 	var jsonData struct {
-		AcquiredPorts map[string][]int `json:"acquired_ports"`
+		Version           int              `json:"version"`
+		AcquiredPorts     map[string][]int `json:"acquired_ports"`
+		LastAllocatedPort int              `json:"last_allocated_port"`
+		RecentlyReleased  []int            `json:"recently_released"`
 	}
 	err := json.Unmarshal(bytes, &jsonData)
 	if err != nil {
 		return err
 	}
 
+	if jsonData.Version > poolVersion {
+		return fmt.Errorf("unsupported state file version %d", jsonData.Version)
+	}
+
 	p.AcquiredPorts = make(map[int]string)
 	for handle, ports := range jsonData.AcquiredPorts {
 		for _, port := range ports {
 			p.AcquiredPorts[port] = handle
 		}
 	}
+	p.LastAllocatedPort = jsonData.LastAllocatedPort
+	p.RecentlyReleased = jsonData.RecentlyReleased
 	return nil
 }
 
+const (
+	StrategyRoundRobin = "round-robin"
+	StrategyRandom     = "random"
+)
+
+type PortRange struct {
+	Start int
+	Size  int
+}
+
+// Tracker allocates ports from Ranges, skipping Reserved ports. Round-robin
+// allocation, the default, picks the next free port after the one allocated
+// last, so a released port is not handed out again straight away. Random
+// allocation starts the search for a free port at a random place instead,
+// and skips the ports in Pool.RecentlyReleased unless no other port is free.
 type Tracker struct {
-	StartPort int
-	Capacity  int
+	Ranges   []PortRange
+	Reserved []int
+	Strategy string
+	Rand     *rand.Rand
 }
 
 func (t *Tracker) InRange(port int) bool {
-	return port >= t.StartPort && port < t.StartPort+t.Capacity
+	for _, r := range t.Ranges {
+		if port >= r.Start && port < r.Start+r.Size {
+			return !t.isReserved(port)
+		}
+	}
+	return false
 }
 
 func (t *Tracker) AcquireOne(pool *Pool, handler string) (int, error) {
@@ -55,25 +111,132 @@ func (t *Tracker) AcquireOne(pool *Pool, handler string) (int, error) {
 		pool.AcquiredPorts = make(map[int]string)
 	}
 
-	for i := 0; i < t.Capacity; i++ {
-		candidatePort := t.StartPort + i
-		if !contains(pool.AcquiredPorts, candidatePort) {
-			pool.AcquiredPorts[candidatePort] = handler
-			return candidatePort, nil
+	candidates := t.candidates()
+	if len(candidates) == 0 {
+		return -1, ErrorPortPoolExhausted
+	}
+
+	first := 0
+	if t.Strategy == StrategyRandom {
+		first = t.random(len(candidates))
+	} else {
+		first = sort.SearchInts(candidates, pool.LastAllocatedPort+1) % len(candidates)
+	}
+
+	for i := 0; i < len(candidates); i++ {
+		candidatePort := candidates[(first+i)%len(candidates)]
+		if contains(pool.AcquiredPorts, candidatePort) {
+			continue
+		}
+		if t.Strategy == StrategyRandom && recentlyReleased(pool, candidatePort) {
+			continue
+		}
+		acquire(pool, handler, candidatePort)
+		return candidatePort, nil
+	}
+
+	for _, port := range pool.RecentlyReleased {
+		if t.InRange(port) && !contains(pool.AcquiredPorts, port) {
+			acquire(pool, handler, port)
+			return port, nil
 		}
 	}
 	return -1, ErrorPortPoolExhausted
 }
 
 func (t *Tracker) ReleaseAll(pool *Pool, handle string) error {
+	released := []int{}
 	for port, h := range pool.AcquiredPorts {
 		if h == handle {
 			delete(pool.AcquiredPorts, port)
+			released = append(released, port)
 		}
 	}
+	sort.Ints(released)
+	for _, port := range released {
+		release(pool, port)
+	}
 	return nil
 }
 
+// ReleaseOne releases a single port, if it is held by the handle.
+func (t *Tracker) ReleaseOne(pool *Pool, handle string, port int) error {
+	if h, ok := pool.AcquiredPorts[port]; ok && h == handle {
+		delete(pool.AcquiredPorts, port)
+		release(pool, port)
+	}
+	return nil
+}
+
+func acquire(pool *Pool, handle string, port int) {
+	pool.AcquiredPorts[port] = handle
+	pool.LastAllocatedPort = port
+	pool.RecentlyReleased = without(pool.RecentlyReleased, port)
+}
+
+func release(pool *Pool, port int) {
+	pool.RecentlyReleased = append(without(pool.RecentlyReleased, port), port)
+	if len(pool.RecentlyReleased) > maxRecentlyReleased {
+		pool.RecentlyReleased = pool.RecentlyReleased[len(pool.RecentlyReleased)-maxRecentlyReleased:]
+	}
+}
+
+func recentlyReleased(pool *Pool, port int) bool {
+	for _, p := range pool.RecentlyReleased {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+func without(ports []int, port int) []int {
+	result := []int{}
+	for _, p := range ports {
+		if p != port {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// candidates returns every port that can be allocated, in ascending order.
+func (t *Tracker) candidates() []int {
+	ports := []int{}
+	for _, r := range t.Ranges {
+		for port := r.Start; port < r.Start+r.Size; port++ {
+			if !t.isReserved(port) {
+				ports = append(ports, port)
+			}
+		}
+	}
+	sort.Ints(ports)
+
+	unique := []int{}
+	for _, port := range ports {
+		if len(unique) == 0 || unique[len(unique)-1] != port {
+			unique = append(unique, port)
+		}
+	}
+	return unique
+}
+
+func (t *Tracker) isReserved(port int) bool {
+	for _, reserved := range t.Reserved {
+		if port == reserved {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Tracker) random(n int) int {
+	if t.Rand != nil {
+		return t.Rand.Intn(n)
+	}
+	return rand.Intn(n)
+}
+
 func contains(list map[int]string, candidate int) bool {
 	_, ok := list[candidate]
 	return ok
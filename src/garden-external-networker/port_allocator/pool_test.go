@@ -3,6 +3,7 @@ package port_allocator_test
 import (
 	"encoding/json"
 	"garden-external-networker/port_allocator"
+	"math/rand"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -15,10 +16,9 @@ var _ = Describe("Tracker", func() {
 		tracker *port_allocator.Tracker
 	)
 	BeforeEach(func() {
-		pool = &port_allocator.Pool{}
+		pool = &port_allocator.Pool{AcquiredPorts: map[int]string{}}
 		tracker = &port_allocator.Tracker{
-			StartPort: 100,
-			Capacity:  10,
+			Ranges: []port_allocator.PortRange{{Start: 100, Size: 10}},
 		}
 	})
 
@@ -46,7 +46,7 @@ var _ = Describe("Tracker", func() {
 
 		Context("when the only unacquired port is in the middle of the range", func() {
 			BeforeEach(func() {
-				tracker.Capacity = 3
+				tracker.Ranges = []port_allocator.PortRange{{Start: 100, Size: 3}}
 				pool.AcquiredPorts = map[int]string{
 					100: "some-handle",
 					102: "some-handle",
@@ -63,7 +63,7 @@ var _ = Describe("Tracker", func() {
 
 		Context("when the pool has reached capacity", func() {
 			BeforeEach(func() {
-				tracker.Capacity = 2
+				tracker.Ranges = []port_allocator.PortRange{{Start: 100, Size: 2}}
 				pool.AcquiredPorts = map[int]string{
 					100: "some-handle",
 					101: "some-handle",
@@ -78,7 +78,7 @@ var _ = Describe("Tracker", func() {
 
 		Describe("performance", func() {
 			Measure("should acquire all of the ports quickly", func(b Benchmarker) {
-				tracker.Capacity = 4000
+				tracker.Ranges = []port_allocator.PortRange{{Start: 100, Size: 4000}}
 				runtime := b.Time("runtime", func() {
 					for i := 0; i < 4000; i++ {
 						_, err := tracker.AcquireOne(pool, "some-handle")
@@ -91,10 +91,127 @@ var _ = Describe("Tracker", func() {
 		})
 	})
 
+	Describe("round-robin allocation", func() {
+		It("does not hand out a released port again straight away", func() {
+			first, err := tracker.AcquireOne(pool, "some-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tracker.ReleaseAll(pool, "some-handle")).To(Succeed())
+
+			second, err := tracker.AcquireOne(pool, "some-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(second).To(Equal(first + 1))
+		})
+
+		It("wraps around to the start of the ranges", func() {
+			pool.LastAllocatedPort = 109
+
+			port, err := tracker.AcquireOne(pool, "some-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(port).To(Equal(100))
+		})
+	})
+
+	Describe("random allocation", func() {
+		BeforeEach(func() {
+			tracker.Strategy = port_allocator.StrategyRandom
+			tracker.Rand = rand.New(rand.NewSource(42))
+		})
+
+		It("allocates unique ports from the ranges", func() {
+			ports := map[int]bool{}
+			for i := 0; i < 10; i++ {
+				port, err := tracker.AcquireOne(pool, "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(port).To(BeInRange(100, 110))
+				ports[port] = true
+			}
+			Expect(ports).To(HaveLen(10))
+
+			_, err := tracker.AcquireOne(pool, "some-handle")
+			Expect(err).To(Equal(port_allocator.ErrorPortPoolExhausted))
+		})
+
+		It("does not always start from the same port", func() {
+			ports := map[int]bool{}
+			for i := 0; i < 10; i++ {
+				port, err := tracker.AcquireOne(pool, "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(tracker.ReleaseAll(pool, "some-handle")).To(Succeed())
+				ports[port] = true
+			}
+			Expect(len(ports)).To(BeNumerically(">", 1))
+		})
+
+		It("does not hand out a released port again straight away", func() {
+			for i := 0; i < 20; i++ {
+				port, err := tracker.AcquireOne(pool, "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(tracker.ReleaseOne(pool, "some-handle", port)).To(Succeed())
+
+				next, err := tracker.AcquireOne(pool, "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(next).NotTo(Equal(port))
+				Expect(tracker.ReleaseAll(pool, "some-handle")).To(Succeed())
+			}
+		})
+
+		It("hands out the port released longest ago when every other port is acquired", func() {
+			for port := 100; port < 110; port++ {
+				pool.AcquiredPorts[port] = "some-handle"
+			}
+			Expect(tracker.ReleaseOne(pool, "some-handle", 104)).To(Succeed())
+			Expect(tracker.ReleaseOne(pool, "some-handle", 102)).To(Succeed())
+
+			port, err := tracker.AcquireOne(pool, "some-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(port).To(Equal(104))
+			Expect(pool.RecentlyReleased).To(Equal([]int{102}))
+		})
+	})
+
+	Describe("multiple ranges and reserved ports", func() {
+		BeforeEach(func() {
+			tracker.Ranges = []port_allocator.PortRange{
+				{Start: 200, Size: 2},
+				{Start: 100, Size: 3},
+			}
+			tracker.Reserved = []int{101, 200}
+		})
+
+		It("allocates from every range in ascending order, skipping reserved ports", func() {
+			ports := []int{}
+			for i := 0; i < 3; i++ {
+				port, err := tracker.AcquireOne(pool, "some-handle")
+				Expect(err).NotTo(HaveOccurred())
+				ports = append(ports, port)
+			}
+			Expect(ports).To(Equal([]int{100, 102, 201}))
+
+			_, err := tracker.AcquireOne(pool, "some-handle")
+			Expect(err).To(Equal(port_allocator.ErrorPortPoolExhausted))
+		})
+
+		It("treats reserved ports as outside the ranges", func() {
+			Expect(tracker.InRange(100)).To(BeTrue())
+			Expect(tracker.InRange(201)).To(BeTrue())
+			Expect(tracker.InRange(101)).To(BeFalse())
+			Expect(tracker.InRange(200)).To(BeFalse())
+			Expect(tracker.InRange(150)).To(BeFalse())
+		})
+
+		It("keeps ports that were allocated outside the current ranges", func() {
+			pool.AcquiredPorts = map[int]string{5000: "old-handle"}
+
+			_, err := tracker.AcquireOne(pool, "some-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pool.AcquiredPorts).To(HaveKeyWithValue(5000, "old-handle"))
+		})
+	})
+
 	Describe("acquire and release lifecycle", func() {
 		It("can re-acquire ports which have been acquired and then released", func() {
 			var err error
-			for i := 0; i < tracker.Capacity; i++ {
+			for i := 0; i < 10; i++ {
 				if i%2 == 0 {
 					_, err = tracker.AcquireOne(pool, "some-handle")
 				} else {
@@ -109,6 +226,30 @@ var _ = Describe("Tracker", func() {
 		})
 	})
 
+	Describe("ReleaseOne", func() {
+		BeforeEach(func() {
+			pool.AcquiredPorts = map[int]string{
+				100: "some-handle",
+				101: "some-handle",
+				102: "other-handle",
+			}
+		})
+
+		It("releases only the given port of the handle", func() {
+			Expect(tracker.ReleaseOne(pool, "some-handle", 100)).To(Succeed())
+			Expect(pool.AcquiredPorts).To(Equal(map[int]string{
+				101: "some-handle",
+				102: "other-handle",
+			}))
+			Expect(pool.RecentlyReleased).To(Equal([]int{100}))
+		})
+
+		It("does not release a port held by another handle", func() {
+			Expect(tracker.ReleaseOne(pool, "some-handle", 102)).To(Succeed())
+			Expect(pool.AcquiredPorts).To(HaveKeyWithValue(102, "other-handle"))
+		})
+	})
+
 	Describe("InRange", func() {
 		It("returns true if the given port is in the allocation range", func() {
 			for i := 100; i < 110; i++ {
@@ -145,11 +286,55 @@ var _ = Describe("Tracker", func() {
 			bytes, err := json.Marshal(pool)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(bytes).To(MatchJSON(`{ "acquired_ports": {
+			Expect(bytes).To(MatchJSON(`{ "version": 2, "acquired_ports": {
 				"some-handle": [ 42 ],
 				"some-handle2": [ 105 ]
 			} }`))
 		})
+
+		It("keeps where round-robin allocation continues from", func() {
+			pool.LastAllocatedPort = 105
+
+			bytes, err := json.Marshal(pool)
+			Expect(err).NotTo(HaveOccurred())
+
+			var newPool port_allocator.Pool
+			Expect(json.Unmarshal(bytes, &newPool)).To(Succeed())
+			Expect(newPool.LastAllocatedPort).To(Equal(105))
+		})
+
+		It("keeps the recently released ports", func() {
+			pool.RecentlyReleased = []int{105, 101}
+
+			bytes, err := json.Marshal(pool)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bytes).To(MatchJSON(`{ "version": 2, "acquired_ports": {}, "recently_released": [ 105, 101 ] }`))
+
+			var newPool port_allocator.Pool
+			Expect(json.Unmarshal(bytes, &newPool)).To(Succeed())
+			Expect(newPool.RecentlyReleased).To(Equal([]int{105, 101}))
+		})
+
+		It("reads state files written before the pool was versioned", func() {
+			var newPool port_allocator.Pool
+			Expect(json.Unmarshal([]byte(`{ "acquired_ports": {
+				"some-handle": [ 42, 43 ],
+				"some-handle2": [ 105 ]
+			} }`), &newPool)).To(Succeed())
+
+			Expect(newPool.AcquiredPorts).To(Equal(map[int]string{
+				42:  "some-handle",
+				43:  "some-handle",
+				105: "some-handle2",
+			}))
+			Expect(newPool.LastAllocatedPort).To(Equal(0))
+		})
+
+		It("refuses state files from a newer version", func() {
+			var newPool port_allocator.Pool
+			err := json.Unmarshal([]byte(`{ "version": 3, "acquired_ports": {} }`), &newPool)
+			Expect(err).To(MatchError("unsupported state file version 3"))
+		})
 	})
 })
 
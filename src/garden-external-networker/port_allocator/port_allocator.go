@@ -1,11 +1,14 @@
 package port_allocator
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"lib/serial"
+	"sort"
 
 	"code.cloudfoundry.org/filelock"
+	"code.cloudfoundry.org/lager"
 )
 
 //go:generate counterfeiter -o ../fakes/file_locker.go --fake-name FileLocker . FileLocker
@@ -22,13 +25,47 @@ type LockedFile interface {
 type tracker interface {
 	AcquireOne(pool *Pool, handle string) (int, error)
 	ReleaseAll(pool *Pool, handle string) error
+	ReleaseOne(pool *Pool, handle string, port int) error
 	InRange(port int) bool
 }
 
+// PortAllocator keeps the pool in Store. Locker must lock a file other
+// than the state file, which Store replaces on every save.
 type PortAllocator struct {
-	Tracker    tracker
-	Serializer serial.Serializer
-	Locker     filelock.FileLocker
+	Tracker tracker
+	Store   serial.StateStore
+	Locker  filelock.FileLocker
+	Logger  lager.Logger
+}
+
+// stateFileVersion is the version of the state file around the pool. Files
+// from before it was versioned hold the bare pool, which needs no migration
+// because the pool reads its own older versions.
+const stateFileVersion = 1
+
+func NewStateFile(path string) *serial.StateFile {
+	return &serial.StateFile{
+		Path:    path,
+		Version: stateFileVersion,
+		Migrate: func(fromVersion int, data json.RawMessage) (json.RawMessage, error) {
+			return data, nil
+		},
+	}
+}
+
+func (p *PortAllocator) loadPool() (*Pool, error) {
+	pool := &Pool{}
+	report, err := p.Store.Load(pool)
+	if err != nil {
+		return nil, fmt.Errorf("decoding state file: %s", err)
+	}
+	if report.Recovered {
+		p.Logger.Error("state-file-recovered-from-backup", report.Corruption, lager.Data{"backup": report.Path})
+	}
+	if report.FromVersion != stateFileVersion {
+		p.Logger.Info("state-file-migrated", lager.Data{"from-version": report.FromVersion, "to-version": stateFileVersion})
+	}
+	return pool, nil
 }
 
 func (p *PortAllocator) AllocatePort(handle string, port int) (int, error) {
@@ -46,10 +83,9 @@ func (p *PortAllocator) AllocatePort(handle string, port int) (int, error) {
 	}
 	defer file.Close() // defer not tested
 
-	pool := &Pool{}
-	err = p.Serializer.DecodeAll(file, pool)
+	pool, err := p.loadPool()
 	if err != nil {
-		return -1, fmt.Errorf("decoding state file: %s", err)
+		return -1, err
 	}
 
 	newPort, err := p.Tracker.AcquireOne(pool, handle)
@@ -57,9 +93,9 @@ func (p *PortAllocator) AllocatePort(handle string, port int) (int, error) {
 		return -1, fmt.Errorf("acquire port: %s", err)
 	}
 
-	err = p.Serializer.EncodeAndOverwrite(file, pool)
+	err = p.Store.Save(pool)
 	if err != nil {
-		return -1, fmt.Errorf("encode and overwrite: %s", err)
+		return -1, fmt.Errorf("saving state file: %s", err)
 	}
 
 	return newPort, nil
@@ -72,20 +108,71 @@ func (p *PortAllocator) ReleaseAllPorts(handle string) error {
 	}
 	defer file.Close() // defer not tested
 
-	pool := &Pool{}
-	err = p.Serializer.DecodeAll(file, pool)
+	pool, err := p.loadPool()
 	if err != nil {
-		return fmt.Errorf("decoding state file: %s", err)
+		return err
 	}
 
 	if err := p.Tracker.ReleaseAll(pool, handle); err != nil {
 		return fmt.Errorf("release all ports: %s", err)
 	}
 
-	err = p.Serializer.EncodeAndOverwrite(file, pool)
+	err = p.Store.Save(pool)
+	if err != nil {
+		return fmt.Errorf("saving state file: %s", err)
+	}
+
+	return nil
+}
+
+// ReleasePort releases one port allocated to the handle. Ports the handle
+// doesn't hold are left alone. The port may be outside the current ranges,
+// if it was allocated before they were changed.
+func (p *PortAllocator) ReleasePort(handle string, port int) error {
+	file, err := p.Locker.Open()
+	if err != nil {
+		return fmt.Errorf("open lock: %s", err)
+	}
+	defer file.Close() // defer not tested
+
+	pool, err := p.loadPool()
 	if err != nil {
-		return fmt.Errorf("encode and overwrite: %s", err)
+		return err
+	}
+
+	if err := p.Tracker.ReleaseOne(pool, handle, port); err != nil {
+		return fmt.Errorf("release port: %s", err)
+	}
+
+	err = p.Store.Save(pool)
+	if err != nil {
+		return fmt.Errorf("saving state file: %s", err)
 	}
 
 	return nil
 }
+
+func (p *PortAllocator) AllocatedHandles() ([]string, error) {
+	file, err := p.Locker.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open lock: %s", err)
+	}
+	defer file.Close() // defer not tested
+
+	pool, err := p.loadPool()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	handles := []string{}
+	for _, handle := range pool.AcquiredPorts {
+		if !seen[handle] {
+			seen[handle] = true
+			handles = append(handles, handle)
+		}
+	}
+	sort.Strings(handles)
+
+	return handles, nil
+}
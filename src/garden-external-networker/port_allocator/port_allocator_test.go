@@ -6,31 +6,37 @@ import (
 	"garden-external-networker/port_allocator"
 	"io/ioutil"
 	libfakes "lib/fakes"
+	"lib/serial"
 	"os"
 
+	"code.cloudfoundry.org/lager/lagertest"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 )
 
 var _ = Describe("PortAllocator", func() {
 	var (
 		portAllocator *port_allocator.PortAllocator
 		tracker       *fakes.Tracker
-		serializer    *libfakes.Serializer
+		store         *libfakes.StateStore
 		locker        *fakes.FileLocker
 		lockedFile    *os.File
+		logger        *lagertest.TestLogger
 	)
 	BeforeEach(func() {
-		serializer = &libfakes.Serializer{}
+		store = &libfakes.StateStore{}
 		tracker = &fakes.Tracker{}
 		locker = &fakes.FileLocker{}
-		serializer.DecodeAllReturns(nil)
+		logger = lagertest.NewTestLogger("test")
+		store.LoadReturns(serial.LoadReport{Path: "some-state-file", FromVersion: 1}, nil)
 		tracker.AcquireOneReturns(111, nil)
 
 		portAllocator = &port_allocator.PortAllocator{
-			Tracker:    tracker,
-			Serializer: serializer,
-			Locker:     locker,
+			Tracker: tracker,
+			Store:   store,
+			Locker:  locker,
+			Logger:  logger,
 		}
 
 		lockedFile = &os.File{}
@@ -38,14 +44,12 @@ var _ = Describe("PortAllocator", func() {
 	})
 
 	Describe("AllocatePort", func() {
-		It("deserializes the pool from the locked file", func() {
+		It("loads the pool from the state file while holding the lock", func() {
 			_, err := portAllocator.AllocatePort("some-handle", 0)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(serializer.DecodeAllCallCount()).To(Equal(1))
-
-			file, _ := serializer.DecodeAllArgsForCall(0)
-			Expect(file).To(Equal(lockedFile))
+			Expect(locker.OpenCallCount()).To(Equal(1))
+			Expect(store.LoadCallCount()).To(Equal(1))
 		})
 
 		Context("when the passed in port is 0", func() {
@@ -53,10 +57,10 @@ var _ = Describe("PortAllocator", func() {
 				_, err := portAllocator.AllocatePort("some-handle", 0)
 				Expect(err).NotTo(HaveOccurred())
 
-				Expect(serializer.DecodeAllCallCount()).To(Equal(1))
+				Expect(store.LoadCallCount()).To(Equal(1))
 				Expect(tracker.AcquireOneCallCount()).To(Equal(1))
 
-				_, pool := serializer.DecodeAllArgsForCall(0)
+				pool := store.LoadArgsForCall(0)
 				receivedPool, receivedHandle := tracker.AcquireOneArgsForCall(0)
 				Expect(receivedPool).To(Equal(pool))
 				Expect(receivedHandle).To(Equal("some-handle"))
@@ -86,16 +90,14 @@ var _ = Describe("PortAllocator", func() {
 			})
 		})
 
-		It("re-serializes the pool to the locked file", func() {
+		It("saves the loaded pool to the state file", func() {
 			_, err := portAllocator.AllocatePort("some-handle", 0)
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(serializer.EncodeAndOverwriteCallCount()).To(Equal(1))
-
-			_, poolForDecode := serializer.DecodeAllArgsForCall(0)
-			file, poolForEncode := serializer.EncodeAndOverwriteArgsForCall(0)
+			Expect(store.SaveCallCount()).To(Equal(1))
 
-			Expect(file).To(Equal(lockedFile))
+			poolForDecode := store.LoadArgsForCall(0)
+			poolForEncode := store.SaveArgsForCall(0)
 			Expect(poolForEncode).To(Equal(poolForDecode))
 		})
 
@@ -105,6 +107,36 @@ var _ = Describe("PortAllocator", func() {
 			Expect(port).To(Equal(111))
 		})
 
+		Context("when the state file was recovered from its backup", func() {
+			BeforeEach(func() {
+				store.LoadReturns(serial.LoadReport{
+					Path:        "some-state-file.bak",
+					Recovered:   true,
+					Corruption:  errors.New("checksum mismatch"),
+					FromVersion: 1,
+				}, nil)
+			})
+			It("logs the corruption and carries on", func() {
+				_, err := portAllocator.AllocatePort("some-handle", 0)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(logger).To(gbytes.Say(`"message":"test.state-file-recovered-from-backup".*"backup":"some-state-file.bak".*"error":"checksum mismatch"`))
+				Expect(store.SaveCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the state file was migrated from an older version", func() {
+			BeforeEach(func() {
+				store.LoadReturns(serial.LoadReport{Path: "some-state-file", FromVersion: 0}, nil)
+			})
+			It("logs the migration", func() {
+				_, err := portAllocator.AllocatePort("some-handle", 0)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(logger).To(gbytes.Say(`"message":"test.state-file-migrated".*"from-version":0,"to-version":1`))
+			})
+		})
+
 		It("closes (and thus unlocks) the file", func() {
 			file, err := ioutil.TempFile("", "")
 			Expect(err).NotTo(HaveOccurred())
@@ -128,9 +160,9 @@ var _ = Describe("PortAllocator", func() {
 			})
 		})
 
-		Context("when the serializer fails to decode", func() {
+		Context("when the state file cannot be loaded", func() {
 			BeforeEach(func() {
-				serializer.DecodeAllReturns(errors.New("potato"))
+				store.LoadReturns(serial.LoadReport{}, errors.New("potato"))
 			})
 			It("wraps and returns the error", func() {
 				_, err := portAllocator.AllocatePort("some-handle", 0)
@@ -148,38 +180,34 @@ var _ = Describe("PortAllocator", func() {
 			})
 		})
 
-		Context("when serializing the pool fails", func() {
+		Context("when saving the pool fails", func() {
 			BeforeEach(func() {
-				serializer.EncodeAndOverwriteReturns(errors.New("turnip"))
+				store.SaveReturns(errors.New("turnip"))
 			})
 			It("wraps and returns the error", func() {
 				_, err := portAllocator.AllocatePort("some-handle", 0)
-				Expect(err).To(MatchError("encode and overwrite: turnip"))
+				Expect(err).To(MatchError("saving state file: turnip"))
 			})
 		})
 	})
 
 	Describe("ReleaseAllPorts", func() {
-		It("deserializes the pool from the locked file", func() {
+		It("loads the pool from the state file while holding the lock", func() {
 			err := portAllocator.ReleaseAllPorts("some-handle")
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(serializer.DecodeAllCallCount()).To(Equal(1))
-
-			file, _ := serializer.DecodeAllArgsForCall(0)
-			Expect(file).To(Equal(lockedFile))
+			Expect(locker.OpenCallCount()).To(Equal(1))
+			Expect(store.LoadCallCount()).To(Equal(1))
 		})
 
-		It("re-serializes the pool to the locked file", func() {
+		It("saves the loaded pool to the state file", func() {
 			err := portAllocator.ReleaseAllPorts("some-handle")
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(serializer.EncodeAndOverwriteCallCount()).To(Equal(1))
+			Expect(store.SaveCallCount()).To(Equal(1))
 
-			_, poolForDecode := serializer.DecodeAllArgsForCall(0)
-			file, poolForEncode := serializer.EncodeAndOverwriteArgsForCall(0)
-
-			Expect(file).To(Equal(lockedFile))
+			poolForDecode := store.LoadArgsForCall(0)
+			poolForEncode := store.SaveArgsForCall(0)
 			Expect(poolForEncode).To(Equal(poolForDecode))
 		})
 
@@ -206,9 +234,9 @@ var _ = Describe("PortAllocator", func() {
 			})
 		})
 
-		Context("when the serializer fails to decode", func() {
+		Context("when the state file cannot be loaded", func() {
 			BeforeEach(func() {
-				serializer.DecodeAllReturns(errors.New("potato"))
+				store.LoadReturns(serial.LoadReport{}, errors.New("potato"))
 			})
 			It("wraps and returns the error", func() {
 				err := portAllocator.ReleaseAllPorts("some-handle")
@@ -226,15 +254,121 @@ var _ = Describe("PortAllocator", func() {
 			})
 		})
 
-		Context("when serializing the pool fails", func() {
+		Context("when saving the pool fails", func() {
 			BeforeEach(func() {
-				serializer.EncodeAndOverwriteReturns(errors.New("turnip"))
+				store.SaveReturns(errors.New("turnip"))
 			})
 			It("wraps and returns the error", func() {
 				err := portAllocator.ReleaseAllPorts("some-handle")
-				Expect(err).To(MatchError("encode and overwrite: turnip"))
+				Expect(err).To(MatchError("saving state file: turnip"))
+			})
+		})
+
+	})
+
+	Describe("ReleasePort", func() {
+		It("releases the port of the handle from the pool in the state file", func() {
+			err := portAllocator.ReleasePort("some-handle", 111)
+			Expect(err).NotTo(HaveOccurred())
+
+			poolForDecode := store.LoadArgsForCall(0)
+
+			Expect(tracker.ReleaseOneCallCount()).To(Equal(1))
+			pool, handle, port := tracker.ReleaseOneArgsForCall(0)
+			Expect(pool).To(Equal(poolForDecode))
+			Expect(handle).To(Equal("some-handle"))
+			Expect(port).To(Equal(111))
+
+			poolForEncode := store.SaveArgsForCall(0)
+			Expect(poolForEncode).To(Equal(poolForDecode))
+		})
+
+		Context("when the locker fails to open the file", func() {
+			BeforeEach(func() {
+				locker.OpenReturns(nil, errors.New("potato"))
+			})
+			It("wraps and returns the error", func() {
+				err := portAllocator.ReleasePort("some-handle", 111)
+				Expect(err).To(MatchError("open lock: potato"))
+			})
+		})
+
+		Context("when the state file cannot be loaded", func() {
+			BeforeEach(func() {
+				store.LoadReturns(serial.LoadReport{}, errors.New("potato"))
+			})
+			It("wraps and returns the error", func() {
+				err := portAllocator.ReleasePort("some-handle", 111)
+				Expect(err).To(MatchError("decoding state file: potato"))
+			})
+		})
+
+		Context("when the tracker fails to release the port", func() {
+			BeforeEach(func() {
+				tracker.ReleaseOneReturns(errors.New("turnip"))
+			})
+			It("wraps and returns the error", func() {
+				err := portAllocator.ReleasePort("some-handle", 111)
+				Expect(err).To(MatchError("release port: turnip"))
 			})
 		})
 
+		Context("when saving the pool fails", func() {
+			BeforeEach(func() {
+				store.SaveReturns(errors.New("turnip"))
+			})
+			It("wraps and returns the error", func() {
+				err := portAllocator.ReleasePort("some-handle", 111)
+				Expect(err).To(MatchError("saving state file: turnip"))
+			})
+		})
+	})
+
+	Describe("AllocatedHandles", func() {
+		BeforeEach(func() {
+			store.LoadStub = func(outData interface{}) (serial.LoadReport, error) {
+				pool := outData.(*port_allocator.Pool)
+				pool.AcquiredPorts = map[int]string{
+					60000: "handle-b",
+					60001: "handle-a",
+					60002: "handle-b",
+				}
+				return serial.LoadReport{FromVersion: 1}, nil
+			}
+		})
+
+		It("returns each handle that holds ports once, sorted", func() {
+			handles, err := portAllocator.AllocatedHandles()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(handles).To(Equal([]string{"handle-a", "handle-b"}))
+			Expect(locker.OpenCallCount()).To(Equal(1))
+		})
+
+		It("does not modify the state file", func() {
+			_, err := portAllocator.AllocatedHandles()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(store.SaveCallCount()).To(Equal(0))
+		})
+
+		Context("when the locker fails to open the file", func() {
+			BeforeEach(func() {
+				locker.OpenReturns(nil, errors.New("potato"))
+			})
+			It("wraps and returns the error", func() {
+				_, err := portAllocator.AllocatedHandles()
+				Expect(err).To(MatchError("open lock: potato"))
+			})
+		})
+
+		Context("when the state file cannot be loaded", func() {
+			BeforeEach(func() {
+				store.LoadStub = nil
+				store.LoadReturns(serial.LoadReport{}, errors.New("potato"))
+			})
+			It("wraps and returns the error", func() {
+				_, err := portAllocator.AllocatedHandles()
+				Expect(err).To(MatchError("decoding state file: potato"))
+			})
+		})
 	})
 })
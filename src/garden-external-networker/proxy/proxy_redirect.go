@@ -3,10 +3,24 @@ package proxy
 import (
 	"fmt"
 	"lib/rules"
+	"net"
+	"strconv"
+	"strings"
 
 	"github.com/containernetworking/plugins/pkg/ns"
 )
 
+const (
+	PropertyEnabled       = "proxy.enabled"
+	PropertyCIDRs         = "proxy.redirect_cidrs"
+	PropertyExcludedPorts = "proxy.excluded_ports"
+	PropertyProtocols     = "proxy.protocols"
+	PropertyInboundPort   = "proxy.inbound_port"
+
+	// iptables multiport matches at most 15 ports
+	maxExcludedPorts = 15
+)
+
 //go:generate counterfeiter -o ../fakes/namespaceAdapter.go --fake-name NamespaceAdapter . namespaceAdapter
 type namespaceAdapter interface {
 	GetNS(netNamespace string) (ns.NetNS, error)
@@ -20,23 +34,185 @@ type Redirect struct {
 	ProxyUID         int
 }
 
-func (r *Redirect) Apply(containerNetNamespace string) error {
-	if r.RedirectCIDR == "" {
-		return nil
+// Settings are the proxy properties of a container, parsed and validated.
+type Settings struct {
+	enabled       bool
+	cidrs         []string
+	excludedPorts []string
+	protocols     []string
+	inboundPort   int
+}
+
+// Apply redirects the traffic of a container to its proxy, as described by
+// settings from Settings. The applied rules are returned in iptables-save form.
+func (r *Redirect) Apply(containerNetNamespace string, s Settings) ([]string, error) {
+	if !s.enabled {
+		return nil, nil
 	}
 
+	outboundRules := r.outboundRules(s)
+	inboundRules := inboundRules(s)
+
 	netNS, err := r.NamespaceAdapter.GetNS(containerNetNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("get container namespace: %s", err)
+	}
+
 	err = netNS.Do(func(_ ns.NetNS) error {
-		return r.IPTables.BulkAppend("nat", "OUTPUT", rules.IPTablesRule{
-			"-d", r.RedirectCIDR,
-			"-p", "tcp",
-			"-m", "owner", "!", "--uid-owner", fmt.Sprintf("%d", r.ProxyUID),
-			"-j", "REDIRECT", "--to-port", fmt.Sprintf("%d", r.ProxyPort),
-		})
+		if err := r.IPTables.BulkAppend("nat", "OUTPUT", outboundRules...); err != nil {
+			return err
+		}
+		if len(inboundRules) > 0 {
+			return r.IPTables.BulkAppend("nat", "PREROUTING", inboundRules...)
+		}
+		return nil
 	})
 	if err != nil {
-		return fmt.Errorf("do in container: %s", err)
+		return nil, fmt.Errorf("do in container: %s", err)
+	}
+
+	applied := []string{}
+	for _, rule := range outboundRules {
+		applied = append(applied, describe("OUTPUT", rule))
+	}
+	for _, rule := range inboundRules {
+		applied = append(applied, describe("PREROUTING", rule))
 	}
+	return applied, nil
+}
+
+func (r *Redirect) outboundRules(s Settings) []rules.IPTablesRule {
+	outbound := []rules.IPTablesRule{}
+	for _, cidr := range s.cidrs {
+		for _, protocol := range s.protocols {
+			rule := rules.IPTablesRule{
+				"-d", cidr,
+				"-p", protocol,
+				"-m", "owner", "!", "--uid-owner", strconv.Itoa(r.ProxyUID),
+			}
+			rule = append(rule, excludePorts(s.excludedPorts)...)
+			rule = append(rule, "-j", "REDIRECT", "--to-port", strconv.Itoa(r.ProxyPort))
+			outbound = append(outbound, rule)
+		}
+	}
+	return outbound
+}
+
+func inboundRules(s Settings) []rules.IPTablesRule {
+	inbound := []rules.IPTablesRule{}
+	if s.inboundPort == 0 {
+		return inbound
+	}
+
+	for _, protocol := range s.protocols {
+		rule := rules.IPTablesRule{"-p", protocol}
+		rule = append(rule, excludePorts(s.excludedPorts)...)
+		rule = append(rule, "-j", "REDIRECT", "--to-port", strconv.Itoa(s.inboundPort))
+		inbound = append(inbound, rule)
+	}
+	return inbound
+}
+
+func excludePorts(ports []string) rules.IPTablesRule {
+	if len(ports) == 0 {
+		return nil
+	}
+	return rules.IPTablesRule{"-m", "multiport", "!", "--dports", strings.Join(ports, ",")}
+}
+
+func describe(chain string, rule rules.IPTablesRule) string {
+	return fmt.Sprintf("-t nat -A %s %s", chain, strings.Join(rule, " "))
+}
+
+// Settings parses the proxy properties of a container. Containers without
+// proxy properties get the outbound redirect of RedirectCIDR, if it is set.
+func (r *Redirect) Settings(properties map[string]interface{}) (Settings, error) {
+	s := Settings{
+		enabled:   r.RedirectCIDR != "",
+		protocols: []string{"tcp"},
+	}
+	if r.RedirectCIDR != "" {
+		s.cidrs = []string{r.RedirectCIDR}
+	}
+
+	if value, ok := property(properties, PropertyEnabled); ok {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return s, invalidProperty(PropertyEnabled, "must be true or false")
+		}
+		s.enabled = enabled
+	}
+
+	if value, ok := property(properties, PropertyCIDRs); ok {
+		s.cidrs = splitList(value)
+		for _, cidr := range s.cidrs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return s, invalidProperty(PropertyCIDRs, fmt.Sprintf("%q is not a valid cidr", cidr))
+			}
+		}
+	}
+	if len(s.cidrs) == 0 {
+		s.cidrs = []string{"0.0.0.0/0"}
+	}
+
+	if value, ok := property(properties, PropertyExcludedPorts); ok {
+		s.excludedPorts = splitList(value)
+		if len(s.excludedPorts) > maxExcludedPorts {
+			return s, invalidProperty(PropertyExcludedPorts, fmt.Sprintf("at most %d ports can be excluded", maxExcludedPorts))
+		}
+		for _, port := range s.excludedPorts {
+			if !validPort(port) {
+				return s, invalidProperty(PropertyExcludedPorts, fmt.Sprintf("%q is not a valid port", port))
+			}
+		}
+	}
+
+	if value, ok := property(properties, PropertyProtocols); ok {
+		s.protocols = splitList(value)
+		if len(s.protocols) == 0 {
+			return s, invalidProperty(PropertyProtocols, "must list tcp, udp or both")
+		}
+		for i, protocol := range s.protocols {
+			s.protocols[i] = strings.ToLower(protocol)
+			if s.protocols[i] != "tcp" && s.protocols[i] != "udp" {
+				return s, invalidProperty(PropertyProtocols, fmt.Sprintf("%q is not tcp or udp", protocol))
+			}
+		}
+	}
+
+	if value, ok := property(properties, PropertyInboundPort); ok {
+		if !validPort(value) {
+			return s, invalidProperty(PropertyInboundPort, fmt.Sprintf("%q is not a valid port", value))
+		}
+		s.inboundPort, _ = strconv.Atoi(value)
+	}
+
+	return s, nil
+}
+
+func property(properties map[string]interface{}, key string) (string, bool) {
+	value, ok := properties[key]
+	if !ok || value == nil {
+		return "", false
+	}
+	return strings.TrimSpace(fmt.Sprintf("%v", value)), true
+}
+
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func validPort(value string) bool {
+	port, err := strconv.Atoi(value)
+	return err == nil && port > 0 && port <= 65535
+}
 
-	return nil
+func invalidProperty(key, reason string) error {
+	return fmt.Errorf("invalid property %s: %s", key, reason)
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/containernetworking/plugins/pkg/ns"
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 )
 
@@ -26,11 +27,18 @@ var _ = Describe("Redirect", func() {
 		netNS            *fakes.NetNS
 
 		containerNetNamespace string
+		properties            map[string]interface{}
 		redirectCIDR          string
 		proxyPort             int
 		proxyUID              int
 	)
 
+	apply := func() ([]string, error) {
+		settings, err := proxyRedirect.Settings(properties)
+		Expect(err).NotTo(HaveOccurred())
+		return proxyRedirect.Apply(containerNetNamespace, settings)
+	}
+
 	BeforeEach(func() {
 		iptablesAdapter = &lib_fakes.IPTablesAdapter{}
 		namespaceAdapter = &fakes.NamespaceAdapter{}
@@ -42,6 +50,7 @@ var _ = Describe("Redirect", func() {
 		namespaceAdapter.GetNSReturns(netNS, nil)
 
 		containerNetNamespace = "some-network-namespace"
+		properties = map[string]interface{}{"policy_group_id": "some-group-id"}
 		redirectCIDR = "10.255.0.0/24"
 		proxyPort = 1111
 		proxyUID = 1
@@ -57,7 +66,7 @@ var _ = Describe("Redirect", func() {
 
 	Describe("Apply", func() {
 		It("apply iptables rules to redirect traffic to the proxy in the container net namespace", func() {
-			err := proxyRedirect.Apply(containerNetNamespace)
+			_, err := apply()
 			Expect(err).NotTo(HaveOccurred())
 
 			Expect(namespaceAdapter.GetNSCallCount()).To(Equal(1))
@@ -79,26 +88,149 @@ var _ = Describe("Redirect", func() {
 			}))
 		})
 
+		It("returns the applied rules", func() {
+			appliedRules, err := apply()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(appliedRules).To(Equal([]string{
+				"-t nat -A OUTPUT -d 10.255.0.0/24 -p tcp -m owner ! --uid-owner 1 -j REDIRECT --to-port 1111",
+			}))
+		})
+
 		Context("when bulk appending to OUTPUT fails", func() {
 			BeforeEach(func() {
 				iptablesAdapter.BulkAppendReturns(errors.New("banana"))
 			})
 
 			It("returns an error", func() {
-				err := proxyRedirect.Apply(containerNetNamespace)
+				_, err := apply()
 				Expect(err).To(MatchError("do in container: banana"))
 			})
 		})
 
+		Context("when getting the container namespace fails", func() {
+			BeforeEach(func() {
+				namespaceAdapter.GetNSReturns(nil, errors.New("banana"))
+			})
+
+			It("returns an error", func() {
+				_, err := apply()
+				Expect(err).To(MatchError("get container namespace: banana"))
+			})
+		})
+
 		Context("when the redirect cidr is empty", func() {
 			BeforeEach(func() {
 				proxyRedirect.RedirectCIDR = ""
 			})
 
 			It("no-ops", func() {
-				Expect(proxyRedirect.Apply(containerNetNamespace)).To(Succeed())
+				appliedRules, err := apply()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(appliedRules).To(BeEmpty())
 				Expect(netNS.DoCallCount()).To(Equal(0))
 			})
+
+			Context("when the container enables the proxy", func() {
+				BeforeEach(func() {
+					properties[proxy.PropertyEnabled] = "true"
+				})
+
+				It("redirects all outbound traffic", func() {
+					appliedRules, err := apply()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(appliedRules).To(Equal([]string{
+						"-t nat -A OUTPUT -d 0.0.0.0/0 -p tcp -m owner ! --uid-owner 1 -j REDIRECT --to-port 1111",
+					}))
+				})
+			})
 		})
+
+		Context("when the container disables the proxy", func() {
+			BeforeEach(func() {
+				properties[proxy.PropertyEnabled] = "false"
+			})
+
+			It("no-ops", func() {
+				appliedRules, err := apply()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(appliedRules).To(BeEmpty())
+				Expect(namespaceAdapter.GetNSCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the container configures the proxy", func() {
+			BeforeEach(func() {
+				properties[proxy.PropertyEnabled] = "true"
+				properties[proxy.PropertyCIDRs] = "10.0.0.0/8, 192.168.0.0/16"
+				properties[proxy.PropertyExcludedPorts] = "22,8080"
+				properties[proxy.PropertyProtocols] = "tcp,UDP"
+				properties[proxy.PropertyInboundPort] = "15001"
+			})
+
+			It("redirects outbound traffic to the cidrs for each protocol", func() {
+				_, err := apply()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(iptablesAdapter.BulkAppendCallCount()).To(Equal(2))
+				table, chain, outboundRules := iptablesAdapter.BulkAppendArgsForCall(0)
+				Expect(table).To(Equal("nat"))
+				Expect(chain).To(Equal("OUTPUT"))
+				Expect(outboundRules).To(Equal([]rules.IPTablesRule{
+					{"-d", "10.0.0.0/8", "-p", "tcp", "-m", "owner", "!", "--uid-owner", "1", "-m", "multiport", "!", "--dports", "22,8080", "-j", "REDIRECT", "--to-port", "1111"},
+					{"-d", "10.0.0.0/8", "-p", "udp", "-m", "owner", "!", "--uid-owner", "1", "-m", "multiport", "!", "--dports", "22,8080", "-j", "REDIRECT", "--to-port", "1111"},
+					{"-d", "192.168.0.0/16", "-p", "tcp", "-m", "owner", "!", "--uid-owner", "1", "-m", "multiport", "!", "--dports", "22,8080", "-j", "REDIRECT", "--to-port", "1111"},
+					{"-d", "192.168.0.0/16", "-p", "udp", "-m", "owner", "!", "--uid-owner", "1", "-m", "multiport", "!", "--dports", "22,8080", "-j", "REDIRECT", "--to-port", "1111"},
+				}))
+			})
+
+			It("redirects inbound traffic to the inbound port", func() {
+				_, err := apply()
+				Expect(err).NotTo(HaveOccurred())
+
+				table, chain, inboundRules := iptablesAdapter.BulkAppendArgsForCall(1)
+				Expect(table).To(Equal("nat"))
+				Expect(chain).To(Equal("PREROUTING"))
+				Expect(inboundRules).To(Equal([]rules.IPTablesRule{
+					{"-p", "tcp", "-m", "multiport", "!", "--dports", "22,8080", "-j", "REDIRECT", "--to-port", "15001"},
+					{"-p", "udp", "-m", "multiport", "!", "--dports", "22,8080", "-j", "REDIRECT", "--to-port", "15001"},
+				}))
+			})
+
+			It("returns every applied rule", func() {
+				appliedRules, err := apply()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(appliedRules).To(HaveLen(6))
+				Expect(appliedRules[5]).To(Equal("-t nat -A PREROUTING -p udp -m multiport ! --dports 22,8080 -j REDIRECT --to-port 15001"))
+			})
+
+			Context("when appending to PREROUTING fails", func() {
+				BeforeEach(func() {
+					iptablesAdapter.BulkAppendReturnsOnCall(1, errors.New("banana"))
+				})
+
+				It("returns an error", func() {
+					_, err := apply()
+					Expect(err).To(MatchError("do in container: banana"))
+				})
+			})
+		})
+
+	})
+
+	Describe("Settings", func() {
+		DescribeTable("when a property is invalid",
+			func(key, value, expectedError string) {
+				properties[key] = value
+				_, err := proxyRedirect.Settings(properties)
+				Expect(err).To(MatchError(expectedError))
+			},
+			Entry("enabled", proxy.PropertyEnabled, "sure", "invalid property proxy.enabled: must be true or false"),
+			Entry("cidrs", proxy.PropertyCIDRs, "10.0.0.0/8,banana", `invalid property proxy.redirect_cidrs: "banana" is not a valid cidr`),
+			Entry("excluded ports", proxy.PropertyExcludedPorts, "22,70000", `invalid property proxy.excluded_ports: "70000" is not a valid port`),
+			Entry("too many excluded ports", proxy.PropertyExcludedPorts, "1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16", "invalid property proxy.excluded_ports: at most 15 ports can be excluded"),
+			Entry("protocols", proxy.PropertyProtocols, "tcp,icmp", `invalid property proxy.protocols: "icmp" is not tcp or udp`),
+			Entry("empty protocols", proxy.PropertyProtocols, "", "invalid property proxy.protocols: must list tcp, udp or both"),
+			Entry("inbound port", proxy.PropertyInboundPort, "banana", `invalid property proxy.inbound_port: "banana" is not a valid port`),
+		)
 	})
 })
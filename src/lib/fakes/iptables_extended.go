@@ -112,6 +112,35 @@ type IPTablesAdapter struct {
 	bulkAppendReturnsOnCall map[int]struct {
 		result1 error
 	}
+	SyncChainStub        func(table, chain string, desired []rules.IPTablesRule) (rules.SyncSummary, error)
+	syncChainMutex       sync.RWMutex
+	syncChainArgsForCall []struct {
+		table   string
+		chain   string
+		desired []rules.IPTablesRule
+	}
+	syncChainReturns struct {
+		result1 rules.SyncSummary
+		result2 error
+	}
+	syncChainReturnsOnCall map[int]struct {
+		result1 rules.SyncSummary
+		result2 error
+	}
+	SyncChainsStub        func(table string, desired map[string][]rules.IPTablesRule) (map[string]rules.SyncSummary, error)
+	syncChainsMutex       sync.RWMutex
+	syncChainsArgsForCall []struct {
+		table   string
+		desired map[string][]rules.IPTablesRule
+	}
+	syncChainsReturns struct {
+		result1 map[string]rules.SyncSummary
+		result2 error
+	}
+	syncChainsReturnsOnCall map[int]struct {
+		result1 map[string]rules.SyncSummary
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -519,6 +548,116 @@ func (fake *IPTablesAdapter) BulkAppendReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *IPTablesAdapter) SyncChain(table string, chain string, desired []rules.IPTablesRule) (rules.SyncSummary, error) {
+	var desiredCopy []rules.IPTablesRule
+	if desired != nil {
+		desiredCopy = make([]rules.IPTablesRule, len(desired))
+		copy(desiredCopy, desired)
+	}
+	fake.syncChainMutex.Lock()
+	ret, specificReturn := fake.syncChainReturnsOnCall[len(fake.syncChainArgsForCall)]
+	fake.syncChainArgsForCall = append(fake.syncChainArgsForCall, struct {
+		table   string
+		chain   string
+		desired []rules.IPTablesRule
+	}{table, chain, desiredCopy})
+	fake.recordInvocation("SyncChain", []interface{}{table, chain, desiredCopy})
+	fake.syncChainMutex.Unlock()
+	if fake.SyncChainStub != nil {
+		return fake.SyncChainStub(table, chain, desired)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.syncChainReturns.result1, fake.syncChainReturns.result2
+}
+
+func (fake *IPTablesAdapter) SyncChainCallCount() int {
+	fake.syncChainMutex.RLock()
+	defer fake.syncChainMutex.RUnlock()
+	return len(fake.syncChainArgsForCall)
+}
+
+func (fake *IPTablesAdapter) SyncChainArgsForCall(i int) (string, string, []rules.IPTablesRule) {
+	fake.syncChainMutex.RLock()
+	defer fake.syncChainMutex.RUnlock()
+	return fake.syncChainArgsForCall[i].table, fake.syncChainArgsForCall[i].chain, fake.syncChainArgsForCall[i].desired
+}
+
+func (fake *IPTablesAdapter) SyncChainReturns(result1 rules.SyncSummary, result2 error) {
+	fake.SyncChainStub = nil
+	fake.syncChainReturns = struct {
+		result1 rules.SyncSummary
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *IPTablesAdapter) SyncChainReturnsOnCall(i int, result1 rules.SyncSummary, result2 error) {
+	fake.SyncChainStub = nil
+	if fake.syncChainReturnsOnCall == nil {
+		fake.syncChainReturnsOnCall = make(map[int]struct {
+			result1 rules.SyncSummary
+			result2 error
+		})
+	}
+	fake.syncChainReturnsOnCall[i] = struct {
+		result1 rules.SyncSummary
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *IPTablesAdapter) SyncChains(table string, desired map[string][]rules.IPTablesRule) (map[string]rules.SyncSummary, error) {
+	fake.syncChainsMutex.Lock()
+	ret, specificReturn := fake.syncChainsReturnsOnCall[len(fake.syncChainsArgsForCall)]
+	fake.syncChainsArgsForCall = append(fake.syncChainsArgsForCall, struct {
+		table   string
+		desired map[string][]rules.IPTablesRule
+	}{table, desired})
+	fake.recordInvocation("SyncChains", []interface{}{table, desired})
+	fake.syncChainsMutex.Unlock()
+	if fake.SyncChainsStub != nil {
+		return fake.SyncChainsStub(table, desired)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.syncChainsReturns.result1, fake.syncChainsReturns.result2
+}
+
+func (fake *IPTablesAdapter) SyncChainsCallCount() int {
+	fake.syncChainsMutex.RLock()
+	defer fake.syncChainsMutex.RUnlock()
+	return len(fake.syncChainsArgsForCall)
+}
+
+func (fake *IPTablesAdapter) SyncChainsArgsForCall(i int) (string, map[string][]rules.IPTablesRule) {
+	fake.syncChainsMutex.RLock()
+	defer fake.syncChainsMutex.RUnlock()
+	return fake.syncChainsArgsForCall[i].table, fake.syncChainsArgsForCall[i].desired
+}
+
+func (fake *IPTablesAdapter) SyncChainsReturns(result1 map[string]rules.SyncSummary, result2 error) {
+	fake.SyncChainsStub = nil
+	fake.syncChainsReturns = struct {
+		result1 map[string]rules.SyncSummary
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *IPTablesAdapter) SyncChainsReturnsOnCall(i int, result1 map[string]rules.SyncSummary, result2 error) {
+	fake.SyncChainsStub = nil
+	if fake.syncChainsReturnsOnCall == nil {
+		fake.syncChainsReturnsOnCall = make(map[int]struct {
+			result1 map[string]rules.SyncSummary
+			result2 error
+		})
+	}
+	fake.syncChainsReturnsOnCall[i] = struct {
+		result1 map[string]rules.SyncSummary
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *IPTablesAdapter) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -538,6 +677,10 @@ func (fake *IPTablesAdapter) Invocations() map[string][][]interface{} {
 	defer fake.bulkInsertMutex.RUnlock()
 	fake.bulkAppendMutex.RLock()
 	defer fake.bulkAppendMutex.RUnlock()
+	fake.syncChainMutex.RLock()
+	defer fake.syncChainMutex.RUnlock()
+	fake.syncChainsMutex.RLock()
+	defer fake.syncChainsMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -1,6 +1,9 @@
 package policy_client
 
-import "policy-server/api/api_v0"
+import (
+	"policy-server/api"
+	"policy-server/api/api_v0"
+)
 
 const DefaultMaxPolicies = 100
 
@@ -35,3 +38,12 @@ func (c *SimpleChunker) Chunk(allPolicies []api_v0.Policy) [][]api_v0.Policy {
 	}
 	return chunkedPolicies
 }
+
+func (c *SimpleChunker) ChunkV1(allPolicies []api.Policy) [][]api.Policy {
+	chunkSize := c.getChunkSize()
+	chunkedPolicies := [][]api.Policy{}
+	for i := 0; i < len(allPolicies); i += chunkSize {
+		chunkedPolicies = append(chunkedPolicies, allPolicies[i:min(len(allPolicies), i+chunkSize)])
+	}
+	return chunkedPolicies
+}
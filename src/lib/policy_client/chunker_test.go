@@ -2,6 +2,7 @@ package policy_client_test
 
 import (
 	"lib/policy_client"
+	"policy-server/api"
 	"policy-server/api/api_v0"
 
 	. "github.com/onsi/ginkgo"
@@ -73,4 +74,38 @@ var _ = Describe("SimpleChunker", func() {
 			Expect(chunkedPolicies[0]).To(Equal(policies))
 		})
 	})
+
+	Describe("ChunkV1", func() {
+		var v1Policies []api.Policy
+		BeforeEach(func() {
+			chunker = policy_client.SimpleChunker{
+				ChunkSize: 2,
+			}
+			v1Policies = []api.Policy{
+				{
+					Source:      api.Source{ID: "some-app-guid"},
+					Destination: api.Destination{ID: "some-other-app-guid", Protocol: "tcp", Ports: api.Ports{Start: 8090, End: 8090}},
+				},
+				{
+					Source:      api.Source{ID: "some-app-guid-2"},
+					Destination: api.Destination{ID: "some-other-app-guid-2", Protocol: "tcp", Ports: api.Ports{Start: 8091, End: 8095}},
+				},
+				{
+					Source:      api.Source{ID: "some-app-guid-3"},
+					Destination: api.Destination{ID: "some-other-app-guid-3", Protocol: "udp", Ports: api.Ports{Start: 8092, End: 8092}},
+				},
+			}
+		})
+		It("chunks the policies by ChunkSize", func() {
+			chunkedPolicies := chunker.ChunkV1(v1Policies)
+			Expect(len(chunkedPolicies)).To(Equal(2))
+			Expect(chunkedPolicies[0]).To(Equal(v1Policies[0:2]))
+			Expect(chunkedPolicies[1]).To(Equal(v1Policies[2:]))
+		})
+		Context("when there are no policies", func() {
+			It("returns no chunks", func() {
+				Expect(chunker.ChunkV1(nil)).To(BeEmpty())
+			})
+		})
+	})
 })
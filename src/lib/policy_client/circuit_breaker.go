@@ -0,0 +1,111 @@
+package policy_client
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker open: policy server is unavailable")
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker fails calls fast after FailureThreshold consecutive
+// failures. Once OpenTimeout has passed it lets a single trial call through
+// and fails other calls until it finishes: if the trial succeeds the breaker
+// closes again, otherwise it stays open for another OpenTimeout.
+type CircuitBreaker struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+	Clock            clock.Clock
+	OnStateChange    func(from, to BreakerState)
+
+	mutex    sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+}
+
+func NewCircuitBreaker(failureThreshold int, openTimeout time.Duration, clock clock.Clock) *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureThreshold: failureThreshold,
+		OpenTimeout:      openTimeout,
+		Clock:            clock,
+	}
+}
+
+// Allow returns ErrCircuitOpen if a call should not be made. Every call that
+// is allowed must be followed by Success or Failure.
+func (b *CircuitBreaker) Allow() error {
+	var err error
+	b.transition(func() {
+		switch b.state {
+		case BreakerOpen:
+			if b.Clock.Since(b.openedAt) < b.OpenTimeout {
+				err = ErrCircuitOpen
+				return
+			}
+			b.state = BreakerHalfOpen
+		case BreakerHalfOpen:
+			err = ErrCircuitOpen
+		}
+	})
+	return err
+}
+
+func (b *CircuitBreaker) Success() {
+	b.transition(func() {
+		b.failures = 0
+		b.state = BreakerClosed
+	})
+}
+
+func (b *CircuitBreaker) Failure() {
+	b.transition(func() {
+		b.failures++
+		if b.state == BreakerHalfOpen || b.failures >= b.FailureThreshold {
+			b.openedAt = b.Clock.Now()
+			b.state = BreakerOpen
+		}
+	})
+}
+
+func (b *CircuitBreaker) State() BreakerState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.state
+}
+
+// transition runs change under the lock and then calls OnStateChange, outside
+// the lock so that it may call State.
+func (b *CircuitBreaker) transition(change func()) {
+	b.mutex.Lock()
+	from := b.state
+	change()
+	to := b.state
+	b.mutex.Unlock()
+
+	if from != to && b.OnStateChange != nil {
+		b.OnStateChange(from, to)
+	}
+}
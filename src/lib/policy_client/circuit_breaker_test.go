@@ -0,0 +1,97 @@
+package policy_client_test
+
+import (
+	"lib/policy_client"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CircuitBreaker", func() {
+	var (
+		breaker   *policy_client.CircuitBreaker
+		fakeClock *fakeclock.FakeClock
+		changes   []string
+	)
+
+	BeforeEach(func() {
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		breaker = policy_client.NewCircuitBreaker(3, 10*time.Second, fakeClock)
+		changes = []string{}
+		breaker.OnStateChange = func(from, to policy_client.BreakerState) {
+			changes = append(changes, from.String()+" -> "+to.String())
+		}
+	})
+
+	fail := func(times int) {
+		for i := 0; i < times; i++ {
+			Expect(breaker.Allow()).To(Succeed())
+			breaker.Failure()
+		}
+	}
+
+	It("allows calls while closed", func() {
+		fail(2)
+		Expect(breaker.Allow()).To(Succeed())
+		Expect(breaker.State()).To(Equal(policy_client.BreakerClosed))
+	})
+
+	It("opens after the failure threshold of consecutive failures", func() {
+		fail(3)
+		Expect(breaker.State()).To(Equal(policy_client.BreakerOpen))
+		Expect(breaker.Allow()).To(MatchError(policy_client.ErrCircuitOpen))
+		Expect(changes).To(Equal([]string{"closed -> open"}))
+	})
+
+	It("resets the failure count on success", func() {
+		fail(2)
+		Expect(breaker.Allow()).To(Succeed())
+		breaker.Success()
+		fail(2)
+		Expect(breaker.State()).To(Equal(policy_client.BreakerClosed))
+	})
+
+	Context("when it has been open for the open timeout", func() {
+		BeforeEach(func() {
+			fail(3)
+			fakeClock.Increment(10 * time.Second)
+		})
+
+		It("lets one trial call through", func() {
+			Expect(breaker.Allow()).To(Succeed())
+			Expect(breaker.State()).To(Equal(policy_client.BreakerHalfOpen))
+			Expect(breaker.Allow()).To(MatchError(policy_client.ErrCircuitOpen))
+		})
+
+		It("closes when the trial call succeeds", func() {
+			Expect(breaker.Allow()).To(Succeed())
+			breaker.Success()
+			Expect(breaker.State()).To(Equal(policy_client.BreakerClosed))
+			Expect(breaker.Allow()).To(Succeed())
+			Expect(changes).To(Equal([]string{"closed -> open", "open -> half-open", "half-open -> closed"}))
+		})
+
+		It("opens again for another open timeout when the trial call fails", func() {
+			Expect(breaker.Allow()).To(Succeed())
+			breaker.Failure()
+			Expect(breaker.State()).To(Equal(policy_client.BreakerOpen))
+
+			fakeClock.Increment(9 * time.Second)
+			Expect(breaker.Allow()).To(MatchError(policy_client.ErrCircuitOpen))
+			fakeClock.Increment(time.Second)
+			Expect(breaker.Allow()).To(Succeed())
+		})
+	})
+
+	It("lets the state change hook read the state", func() {
+		states := []policy_client.BreakerState{}
+		breaker.OnStateChange = func(from, to policy_client.BreakerState) {
+			states = append(states, breaker.State())
+		}
+		fail(3)
+		Expect(states).To(Equal([]policy_client.BreakerState{policy_client.BreakerOpen}))
+	})
+})
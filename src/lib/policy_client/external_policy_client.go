@@ -36,6 +36,21 @@ func NewExternal(logger lager.Logger, httpClient json_client.HttpClient, baseURL
 	}
 }
 
+// NewExternalWithRetries returns a client that retries idempotent requests,
+// including policy deletes, and fails fast while the policy server is down,
+// as configured.
+func NewExternalWithRetries(logger lager.Logger, httpClient json_client.HttpClient, baseURL string, config RetryConfig) *ExternalClient {
+	retryingClient := NewRetryingHTTPClient(logger, httpClient, config)
+	retryingClient.IdempotentPOSTPaths = []string{
+		"/networking/v0/external/policies/delete",
+		"/networking/v1/external/policies/delete",
+	}
+	return &ExternalClient{
+		JsonClient: json_client.New(logger, retryingClient, baseURL),
+		Chunker:    &SimpleChunker{ChunkSize: DefaultMaxPolicies},
+	}
+}
+
 func (c *ExternalClient) GetPolicies(token string) ([]api.Policy, error) {
 	var policies struct {
 		Policies []api.Policy `json:"policies"`
@@ -136,6 +151,28 @@ func (c *ExternalClient) DeletePoliciesV0(token string, policies []api_v0.Policy
 	return nil
 }
 
+func (c *ExternalClient) CleanupPolicies(token string) ([]api.Policy, error) {
+	var policies struct {
+		Policies []api.Policy `json:"policies"`
+	}
+	err := c.JsonClient.Do("POST", "/networking/v1/external/policies/cleanup", nil, &policies, token)
+	if err != nil {
+		return nil, parseHttpError(err)
+	}
+	return policies.Policies, nil
+}
+
+func (c *ExternalClient) GetTags(token string) ([]api.Tag, error) {
+	var tags struct {
+		Tags []api.Tag `json:"tags"`
+	}
+	err := c.JsonClient.Do("GET", "/networking/v1/external/tags", nil, &tags, token)
+	if err != nil {
+		return nil, parseHttpError(err)
+	}
+	return tags.Tags, nil
+}
+
 // Check if error is bad status code and parse out the JSON body
 func parseHttpError(err error) error {
 	httpErr, ok := err.(*json_client.HttpResponseCodeError)
@@ -668,4 +668,88 @@ var _ = Describe("ExternalClient", func() {
 			})
 		})
 	})
+
+	Describe("CleanupPolicies", func() {
+		BeforeEach(func() {
+			jsonClient.DoStub = func(method, route string, reqData, respData interface{}, token string) error {
+				respBytes := []byte(`{ "total_policies": 1, "policies": [ {"source": { "id": "some-app-guid" }, "destination": { "id": "some-other-app-guid", "protocol": "tcp", "ports": { "start": 8090, "end": 8100 } } } ] }`)
+				json.Unmarshal(respBytes, respData)
+				return nil
+			}
+		})
+		It("does the right json http client request", func() {
+			policies, err := client.CleanupPolicies("some-token")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(jsonClient.DoCallCount()).To(Equal(1))
+			method, route, reqData, _, token := jsonClient.DoArgsForCall(0)
+			Expect(method).To(Equal("POST"))
+			Expect(route).To(Equal("/networking/v1/external/policies/cleanup"))
+			Expect(reqData).To(BeNil())
+			Expect(token).To(Equal("some-token"))
+
+			Expect(policies).To(Equal([]api.Policy{
+				{
+					Source: api.Source{
+						ID: "some-app-guid",
+					},
+					Destination: api.Destination{
+						ID: "some-other-app-guid",
+						Ports: api.Ports{
+							Start: 8090,
+							End:   8100,
+						},
+						Protocol: "tcp",
+					},
+				},
+			}))
+		})
+		Context("when the json client gets a bad status code", func() {
+			BeforeEach(func() {
+				jsonClient.DoReturns(&json_client.HttpResponseCodeError{
+					StatusCode: http.StatusForbidden,
+					Message:    "some-error",
+				})
+			})
+			It("parses out the error body", func() {
+				_, err := client.CleanupPolicies("some-token")
+				Expect(err).To(MatchError("403 Forbidden: some-error"))
+			})
+		})
+	})
+
+	Describe("GetTags", func() {
+		BeforeEach(func() {
+			jsonClient.DoStub = func(method, route string, reqData, respData interface{}, token string) error {
+				respBytes := []byte(`{ "tags": [ { "id": "some-app-guid", "tag": "0001" }, { "id": "some-other-app-guid", "tag": "0002" } ] }`)
+				json.Unmarshal(respBytes, respData)
+				return nil
+			}
+		})
+		It("does the right json http client request", func() {
+			tags, err := client.GetTags("some-token")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(jsonClient.DoCallCount()).To(Equal(1))
+			method, route, reqData, _, token := jsonClient.DoArgsForCall(0)
+			Expect(method).To(Equal("GET"))
+			Expect(route).To(Equal("/networking/v1/external/tags"))
+			Expect(reqData).To(BeNil())
+			Expect(token).To(Equal("some-token"))
+
+			Expect(tags).To(Equal([]api.Tag{
+				{ID: "some-app-guid", Tag: "0001"},
+				{ID: "some-other-app-guid", Tag: "0002"},
+			}))
+		})
+		Context("when the json client fails", func() {
+			BeforeEach(func() {
+				jsonClient.DoReturns(errors.New("banana"))
+			})
+			It("returns the error", func() {
+				_, err := client.GetTags("some-token")
+				Expect(err).To(MatchError("banana"))
+			})
+		})
+	})
 })
@@ -1,49 +1,135 @@
 package policy_client
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
 	"policy-server/api"
 	"strings"
+	"sync"
 
 	"code.cloudfoundry.org/cf-networking-helpers/json_client"
 	"code.cloudfoundry.org/lager"
 )
 
+//go:generate counterfeiter -o ../fakes/http_client.go --fake-name HTTPClient . httpClient
+type httpClient interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
 type InternalClient struct {
 	JsonClient json_client.JsonClient
+	HTTPClient httpClient
+	BaseURL    string
+
+	cacheMutex sync.Mutex
+	cache      map[string]cachedPolicies
+}
+
+// cachedPolicies is the last response for a kind of policies request, kept
+// so that the next request can be conditional on its ETag.
+type cachedPolicies struct {
+	route    string
+	etag     string
+	policies []api.Policy
 }
 
 func NewInternal(logger lager.Logger, httpClient json_client.HttpClient, baseURL string) *InternalClient {
 	return &InternalClient{
 		JsonClient: json_client.New(logger, httpClient, baseURL),
+		HTTPClient: httpClient,
+		BaseURL:    baseURL,
+	}
+}
+
+// NewInternalWithRetries returns a client that retries idempotent requests
+// and fails fast while the policy server is down, as configured.
+func NewInternalWithRetries(logger lager.Logger, httpClient json_client.HttpClient, baseURL string, config RetryConfig) *InternalClient {
+	retryingClient := NewRetryingHTTPClient(logger, httpClient, config)
+	return &InternalClient{
+		JsonClient: json_client.New(logger, retryingClient, baseURL),
+		HTTPClient: retryingClient,
+		BaseURL:    baseURL,
 	}
 }
 
 func (c *InternalClient) GetPolicies() ([]api.Policy, error) {
-	var policies struct {
-		Policies []api.Policy `json:"policies"`
+	return c.getPolicies("all", "/networking/v1/internal/policies")
+}
+
+func (c *InternalClient) GetPoliciesByID(ids ...string) ([]api.Policy, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("ids cannot be empty")
 	}
-	err := c.JsonClient.Do("GET", "/networking/v1/internal/policies", nil, &policies, "")
+	return c.getPolicies("by-id", "/networking/v1/internal/policies?id="+strings.Join(ids, ","))
+}
+
+// getPolicies gets the policies at route, sending the ETag of the last
+// response of the same kind and reusing its policies when the server
+// answers 304 Not Modified. Only the last route of each kind is cached, so
+// a changing id filter does not grow the cache.
+func (c *InternalClient) getPolicies(kind, route string) ([]api.Policy, error) {
+	c.cacheMutex.Lock()
+	cached, ok := c.cache[kind]
+	c.cacheMutex.Unlock()
+	ok = ok && cached.route == route
+
+	request, err := http.NewRequest("GET", c.BaseURL+route, nil)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("http new request: %s", err)
+	}
+	if ok {
+		request.Header.Set("If-None-Match", cached.etag)
+	}
+
+	resp, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("http client do: %s", err)
+	}
+	defer resp.Body.Close()
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("body read: %s", err)
+	}
+
+	if resp.StatusCode == http.StatusNotModified && ok {
+		return copyPolicies(cached.policies), nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &json_client.HttpResponseCodeError{
+			StatusCode: resp.StatusCode,
+			Message:    string(respBytes),
+		}
 	}
-	return policies.Policies, nil
-}
 
-func (c *InternalClient) GetPoliciesByID(ids ...string) ([]api.Policy, error) {
 	var policies struct {
 		Policies []api.Policy `json:"policies"`
 	}
-	if len(ids) == 0 {
-		return nil, errors.New("ids cannot be empty")
-	}
-	err := c.JsonClient.Do("GET", "/networking/v1/internal/policies?id="+strings.Join(ids, ","), nil, &policies, "")
+	err = json.Unmarshal(respBytes, &policies)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("json unmarshal: %s", err)
+	}
+
+	c.cacheMutex.Lock()
+	if c.cache == nil {
+		c.cache = map[string]cachedPolicies{}
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		c.cache[kind] = cachedPolicies{route: route, etag: etag, policies: copyPolicies(policies.Policies)}
+	} else {
+		delete(c.cache, kind)
 	}
+	c.cacheMutex.Unlock()
+
 	return policies.Policies, nil
 }
 
+func copyPolicies(policies []api.Policy) []api.Policy {
+	return append([]api.Policy{}, policies...)
+}
+
 func (c *InternalClient) HealthCheck() (bool, error) {
 	var healthcheck struct {
 		Healthcheck bool `json:"healthcheck"`
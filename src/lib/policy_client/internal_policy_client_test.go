@@ -3,10 +3,15 @@ package policy_client_test
 import (
 	"encoding/json"
 	"errors"
+	"io/ioutil"
+	"lib/fakes"
 	"lib/policy_client"
+	"net/http"
 	"policy-server/api"
+	"strings"
 
 	hfakes "code.cloudfoundry.org/cf-networking-helpers/fakes"
+	"code.cloudfoundry.org/cf-networking-helpers/json_client"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -14,121 +19,219 @@ import (
 
 var _ = Describe("InternalClient", func() {
 	var (
-		client     *policy_client.InternalClient
-		jsonClient *hfakes.JSONClient
+		client           *policy_client.InternalClient
+		jsonClient       *hfakes.JSONClient
+		httpClient       *fakes.HTTPClient
+		policiesBody     string
+		expectedPolicies []api.Policy
 	)
 
 	BeforeEach(func() {
 		jsonClient = &hfakes.JSONClient{}
+		httpClient = &fakes.HTTPClient{}
 		client = &policy_client.InternalClient{
 			JsonClient: jsonClient,
+			HTTPClient: httpClient,
+			BaseURL:    "https://some.base.url",
+		}
+
+		policiesBody = `{ "policies": [ {"source": { "id": "some-app-guid", "tag": "BEEF" }, "destination": { "id": "some-other-app-guid", "protocol": "tcp", "ports": { "start": 8090, "end": 8090 } } } ] }`
+		expectedPolicies = []api.Policy{
+			{
+				Source: api.Source{
+					ID:  "some-app-guid",
+					Tag: "BEEF",
+				},
+				Destination: api.Destination{
+					ID: "some-other-app-guid",
+					Ports: api.Ports{
+						Start: 8090,
+						End:   8090,
+					},
+					Protocol: "tcp",
+				},
+			},
 		}
 	})
 
 	Describe("GetPolicies", func() {
 		BeforeEach(func() {
-			jsonClient.DoStub = func(method, route string, reqData, respData interface{}, token string) error {
-				respBytes := []byte(`{ "policies": [ {"source": { "id": "some-app-guid", "tag": "BEEF" }, "destination": { "id": "some-other-app-guid", "protocol": "tcp", "ports": { "start": 8090, "end": 8090 } } } ] }`)
-				json.Unmarshal(respBytes, respData)
-				return nil
+			httpClient.DoStub = func(*http.Request) (*http.Response, error) {
+				return newResponse(http.StatusOK, `"some-etag"`, policiesBody), nil
 			}
 		})
-		It("does the right json http client request", func() {
+
+		It("gets the policies from the internal endpoint", func() {
 			policies, err := client.GetPolicies()
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(jsonClient.DoCallCount()).To(Equal(1))
-			method, route, reqData, _, token := jsonClient.DoArgsForCall(0)
-			Expect(method).To(Equal("GET"))
-			Expect(route).To(Equal("/networking/v1/internal/policies"))
-			Expect(reqData).To(BeNil())
+			Expect(httpClient.DoCallCount()).To(Equal(1))
+			request := httpClient.DoArgsForCall(0)
+			Expect(request.Method).To(Equal("GET"))
+			Expect(request.URL.String()).To(Equal("https://some.base.url/networking/v1/internal/policies"))
+			Expect(request.Header.Get("If-None-Match")).To(BeEmpty())
+			Expect(request.Header.Get("Authorization")).To(BeEmpty())
 
-			Expect(policies).To(Equal([]api.Policy{
-				{
-					Source: api.Source{
-						ID:  "some-app-guid",
-						Tag: "BEEF",
-					},
-					Destination: api.Destination{
-						ID: "some-other-app-guid",
-						Ports: api.Ports{
-							Start: 8090,
-							End:   8090,
-						},
-						Protocol: "tcp",
-					},
-				},
-			},
-			))
-			Expect(token).To(BeEmpty())
+			Expect(policies).To(Equal(expectedPolicies))
 		})
 
-		Context("when the json client fails", func() {
+		It("sends the ETag of the last response and reuses its policies when they are not modified", func() {
+			_, err := client.GetPolicies()
+			Expect(err).NotTo(HaveOccurred())
+
+			httpClient.DoStub = nil
+			httpClient.DoReturns(newResponse(http.StatusNotModified, `"some-etag"`, ""), nil)
+			policies, err := client.GetPolicies()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(httpClient.DoCallCount()).To(Equal(2))
+			Expect(httpClient.DoArgsForCall(1).Header.Get("If-None-Match")).To(Equal(`"some-etag"`))
+			Expect(policies).To(Equal(expectedPolicies))
+		})
+
+		It("returns a copy of the cached policies", func() {
+			policies, err := client.GetPolicies()
+			Expect(err).NotTo(HaveOccurred())
+			policies[0].Source.ID = "some-changed-guid"
+
+			httpClient.DoStub = nil
+			httpClient.DoReturns(newResponse(http.StatusNotModified, `"some-etag"`, ""), nil)
+			policies, err = client.GetPolicies()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policies).To(Equal(expectedPolicies))
+		})
+
+		Context("when the policies changed", func() {
+			It("caches the new policies and ETag", func() {
+				_, err := client.GetPolicies()
+				Expect(err).NotTo(HaveOccurred())
+
+				httpClient.DoStub = func(*http.Request) (*http.Response, error) {
+					return newResponse(http.StatusOK, `"some-other-etag"`, `{"policies": []}`), nil
+				}
+				policies, err := client.GetPolicies()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(policies).To(BeEmpty())
+
+				_, err = client.GetPolicies()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(httpClient.DoArgsForCall(2).Header.Get("If-None-Match")).To(Equal(`"some-other-etag"`))
+			})
+		})
+
+		Context("when the response has no ETag", func() {
 			BeforeEach(func() {
-				jsonClient.DoReturns(errors.New("banana"))
+				httpClient.DoStub = func(*http.Request) (*http.Response, error) {
+					return newResponse(http.StatusOK, "", policiesBody), nil
+				}
+			})
+
+			It("does not send a conditional request", func() {
+				_, err := client.GetPolicies()
+				Expect(err).NotTo(HaveOccurred())
+				_, err = client.GetPolicies()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(httpClient.DoArgsForCall(1).Header.Get("If-None-Match")).To(BeEmpty())
+			})
+		})
+
+		Context("when the http client fails", func() {
+			BeforeEach(func() {
+				httpClient.DoStub = nil
+				httpClient.DoReturns(nil, errors.New("banana"))
 			})
 			It("returns the error", func() {
 				_, err := client.GetPolicies()
-				Expect(err).To(MatchError("banana"))
+				Expect(err).To(MatchError("http client do: banana"))
+			})
+		})
+
+		Context("when the server responds with an error", func() {
+			BeforeEach(func() {
+				httpClient.DoStub = func(*http.Request) (*http.Response, error) {
+					return newResponse(http.StatusInternalServerError, "", `{"error": "banana"}`), nil
+				}
+			})
+			It("returns the status code and body", func() {
+				_, err := client.GetPolicies()
+				Expect(err).To(Equal(&json_client.HttpResponseCodeError{
+					StatusCode: http.StatusInternalServerError,
+					Message:    `{"error": "banana"}`,
+				}))
+			})
+		})
+
+		Context("when the response is not json", func() {
+			BeforeEach(func() {
+				httpClient.DoStub = func(*http.Request) (*http.Response, error) {
+					return newResponse(http.StatusOK, `"some-etag"`, "banana"), nil
+				}
+			})
+			It("returns an error", func() {
+				_, err := client.GetPolicies()
+				Expect(err).To(MatchError(ContainSubstring("json unmarshal:")))
 			})
 		})
 	})
 
 	Describe("GetPoliciesByID", func() {
 		BeforeEach(func() {
-			jsonClient.DoStub = func(method, route string, reqData, respData interface{}, token string) error {
-				respBytes := []byte(`{ "policies": [ {"source": { "id": "some-app-guid", "tag": "BEEF" }, "destination": { "id": "some-other-app-guid", "protocol": "tcp", "port": 8090, "ports": { "start": 8090, "end": 8090 } } } ] }`)
-				json.Unmarshal(respBytes, respData)
-				return nil
+			httpClient.DoStub = func(*http.Request) (*http.Response, error) {
+				return newResponse(http.StatusOK, `"some-etag"`, policiesBody), nil
 			}
 		})
-		It("does the right json http client request", func() {
+
+		It("gets the policies filtered by id", func() {
 			policies, err := client.GetPoliciesByID("some-app-guid", "some-other-app-guid")
 			Expect(err).NotTo(HaveOccurred())
 
-			Expect(jsonClient.DoCallCount()).To(Equal(1))
-			method, route, reqData, _, token := jsonClient.DoArgsForCall(0)
-			Expect(method).To(Equal("GET"))
-			Expect(route).To(Equal("/networking/v1/internal/policies?id=some-app-guid,some-other-app-guid"))
-			Expect(reqData).To(BeNil())
+			Expect(httpClient.DoCallCount()).To(Equal(1))
+			request := httpClient.DoArgsForCall(0)
+			Expect(request.Method).To(Equal("GET"))
+			Expect(request.URL.String()).To(Equal("https://some.base.url/networking/v1/internal/policies?id=some-app-guid,some-other-app-guid"))
 
-			Expect(policies).To(Equal([]api.Policy{
-				{
-					Source: api.Source{
-						ID:  "some-app-guid",
-						Tag: "BEEF",
-					},
-					Destination: api.Destination{
-						ID: "some-other-app-guid",
-						Ports: api.Ports{
-							Start: 8090,
-							End:   8090,
-						},
-						Protocol: "tcp",
-					},
-				},
-			},
-			))
-			Expect(token).To(BeEmpty())
+			Expect(policies).To(Equal(expectedPolicies))
 		})
 
-		Context("when the json client fails", func() {
+		It("sends the ETag of the last response for the same ids", func() {
+			_, err := client.GetPoliciesByID("some-app-guid")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = client.GetPoliciesByID("some-app-guid")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(httpClient.DoArgsForCall(1).Header.Get("If-None-Match")).To(Equal(`"some-etag"`))
+		})
+
+		It("does not send the ETag of a response for other ids or for all policies", func() {
+			_, err := client.GetPolicies()
+			Expect(err).NotTo(HaveOccurred())
+			_, err = client.GetPoliciesByID("some-app-guid")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = client.GetPoliciesByID("some-other-app-guid")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(httpClient.DoArgsForCall(1).Header.Get("If-None-Match")).To(BeEmpty())
+			Expect(httpClient.DoArgsForCall(2).Header.Get("If-None-Match")).To(BeEmpty())
+		})
+
+		Context("when the http client fails", func() {
 			BeforeEach(func() {
-				jsonClient.DoReturns(errors.New("banana"))
+				httpClient.DoStub = nil
+				httpClient.DoReturns(nil, errors.New("banana"))
 			})
 			It("returns the error", func() {
 				_, err := client.GetPoliciesByID("foo")
-				Expect(err).To(MatchError("banana"))
+				Expect(err).To(MatchError("http client do: banana"))
 			})
 		})
 
 		Context("when ids is empty", func() {
-			BeforeEach(func() {})
-			It("returns an error and does not call the json http client", func() {
+			It("returns an error and does not call the http client", func() {
 				policies, err := client.GetPoliciesByID()
 				Expect(err).To(MatchError("ids cannot be empty"))
 				Expect(policies).To(BeNil())
-				Expect(jsonClient.DoCallCount()).To(Equal(0))
+				Expect(httpClient.DoCallCount()).To(Equal(0))
 			})
 		})
 	})
@@ -166,3 +269,15 @@ var _ = Describe("InternalClient", func() {
 		})
 	})
 })
+
+func newResponse(statusCode int, etag, body string) *http.Response {
+	header := http.Header{}
+	if etag != "" {
+		header.Set("ETag", etag)
+	}
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}
+}
@@ -0,0 +1,205 @@
+package policy_client
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+)
+
+//go:generate counterfeiter -o ../fakes/sleeper.go --fake-name Sleeper . sleeper
+type sleeper interface {
+	Sleep(time.Duration)
+}
+
+type RetryConfig struct {
+	// MaxAttempts is the number of times an idempotent request is tried,
+	// including the first. 1 disables retries.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+
+	// FailureThreshold is the number of consecutive failed requests, counting
+	// a retried request once, that opens the circuit breaker. 0 disables the
+	// circuit breaker.
+	FailureThreshold int
+	OpenTimeout      time.Duration
+
+	OnRetry              func(RetryEvent)
+	OnBreakerStateChange func(from, to BreakerState)
+}
+
+var DefaultRetryConfig = RetryConfig{
+	MaxAttempts:      3,
+	BaseDelay:        100 * time.Millisecond,
+	MaxDelay:         2 * time.Second,
+	FailureThreshold: 5,
+	OpenTimeout:      10 * time.Second,
+}
+
+// RetryEvent describes a failed attempt that is about to be retried.
+type RetryEvent struct {
+	Method  string
+	Path    string
+	Attempt int
+	Delay   time.Duration
+	Err     error
+}
+
+// RetryingHTTPClient retries idempotent requests that fail with a
+// connection error or a 502, 503 or 504, waiting an exponentially growing,
+// jittered delay between attempts. POST requests are only retried for the
+// paths in IdempotentPOSTPaths, so a create is never sent twice.
+type RetryingHTTPClient struct {
+	HTTPClient          httpClient
+	Logger              lager.Logger
+	MaxAttempts         int
+	BaseDelay           time.Duration
+	MaxDelay            time.Duration
+	IdempotentPOSTPaths []string
+	Breaker             *CircuitBreaker
+	Sleeper             sleeper
+	RandomFloat         func() float64
+	OnRetry             func(RetryEvent)
+}
+
+func NewRetryingHTTPClient(logger lager.Logger, httpClient httpClient, config RetryConfig) *RetryingHTTPClient {
+	client := &RetryingHTTPClient{
+		HTTPClient:  httpClient,
+		Logger:      logger,
+		MaxAttempts: config.MaxAttempts,
+		BaseDelay:   config.BaseDelay,
+		MaxDelay:    config.MaxDelay,
+		Sleeper:     clock.NewClock(),
+		RandomFloat: rand.Float64,
+		OnRetry:     config.OnRetry,
+	}
+	if config.FailureThreshold > 0 {
+		client.Breaker = NewCircuitBreaker(config.FailureThreshold, config.OpenTimeout, clock.NewClock())
+		client.Breaker.OnStateChange = func(from, to BreakerState) {
+			logger.Info("circuit-breaker", lager.Data{"from": from.String(), "to": to.String()})
+			if config.OnBreakerStateChange != nil {
+				config.OnBreakerStateChange(from, to)
+			}
+		}
+	}
+	return client
+}
+
+// Do sends the request, retrying it if it is idempotent. The circuit breaker
+// is checked once before the first attempt and told the outcome of the last
+// one, so a retried request counts as a single success or failure.
+func (c *RetryingHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	if c.Breaker != nil {
+		if err := c.Breaker.Allow(); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err, failure := c.do(request)
+	if c.Breaker != nil {
+		if failure != nil {
+			c.Breaker.Failure()
+		} else {
+			c.Breaker.Success()
+		}
+	}
+	return resp, err
+}
+
+// do makes the attempts and returns the result of the last one, along with
+// the error or server error status it failed with, if any.
+func (c *RetryingHTTPClient) do(request *http.Request) (*http.Response, error, error) {
+	retriable := c.idempotent(request) && (request.Body == nil || request.GetBody != nil)
+	for attempt := 1; ; attempt++ {
+		resp, err := c.HTTPClient.Do(request)
+		failure := err
+		if err == nil && resp.StatusCode >= 500 {
+			failure = fmt.Errorf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+		}
+
+		if !retriable || attempt >= c.MaxAttempts || !retriableFailure(resp, err) || request.Context().Err() != nil {
+			return resp, err, failure
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if request.Body != nil {
+			if request, err = rewind(request); err != nil {
+				return nil, err, err
+			}
+		}
+
+		delay := c.backoff(attempt)
+		event := RetryEvent{
+			Method:  request.Method,
+			Path:    request.URL.Path,
+			Attempt: attempt,
+			Delay:   delay,
+			Err:     failure,
+		}
+		c.Logger.Info("retrying-request", lager.Data{
+			"method":  event.Method,
+			"path":    event.Path,
+			"attempt": event.Attempt,
+			"delay":   event.Delay.String(),
+			"error":   event.Err.Error(),
+		})
+		if c.OnRetry != nil {
+			c.OnRetry(event)
+		}
+		c.Sleeper.Sleep(delay)
+	}
+}
+
+func (c *RetryingHTTPClient) idempotent(request *http.Request) bool {
+	switch request.Method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	case "POST":
+		for _, path := range c.IdempotentPOSTPaths {
+			if request.URL.Path == path {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// backoff returns the delay before retrying a failed attempt: BaseDelay
+// doubled for every earlier attempt, capped at MaxDelay, of which a random
+// half is taken off so that clients failing together do not retry together.
+func (c *RetryingHTTPClient) backoff(attempt int) time.Duration {
+	delay := c.MaxDelay
+	if shift := uint(attempt - 1); shift < 32 && c.BaseDelay<<shift < c.MaxDelay {
+		delay = c.BaseDelay << shift
+	}
+	return delay/2 + time.Duration(float64(delay/2)*c.RandomFloat())
+}
+
+func retriableFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func rewind(request *http.Request) (*http.Request, error) {
+	body, err := request.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("rewind request body: %s", err)
+	}
+	retry := *request
+	retry.Body = body
+	return &retry, nil
+}
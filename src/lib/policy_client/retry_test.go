@@ -0,0 +1,265 @@
+package policy_client_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"lib/fakes"
+	"lib/policy_client"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/onsi/gomega/gbytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RetryingHTTPClient", func() {
+	var (
+		client     *policy_client.RetryingHTTPClient
+		httpClient *fakes.HTTPClient
+		sleeper    *fakes.Sleeper
+		logger     *lagertest.TestLogger
+		events     []policy_client.RetryEvent
+		request    *http.Request
+	)
+
+	statusResponse := func(statusCode int) *http.Response {
+		return &http.Response{
+			StatusCode: statusCode,
+			Body:       ioutil.NopCloser(strings.NewReader("some-body")),
+		}
+	}
+
+	BeforeEach(func() {
+		httpClient = &fakes.HTTPClient{}
+		sleeper = &fakes.Sleeper{}
+		logger = lagertest.NewTestLogger("test")
+		events = []policy_client.RetryEvent{}
+		client = &policy_client.RetryingHTTPClient{
+			HTTPClient:  httpClient,
+			Logger:      logger,
+			MaxAttempts: 4,
+			BaseDelay:   100 * time.Millisecond,
+			MaxDelay:    300 * time.Millisecond,
+			Sleeper:     sleeper,
+			RandomFloat: func() float64 { return 1 },
+			OnRetry: func(event policy_client.RetryEvent) {
+				events = append(events, event)
+			},
+		}
+
+		var err error
+		request, err = http.NewRequest("GET", "https://some.base.url/some/path", nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("returns the first successful response", func() {
+		httpClient.DoReturns(statusResponse(http.StatusOK), nil)
+		resp, err := client.Do(request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(httpClient.DoCallCount()).To(Equal(1))
+		Expect(sleeper.SleepCallCount()).To(Equal(0))
+	})
+
+	It("retries connection errors and 502, 503 and 504 responses with exponential backoff", func() {
+		httpClient.DoReturnsOnCall(0, nil, errors.New("connection reset"))
+		httpClient.DoReturnsOnCall(1, statusResponse(http.StatusBadGateway), nil)
+		httpClient.DoReturnsOnCall(2, statusResponse(http.StatusServiceUnavailable), nil)
+		httpClient.DoReturnsOnCall(3, statusResponse(http.StatusOK), nil)
+
+		resp, err := client.Do(request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(httpClient.DoCallCount()).To(Equal(4))
+
+		Expect(sleeper.SleepCallCount()).To(Equal(3))
+		Expect(sleeper.SleepArgsForCall(0)).To(Equal(100 * time.Millisecond))
+		Expect(sleeper.SleepArgsForCall(1)).To(Equal(200 * time.Millisecond))
+		Expect(sleeper.SleepArgsForCall(2)).To(Equal(300 * time.Millisecond))
+
+		Expect(events).To(HaveLen(3))
+		Expect(events[0]).To(Equal(policy_client.RetryEvent{
+			Method:  "GET",
+			Path:    "/some/path",
+			Attempt: 1,
+			Delay:   100 * time.Millisecond,
+			Err:     errors.New("connection reset"),
+		}))
+		Expect(events[1].Err).To(MatchError("502 Bad Gateway"))
+		Expect(logger).To(gbytes.Say("retrying-request"))
+	})
+
+	It("takes up to half of the delay off at random", func() {
+		client.RandomFloat = func() float64 { return 0 }
+		httpClient.DoReturnsOnCall(0, statusResponse(http.StatusBadGateway), nil)
+		httpClient.DoReturnsOnCall(1, statusResponse(http.StatusOK), nil)
+
+		_, err := client.Do(request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sleeper.SleepArgsForCall(0)).To(Equal(50 * time.Millisecond))
+	})
+
+	It("returns the last failure after the maximum number of attempts", func() {
+		httpClient.DoReturns(statusResponse(http.StatusGatewayTimeout), nil)
+		resp, err := client.Do(request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusGatewayTimeout))
+		Expect(httpClient.DoCallCount()).To(Equal(4))
+		Expect(sleeper.SleepCallCount()).To(Equal(3))
+	})
+
+	It("does not retry other error responses", func() {
+		httpClient.DoReturns(statusResponse(http.StatusInternalServerError), nil)
+		resp, err := client.Do(request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+		Expect(httpClient.DoCallCount()).To(Equal(1))
+	})
+
+	Context("when the request is a POST", func() {
+		BeforeEach(func() {
+			var err error
+			request, err = http.NewRequest("POST", "https://some.base.url/some/path", bytes.NewReader([]byte("some-request-body")))
+			Expect(err).NotTo(HaveOccurred())
+			httpClient.DoReturnsOnCall(0, statusResponse(http.StatusBadGateway), nil)
+			httpClient.DoReturnsOnCall(1, statusResponse(http.StatusOK), nil)
+		})
+
+		It("does not retry it", func() {
+			resp, err := client.Do(request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusBadGateway))
+			Expect(httpClient.DoCallCount()).To(Equal(1))
+		})
+
+		Context("when the path is idempotent", func() {
+			BeforeEach(func() {
+				client.IdempotentPOSTPaths = []string{"/some/path"}
+			})
+
+			It("retries it with the same body", func() {
+				resp, err := client.Do(request)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+				Expect(httpClient.DoCallCount()).To(Equal(2))
+
+				body, err := ioutil.ReadAll(httpClient.DoArgsForCall(1).Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(body)).To(Equal("some-request-body"))
+			})
+		})
+	})
+
+	Context("with a circuit breaker", func() {
+		var fakeClock *fakeclock.FakeClock
+
+		BeforeEach(func() {
+			fakeClock = fakeclock.NewFakeClock(time.Now())
+			client.MaxAttempts = 1
+			client.Breaker = policy_client.NewCircuitBreaker(2, time.Minute, fakeClock)
+			httpClient.DoReturns(nil, errors.New("connection refused"))
+		})
+
+		It("fails fast once the breaker opens", func() {
+			for i := 0; i < 2; i++ {
+				_, err := client.Do(request)
+				Expect(err).To(MatchError("connection refused"))
+			}
+			_, err := client.Do(request)
+			Expect(err).To(MatchError(policy_client.ErrCircuitOpen))
+			Expect(httpClient.DoCallCount()).To(Equal(2))
+		})
+
+		It("counts 5xx responses as failures and other responses as successes", func() {
+			httpClient.DoReturnsOnCall(0, statusResponse(http.StatusInternalServerError), nil)
+			httpClient.DoReturnsOnCall(1, statusResponse(http.StatusNotFound), nil)
+			httpClient.DoReturnsOnCall(2, statusResponse(http.StatusInternalServerError), nil)
+
+			for i := 0; i < 3; i++ {
+				_, err := client.Do(request)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(client.Breaker.State()).To(Equal(policy_client.BreakerClosed))
+		})
+
+		Context("when a request is retried", func() {
+			BeforeEach(func() {
+				client.MaxAttempts = 3
+			})
+
+			It("counts it as one failure and returns its last error", func() {
+				_, err := client.Do(request)
+				Expect(err).To(MatchError("connection refused"))
+				Expect(httpClient.DoCallCount()).To(Equal(3))
+				Expect(client.Breaker.State()).To(Equal(policy_client.BreakerClosed))
+
+				_, err = client.Do(request)
+				Expect(err).To(MatchError("connection refused"))
+				Expect(client.Breaker.State()).To(Equal(policy_client.BreakerOpen))
+			})
+
+			It("counts it as one success if a retry succeeds", func() {
+				httpClient.DoReturnsOnCall(4, statusResponse(http.StatusOK), nil)
+
+				_, err := client.Do(request)
+				Expect(err).To(MatchError("connection refused"))
+				_, err = client.Do(request)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(client.Breaker.State()).To(Equal(policy_client.BreakerClosed))
+
+				_, err = client.Do(request)
+				Expect(err).To(MatchError("connection refused"))
+				Expect(client.Breaker.State()).To(Equal(policy_client.BreakerClosed))
+			})
+		})
+	})
+})
+
+var _ = Describe("NewExternal", func() {
+	It("does not retry", func() {
+		httpClient := &fakes.HTTPClient{}
+		httpClient.DoReturns(&http.Response{StatusCode: http.StatusBadGateway, Body: ioutil.NopCloser(strings.NewReader(""))}, nil)
+		client := policy_client.NewExternal(lagertest.NewTestLogger("test"), httpClient, "https://some.base.url")
+
+		err := client.DeletePolicies("some-token", nil)
+		Expect(err).To(MatchError(ContainSubstring("502 Bad Gateway")))
+		Expect(httpClient.DoCallCount()).To(Equal(1))
+	})
+})
+
+var _ = Describe("NewExternalWithRetries", func() {
+	var (
+		client     *policy_client.ExternalClient
+		httpClient *fakes.HTTPClient
+	)
+
+	BeforeEach(func() {
+		httpClient = &fakes.HTTPClient{}
+		httpClient.DoStub = func(*http.Request) (*http.Response, error) {
+			if httpClient.DoCallCount() == 1 {
+				return &http.Response{StatusCode: http.StatusBadGateway, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+		}
+		client = policy_client.NewExternalWithRetries(lagertest.NewTestLogger("test"), httpClient, "https://some.base.url", policy_client.RetryConfig{
+			MaxAttempts: 2,
+		})
+	})
+
+	It("retries deletes", func() {
+		Expect(client.DeletePolicies("some-token", nil)).To(Succeed())
+		Expect(httpClient.DoCallCount()).To(Equal(2))
+	})
+
+	It("does not retry creates", func() {
+		err := client.AddPolicies("some-token", nil)
+		Expect(err).To(MatchError(ContainSubstring("502 Bad Gateway")))
+		Expect(httpClient.DoCallCount()).To(Equal(1))
+	})
+})
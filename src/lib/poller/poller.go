@@ -1,31 +1,147 @@
 package poller
 
 import (
+	"errors"
+	"math/rand"
 	"os"
 	"time"
 
+	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/lager"
 )
 
+//go:generate counterfeiter -o ../fakes/poller_metrics_sender.go --fake-name PollerMetricsSender . metricsSender
+type metricsSender interface {
+	IncrementCounter(string)
+	SendDuration(string, time.Duration)
+}
+
 type Poller struct {
 	Logger       lager.Logger
 	PollInterval time.Duration
 
 	SingleCycleFunc func() error
+
+	// StartJitter is the most that is randomly added to the wait before the
+	// first cycle, so that instances started together do not poll together.
+	StartJitter time.Duration
+	// CycleJitter is the most that is randomly added to the wait before
+	// every other cycle.
+	CycleJitter time.Duration
+	// MaxBackoff is the longest wait after consecutive failed cycles, which
+	// double the wait from PollInterval. 0 disables backoff.
+	MaxBackoff time.Duration
+	// CycleTimeout is how long a cycle may take before it counts as failed.
+	// A cycle that times out keeps running and no new cycle starts until it
+	// finishes. 0 disables the timeout.
+	CycleTimeout time.Duration
+
+	// MetricsSender, when set, is sent the duration of every cycle as
+	// <MetricPrefix>PollCycleTime and counts failed cycles as
+	// <MetricPrefix>PollCycleError.
+	MetricsSender metricsSender
+	MetricPrefix  string
+
+	Clock       clock.Clock
+	RandomFloat func() float64
 }
 
 func (m *Poller) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	if m.Clock == nil {
+		m.Clock = clock.NewClock()
+	}
+	if m.RandomFloat == nil {
+		m.RandomFloat = rand.Float64
+	}
 	close(ready)
 
+	failures := 0
+	var running chan error
+	wait := m.PollInterval + m.jitter(m.StartJitter)
 	for {
+		timer := m.Clock.NewTimer(wait)
 		select {
 		case <-signals:
+			timer.Stop()
 			return nil
-		case <-time.After(m.PollInterval):
-			if err := m.SingleCycleFunc(); err != nil {
-				m.Logger.Error("poll-cycle", err)
+		case <-timer.C():
+		}
+
+		if running != nil {
+			select {
+			case <-running:
+				running = nil
+			default:
+				m.Logger.Info("poll-cycle-skipped", lager.Data{"reason": "previous cycle still running"})
+				wait = m.backoff(failures) + m.jitter(m.CycleJitter)
 				continue
 			}
 		}
+
+		running = make(chan error, 1)
+		err := m.cycle(running)
+		if err != errCycleTimeout {
+			running = nil
+		}
+
+		if err != nil {
+			failures++
+			m.Logger.Error("poll-cycle", err, lager.Data{"consecutive-failures": failures})
+			if m.MetricsSender != nil {
+				m.MetricsSender.IncrementCounter(m.MetricPrefix + "PollCycleError")
+			}
+		} else {
+			failures = 0
+		}
+		wait = m.backoff(failures) + m.jitter(m.CycleJitter)
+	}
+}
+
+var errCycleTimeout = errors.New("cycle timed out")
+
+// cycle runs SingleCycleFunc, which reports its result on done, and waits
+// for it to finish or for CycleTimeout to pass.
+func (m *Poller) cycle(done chan error) error {
+	start := m.Clock.Now()
+	go func() {
+		done <- m.SingleCycleFunc()
+	}()
+
+	var timeout <-chan time.Time
+	if m.CycleTimeout > 0 {
+		timer := m.Clock.NewTimer(m.CycleTimeout)
+		defer timer.Stop()
+		timeout = timer.C()
+	}
+
+	var err error
+	select {
+	case <-timeout:
+		err = errCycleTimeout
+	case err = <-done:
+	}
+	if m.MetricsSender != nil {
+		m.MetricsSender.SendDuration(m.MetricPrefix+"PollCycleTime", m.Clock.Since(start))
+	}
+	return err
+}
+
+// backoff returns PollInterval doubled for every consecutive failure, up to
+// MaxBackoff.
+func (m *Poller) backoff(failures int) time.Duration {
+	wait := m.PollInterval
+	for i := 0; i < failures && wait < m.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > m.MaxBackoff && m.MaxBackoff > m.PollInterval {
+		wait = m.MaxBackoff
+	}
+	return wait
+}
+
+func (m *Poller) jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
 	}
+	return time.Duration(m.RandomFloat() * float64(max))
 }
@@ -2,11 +2,13 @@ package poller_test
 
 import (
 	"errors"
+	"lib/fakes"
 	"lib/poller"
 	"os"
 	"sync/atomic"
 	"time"
 
+	"code.cloudfoundry.org/clock/fakeclock"
 	"code.cloudfoundry.org/lager/lagertest"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -82,4 +84,172 @@ var _ = Describe("Poller", func() {
 			})
 		})
 	})
+
+	Describe("Run with a clock", func() {
+		var (
+			logger        *lagertest.TestLogger
+			p             *poller.Poller
+			fakeClock     *fakeclock.FakeClock
+			metricsSender *fakes.PollerMetricsSender
+			signals       chan os.Signal
+			ready         chan struct{}
+			retChan       chan error
+			cycles        chan struct{}
+			cycleErrors   chan error
+		)
+
+		BeforeEach(func() {
+			signals = make(chan os.Signal)
+			ready = make(chan struct{})
+			retChan = make(chan error)
+			cycles = make(chan struct{}, 10)
+			cycleErrors = make(chan error, 10)
+
+			logger = lagertest.NewTestLogger("test")
+			fakeClock = fakeclock.NewFakeClock(time.Now())
+			metricsSender = &fakes.PollerMetricsSender{}
+
+			p = &poller.Poller{
+				Logger:       logger,
+				PollInterval: 10 * time.Second,
+				SingleCycleFunc: func() error {
+					cycles <- struct{}{}
+					select {
+					case err := <-cycleErrors:
+						return err
+					default:
+						return nil
+					}
+				},
+				MetricsSender: metricsSender,
+				MetricPrefix:  "Some",
+				Clock:         fakeClock,
+				RandomFloat:   func() float64 { return 0.5 },
+			}
+		})
+
+		run := func() {
+			go func() {
+				retChan <- p.Run(signals, ready)
+			}()
+			Eventually(ready).Should(BeClosed())
+		}
+
+		AfterEach(func() {
+			signals <- os.Interrupt
+			Eventually(retChan).Should(Receive(nil))
+		})
+
+		It("waits the poll interval plus half the start jitter before the first cycle", func() {
+			p.StartJitter = 4 * time.Second
+			run()
+
+			fakeClock.WaitForWatcherAndIncrement(11 * time.Second)
+			Consistently(cycles).ShouldNot(Receive())
+			fakeClock.Increment(time.Second)
+			Eventually(cycles).Should(Receive())
+		})
+
+		It("adds the cycle jitter to the wait between cycles", func() {
+			p.CycleJitter = 2 * time.Second
+			run()
+
+			fakeClock.WaitForWatcherAndIncrement(10 * time.Second)
+			Eventually(cycles).Should(Receive())
+
+			fakeClock.WaitForWatcherAndIncrement(10 * time.Second)
+			Consistently(cycles).ShouldNot(Receive())
+			fakeClock.Increment(time.Second)
+			Eventually(cycles).Should(Receive())
+		})
+
+		It("sends the cycle duration", func() {
+			run()
+			fakeClock.WaitForWatcherAndIncrement(10 * time.Second)
+			Eventually(cycles).Should(Receive())
+
+			Eventually(metricsSender.SendDurationCallCount).Should(Equal(1))
+			name, _ := metricsSender.SendDurationArgsForCall(0)
+			Expect(name).To(Equal("SomePollCycleTime"))
+			Expect(metricsSender.IncrementCounterCallCount()).To(Equal(0))
+		})
+
+		Context("when cycles fail", func() {
+			BeforeEach(func() {
+				p.MaxBackoff = 25 * time.Second
+				cycleErrors <- errors.New("banana")
+				cycleErrors <- errors.New("banana")
+			})
+
+			It("backs off exponentially up to the max backoff and resets after a success", func() {
+				run()
+				fakeClock.WaitForWatcherAndIncrement(10 * time.Second)
+				Eventually(cycles).Should(Receive())
+
+				By("doubling the wait after the first failure")
+				fakeClock.WaitForWatcherAndIncrement(19 * time.Second)
+				Consistently(cycles).ShouldNot(Receive())
+				fakeClock.Increment(time.Second)
+				Eventually(cycles).Should(Receive())
+
+				By("capping the wait at the max backoff")
+				fakeClock.WaitForWatcherAndIncrement(24 * time.Second)
+				Consistently(cycles).ShouldNot(Receive())
+				fakeClock.Increment(time.Second)
+				Eventually(cycles).Should(Receive())
+
+				By("going back to the poll interval after a success")
+				fakeClock.WaitForWatcherAndIncrement(10 * time.Second)
+				Eventually(cycles).Should(Receive())
+			})
+
+			It("counts the failures", func() {
+				run()
+				fakeClock.WaitForWatcherAndIncrement(10 * time.Second)
+				Eventually(cycles).Should(Receive())
+
+				Eventually(metricsSender.IncrementCounterCallCount).Should(Equal(1))
+				Expect(metricsSender.IncrementCounterArgsForCall(0)).To(Equal("SomePollCycleError"))
+				Eventually(logger).Should(gbytes.Say(`poll-cycle.*"consecutive-failures":1,"error":"banana"`))
+			})
+		})
+
+		Context("when a cycle takes longer than the cycle timeout", func() {
+			var release chan struct{}
+
+			BeforeEach(func() {
+				release = make(chan struct{})
+				p.CycleTimeout = 5 * time.Second
+				p.SingleCycleFunc = func() error {
+					cycles <- struct{}{}
+					<-release
+					return nil
+				}
+			})
+
+			It("fails the cycle and does not start another one until it finishes", func() {
+				run()
+				fakeClock.WaitForWatcherAndIncrement(10 * time.Second)
+				Eventually(cycles).Should(Receive())
+
+				fakeClock.WaitForWatcherAndIncrement(5 * time.Second)
+				Eventually(logger).Should(gbytes.Say("poll-cycle.*cycle timed out"))
+
+				fakeClock.WaitForWatcherAndIncrement(10 * time.Second)
+				Eventually(logger).Should(gbytes.Say("poll-cycle-skipped"))
+				Expect(cycles).NotTo(Receive())
+
+				close(release)
+				Eventually(func() bool {
+					fakeClock.WaitForWatcherAndIncrement(10 * time.Second)
+					select {
+					case <-cycles:
+						return true
+					case <-time.After(100 * time.Millisecond):
+						return false
+					}
+				}).Should(BeTrue())
+			})
+		})
+	})
 })
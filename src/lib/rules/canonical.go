@@ -0,0 +1,235 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SplitRuleSpec splits a rulespec as printed by iptables -S into its
+// arguments. Quoted arguments are kept with their quotes, like the log
+// prefixes of the rule constructors.
+func SplitRuleSpec(spec string) IPTablesRule {
+	rule := IPTablesRule{}
+	current := []byte{}
+	inQuotes, escaped := false, false
+	for i := 0; i < len(spec); i++ {
+		c := spec[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inQuotes && c == '\\':
+			escaped = true
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == ' ' && !inQuotes:
+			if len(current) > 0 {
+				rule = append(rule, string(current))
+				current = []byte{}
+			}
+			continue
+		}
+		current = append(current, c)
+	}
+	if len(current) > 0 {
+		rule = append(rule, string(current))
+	}
+	return rule
+}
+
+func unquote(value string) string {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return value
+	}
+	value = value[1 : len(value)-1]
+	unescaped := []byte{}
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+		}
+		unescaped = append(unescaped, value[i])
+	}
+	return string(unescaped)
+}
+
+// saveString quotes a value the way iptables-save does.
+func saveString(value string) string {
+	const noQuote = "_-0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	if value != "" && strings.Trim(value, noQuote) == "" {
+		return value
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `'`, `\'`).Replace(value)
+	return `"` + escaped + `"`
+}
+
+// canonical returns a rulespec the way iptables -S prints it, so that
+// rulespecs built by the constructors in this package can be compared with
+// the rules listed by iptables. Rulespecs that do not parse are returned
+// unchanged.
+func canonical(rule IPTablesRule) string {
+	p, err := Parse(rule)
+	if err != nil {
+		return strings.Join(rule, " ")
+	}
+
+	args := []string{}
+	add := func(m Match, value string) {
+		if m.Negated {
+			args = append(args, "!")
+		}
+		args = append(args, m.Option, value)
+	}
+
+	for _, option := range []string{"-s", "-d", "-i", "-o", "-p"} {
+		for _, m := range p.Matches {
+			if m.Module != "" || m.Option != option {
+				continue
+			}
+			value := m.Value
+			if option == "-s" || option == "-d" {
+				value = canonicalAddress(value)
+				if value == "" && !m.Negated {
+					continue
+				}
+			}
+			add(m, value)
+		}
+	}
+
+	for _, module := range p.Modules {
+		args = append(args, "-m", module)
+		for _, option := range moduleOptionOrder[module] {
+			for _, m := range p.Matches {
+				if m.Module != module || m.Option != option {
+					continue
+				}
+				switch option {
+				case "--sport", "--dport":
+					add(m, canonicalPorts(m.Value))
+				case "--mark":
+					mark, _ := parseMark(m.Value)
+					add(m, mark.String())
+				case "--ctstate", "--state":
+					add(m, canonicalStates(m.Value))
+				case "--limit":
+					add(m, canonicalLimit(m.Value))
+				case "--limit-burst":
+					if m.Value != "5" {
+						add(m, m.Value)
+					}
+				case "--comment":
+					add(m, saveString(m.Value))
+				default:
+					add(m, m.Value)
+				}
+			}
+		}
+	}
+
+	if p.Target == "" {
+		return strings.Join(args, " ")
+	}
+	if p.Goto {
+		return strings.Join(append(args, "-g", p.Target), " ")
+	}
+	args = append(args, "-j", p.Target)
+
+	switch p.Target {
+	case "REJECT":
+		with := p.TargetOptions["--reject-with"]
+		if with == "" {
+			with = "icmp-port-unreachable"
+		}
+		args = append(args, "--reject-with", with)
+	case "LOG":
+		if _, ok := p.TargetOptions["--log-prefix"]; ok {
+			args = append(args, "--log-prefix", saveString(p.LogPrefix))
+		}
+	case "MARK":
+		args = append(args, "--set-xmark", fmt.Sprintf("0x%x/0x%x", p.SetMark.Value, p.SetMark.Mask))
+	case "DNAT":
+		args = append(args, "--to-destination", p.TargetOptions["--to-destination"])
+	case "REDIRECT":
+		if port, ok := p.TargetOptions["--to-ports"]; ok {
+			args = append(args, "--to-ports", port)
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// moduleOptionOrder is the order in which iptables prints the options of a
+// match module.
+var moduleOptionOrder = map[string][]string{
+	"tcp":       {"--sport", "--dport"},
+	"udp":       {"--sport", "--dport"},
+	"multiport": {"--dports"},
+	"mark":      {"--mark"},
+	"conntrack": {"--ctstate"},
+	"state":     {"--state"},
+	"limit":     {"--limit", "--limit-burst"},
+	"iprange":   {"--src-range", "--dst-range"},
+	"icmp":      {"--icmp-type"},
+	"owner":     {"--uid-owner"},
+	"comment":   {"--comment"},
+}
+
+// canonicalPorts prints a port range of one port as that port.
+func canonicalPorts(value string) string {
+	portRange, err := parsePortRange(value)
+	if err != nil || portRange.Start != portRange.End {
+		return value
+	}
+	return strconv.Itoa(portRange.Start)
+}
+
+func canonicalAddress(value string) string {
+	ipNet, err := parseAddress(value)
+	if err != nil {
+		return value
+	}
+	ones, _ := ipNet.Mask.Size()
+	if ones == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s/%d", ipNet.IP, ones)
+}
+
+func canonicalStates(value string) string {
+	given := strings.Split(value, ",")
+	states := []string{}
+	for _, state := range stateOrder {
+		if containsString(given, state) {
+			states = append(states, state)
+		}
+	}
+	return strings.Join(states, ",")
+}
+
+// canonicalLimit prints a rate like the iptables limit match, in the
+// largest unit that keeps it a whole number.
+func canonicalLimit(value string) string {
+	const scale = 10000
+	units := []struct {
+		name string
+		mult uint64
+	}{
+		{"day", scale * 24 * 60 * 60},
+		{"hour", scale * 60 * 60},
+		{"min", scale * 60},
+		{"sec", scale},
+	}
+
+	rate, seconds, err := parseRate(value)
+	if err != nil {
+		return value
+	}
+
+	period := seconds * scale / rate
+	i := 1
+	for ; i < len(units); i++ {
+		if period > units[i].mult || units[i].mult/period < units[i].mult%period {
+			break
+		}
+	}
+	return fmt.Sprintf("%d/%s", units[i-1].mult/period, units[i-1].name)
+}
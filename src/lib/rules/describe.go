@@ -0,0 +1,128 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Description returns a one-line English description of the rule, e.g.
+// "tcp traffic to 10.255.0.2 on port 8080 with mark 0xabcd: accept".
+func (p *ParsedRule) Description() string {
+	subject := "traffic"
+	conditions := []string{}
+	for _, m := range p.Matches {
+		switch m.Option {
+		case "-p":
+			if m.Negated {
+				subject = fmt.Sprintf("non-%s traffic", m.Value)
+			} else if m.Value != "all" {
+				subject = fmt.Sprintf("%s traffic", m.Value)
+			}
+		case "--comment":
+		default:
+			conditions = append(conditions, describeMatch(m))
+		}
+	}
+	if subject == "traffic" && len(conditions) == 0 {
+		subject = "all traffic"
+	}
+
+	description := strings.Join(append([]string{subject}, conditions...), " ")
+	description = fmt.Sprintf("%s: %s", description, p.action())
+	if p.Comment != "" {
+		description = fmt.Sprintf("%s (%s)", description, p.Comment)
+	}
+	return description
+}
+
+func describeMatch(m Match) string {
+	not := ""
+	if m.Negated {
+		not = "not "
+	}
+	switch m.Option {
+	case "-s":
+		return fmt.Sprintf("%sfrom %s", not, m.Value)
+	case "-d":
+		return fmt.Sprintf("%sto %s", not, m.Value)
+	case "-i":
+		return fmt.Sprintf("%sin on %s", not, m.Value)
+	case "-o":
+		return fmt.Sprintf("%sout on %s", not, m.Value)
+	case "--sport":
+		return fmt.Sprintf("%sfrom %s", not, describePorts(m.Value))
+	case "--dport", "--dports":
+		return fmt.Sprintf("%son %s", not, describePorts(m.Value))
+	case "--mark":
+		mark, _ := parseMark(m.Value)
+		if m.Negated {
+			return fmt.Sprintf("without mark %s", mark)
+		}
+		return fmt.Sprintf("with mark %s", mark)
+	case "--ctstate", "--state":
+		return fmt.Sprintf("%sin state %s", not, strings.ToLower(strings.Join(strings.Split(m.Value, ","), " or ")))
+	case "--limit":
+		return fmt.Sprintf("at most %s", m.Value)
+	case "--limit-burst":
+		return fmt.Sprintf("in bursts of %s", m.Value)
+	case "--src-range":
+		return fmt.Sprintf("%sfrom addresses %s", not, m.Value)
+	case "--dst-range":
+		return fmt.Sprintf("%sto addresses %s", not, m.Value)
+	case "--icmp-type":
+		parts := strings.SplitN(m.Value, "/", 2)
+		if len(parts) == 2 {
+			return fmt.Sprintf("%sof icmp type %s code %s", not, parts[0], parts[1])
+		}
+		return fmt.Sprintf("%sof icmp type %s", not, parts[0])
+	case "--uid-owner":
+		return fmt.Sprintf("%sfrom uid %s", not, m.Value)
+	}
+	return fmt.Sprintf("%s%s %s", not, m.Option, m.Value)
+}
+
+func describePorts(value string) string {
+	ports := []string{}
+	for _, port := range strings.Split(value, ",") {
+		portRange, err := parsePortRange(port)
+		if err != nil {
+			ports = append(ports, port)
+			continue
+		}
+		ports = append(ports, portRange.String())
+	}
+	if len(ports) == 1 && !strings.Contains(ports[0], "-") {
+		return "port " + ports[0]
+	}
+	return "ports " + strings.Join(ports, ", ")
+}
+
+func (p *ParsedRule) action() string {
+	switch p.Target {
+	case "":
+		return "count"
+	case "ACCEPT", "DROP", "RETURN", "MASQUERADE":
+		return strings.ToLower(p.Target)
+	case "REJECT":
+		with := p.TargetOptions["--reject-with"]
+		if with == "" {
+			with = "icmp-port-unreachable"
+		}
+		return fmt.Sprintf("reject with %s", with)
+	case "LOG":
+		return fmt.Sprintf("log with prefix %q", p.LogPrefix)
+	case "MARK":
+		return fmt.Sprintf("set mark %s", p.SetMark)
+	case "DNAT":
+		return fmt.Sprintf("forward to %s", p.TargetOptions["--to-destination"])
+	case "REDIRECT":
+		if port, ok := p.TargetOptions["--to-ports"]; ok {
+			return fmt.Sprintf("redirect to port %s", port)
+		}
+		return "redirect"
+	}
+	if p.Goto {
+		return fmt.Sprintf("go to chain %s", p.Target)
+	}
+	return fmt.Sprintf("jump to chain %s", p.Target)
+}
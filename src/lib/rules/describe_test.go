@@ -0,0 +1,57 @@
+package rules_test
+
+import (
+	"lib/rules"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Describe", func() {
+	table.DescribeTable("describes a rule in one line",
+		func(rule rules.IPTablesRule, expectedDescription string) {
+			Expect(rule.Describe()).To(Equal(expectedDescription))
+		},
+		table.Entry("NewMarkAllowRule",
+			rules.NewMarkAllowRule("10.255.0.2", "tcp", 8080, 8080, "ABCD", "some-source-guid", "some-destination-guid"),
+			"tcp traffic to 10.255.0.2 on port 8080 with mark 0xabcd: accept (src:some-source-guid_dst:some-destination-guid)",
+		),
+		table.Entry("NewPortForwardingRule",
+			rules.NewPortForwardingRule(61000, 8080, "10.0.0.1", "10.255.0.2"),
+			"tcp traffic to 10.0.0.1 on port 61000: forward to 10.255.0.2:8080",
+		),
+		table.Entry("NewMarkSetRule",
+			rules.NewMarkSetRule("10.255.0.2", "ABCD", "some-guid"),
+			"traffic from 10.255.0.2: set mark 0xabcd (src:some-guid)",
+		),
+		table.Entry("NewNetOutWithPortsRule",
+			rules.NewNetOutWithPortsRule("1.1.1.1", "2.2.2.2", 80, 443, "udp"),
+			"udp traffic to addresses 1.1.1.1-2.2.2.2 on ports 80-443: accept",
+		),
+		table.Entry("NewNetOutICMPRule",
+			rules.NewNetOutICMPRule("1.1.1.1", "2.2.2.2", 8, 0),
+			"icmp traffic to addresses 1.1.1.1-2.2.2.2 of icmp type 8 code 0: accept",
+		),
+		table.Entry("NewInputRelatedEstablishedRule",
+			rules.NewInputRelatedEstablishedRule(),
+			"traffic in state related or established: accept",
+		),
+		table.Entry("NewNetOutDefaultRejectRule",
+			rules.NewNetOutDefaultRejectRule(),
+			"all traffic: reject with icmp-port-unreachable",
+		),
+		table.Entry("NewLogRule",
+			rules.NewLogRule(rules.IPTablesRule{"-p", "tcp"}, "some-name"),
+			`tcp traffic at most 2/min: log with prefix "some-name "`,
+		),
+		table.Entry("a negated goto",
+			rules.IPTablesRule{"!", "-d", "10.255.0.0/16", "-g", "some-chain"},
+			"traffic not to 10.255.0.0/16: go to chain some-chain",
+		),
+		table.Entry("an invalid rule",
+			rules.IPTablesRule{"--potato", "1"},
+			"invalid rule (unsupported option '--potato'): --potato 1",
+		),
+	)
+})
@@ -3,6 +3,7 @@ package rules
 import (
 	"fmt"
 	"os/exec"
+	"sort"
 	"strings"
 )
 
@@ -28,6 +29,8 @@ type IPTablesAdapter interface {
 	DeleteChain(table, chain string) error
 	BulkInsert(table, chain string, pos int, rulespec ...IPTablesRule) error
 	BulkAppend(table, chain string, rulespec ...IPTablesRule) error
+	SyncChain(table, chain string, desired []IPTablesRule) (SyncSummary, error)
+	SyncChains(table string, desired map[string][]IPTablesRule) (map[string]SyncSummary, error)
 }
 
 //go:generate counterfeiter -o ../fakes/locker.go --fake-name Locker . locker
@@ -151,3 +154,87 @@ func (l *LockedIPTables) chainExec(table, chain string, action func(string, stri
 
 	return l.Locker.Unlock()
 }
+
+// SyncChain makes the rules of an existing chain match desired. Only the
+// rules that differ are added or removed, in one iptables-restore
+// transaction, so rules that stay are never missing.
+func (l *LockedIPTables) SyncChain(table, chain string, desired []IPTablesRule) (SyncSummary, error) {
+	summaries, err := l.SyncChains(table, map[string][]IPTablesRule{chain: desired})
+	return summaries[chain], err
+}
+
+// SyncChains syncs several chains of a table in one transaction.
+func (l *LockedIPTables) SyncChains(table string, desired map[string][]IPTablesRule) (map[string]SyncSummary, error) {
+	if err := l.Locker.Lock(); err != nil {
+		return nil, fmt.Errorf("lock: %s", err)
+	}
+
+	chains := []string{}
+	for chain := range desired {
+		chains = append(chains, chain)
+	}
+	sort.Strings(chains)
+
+	summaries := map[string]SyncSummary{}
+	input := []string{}
+	for _, chain := range chains {
+		listed, err := l.IPTables.List(table, chain)
+		if err != nil {
+			return nil, handleIPTablesError(err, l.Locker.Unlock())
+		}
+
+		lines, summary := syncLines(chain, listedRules(chain, listed), desired[chain])
+		input = append(input, lines...)
+		summaries[chain] = summary
+	}
+
+	if len(input) > 0 {
+		restoreInput := fmt.Sprintf("*%s\n%sCOMMIT\n", table, strings.Join(input, ""))
+		if err := l.Restorer.Restore(restoreInput); err != nil {
+			return nil, handleIPTablesError(err, l.Locker.Unlock())
+		}
+	}
+
+	return summaries, l.Locker.Unlock()
+}
+
+// listedRules returns the rules of a chain from its iptables -S listing.
+func listedRules(chain string, listed []string) []IPTablesRule {
+	prefix := fmt.Sprintf("-A %s ", chain)
+	current := []IPTablesRule{}
+	for _, line := range listed {
+		if strings.HasPrefix(line, prefix) {
+			current = append(current, SplitRuleSpec(strings.TrimPrefix(line, prefix)))
+		}
+	}
+	return current
+}
+
+// syncLines returns the iptables-restore lines that turn current into
+// desired. Rules are deleted by number from the end first, then the new
+// rules are inserted at their final positions from the start.
+func syncLines(chain string, current, desired []IPTablesRule) ([]string, SyncSummary) {
+	currentKeys := []string{}
+	for _, rule := range current {
+		currentKeys = append(currentKeys, canonical(rule))
+	}
+	desiredKeys := []string{}
+	for _, rule := range desired {
+		desiredKeys = append(desiredKeys, canonical(rule))
+	}
+	removed, added := Diff(currentKeys, desiredKeys)
+
+	lines := []string{}
+	summary := SyncSummary{Added: []IPTablesRule{}, Removed: []IPTablesRule{}}
+	for k := len(removed) - 1; k >= 0; k-- {
+		lines = append(lines, fmt.Sprintf("-D %s %d\n", chain, removed[k]+1))
+	}
+	for _, i := range removed {
+		summary.Removed = append(summary.Removed, current[i])
+	}
+	for _, j := range added {
+		lines = append(lines, fmt.Sprintf("-I %s %d %s\n", chain, j+1, strings.Join(desired[j], " ")))
+		summary.Added = append(summary.Added, desired[j])
+	}
+	return lines, summary
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"lib/filelock"
 	"lib/rules"
+	libtestsupport "lib/testsupport"
 	"os/exec"
 	"runtime"
 	"strings"
@@ -86,6 +87,13 @@ var _ = Describe("Locked IPTables Integration Test", func() {
 		}
 	})
 
+	Describe("shared adapter behaviour", func() {
+		libtestsupport.DescribeIPTablesAdapter(func() rules.IPTablesAdapter {
+			onlyRunOnLinux()
+			return lockedIPT
+		})
+	})
+
 })
 
 func onlyRunOnLinux() {
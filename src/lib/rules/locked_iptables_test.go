@@ -7,6 +7,7 @@ import (
 	"lib/rules"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 )
 
@@ -155,6 +156,18 @@ var _ = Describe("LockedIptables", func() {
 				Expect(err).To(MatchError("iptables call: patato and unlock: banana"))
 			})
 		})
+
+		Context("when a rule uses options the rule parser does not know", func() {
+			BeforeEach(func() {
+				ruleSet = append(ruleSet, rules.IPTablesRule{"-p", "sctp", "-m", "set", "--match-set", "some-set", "dst", "-j", "ACCEPT"})
+			})
+			It("leaves them to iptables-restore", func() {
+				err := lockedIPT.BulkAppend("some-table", "some-chain", ruleSet...)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(restorer.RestoreArgsForCall(0)).To(ContainSubstring("-A some-chain -p sctp -m set --match-set some-set dst -j ACCEPT\n"))
+			})
+		})
 	})
 
 	Describe("Exists", func() {
@@ -436,4 +449,176 @@ var _ = Describe("LockedIptables", func() {
 			})
 		})
 	})
+
+	Describe("SyncChain", func() {
+		var desired []rules.IPTablesRule
+
+		BeforeEach(func() {
+			ipt.ListReturns([]string{
+				"-N some-chain",
+				`-A some-chain -s 1.2.3.4/32 -m comment --comment "src:a-guid" -j MARK --set-xmark 0xa/0xffffffff`,
+				"-A some-chain -d 10.255.0.2/32 -p tcp -m tcp --dport 8080 -m mark --mark 0xabcd -j ACCEPT",
+				"-A some-chain -m state --state RELATED,ESTABLISHED -j ACCEPT",
+				`-A some-chain -m limit --limit 10/sec --limit-burst 10 -j LOG --log-prefix "DENY_some-handle "`,
+				"-A some-chain -j REJECT --reject-with icmp-port-unreachable",
+			}, nil)
+			desired = []rules.IPTablesRule{
+				rules.NewMarkSetRule("1.2.3.4", "A", "a-guid"),
+				rules.NewMarkSetRule("2.2.2.2", "B", "b-guid"),
+				rules.NewAcceptExistingLocalRule(),
+				rules.NewNetOutDefaultRejectLogRule("some-handle", 10),
+				rules.NewNetOutDefaultRejectRule(),
+			}
+		})
+
+		It("adds and removes only the rules that differ in one transaction", func() {
+			summary, err := lockedIPT.SyncChain("some-table", "some-chain", desired)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(lock.LockCallCount()).To(Equal(1))
+			Expect(lock.UnlockCallCount()).To(Equal(1))
+			table, chain := ipt.ListArgsForCall(0)
+			Expect(table).To(Equal("some-table"))
+			Expect(chain).To(Equal("some-chain"))
+
+			Expect(restorer.RestoreCallCount()).To(Equal(1))
+			Expect(restorer.RestoreArgsForCall(0)).To(Equal("*some-table\n" +
+				"-D some-chain 2\n" +
+				"-I some-chain 2 --source 2.2.2.2 --jump MARK --set-xmark 0xB -m comment --comment src:b-guid\n" +
+				"COMMIT\n"))
+
+			Expect(summary.Added).To(Equal([]rules.IPTablesRule{desired[1]}))
+			Expect(summary.Removed).To(Equal([]rules.IPTablesRule{
+				{"-d", "10.255.0.2/32", "-p", "tcp", "-m", "tcp", "--dport", "8080", "-m", "mark", "--mark", "0xabcd", "-j", "ACCEPT"},
+			}))
+		})
+
+		It("keeps the order of the desired rules", func() {
+			ipt.ListReturns([]string{
+				"-N some-chain",
+				"-A some-chain -d 1.1.1.1/32 -j ACCEPT",
+				"-A some-chain -d 2.2.2.2/32 -j ACCEPT",
+				"-A some-chain -d 3.3.3.3/32 -j ACCEPT",
+			}, nil)
+
+			_, err := lockedIPT.SyncChain("some-table", "some-chain", []rules.IPTablesRule{
+				{"-d", "3.3.3.3", "-j", "ACCEPT"},
+				{"-d", "1.1.1.1", "-j", "ACCEPT"},
+				{"-d", "4.4.4.4", "-j", "ACCEPT"},
+				{"-d", "2.2.2.2", "-j", "ACCEPT"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(restorer.RestoreArgsForCall(0)).To(Equal("*some-table\n" +
+				"-D some-chain 3\n" +
+				"-I some-chain 1 -d 3.3.3.3 -j ACCEPT\n" +
+				"-I some-chain 3 -d 4.4.4.4 -j ACCEPT\n" +
+				"COMMIT\n"))
+		})
+
+		Context("when the chain is already in sync", func() {
+			It("does not restore anything", func() {
+				desired[1] = rules.IPTablesRule{
+					"-d", "10.255.0.2",
+					"-p", "tcp",
+					"--dport", "8080:8080",
+					"-m", "mark", "--mark", "0xABCD",
+					"--jump", "ACCEPT",
+				}
+				summary, err := lockedIPT.SyncChain("some-table", "some-chain", desired)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(restorer.RestoreCallCount()).To(Equal(0))
+				Expect(summary.Added).To(BeEmpty())
+				Expect(summary.Removed).To(BeEmpty())
+			})
+		})
+
+		DescribeTable("recognising the rules of the constructors as listed by iptables",
+			func(rule rules.IPTablesRule, listed string) {
+				ipt.ListReturns([]string{"-N some-chain", "-A some-chain " + listed}, nil)
+				summary, err := lockedIPT.SyncChain("some-table", "some-chain", []rules.IPTablesRule{rule})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(summary.Added).To(BeEmpty())
+				Expect(restorer.RestoreCallCount()).To(Equal(0))
+			},
+			Entry("port forwarding", rules.NewPortForwardingRule(61000, 8080, "10.0.0.5", "10.255.0.2"),
+				"-d 10.0.0.5/32 -p tcp -m tcp --dport 61000 -j DNAT --to-destination 10.255.0.2:8080"),
+			Entry("ingress mark", rules.NewIngressMarkRule("eth0", 61000, "10.0.0.5", "ABCD"),
+				"-d 10.0.0.5/32 -i eth0 -p tcp -m tcp --dport 61000 -j MARK --set-xmark 0xabcd/0xffffffff"),
+			Entry("mark allow", rules.NewMarkAllowRule("10.255.0.2", "udp", 8080, 8090, "ABCD", "src-guid", "dst-guid"),
+				`-d 10.255.0.2/32 -p udp -m udp --dport 8080:8090 -m mark --mark 0xabcd -m comment --comment "src:src-guid_dst:dst-guid" -j ACCEPT`),
+			Entry("mark allow log", rules.NewMarkAllowLogRule("10.255.0.2", "tcp", 8080, 8080, "ABCD", "dst-guid", 10),
+				`-d 10.255.0.2/32 -p tcp -m tcp --dport 8080 -m mark --mark 0xabcd -m conntrack --ctstate INVALID,NEW,UNTRACKED -j LOG --log-prefix "OK_ABCD_dst-guid "`),
+			Entry("default egress", rules.NewDefaultEgressRule("10.255.0.0/24", "silk-vtep"),
+				"-s 10.255.0.0/24 ! -o silk-vtep -j MASQUERADE"),
+			Entry("log local reject", rules.NewLogLocalRejectRule("10.255.0.0/16"),
+				`-s 10.255.0.0/16 -d 10.255.0.0/16 -m limit --limit 2/min -j LOG --log-prefix "REJECT_LOCAL:  "`),
+			Entry("net out with ports", rules.NewNetOutWithPortsRule("1.1.1.1", "2.2.2.2", 80, 90, "tcp"),
+				"-p tcp -m iprange --dst-range 1.1.1.1-2.2.2.2 -m tcp --dport 80:90 -j ACCEPT"),
+			Entry("net out icmp log", rules.NewNetOutICMPLogRule("1.1.1.1", "2.2.2.2", 8, 0, "netout--log"),
+				"-p icmp -m iprange --dst-range 1.1.1.1-2.2.2.2 -m icmp --icmp-type 8/0 -g netout--log"),
+			Entry("net out default non udp log", rules.NewNetOutDefaultNonUDPLogRule("some-prefix"),
+				`! -p udp -m conntrack --ctstate INVALID,NEW,UNTRACKED -j LOG --log-prefix "OK_some-prefix "`),
+			Entry("overlay allow egress", rules.NewOverlayAllowEgress("silk-vtep", "10.255.0.2"),
+				"-s 10.255.0.2/32 -o silk-vtep -m mark ! --mark 0x0 -j ACCEPT"),
+			Entry("input default reject", rules.NewInputDefaultRejectRule(),
+				"-j REJECT --reject-with icmp-port-unreachable"),
+		)
+
+		Context("when listing the chain fails", func() {
+			BeforeEach(func() {
+				ipt.ListReturns(nil, errors.New("banana"))
+			})
+			It("returns an error", func() {
+				_, err := lockedIPT.SyncChain("some-table", "some-chain", desired)
+				Expect(err).To(MatchError("iptables call: banana and unlock: <nil>"))
+				Expect(restorer.RestoreCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the lock fails", func() {
+			BeforeEach(func() {
+				lock.LockReturns(errors.New("banana"))
+			})
+			It("returns an error", func() {
+				_, err := lockedIPT.SyncChain("some-table", "some-chain", desired)
+				Expect(err).To(MatchError("lock: banana"))
+			})
+		})
+
+		Context("when the restorer fails", func() {
+			BeforeEach(func() {
+				restorer.RestoreReturns(errors.New("banana"))
+			})
+			It("returns an error", func() {
+				_, err := lockedIPT.SyncChain("some-table", "some-chain", desired)
+				Expect(err).To(MatchError("iptables call: banana and unlock: <nil>"))
+			})
+		})
+	})
+
+	Describe("SyncChains", func() {
+		It("syncs every chain in one transaction", func() {
+			ipt.ListStub = func(table, chain string) ([]string, error) {
+				return []string{"-N " + chain, "-A " + chain + " -d 1.1.1.1/32 -j ACCEPT"}, nil
+			}
+
+			summaries, err := lockedIPT.SyncChains("some-table", map[string][]rules.IPTablesRule{
+				"chain-b": {{"-d", "2.2.2.2", "-j", "ACCEPT"}},
+				"chain-a": {{"-d", "1.1.1.1", "-j", "ACCEPT"}, {"-d", "2.2.2.2", "-j", "ACCEPT"}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ipt.ListCallCount()).To(Equal(2))
+			Expect(restorer.RestoreCallCount()).To(Equal(1))
+			Expect(restorer.RestoreArgsForCall(0)).To(Equal("*some-table\n" +
+				"-I chain-a 2 -d 2.2.2.2 -j ACCEPT\n" +
+				"-D chain-b 1\n" +
+				"-I chain-b 1 -d 2.2.2.2 -j ACCEPT\n" +
+				"COMMIT\n"))
+
+			Expect(summaries).To(HaveLen(2))
+			Expect(summaries["chain-a"].Added).To(Equal([]rules.IPTablesRule{{"-d", "2.2.2.2", "-j", "ACCEPT"}}))
+			Expect(summaries["chain-b"].Removed).To(Equal([]rules.IPTablesRule{{"-d", "1.1.1.1/32", "-j", "ACCEPT"}}))
+		})
+	})
 })
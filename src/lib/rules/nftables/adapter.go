@@ -0,0 +1,344 @@
+package nftables
+
+import (
+	"fmt"
+	"lib/rules"
+	"sort"
+	"strings"
+)
+
+// maxCommentLength is the longest rulespec that fits in the rule userdata:
+// NFT_USERDATA_MAXLEN is 256 bytes, and the comment takes a 2 byte header and
+// a trailing NUL.
+const maxCommentLength = 253
+
+//go:generate counterfeiter -o ../../fakes/nft_conn.go --fake-name NFTConn . conn
+type conn interface {
+	Apply(ops ...Operation) error
+	ListRules(table, chain string) ([]Rule, error)
+	ChainExists(table, chain string) (bool, error)
+}
+
+type locker interface {
+	Lock() error
+	Unlock() error
+}
+
+var baseChains = map[string]map[string]ChainHook{
+	"filter": {
+		"INPUT":   {Type: "filter", Hooknum: 1, Priority: 0},
+		"FORWARD": {Type: "filter", Hooknum: 2, Priority: 0},
+		"OUTPUT":  {Type: "filter", Hooknum: 3, Priority: 0},
+	},
+	"nat": {
+		"PREROUTING":  {Type: "nat", Hooknum: 0, Priority: -100},
+		"INPUT":       {Type: "nat", Hooknum: 1, Priority: 100},
+		"OUTPUT":      {Type: "nat", Hooknum: 3, Priority: -100},
+		"POSTROUTING": {Type: "nat", Hooknum: 4, Priority: 100},
+	},
+	"mangle": {
+		"PREROUTING":  {Type: "filter", Hooknum: 0, Priority: -150},
+		"INPUT":       {Type: "filter", Hooknum: 1, Priority: -150},
+		"FORWARD":     {Type: "filter", Hooknum: 2, Priority: -150},
+		"OUTPUT":      {Type: "route", Hooknum: 3, Priority: -150},
+		"POSTROUTING": {Type: "filter", Hooknum: 4, Priority: -150},
+	},
+}
+
+// Adapter implements rules.IPTablesAdapter on nf_tables. The iptables
+// tables and their built-in chains are created in the ip family on first
+// use, and every rule keeps its rulespec as its comment, so that rules can
+// be found again by rulespec.
+type Adapter struct {
+	Conn   conn
+	Locker locker
+}
+
+func handleNFTablesError(err1, err2 error) error {
+	return fmt.Errorf("nftables call: %+v and unlock: %+v", err1, err2)
+}
+
+func baseChain(table, chain string) (ChainHook, bool) {
+	hook, ok := baseChains[table][chain]
+	return hook, ok
+}
+
+// chainOps returns the operations that make sure the table and, for a
+// built-in chain, the chain exist.
+func chainOps(table, chain string) []Operation {
+	ops := []Operation{AddTable{Table: table}}
+	if hook, ok := baseChain(table, chain); ok {
+		ops = append(ops, AddChain{Table: table, Chain: chain, Hook: &hook})
+	}
+	return ops
+}
+
+func (a *Adapter) withLock(action func() error) error {
+	if err := a.Locker.Lock(); err != nil {
+		return fmt.Errorf("lock: %s", err)
+	}
+	if err := action(); err != nil {
+		return handleNFTablesError(err, a.Locker.Unlock())
+	}
+	return a.Locker.Unlock()
+}
+
+func (a *Adapter) findRule(table, chain string, rulespec rules.IPTablesRule) (Rule, bool, error) {
+	existing, err := a.Conn.ListRules(table, chain)
+	if err != nil {
+		return Rule{}, false, err
+	}
+	spec := strings.Join(rulespec, " ")
+	for _, rule := range existing {
+		if rule.Comment == spec {
+			return rule, true, nil
+		}
+	}
+	return Rule{}, false, nil
+}
+
+func (a *Adapter) Exists(table, chain string, rulespec rules.IPTablesRule) (bool, error) {
+	var exists bool
+	err := a.withLock(func() error {
+		var err error
+		_, exists, err = a.findRule(table, chain, rulespec)
+		return err
+	})
+	return exists, err
+}
+
+func (a *Adapter) Delete(table, chain string, rulespec rules.IPTablesRule) error {
+	return a.withLock(func() error {
+		rule, found, err := a.findRule(table, chain, rulespec)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("rule not found in %s/%s: %s", table, chain, strings.Join(rulespec, " "))
+		}
+		return a.Conn.Apply(DeleteRule{Table: table, Chain: chain, Handle: rule.Handle})
+	})
+}
+
+func (a *Adapter) List(table, chain string) ([]string, error) {
+	var list []string
+	err := a.withLock(func() error {
+		exists, err := a.Conn.ChainExists(table, chain)
+		if err != nil {
+			return err
+		}
+
+		_, isBase := baseChain(table, chain)
+		switch {
+		case isBase:
+			list = []string{fmt.Sprintf("-P %s ACCEPT", chain)}
+		case exists:
+			list = []string{fmt.Sprintf("-N %s", chain)}
+		default:
+			return fmt.Errorf("chain %s not found in table %s", chain, table)
+		}
+		if !exists {
+			return nil
+		}
+
+		existing, err := a.Conn.ListRules(table, chain)
+		if err != nil {
+			return err
+		}
+		for _, rule := range existing {
+			list = append(list, fmt.Sprintf("-A %s %s", chain, rule.Comment))
+		}
+		return nil
+	})
+	return list, err
+}
+
+func (a *Adapter) NewChain(table, chain string) error {
+	return a.withLock(func() error {
+		return a.Conn.Apply(AddTable{Table: table}, AddChain{Table: table, Chain: chain, Exclusive: true})
+	})
+}
+
+// ClearChain deletes the rules of a chain, creating the chain if it does not
+// exist.
+func (a *Adapter) ClearChain(table, chain string) error {
+	return a.withLock(func() error {
+		ops := chainOps(table, chain)
+		if _, ok := baseChain(table, chain); !ok {
+			ops = append(ops, AddChain{Table: table, Chain: chain})
+		}
+		ops = append(ops, FlushChain{Table: table, Chain: chain})
+		return a.Conn.Apply(ops...)
+	})
+}
+
+func (a *Adapter) DeleteChain(table, chain string) error {
+	return a.withLock(func() error {
+		return a.Conn.Apply(DeleteChain{Table: table, Chain: chain})
+	})
+}
+
+// BulkInsert inserts the rules at pos in one batch. Like iptables-restore,
+// each rule is inserted at pos in turn, so they end up in reverse order.
+func (a *Adapter) BulkInsert(table, chain string, pos int, rulespec ...rules.IPTablesRule) error {
+	if pos < 1 {
+		return fmt.Errorf("invalid rule position %d", pos)
+	}
+	return a.withLock(func() error {
+		after := uint64(0)
+		if pos > 1 {
+			existing, err := a.Conn.ListRules(table, chain)
+			if err != nil {
+				return err
+			}
+			if pos-1 > len(existing) {
+				return fmt.Errorf("index of insertion too big: %d", pos)
+			}
+			after = existing[pos-2].Handle
+		}
+
+		return a.apply(table, chain, rulespec, func(rule *AddRule) {
+			if after == 0 {
+				rule.Prepend = true
+			} else {
+				rule.Position = after
+			}
+		})
+	})
+}
+
+func (a *Adapter) BulkAppend(table, chain string, rulespec ...rules.IPTablesRule) error {
+	return a.withLock(func() error {
+		return a.apply(table, chain, rulespec, func(*AddRule) {})
+	})
+}
+
+func (a *Adapter) apply(table, chain string, rulespec []rules.IPTablesRule, place func(*AddRule)) error {
+	ops := chainOps(table, chain)
+	for _, spec := range rulespec {
+		rule, err := newAddRule(table, chain, spec)
+		if err != nil {
+			return err
+		}
+		place(&rule)
+		ops = append(ops, rule)
+	}
+	return a.Conn.Apply(ops...)
+}
+
+func newAddRule(table, chain string, spec rules.IPTablesRule) (AddRule, error) {
+	comment := strings.Join(spec, " ")
+	exprs, err := Translate(spec)
+	if err != nil {
+		return AddRule{}, fmt.Errorf("translate rule '%s': %s", comment, err)
+	}
+	if len(comment) > maxCommentLength {
+		return AddRule{}, fmt.Errorf("rule too long to store: %s", comment)
+	}
+	return AddRule{Table: table, Chain: chain, Exprs: exprs, Comment: comment}, nil
+}
+
+// SyncChain makes the rules of a chain match desired. Only the rules that
+// differ are added or removed, in one batch.
+func (a *Adapter) SyncChain(table, chain string, desired []rules.IPTablesRule) (rules.SyncSummary, error) {
+	summaries, err := a.SyncChains(table, map[string][]rules.IPTablesRule{chain: desired})
+	return summaries[chain], err
+}
+
+// SyncChains syncs several chains of a table in one batch.
+func (a *Adapter) SyncChains(table string, desired map[string][]rules.IPTablesRule) (map[string]rules.SyncSummary, error) {
+	chains := []string{}
+	for chain := range desired {
+		chains = append(chains, chain)
+	}
+	sort.Strings(chains)
+
+	summaries := map[string]rules.SyncSummary{}
+	err := a.withLock(func() error {
+		ops := []Operation{AddTable{Table: table}}
+		changed := false
+		for _, chain := range chains {
+			if hook, ok := baseChain(table, chain); ok {
+				ops = append(ops, AddChain{Table: table, Chain: chain, Hook: &hook})
+			}
+
+			existing, err := a.Conn.ListRules(table, chain)
+			if err != nil {
+				return err
+			}
+			chainSyncOps, summary, err := syncOps(table, chain, existing, desired[chain])
+			if err != nil {
+				return err
+			}
+			ops = append(ops, chainSyncOps...)
+			changed = changed || len(chainSyncOps) > 0
+			summaries[chain] = summary
+		}
+
+		if !changed {
+			return nil
+		}
+		return a.Conn.Apply(ops...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// syncOps returns the operations that turn existing into desired. A new
+// rule is added before the next rule that stays, or at the end when no rule
+// after it stays.
+func syncOps(table, chain string, existing []Rule, desired []rules.IPTablesRule) ([]Operation, rules.SyncSummary, error) {
+	currentKeys := []string{}
+	for _, rule := range existing {
+		currentKeys = append(currentKeys, rule.Comment)
+	}
+	desiredKeys := []string{}
+	for _, spec := range desired {
+		desiredKeys = append(desiredKeys, strings.Join(spec, " "))
+	}
+	removed, added := rules.Diff(currentKeys, desiredKeys)
+
+	summary := rules.SyncSummary{Added: []rules.IPTablesRule{}, Removed: []rules.IPTablesRule{}}
+	ops := []Operation{}
+	isRemoved := map[int]bool{}
+	for _, i := range removed {
+		isRemoved[i] = true
+		ops = append(ops, DeleteRule{Table: table, Chain: chain, Handle: existing[i].Handle})
+		summary.Removed = append(summary.Removed, rules.SplitRuleSpec(existing[i].Comment))
+	}
+
+	isAdded := map[int]bool{}
+	for _, j := range added {
+		isAdded[j] = true
+	}
+	handles := map[int]uint64{}
+	i := 0
+	for j := range desired {
+		if isAdded[j] {
+			continue
+		}
+		for isRemoved[i] {
+			i++
+		}
+		handles[j] = existing[i].Handle
+		i++
+	}
+
+	for _, j := range added {
+		rule, err := newAddRule(table, chain, desired[j])
+		if err != nil {
+			return nil, summary, err
+		}
+		for next := j + 1; next < len(desired); next++ {
+			if handle, ok := handles[next]; ok {
+				rule.Prepend, rule.Position = true, handle
+				break
+			}
+		}
+		ops = append(ops, rule)
+		summary.Added = append(summary.Added, desired[j])
+	}
+	return ops, summary, nil
+}
@@ -0,0 +1,28 @@
+package nftables_test
+
+import (
+	"lib/fakes"
+	"lib/rules"
+	"lib/rules/nftables"
+	"lib/testsupport"
+	"os"
+	"runtime"
+
+	. "github.com/onsi/ginkgo"
+)
+
+var _ = Describe("Adapter Integration Test", func() {
+	testsupport.DescribeIPTablesAdapter(func() rules.IPTablesAdapter {
+		onlyRunAsRootOnLinux()
+		return &nftables.Adapter{
+			Conn:   &nftables.NetlinkConn{},
+			Locker: &fakes.Locker{},
+		}
+	})
+})
+
+func onlyRunAsRootOnLinux() {
+	if runtime.GOOS != "linux" || os.Geteuid() != 0 {
+		Skip("not running as root on linux. Skipping...")
+	}
+}
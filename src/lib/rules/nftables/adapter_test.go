@@ -0,0 +1,405 @@
+package nftables_test
+
+import (
+	"errors"
+	"lib/fakes"
+	"lib/rules"
+	"lib/rules/nftables"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Adapter", func() {
+	var (
+		adapter *nftables.Adapter
+		conn    *fakes.NFTConn
+		lock    *fakes.Locker
+		ruleSet []rules.IPTablesRule
+	)
+
+	BeforeEach(func() {
+		conn = &fakes.NFTConn{}
+		lock = &fakes.Locker{}
+		adapter = &nftables.Adapter{
+			Conn:   conn,
+			Locker: lock,
+		}
+		ruleSet = []rules.IPTablesRule{
+			rules.NewMarkSetRule("1.2.3.4", "A", "a-guid"),
+			rules.NewMarkSetRule("2.2.2.2", "B", "b-guid"),
+		}
+	})
+
+	addRules := func(ops []nftables.Operation) []nftables.AddRule {
+		added := []nftables.AddRule{}
+		for _, op := range ops {
+			if rule, ok := op.(nftables.AddRule); ok {
+				added = append(added, rule)
+			}
+		}
+		return added
+	}
+
+	Describe("BulkAppend", func() {
+		It("creates the table and built-in chain and appends the rules in one batch", func() {
+			err := adapter.BulkAppend("filter", "FORWARD", ruleSet...)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(lock.LockCallCount()).To(Equal(1))
+			Expect(lock.UnlockCallCount()).To(Equal(1))
+			Expect(conn.ApplyCallCount()).To(Equal(1))
+
+			ops := conn.ApplyArgsForCall(0)
+			Expect(ops).To(HaveLen(4))
+			Expect(ops[0]).To(Equal(nftables.AddTable{Table: "filter"}))
+			Expect(ops[1]).To(Equal(nftables.AddChain{
+				Table: "filter",
+				Chain: "FORWARD",
+				Hook:  &nftables.ChainHook{Type: "filter", Hooknum: 2, Priority: 0},
+			}))
+
+			added := addRules(ops)
+			Expect(added[0].Chain).To(Equal("FORWARD"))
+			Expect(added[0].Prepend).To(BeFalse())
+			Expect(added[0].Comment).To(Equal("--source 1.2.3.4 --jump MARK --set-xmark 0xA -m comment --comment src:a-guid"))
+			Expect(added[1].Comment).To(Equal("--source 2.2.2.2 --jump MARK --set-xmark 0xB -m comment --comment src:b-guid"))
+
+			exprs, err := nftables.Translate(ruleSet[0])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(added[0].Exprs).To(Equal(exprs))
+		})
+
+		It("does not create user chains", func() {
+			err := adapter.BulkAppend("filter", "netout--some-handle", ruleSet...)
+			Expect(err).NotTo(HaveOccurred())
+
+			ops := conn.ApplyArgsForCall(0)
+			Expect(ops).To(HaveLen(3))
+			Expect(ops[0]).To(Equal(nftables.AddTable{Table: "filter"}))
+		})
+
+		Context("when a rule cannot be translated", func() {
+			It("returns an error without applying anything", func() {
+				err := adapter.BulkAppend("filter", "FORWARD", rules.IPTablesRule{"-p", "tcp", "-m", "multiport", "--dports", "80,443", "-j", "ACCEPT"})
+				Expect(err).To(MatchError("nftables call: translate rule '-p tcp -m multiport --dports 80,443 -j ACCEPT': multiport match of several ports is only supported negated and unlock: <nil>"))
+				Expect(conn.ApplyCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when a rulespec is longer than fits in the rule userdata", func() {
+			commentRule := func(length int) rules.IPTablesRule {
+				prefix := "-j ACCEPT -m comment --comment "
+				return rules.IPTablesRule{"-j", "ACCEPT", "-m", "comment", "--comment", strings.Repeat("a", length-len(prefix))}
+			}
+
+			It("stores rulespecs of up to 253 bytes", func() {
+				Expect(adapter.BulkAppend("filter", "FORWARD", commentRule(253))).To(Succeed())
+
+				added := addRules(conn.ApplyArgsForCall(0))
+				Expect(added[0].Comment).To(HaveLen(253))
+			})
+
+			It("returns an error without applying anything", func() {
+				err := adapter.BulkAppend("filter", "FORWARD", commentRule(254))
+				Expect(err).To(MatchError(ContainSubstring("rule too long to store: -j ACCEPT -m comment --comment aaa")))
+				Expect(conn.ApplyCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the lock fails", func() {
+			BeforeEach(func() {
+				lock.LockReturns(errors.New("banana"))
+			})
+			It("returns an error", func() {
+				err := adapter.BulkAppend("filter", "FORWARD", ruleSet...)
+				Expect(err).To(MatchError("lock: banana"))
+				Expect(conn.ApplyCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when applying fails", func() {
+			BeforeEach(func() {
+				conn.ApplyReturns(errors.New("banana"))
+			})
+			It("returns an error", func() {
+				err := adapter.BulkAppend("filter", "FORWARD", ruleSet...)
+				Expect(err).To(MatchError("nftables call: banana and unlock: <nil>"))
+			})
+		})
+
+		Context("when applying fails and then the unlock fails", func() {
+			BeforeEach(func() {
+				conn.ApplyReturns(errors.New("patato"))
+				lock.UnlockReturns(errors.New("banana"))
+			})
+			It("returns an error", func() {
+				err := adapter.BulkAppend("filter", "FORWARD", ruleSet...)
+				Expect(err).To(MatchError("nftables call: patato and unlock: banana"))
+			})
+		})
+
+		Context("when the unlock fails", func() {
+			BeforeEach(func() {
+				lock.UnlockReturns(errors.New("banana"))
+			})
+			It("returns an error", func() {
+				err := adapter.BulkAppend("filter", "FORWARD", ruleSet...)
+				Expect(err).To(MatchError("banana"))
+			})
+		})
+	})
+
+	Describe("BulkInsert", func() {
+		It("prepends the rules when inserting at the first position", func() {
+			err := adapter.BulkInsert("filter", "FORWARD", 1, ruleSet...)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(conn.ListRulesCallCount()).To(Equal(0))
+			added := addRules(conn.ApplyArgsForCall(0))
+			Expect(added).To(HaveLen(2))
+			for _, rule := range added {
+				Expect(rule.Prepend).To(BeTrue())
+				Expect(rule.Position).To(BeZero())
+			}
+		})
+
+		It("adds the rules after the rule before the position", func() {
+			conn.ListRulesReturns([]nftables.Rule{{Handle: 4}, {Handle: 7}, {Handle: 9}}, nil)
+
+			err := adapter.BulkInsert("filter", "FORWARD", 3, ruleSet...)
+			Expect(err).NotTo(HaveOccurred())
+
+			table, chain := conn.ListRulesArgsForCall(0)
+			Expect(table).To(Equal("filter"))
+			Expect(chain).To(Equal("FORWARD"))
+			for _, rule := range addRules(conn.ApplyArgsForCall(0)) {
+				Expect(rule.Prepend).To(BeFalse())
+				Expect(rule.Position).To(Equal(uint64(7)))
+			}
+		})
+
+		Context("when the position is past the end of the chain", func() {
+			It("returns an error", func() {
+				conn.ListRulesReturns([]nftables.Rule{{Handle: 4}}, nil)
+
+				err := adapter.BulkInsert("filter", "FORWARD", 3, ruleSet...)
+				Expect(err).To(MatchError("nftables call: index of insertion too big: 3 and unlock: <nil>"))
+				Expect(conn.ApplyCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the position is invalid", func() {
+			It("returns an error", func() {
+				err := adapter.BulkInsert("filter", "FORWARD", 0, ruleSet...)
+				Expect(err).To(MatchError("invalid rule position 0"))
+			})
+		})
+	})
+
+	Describe("Exists", func() {
+		BeforeEach(func() {
+			conn.ListRulesReturns([]nftables.Rule{
+				{Handle: 3, Comment: "--source 1.2.3.4 --jump MARK --set-xmark 0xA -m comment --comment src:a-guid"},
+			}, nil)
+		})
+
+		It("finds rules by their rulespec", func() {
+			exists, err := adapter.Exists("filter", "FORWARD", ruleSet[0])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+
+			exists, err = adapter.Exists("filter", "FORWARD", ruleSet[1])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeFalse())
+		})
+
+		Context("when listing the rules fails", func() {
+			It("returns an error", func() {
+				conn.ListRulesReturns(nil, errors.New("banana"))
+				_, err := adapter.Exists("filter", "FORWARD", ruleSet[0])
+				Expect(err).To(MatchError("nftables call: banana and unlock: <nil>"))
+			})
+		})
+	})
+
+	Describe("Delete", func() {
+		BeforeEach(func() {
+			conn.ListRulesReturns([]nftables.Rule{
+				{Handle: 3, Comment: "--source 1.2.3.4 --jump MARK --set-xmark 0xA -m comment --comment src:a-guid"},
+			}, nil)
+		})
+
+		It("deletes the rule by handle", func() {
+			err := adapter.Delete("filter", "FORWARD", ruleSet[0])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conn.ApplyArgsForCall(0)).To(Equal([]nftables.Operation{
+				nftables.DeleteRule{Table: "filter", Chain: "FORWARD", Handle: 3},
+			}))
+		})
+
+		Context("when the rule does not exist", func() {
+			It("returns an error", func() {
+				err := adapter.Delete("filter", "FORWARD", rules.IPTablesRule{"--jump", "ACCEPT"})
+				Expect(err).To(MatchError("nftables call: rule not found in filter/FORWARD: --jump ACCEPT and unlock: <nil>"))
+				Expect(conn.ApplyCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("List", func() {
+		BeforeEach(func() {
+			conn.ChainExistsReturns(true, nil)
+			conn.ListRulesReturns([]nftables.Rule{
+				{Handle: 3, Comment: "-d 10.255.0.2 --jump ACCEPT"},
+			}, nil)
+		})
+
+		It("lists the chain and its rules like iptables -S", func() {
+			list, err := adapter.List("filter", "netout--some-handle")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list).To(Equal([]string{
+				"-N netout--some-handle",
+				"-A netout--some-handle -d 10.255.0.2 --jump ACCEPT",
+			}))
+		})
+
+		It("lists the policy of built-in chains", func() {
+			list, err := adapter.List("filter", "FORWARD")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list).To(Equal([]string{
+				"-P FORWARD ACCEPT",
+				"-A FORWARD -d 10.255.0.2 --jump ACCEPT",
+			}))
+		})
+
+		Context("when a built-in chain has not been created yet", func() {
+			It("lists only the policy", func() {
+				conn.ChainExistsReturns(false, nil)
+				list, err := adapter.List("nat", "OUTPUT")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(list).To(Equal([]string{"-P OUTPUT ACCEPT"}))
+				Expect(conn.ListRulesCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the chain does not exist", func() {
+			It("returns an error", func() {
+				conn.ChainExistsReturns(false, nil)
+				_, err := adapter.List("filter", "potato")
+				Expect(err).To(MatchError("nftables call: chain potato not found in table filter and unlock: <nil>"))
+			})
+		})
+	})
+
+	Describe("NewChain", func() {
+		It("adds the chain, failing if it exists", func() {
+			err := adapter.NewChain("filter", "some-chain")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conn.ApplyArgsForCall(0)).To(Equal([]nftables.Operation{
+				nftables.AddTable{Table: "filter"},
+				nftables.AddChain{Table: "filter", Chain: "some-chain", Exclusive: true},
+			}))
+		})
+	})
+
+	Describe("ClearChain", func() {
+		It("creates the chain if needed and flushes it", func() {
+			err := adapter.ClearChain("filter", "some-chain")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conn.ApplyArgsForCall(0)).To(Equal([]nftables.Operation{
+				nftables.AddTable{Table: "filter"},
+				nftables.AddChain{Table: "filter", Chain: "some-chain"},
+				nftables.FlushChain{Table: "filter", Chain: "some-chain"},
+			}))
+		})
+	})
+
+	Describe("DeleteChain", func() {
+		It("deletes the chain", func() {
+			err := adapter.DeleteChain("filter", "some-chain")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(conn.ApplyArgsForCall(0)).To(Equal([]nftables.Operation{
+				nftables.DeleteChain{Table: "filter", Chain: "some-chain"},
+			}))
+		})
+
+		Context("when it fails", func() {
+			It("returns an error", func() {
+				conn.ApplyReturns(errors.New("banana"))
+				err := adapter.DeleteChain("filter", "some-chain")
+				Expect(err).To(MatchError("nftables call: banana and unlock: <nil>"))
+			})
+		})
+	})
+
+	Describe("SyncChains", func() {
+		rule := func(ip string) rules.IPTablesRule {
+			return rules.IPTablesRule{"-d", ip, "--jump", "ACCEPT"}
+		}
+
+		BeforeEach(func() {
+			conn.ListRulesStub = func(table, chain string) ([]nftables.Rule, error) {
+				if chain != "some-chain" {
+					return []nftables.Rule{}, nil
+				}
+				return []nftables.Rule{
+					{Handle: 1, Comment: "-d 1.1.1.1 --jump ACCEPT"},
+					{Handle: 2, Comment: "-d 2.2.2.2 --jump ACCEPT"},
+					{Handle: 3, Comment: "-d 3.3.3.3 --jump ACCEPT"},
+				}, nil
+			}
+		})
+
+		It("adds and removes only the rules that differ in one batch", func() {
+			summaries, err := adapter.SyncChains("filter", map[string][]rules.IPTablesRule{
+				"some-chain": {rule("1.1.1.1"), rule("4.4.4.4"), rule("3.3.3.3"), rule("5.5.5.5")},
+				"FORWARD":    {rule("6.6.6.6")},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(conn.ApplyCallCount()).To(Equal(1))
+			ops := conn.ApplyArgsForCall(0)
+			Expect(ops[0]).To(Equal(nftables.AddTable{Table: "filter"}))
+			Expect(ops).To(ContainElement(nftables.DeleteRule{Table: "filter", Chain: "some-chain", Handle: 2}))
+
+			added := addRules(ops)
+			Expect(added).To(HaveLen(3))
+			Expect(added[0].Chain).To(Equal("FORWARD"))
+			Expect(added[1].Comment).To(Equal("-d 4.4.4.4 --jump ACCEPT"))
+			Expect(added[1].Prepend).To(BeTrue())
+			Expect(added[1].Position).To(Equal(uint64(3)))
+			Expect(added[2].Comment).To(Equal("-d 5.5.5.5 --jump ACCEPT"))
+			Expect(added[2].Prepend).To(BeFalse())
+			Expect(added[2].Position).To(BeZero())
+
+			Expect(summaries["some-chain"]).To(Equal(rules.SyncSummary{
+				Added:   []rules.IPTablesRule{rule("4.4.4.4"), rule("5.5.5.5")},
+				Removed: []rules.IPTablesRule{rule("2.2.2.2")},
+			}))
+			Expect(summaries["FORWARD"].Added).To(Equal([]rules.IPTablesRule{rule("6.6.6.6")}))
+		})
+
+		Context("when the chains are in sync", func() {
+			It("applies nothing", func() {
+				summary, err := adapter.SyncChain("filter", "some-chain", []rules.IPTablesRule{
+					rule("1.1.1.1"), rule("2.2.2.2"), rule("3.3.3.3"),
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(conn.ApplyCallCount()).To(Equal(0))
+				Expect(summary.Added).To(BeEmpty())
+				Expect(summary.Removed).To(BeEmpty())
+			})
+		})
+
+		Context("when listing the rules fails", func() {
+			It("returns an error", func() {
+				conn.ListRulesStub = nil
+				conn.ListRulesReturns(nil, errors.New("banana"))
+				_, err := adapter.SyncChain("filter", "some-chain", []rules.IPTablesRule{rule("1.1.1.1")})
+				Expect(err).To(MatchError("nftables call: banana and unlock: <nil>"))
+			})
+		})
+	})
+})
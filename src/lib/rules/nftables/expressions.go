@@ -0,0 +1,316 @@
+package nftables
+
+import "encoding/binary"
+
+// Expr is one nftables expression of a rule, such as loading a header field
+// into a register or comparing a register with a value.
+type Expr interface {
+	marshal() (string, []attribute)
+}
+
+const (
+	// RegVerdict holds the verdict of a rule; Reg1 and Reg2 hold data.
+	RegVerdict uint32 = 0
+	Reg1       uint32 = 1
+	Reg2       uint32 = 2
+)
+
+// Payload bases.
+const (
+	BaseNetworkHeader   uint32 = 1
+	BaseTransportHeader uint32 = 2
+)
+
+// Meta keys.
+const (
+	MetaMark    uint32 = 3
+	MetaIIFName uint32 = 6
+	MetaOIFName uint32 = 7
+	MetaSKUID   uint32 = 10
+	MetaL4Proto uint32 = 16
+)
+
+// Cmp and Range operators.
+const (
+	CmpEq  uint32 = 0
+	CmpNeq uint32 = 1
+)
+
+// Verdict codes.
+const (
+	VerdictDrop   int32 = 0
+	VerdictAccept int32 = 1
+	VerdictJump   int32 = -3
+	VerdictGoto   int32 = -4
+	VerdictReturn int32 = -5
+)
+
+// Conntrack states, as used by Ct with CtKeyState.
+const (
+	CtStateInvalid     uint32 = 1
+	CtStateEstablished uint32 = 2
+	CtStateRelated     uint32 = 4
+	CtStateNew         uint32 = 8
+	CtStateUntracked   uint32 = 64
+)
+
+// Ct keys. The keys of a connection's addresses and ports also need a
+// direction.
+const (
+	CtKeyState    uint32 = 0
+	CtKeyProtoDst uint32 = 12
+)
+
+// Fib results and flags, and the address types of the addrtype result.
+const (
+	FibResultAddrType uint32 = 3
+	FibFlagDaddr      uint32 = 2
+
+	AddrTypeLocal uint32 = 2
+)
+
+// Ct directions.
+const (
+	CtDirOriginal uint8 = 0
+)
+
+const (
+	NatTypeDNAT uint32 = 1
+
+	familyIPv4 uint32 = 2
+)
+
+// Payload loads Len bytes at Offset of a header into Register.
+type Payload struct {
+	Register uint32
+	Base     uint32
+	Offset   uint32
+	Len      uint32
+}
+
+func (e *Payload) marshal() (string, []attribute) {
+	return "payload", []attribute{
+		u32Attr(1, e.Register),
+		u32Attr(2, e.Base),
+		u32Attr(3, e.Offset),
+		u32Attr(4, e.Len),
+	}
+}
+
+// Meta loads packet metadata into Register, or sets it from Register when
+// Set is true.
+type Meta struct {
+	Key      uint32
+	Register uint32
+	Set      bool
+}
+
+func (e *Meta) marshal() (string, []attribute) {
+	if e.Set {
+		return "meta", []attribute{u32Attr(2, e.Key), u32Attr(3, e.Register)}
+	}
+	return "meta", []attribute{u32Attr(2, e.Key), u32Attr(1, e.Register)}
+}
+
+// Cmp compares Register with Data and stops evaluating the rule when the
+// comparison fails.
+type Cmp struct {
+	Op       uint32
+	Register uint32
+	Data     []byte
+}
+
+func (e *Cmp) marshal() (string, []attribute) {
+	return "cmp", []attribute{
+		u32Attr(1, e.Register),
+		u32Attr(2, e.Op),
+		nestedAttr(3, bytesAttr(1, e.Data)),
+	}
+}
+
+// Range checks that Register is between From and To, inclusive.
+type Range struct {
+	Op       uint32
+	Register uint32
+	From     []byte
+	To       []byte
+}
+
+func (e *Range) marshal() (string, []attribute) {
+	return "range", []attribute{
+		u32Attr(1, e.Register),
+		u32Attr(2, e.Op),
+		nestedAttr(3, bytesAttr(1, e.From)),
+		nestedAttr(4, bytesAttr(1, e.To)),
+	}
+}
+
+// Bitwise sets Register to (Register & Mask) ^ Xor.
+type Bitwise struct {
+	Register uint32
+	Len      uint32
+	Mask     []byte
+	Xor      []byte
+}
+
+func (e *Bitwise) marshal() (string, []attribute) {
+	return "bitwise", []attribute{
+		u32Attr(1, e.Register),
+		u32Attr(2, e.Register),
+		u32Attr(3, e.Len),
+		nestedAttr(4, bytesAttr(1, e.Mask)),
+		nestedAttr(5, bytesAttr(1, e.Xor)),
+	}
+}
+
+// Immediate loads Data into Register, or sets the verdict when Verdict is
+// not nil.
+type Immediate struct {
+	Register uint32
+	Data     []byte
+	Verdict  *Verdict
+}
+
+type Verdict struct {
+	Code  int32
+	Chain string
+}
+
+func (e *Immediate) marshal() (string, []attribute) {
+	if e.Verdict != nil {
+		verdict := []attribute{u32Attr(1, uint32(e.Verdict.Code))}
+		if e.Verdict.Chain != "" {
+			verdict = append(verdict, stringAttr(2, e.Verdict.Chain))
+		}
+		return "immediate", []attribute{
+			u32Attr(1, RegVerdict),
+			nestedAttr(2, nestedAttr(2, verdict...)),
+		}
+	}
+	return "immediate", []attribute{
+		u32Attr(1, e.Register),
+		nestedAttr(2, bytesAttr(1, e.Data)),
+	}
+}
+
+// Ct loads conntrack information into Register.
+type Ct struct {
+	Key       uint32
+	Register  uint32
+	Direction *uint8
+}
+
+func (e *Ct) marshal() (string, []attribute) {
+	attrs := []attribute{u32Attr(1, e.Register), u32Attr(2, e.Key)}
+	if e.Direction != nil {
+		attrs = append(attrs, bytesAttr(3, []byte{*e.Direction}))
+	}
+	return "ct", attrs
+}
+
+// Fib looks up the destination address of the packet and loads the Result
+// of the lookup into Register.
+type Fib struct {
+	Register uint32
+	Result   uint32
+	Flags    uint32
+}
+
+func (e *Fib) marshal() (string, []attribute) {
+	return "fib", []attribute{u32Attr(1, e.Register), u32Attr(2, e.Result), u32Attr(3, e.Flags)}
+}
+
+// Limit matches at most Rate packets per Unit seconds, with bursts of Burst.
+type Limit struct {
+	Rate  uint64
+	Unit  uint64
+	Burst uint32
+}
+
+func (e *Limit) marshal() (string, []attribute) {
+	return "limit", []attribute{
+		u64Attr(1, e.Rate),
+		u64Attr(2, e.Unit),
+		u32Attr(3, e.Burst),
+		u32Attr(4, 0),
+	}
+}
+
+// Log logs the packet to the kernel log with Prefix.
+type Log struct {
+	Prefix string
+}
+
+func (e *Log) marshal() (string, []attribute) {
+	return "log", []attribute{stringAttr(2, e.Prefix)}
+}
+
+// Reject rejects the packet with an ICMP unreachable of ICMPCode.
+type Reject struct {
+	ICMPCode uint8
+}
+
+func (e *Reject) marshal() (string, []attribute) {
+	return "reject", []attribute{u32Attr(1, 0), bytesAttr(2, []byte{e.ICMPCode})}
+}
+
+// Nat translates the destination to the address in AddressRegister and, if
+// ProtoRegister is set, the port in it.
+type Nat struct {
+	Type            uint32
+	AddressRegister uint32
+	ProtoRegister   uint32
+}
+
+func (e *Nat) marshal() (string, []attribute) {
+	attrs := []attribute{
+		u32Attr(1, e.Type),
+		u32Attr(2, familyIPv4),
+		u32Attr(3, e.AddressRegister),
+	}
+	if e.ProtoRegister != 0 {
+		attrs = append(attrs, u32Attr(5, e.ProtoRegister))
+	}
+	return "nat", attrs
+}
+
+// Masq masquerades the source address.
+type Masq struct{}
+
+func (e *Masq) marshal() (string, []attribute) {
+	return "masq", nil
+}
+
+// Redir redirects the packet to the local port in ProtoRegister.
+type Redir struct {
+	ProtoRegister uint32
+}
+
+func (e *Redir) marshal() (string, []attribute) {
+	return "redir", []attribute{u32Attr(1, e.ProtoRegister)}
+}
+
+func marshalExprs(exprs []Expr) attribute {
+	elements := []attribute{}
+	for _, expr := range exprs {
+		name, data := expr.marshal()
+		element := []attribute{stringAttr(1, name)}
+		if len(data) > 0 {
+			element = append(element, nestedAttr(2, data...))
+		}
+		elements = append(elements, nestedAttr(1, element...))
+	}
+	return nestedAttr(4, elements...)
+}
+
+func be16(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return b
+}
+
+func native32(v uint32) []byte {
+	b := make([]byte, 4)
+	nativeEndian.PutUint32(b, v)
+	return b
+}
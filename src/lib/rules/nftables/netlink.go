@@ -0,0 +1,354 @@
+package nftables
+
+import (
+	"encoding/binary"
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	subsysNFTables = 10
+
+	msgNewTable = 0
+	msgNewChain = 3
+	msgGetChain = 4
+	msgDelChain = 5
+	msgNewRule  = 6
+	msgGetRule  = 7
+	msgDelRule  = 8
+
+	msgBatchBegin = 0x10
+	msgBatchEnd   = 0x11
+
+	flagRequest = 0x1
+	flagAck     = 0x4
+	flagExcl    = 0x200
+	flagCreate  = 0x400
+	flagAppend  = 0x800
+	flagDump    = 0x300
+
+	attrNested = 0x8000
+
+	familyUnspec = 0
+	familyIP     = 2
+
+	receiveTimeout = 5 * time.Second
+)
+
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+type attribute struct {
+	typ    uint16
+	data   []byte
+	nested []attribute
+}
+
+func u32Attr(typ uint16, v uint32) attribute {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return attribute{typ: typ, data: b}
+}
+
+func u64Attr(typ uint16, v uint64) attribute {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return attribute{typ: typ, data: b}
+}
+
+func stringAttr(typ uint16, s string) attribute {
+	return attribute{typ: typ, data: append([]byte(s), 0)}
+}
+
+func bytesAttr(typ uint16, b []byte) attribute {
+	return attribute{typ: typ, data: b}
+}
+
+func nestedAttr(typ uint16, children ...attribute) attribute {
+	return attribute{typ: typ, nested: children}
+}
+
+func align4(n int) int {
+	return (n + 3) &^ 3
+}
+
+func encodeAttributes(attrs []attribute) []byte {
+	b := []byte{}
+	for _, attr := range attrs {
+		typ, data := attr.typ, attr.data
+		if attr.nested != nil {
+			typ |= attrNested
+			data = encodeAttributes(attr.nested)
+		}
+		header := make([]byte, 4)
+		nativeEndian.PutUint16(header[0:2], uint16(4+len(data)))
+		nativeEndian.PutUint16(header[2:4], typ)
+		b = append(b, header...)
+		b = append(b, data...)
+		b = append(b, make([]byte, align4(len(data))-len(data))...)
+	}
+	return b
+}
+
+// parseAttributes returns the attributes in b by type, without the nested
+// and byte order flags.
+func parseAttributes(b []byte) map[uint16][]byte {
+	attrs := map[uint16][]byte{}
+	for len(b) >= 4 {
+		length := int(nativeEndian.Uint16(b[0:2]))
+		typ := nativeEndian.Uint16(b[2:4]) & 0x3fff
+		if length < 4 || length > len(b) {
+			break
+		}
+		attrs[typ] = b[4:length]
+		if align4(length) >= len(b) {
+			break
+		}
+		b = b[align4(length):]
+	}
+	return attrs
+}
+
+type request struct {
+	msgType     uint16
+	flags       uint16
+	attrs       []attribute
+	description string
+}
+
+func encodeMessage(msgType, flags uint16, seq uint32, family uint8, resID uint16, attrs []attribute) []byte {
+	payload := encodeAttributes(attrs)
+	b := make([]byte, unix.SizeofNlMsghdr+4, unix.SizeofNlMsghdr+4+len(payload))
+	nativeEndian.PutUint32(b[0:4], uint32(len(b)+len(payload)))
+	nativeEndian.PutUint16(b[4:6], msgType)
+	nativeEndian.PutUint16(b[6:8], flags)
+	nativeEndian.PutUint32(b[8:12], seq)
+	b[16] = family
+	binary.BigEndian.PutUint16(b[18:20], resID)
+	return append(b, payload...)
+}
+
+// encodeBatch wraps the requests in a batch, which the kernel applies as one
+// transaction. Every request asks for an ack, with the sequence numbers 2 and
+// up.
+func encodeBatch(requests []request) []byte {
+	b := encodeMessage(msgBatchBegin, flagRequest, 1, familyUnspec, subsysNFTables, nil)
+	for i, r := range requests {
+		b = append(b, encodeMessage(subsysNFTables<<8|r.msgType, flagRequest|flagAck|r.flags, uint32(i+2), familyIP, 0, r.attrs)...)
+	}
+	return append(b, encodeMessage(msgBatchEnd, flagRequest, uint32(len(requests)+2), familyUnspec, subsysNFTables, nil)...)
+}
+
+// NetlinkConn talks to nf_tables over netlink. It opens a socket per call, so
+// that calls made inside a network namespace apply to that namespace.
+type NetlinkConn struct{}
+
+func (c *NetlinkConn) Apply(ops ...Operation) error {
+	if len(ops) == 0 {
+		return nil
+	}
+
+	requests := []request{}
+	for _, op := range ops {
+		requests = append(requests, op.request())
+	}
+
+	fd, err := openSocket()
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	if err := unix.Sendto(fd, encodeBatch(requests), 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return fmt.Errorf("send batch: %s", err)
+	}
+
+	acked := 0
+	for acked < len(requests) {
+		messages, err := receive(fd)
+		if err != nil {
+			return err
+		}
+		for _, m := range messages {
+			if m.Header.Type != unix.NLMSG_ERROR {
+				continue
+			}
+			if errno := messageErrno(m); errno != 0 {
+				return requestError(requests, m.Header.Seq, errno)
+			}
+			acked++
+		}
+	}
+	return nil
+}
+
+func (c *NetlinkConn) ListRules(table, chain string) ([]Rule, error) {
+	fd, err := openSocket()
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(fd)
+
+	msg := encodeMessage(subsysNFTables<<8|msgGetRule, flagRequest|flagDump, 1, familyIP, 0, []attribute{
+		stringAttr(1, table),
+		stringAttr(2, chain),
+	})
+	if err := unix.Sendto(fd, msg, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("send rule dump: %s", err)
+	}
+
+	rules := []Rule{}
+	for {
+		messages, err := receive(fd)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range messages {
+			switch m.Header.Type {
+			case unix.NLMSG_DONE:
+				return rules, nil
+			case unix.NLMSG_ERROR:
+				errno := messageErrno(m)
+				if errno == unix.ENOENT {
+					return []Rule{}, nil
+				}
+				return nil, fmt.Errorf("list rules of %s/%s: %s", table, chain, errno)
+			default:
+				if len(m.Data) < 4 {
+					continue
+				}
+				rule, ruleChain := decodeRule(parseAttributes(m.Data[4:]))
+				if ruleChain == chain {
+					rules = append(rules, rule)
+				}
+			}
+		}
+	}
+}
+
+func (c *NetlinkConn) ChainExists(table, chain string) (bool, error) {
+	fd, err := openSocket()
+	if err != nil {
+		return false, err
+	}
+	defer unix.Close(fd)
+
+	msg := encodeMessage(subsysNFTables<<8|msgGetChain, flagRequest, 1, familyIP, 0, []attribute{
+		stringAttr(1, table),
+		stringAttr(3, chain),
+	})
+	if err := unix.Sendto(fd, msg, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return false, fmt.Errorf("send chain lookup: %s", err)
+	}
+
+	messages, err := receive(fd)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range messages {
+		if m.Header.Type != unix.NLMSG_ERROR {
+			return true, nil
+		}
+		switch errno := messageErrno(m); errno {
+		case 0:
+			continue
+		case unix.ENOENT:
+			return false, nil
+		default:
+			return false, fmt.Errorf("look up chain %s/%s: %s", table, chain, errno)
+		}
+	}
+	return false, nil
+}
+
+func openSocket() (int, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_NETFILTER)
+	if err != nil {
+		return -1, fmt.Errorf("open netlink socket: %s", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		unix.Close(fd)
+		return -1, fmt.Errorf("bind netlink socket: %s", err)
+	}
+	timeout := unix.NsecToTimeval(receiveTimeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &timeout); err != nil {
+		unix.Close(fd)
+		return -1, fmt.Errorf("set netlink receive timeout: %s", err)
+	}
+	return fd, nil
+}
+
+func receive(fd int) ([]syscall.NetlinkMessage, error) {
+	b := make([]byte, 1<<16)
+	n, _, err := unix.Recvfrom(fd, b, 0)
+	if err != nil {
+		return nil, fmt.Errorf("receive from netlink: %s", err)
+	}
+	messages, err := syscall.ParseNetlinkMessage(b[:n])
+	if err != nil {
+		return nil, fmt.Errorf("parse netlink message: %s", err)
+	}
+	return messages, nil
+}
+
+func messageErrno(m syscall.NetlinkMessage) syscall.Errno {
+	if len(m.Data) < 4 {
+		return 0
+	}
+	return syscall.Errno(-int32(nativeEndian.Uint32(m.Data[0:4])))
+}
+
+func requestError(requests []request, seq uint32, errno syscall.Errno) error {
+	i := int(seq) - 2
+	if i < 0 || i >= len(requests) {
+		return fmt.Errorf("apply batch: %s", errno)
+	}
+	return fmt.Errorf("%s: %s", requests[i].description, errno)
+}
+
+func decodeRule(attrs map[uint16][]byte) (Rule, string) {
+	rule := Rule{}
+	if handle, ok := attrs[3]; ok && len(handle) == 8 {
+		rule.Handle = binary.BigEndian.Uint64(handle)
+	}
+	rule.Comment = decodeComment(attrs[7])
+	return rule, trimNull(attrs[2])
+}
+
+const userDataComment = 0
+
+func encodeComment(comment string) []byte {
+	return append([]byte{userDataComment, byte(len(comment) + 1)}, append([]byte(comment), 0)...)
+}
+
+func decodeComment(userData []byte) string {
+	for len(userData) >= 2 {
+		typ, length := userData[0], int(userData[1])
+		if 2+length > len(userData) {
+			break
+		}
+		if typ == userDataComment {
+			return trimNull(userData[2 : 2+length])
+		}
+		userData = userData[2+length:]
+	}
+	return ""
+}
+
+func trimNull(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,145 @@
+package nftables
+
+import "fmt"
+
+// Operation is one change in a batch applied by Apply.
+type Operation interface {
+	request() request
+}
+
+// Rule is a rule as stored in nf_tables. Rules added by the Adapter carry
+// their iptables rulespec as Comment.
+type Rule struct {
+	Handle  uint64
+	Comment string
+}
+
+// AddTable adds an ip table, unless it exists.
+type AddTable struct {
+	Table string
+}
+
+func (o AddTable) request() request {
+	return request{
+		msgType:     msgNewTable,
+		flags:       flagCreate,
+		attrs:       []attribute{stringAttr(1, o.Table)},
+		description: fmt.Sprintf("add table %s", o.Table),
+	}
+}
+
+// ChainHook attaches a base chain to a netfilter hook.
+type ChainHook struct {
+	Type     string
+	Hooknum  uint32
+	Priority int32
+}
+
+// AddChain adds a chain, which fails if it exists when Exclusive is true. A
+// chain with a Hook is a base chain with an accept policy.
+type AddChain struct {
+	Table     string
+	Chain     string
+	Hook      *ChainHook
+	Exclusive bool
+}
+
+func (o AddChain) request() request {
+	attrs := []attribute{stringAttr(1, o.Table), stringAttr(3, o.Chain)}
+	if o.Hook != nil {
+		attrs = append(attrs,
+			nestedAttr(4, u32Attr(1, o.Hook.Hooknum), u32Attr(2, uint32(o.Hook.Priority))),
+			u32Attr(5, uint32(VerdictAccept)),
+			stringAttr(7, o.Hook.Type),
+		)
+	}
+	flags := uint16(flagCreate)
+	if o.Exclusive {
+		flags |= flagExcl
+	}
+	return request{
+		msgType:     msgNewChain,
+		flags:       flags,
+		attrs:       attrs,
+		description: fmt.Sprintf("add chain %s/%s", o.Table, o.Chain),
+	}
+}
+
+// AddRule adds a rule at the end of the chain, or at the start when Prepend
+// is true. With a Position, it is added after the rule with that handle, or
+// before it when Prepend is true.
+type AddRule struct {
+	Table    string
+	Chain    string
+	Exprs    []Expr
+	Comment  string
+	Position uint64
+	Prepend  bool
+}
+
+func (o AddRule) request() request {
+	attrs := []attribute{
+		stringAttr(1, o.Table),
+		stringAttr(2, o.Chain),
+		marshalExprs(o.Exprs),
+	}
+	if o.Position != 0 {
+		attrs = append(attrs, u64Attr(6, o.Position))
+	}
+	if o.Comment != "" {
+		attrs = append(attrs, bytesAttr(7, encodeComment(o.Comment)))
+	}
+	flags := uint16(flagCreate)
+	if !o.Prepend {
+		flags |= flagAppend
+	}
+	return request{
+		msgType:     msgNewRule,
+		flags:       flags,
+		attrs:       attrs,
+		description: fmt.Sprintf("add rule to %s/%s", o.Table, o.Chain),
+	}
+}
+
+// DeleteRule deletes the rule with Handle.
+type DeleteRule struct {
+	Table  string
+	Chain  string
+	Handle uint64
+}
+
+func (o DeleteRule) request() request {
+	return request{
+		msgType:     msgDelRule,
+		attrs:       []attribute{stringAttr(1, o.Table), stringAttr(2, o.Chain), u64Attr(3, o.Handle)},
+		description: fmt.Sprintf("delete rule from %s/%s", o.Table, o.Chain),
+	}
+}
+
+// FlushChain deletes every rule of a chain.
+type FlushChain struct {
+	Table string
+	Chain string
+}
+
+func (o FlushChain) request() request {
+	return request{
+		msgType:     msgDelRule,
+		attrs:       []attribute{stringAttr(1, o.Table), stringAttr(2, o.Chain)},
+		description: fmt.Sprintf("flush chain %s/%s", o.Table, o.Chain),
+	}
+}
+
+// DeleteChain deletes an empty chain.
+type DeleteChain struct {
+	Table string
+	Chain string
+}
+
+func (o DeleteChain) request() request {
+	return request{
+		msgType:     msgDelChain,
+		attrs:       []attribute{stringAttr(1, o.Table), stringAttr(3, o.Chain)},
+		description: fmt.Sprintf("delete chain %s/%s", o.Table, o.Chain),
+	}
+}
@@ -0,0 +1,550 @@
+package nftables
+
+import (
+	"fmt"
+	"lib/rules"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var ctStates = map[string]uint32{
+	"INVALID":     CtStateInvalid,
+	"ESTABLISHED": CtStateEstablished,
+	"RELATED":     CtStateRelated,
+	"NEW":         CtStateNew,
+	"UNTRACKED":   CtStateUntracked,
+}
+
+var protocols = map[string]byte{
+	"icmp": 1,
+	"tcp":  6,
+	"udp":  17,
+}
+
+var rejectCodes = map[string]uint8{
+	"icmp-net-unreachable":   0,
+	"icmp-host-unreachable":  1,
+	"icmp-proto-unreachable": 2,
+	"icmp-port-unreachable":  3,
+	"icmp-net-prohibited":    9,
+	"icmp-host-prohibited":   10,
+	"icmp-admin-prohibited":  13,
+}
+
+var limitUnits = map[string]uint64{
+	"s": 1, "sec": 1, "second": 1,
+	"m": 60, "min": 60, "minute": 60,
+	"h": 3600, "hour": 3600,
+	"d": 86400, "day": 86400,
+}
+
+var addrTypes = map[string]uint32{
+	"LOCAL": AddrTypeLocal,
+}
+
+var matchModules = map[string]bool{
+	"addrtype":  true,
+	"comment":   true,
+	"conntrack": true,
+	"icmp":      true,
+	"iprange":   true,
+	"limit":     true,
+	"mark":      true,
+	"multiport": true,
+	"owner":     true,
+	"state":     true,
+	"tcp":       true,
+	"udp":       true,
+}
+
+type translator struct {
+	rule     rules.IPTablesRule
+	i        int
+	negate   bool
+	protocol string
+	exprs    []Expr
+	limit    *Limit
+	target   string
+	goTo     bool
+	params   map[string]string
+}
+
+// Translate returns the nftables expressions of an iptables rulespec. It
+// supports the matches and targets used by the rules package, the proxy
+// redirect and the net rules of container updates.
+func Translate(rule rules.IPTablesRule) ([]Expr, error) {
+	t := &translator{rule: rule, params: map[string]string{}}
+	for t.i < len(rule) {
+		option := rule[t.i]
+		t.i++
+		if option == "!" {
+			t.negate = true
+			continue
+		}
+		if err := t.option(option); err != nil {
+			return nil, err
+		}
+		t.negate = false
+	}
+	if t.negate {
+		return nil, fmt.Errorf("dangling '!'")
+	}
+
+	target, err := t.targetExprs()
+	if err != nil {
+		return nil, err
+	}
+	return append(t.exprs, target...), nil
+}
+
+func (t *translator) value(option string) (string, error) {
+	if t.i >= len(t.rule) {
+		return "", fmt.Errorf("option %s requires a value", option)
+	}
+	value := t.rule[t.i]
+	t.i++
+	return value, nil
+}
+
+func (t *translator) option(option string) error {
+	value, err := t.value(option)
+	if err != nil {
+		return err
+	}
+
+	switch option {
+	case "-s", "--source":
+		return t.address(12, value)
+	case "-d", "--destination":
+		return t.address(16, value)
+	case "-p", "--protocol":
+		return t.protocolMatch(value)
+	case "-i", "--in-interface":
+		t.interfaceMatch(MetaIIFName, value)
+	case "-o", "--out-interface":
+		t.interfaceMatch(MetaOIFName, value)
+	case "-m", "--match":
+		if !matchModules[value] {
+			return fmt.Errorf("unsupported match module %s", value)
+		}
+	case "--comment":
+	case "--sport", "--source-port":
+		return t.portMatch(0, value)
+	case "--dport", "--destination-port":
+		return t.portMatch(2, value)
+	case "--dports", "--destination-ports":
+		return t.multiportMatch(value)
+	case "--mark":
+		return t.markMatch(value)
+	case "--ctstate", "--state":
+		return t.stateMatch(value)
+	case "--ctorigdstport":
+		return t.origDstPortMatch(value)
+	case "--limit":
+		return t.limitMatch(value)
+	case "--limit-burst":
+		return t.limitBurst(value)
+	case "--dst-type":
+		return t.addrTypeMatch(value)
+	case "--src-range":
+		return t.addressRange(12, value)
+	case "--dst-range":
+		return t.addressRange(16, value)
+	case "--icmp-type":
+		return t.icmpMatch(value)
+	case "--uid-owner":
+		return t.uidMatch(value)
+	case "-j", "--jump":
+		t.target = value
+	case "-g", "--goto":
+		t.target, t.goTo = value, true
+	case "--set-mark", "--set-xmark", "--to-destination", "--log-prefix", "--reject-with", "--to-port", "--to-ports":
+		t.params[option] = value
+	default:
+		return fmt.Errorf("unsupported option %s", option)
+	}
+	return nil
+}
+
+func (t *translator) cmpOp() uint32 {
+	if t.negate {
+		return CmpNeq
+	}
+	return CmpEq
+}
+
+func (t *translator) address(offset uint32, value string) error {
+	if !strings.Contains(value, "/") {
+		value += "/32"
+	}
+	_, ipNet, err := net.ParseCIDR(value)
+	if err != nil || ipNet.IP.To4() == nil {
+		return fmt.Errorf("invalid address %s", value)
+	}
+	ones, _ := ipNet.Mask.Size()
+	if ones == 0 {
+		return nil
+	}
+
+	t.exprs = append(t.exprs, &Payload{Register: Reg1, Base: BaseNetworkHeader, Offset: offset, Len: 4})
+	if ones < 32 {
+		t.exprs = append(t.exprs, &Bitwise{Register: Reg1, Len: 4, Mask: []byte(ipNet.Mask), Xor: make([]byte, 4)})
+	}
+	t.exprs = append(t.exprs, &Cmp{Op: t.cmpOp(), Register: Reg1, Data: []byte(ipNet.IP.To4())})
+	return nil
+}
+
+func (t *translator) addressRange(offset uint32, value string) error {
+	parts := strings.Split(value, "-")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid address range %s", value)
+	}
+	from, to := net.ParseIP(parts[0]).To4(), net.ParseIP(parts[1]).To4()
+	if from == nil || to == nil {
+		return fmt.Errorf("invalid address range %s", value)
+	}
+
+	t.exprs = append(t.exprs,
+		&Payload{Register: Reg1, Base: BaseNetworkHeader, Offset: offset, Len: 4},
+		&Range{Op: t.cmpOp(), Register: Reg1, From: []byte(from), To: []byte(to)},
+	)
+	return nil
+}
+
+func (t *translator) addrTypeMatch(value string) error {
+	addrType, ok := addrTypes[value]
+	if !ok {
+		return fmt.Errorf("unsupported address type %s", value)
+	}
+
+	t.exprs = append(t.exprs,
+		&Fib{Register: Reg1, Result: FibResultAddrType, Flags: FibFlagDaddr},
+		&Cmp{Op: t.cmpOp(), Register: Reg1, Data: native32(addrType)},
+	)
+	return nil
+}
+
+func (t *translator) protocolMatch(value string) error {
+	number, ok := protocols[value]
+	if !ok {
+		return fmt.Errorf("unsupported protocol %s", value)
+	}
+	if !t.negate {
+		t.protocol = value
+	}
+
+	t.exprs = append(t.exprs,
+		&Meta{Key: MetaL4Proto, Register: Reg1},
+		&Cmp{Op: t.cmpOp(), Register: Reg1, Data: []byte{number}},
+	)
+	return nil
+}
+
+func (t *translator) interfaceMatch(key uint32, name string) {
+	t.exprs = append(t.exprs,
+		&Meta{Key: key, Register: Reg1},
+		&Cmp{Op: t.cmpOp(), Register: Reg1, Data: append([]byte(name), 0)},
+	)
+}
+
+func (t *translator) requireProtocol(option string, allowed ...string) error {
+	for _, protocol := range allowed {
+		if t.protocol == protocol {
+			return nil
+		}
+	}
+	return fmt.Errorf("option %s requires protocol %s", option, strings.Join(allowed, " or "))
+}
+
+func parsePort(value string) (uint16, error) {
+	port, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port %s", value)
+	}
+	return uint16(port), nil
+}
+
+func (t *translator) portMatch(offset uint32, value string) error {
+	if err := t.requireProtocol("port match", "tcp", "udp"); err != nil {
+		return err
+	}
+
+	bounds := strings.SplitN(value, ":", 2)
+	from, err := parsePort(bounds[0])
+	if err != nil {
+		return err
+	}
+	to := from
+	if len(bounds) == 2 {
+		if to, err = parsePort(bounds[1]); err != nil {
+			return err
+		}
+	}
+
+	t.exprs = append(t.exprs, &Payload{Register: Reg1, Base: BaseTransportHeader, Offset: offset, Len: 2})
+	if from == to {
+		t.exprs = append(t.exprs, &Cmp{Op: t.cmpOp(), Register: Reg1, Data: be16(from)})
+	} else {
+		t.exprs = append(t.exprs, &Range{Op: t.cmpOp(), Register: Reg1, From: be16(from), To: be16(to)})
+	}
+	return nil
+}
+
+func (t *translator) multiportMatch(value string) error {
+	if err := t.requireProtocol("--dports", "tcp", "udp"); err != nil {
+		return err
+	}
+	ports := strings.Split(value, ",")
+	if !t.negate && len(ports) > 1 {
+		return fmt.Errorf("multiport match of several ports is only supported negated")
+	}
+
+	t.exprs = append(t.exprs, &Payload{Register: Reg1, Base: BaseTransportHeader, Offset: 2, Len: 2})
+	for _, p := range ports {
+		port, err := parsePort(p)
+		if err != nil {
+			return err
+		}
+		t.exprs = append(t.exprs, &Cmp{Op: t.cmpOp(), Register: Reg1, Data: be16(port)})
+	}
+	return nil
+}
+
+func parseMark(value string) (uint32, uint32, error) {
+	parts := strings.SplitN(value, "/", 2)
+	mark, err := strconv.ParseUint(parts[0], 0, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid mark %s", value)
+	}
+	mask := uint64(0xffffffff)
+	if len(parts) == 2 {
+		if mask, err = strconv.ParseUint(parts[1], 0, 32); err != nil {
+			return 0, 0, fmt.Errorf("invalid mark %s", value)
+		}
+	}
+	return uint32(mark), uint32(mask), nil
+}
+
+func (t *translator) markMatch(value string) error {
+	mark, mask, err := parseMark(value)
+	if err != nil {
+		return err
+	}
+
+	t.exprs = append(t.exprs, &Meta{Key: MetaMark, Register: Reg1})
+	if mask != 0xffffffff {
+		t.exprs = append(t.exprs, &Bitwise{Register: Reg1, Len: 4, Mask: native32(mask), Xor: make([]byte, 4)})
+	}
+	t.exprs = append(t.exprs, &Cmp{Op: t.cmpOp(), Register: Reg1, Data: native32(mark)})
+	return nil
+}
+
+func (t *translator) stateMatch(value string) error {
+	states := uint32(0)
+	for _, name := range strings.Split(value, ",") {
+		state, ok := ctStates[name]
+		if !ok {
+			return fmt.Errorf("unsupported conntrack state %s", name)
+		}
+		states |= state
+	}
+
+	op := CmpNeq
+	if t.negate {
+		op = CmpEq
+	}
+	t.exprs = append(t.exprs,
+		&Ct{Key: CtKeyState, Register: Reg1},
+		&Bitwise{Register: Reg1, Len: 4, Mask: native32(states), Xor: make([]byte, 4)},
+		&Cmp{Op: op, Register: Reg1, Data: make([]byte, 4)},
+	)
+	return nil
+}
+
+func (t *translator) origDstPortMatch(value string) error {
+	port, err := parsePort(value)
+	if err != nil {
+		return err
+	}
+
+	direction := CtDirOriginal
+	t.exprs = append(t.exprs,
+		&Ct{Key: CtKeyProtoDst, Register: Reg1, Direction: &direction},
+		&Cmp{Op: t.cmpOp(), Register: Reg1, Data: be16(port)},
+	)
+	return nil
+}
+
+func (t *translator) limitMatch(value string) error {
+	parts := strings.SplitN(value, "/", 2)
+	rate, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil || len(parts) != 2 {
+		return fmt.Errorf("invalid limit %s", value)
+	}
+	unit, ok := limitUnits[parts[1]]
+	if !ok {
+		return fmt.Errorf("invalid limit %s", value)
+	}
+
+	t.limit = &Limit{Rate: rate, Unit: unit, Burst: 5}
+	t.exprs = append(t.exprs, t.limit)
+	return nil
+}
+
+func (t *translator) limitBurst(value string) error {
+	if t.limit == nil {
+		return fmt.Errorf("option --limit-burst requires --limit")
+	}
+	burst, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid limit burst %s", value)
+	}
+	t.limit.Burst = uint32(burst)
+	return nil
+}
+
+func (t *translator) icmpMatch(value string) error {
+	if err := t.requireProtocol("--icmp-type", "icmp"); err != nil {
+		return err
+	}
+
+	parts := strings.SplitN(value, "/", 2)
+	for offset, part := range parts {
+		number, err := strconv.ParseUint(part, 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid icmp type %s", value)
+		}
+		t.exprs = append(t.exprs,
+			&Payload{Register: Reg1, Base: BaseTransportHeader, Offset: uint32(offset), Len: 1},
+			&Cmp{Op: t.cmpOp(), Register: Reg1, Data: []byte{byte(number)}},
+		)
+	}
+	return nil
+}
+
+func (t *translator) uidMatch(value string) error {
+	uid, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid uid %s", value)
+	}
+
+	t.exprs = append(t.exprs,
+		&Meta{Key: MetaSKUID, Register: Reg1},
+		&Cmp{Op: t.cmpOp(), Register: Reg1, Data: native32(uint32(uid))},
+	)
+	return nil
+}
+
+func (t *translator) targetExprs() ([]Expr, error) {
+	switch t.target {
+	case "":
+		return nil, nil
+	case "ACCEPT":
+		return []Expr{&Immediate{Verdict: &Verdict{Code: VerdictAccept}}}, nil
+	case "DROP":
+		return []Expr{&Immediate{Verdict: &Verdict{Code: VerdictDrop}}}, nil
+	case "RETURN":
+		return []Expr{&Immediate{Verdict: &Verdict{Code: VerdictReturn}}}, nil
+	case "REJECT":
+		with := t.params["--reject-with"]
+		if with == "" {
+			with = "icmp-port-unreachable"
+		}
+		code, ok := rejectCodes[with]
+		if !ok {
+			return nil, fmt.Errorf("unsupported reject type %s", with)
+		}
+		return []Expr{&Reject{ICMPCode: code}}, nil
+	case "LOG":
+		return []Expr{&Log{Prefix: strings.Trim(t.params["--log-prefix"], `"`)}}, nil
+	case "MARK":
+		return t.markTarget()
+	case "DNAT":
+		return t.dnatTarget()
+	case "MASQUERADE":
+		return []Expr{&Masq{}}, nil
+	case "REDIRECT":
+		return t.redirectTarget()
+	}
+
+	if strings.ToUpper(t.target) == t.target && !strings.ContainsAny(t.target, "-_0123456789") {
+		return nil, fmt.Errorf("unsupported target %s", t.target)
+	}
+	code := VerdictJump
+	if t.goTo {
+		code = VerdictGoto
+	}
+	return []Expr{&Immediate{Verdict: &Verdict{Code: code, Chain: t.target}}}, nil
+}
+
+func (t *translator) markTarget() ([]Expr, error) {
+	value, ok := t.params["--set-xmark"]
+	if !ok {
+		value, ok = t.params["--set-mark"]
+	}
+	if !ok {
+		return nil, fmt.Errorf("target MARK requires --set-mark or --set-xmark")
+	}
+	mark, mask, err := parseMark(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if mask == 0xffffffff {
+		return []Expr{
+			&Immediate{Register: Reg1, Data: native32(mark)},
+			&Meta{Key: MetaMark, Register: Reg1, Set: true},
+		}, nil
+	}
+	return []Expr{
+		&Meta{Key: MetaMark, Register: Reg1},
+		&Bitwise{Register: Reg1, Len: 4, Mask: native32(^mask), Xor: native32(mark)},
+		&Meta{Key: MetaMark, Register: Reg1, Set: true},
+	}, nil
+}
+
+func (t *translator) dnatTarget() ([]Expr, error) {
+	destination := t.params["--to-destination"]
+	host, port := destination, ""
+	if strings.Contains(destination, ":") {
+		var err error
+		if host, port, err = net.SplitHostPort(destination); err != nil {
+			return nil, fmt.Errorf("invalid destination %s", destination)
+		}
+	}
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("invalid destination %s", destination)
+	}
+
+	exprs := []Expr{&Immediate{Register: Reg1, Data: []byte(ip)}}
+	nat := &Nat{Type: NatTypeDNAT, AddressRegister: Reg1}
+	if port != "" {
+		p, err := parsePort(port)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, &Immediate{Register: Reg2, Data: be16(p)})
+		nat.ProtoRegister = Reg2
+	}
+	return append(exprs, nat), nil
+}
+
+func (t *translator) redirectTarget() ([]Expr, error) {
+	value, ok := t.params["--to-port"]
+	if !ok {
+		value, ok = t.params["--to-ports"]
+	}
+	if !ok {
+		return nil, fmt.Errorf("target REDIRECT requires --to-port")
+	}
+	port, err := parsePort(value)
+	if err != nil {
+		return nil, err
+	}
+	return []Expr{
+		&Immediate{Register: Reg1, Data: be16(port)},
+		&Redir{ProtoRegister: Reg1},
+	}, nil
+}
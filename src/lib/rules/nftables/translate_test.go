@@ -0,0 +1,195 @@
+package nftables_test
+
+import (
+	"encoding/binary"
+	"lib/rules"
+	"lib/rules/nftables"
+	"unsafe"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Translate", func() {
+	It("translates every rule constructor", func() {
+		for _, rule := range []rules.IPTablesRule{
+			rules.NewPortForwardingRule(61000, 8080, "10.0.0.5", "10.255.0.2"),
+			rules.NewIngressMarkRule("eth0", 61000, "10.0.0.5", "ABCD"),
+			rules.NewMarkAllowRule("10.255.0.2", "tcp", 8080, 8080, "ABCD", "src-guid", "dst-guid"),
+			rules.NewMarkAllowLogRule("10.255.0.2", "tcp", 8080, 8081, "ABCD", "dst-guid", 10),
+			rules.NewMarkAllowLogRule("10.255.0.2", "udp", 8080, 8081, "ABCD", "dst-guid", 10),
+			rules.NewMarkSetRule("10.255.0.3", "ABCD", "app-guid"),
+			rules.NewDefaultEgressRule("10.255.0.0/24", "silk-vtep"),
+			rules.NewLogRule(rules.IPTablesRule{"-d", "10.0.0.1"}, "some-log"),
+			rules.NewAcceptExistingLocalRule(),
+			rules.NewLogLocalRejectRule("10.255.0.0/16"),
+			rules.NewDefaultDenyLocalRule("10.255.0.0/16"),
+			rules.NewNetOutRule("1.1.1.1", "2.2.2.2"),
+			rules.NewNetOutWithPortsRule("1.1.1.1", "2.2.2.2", 80, 90, "udp"),
+			rules.NewNetOutICMPRule("1.1.1.1", "2.2.2.2", 8, 0),
+			rules.NewNetOutICMPLogRule("1.1.1.1", "2.2.2.2", 8, 0, "netout--log"),
+			rules.NewNetOutLogRule("1.1.1.1", "2.2.2.2", "netout--log"),
+			rules.NewNetOutWithPortsLogRule("1.1.1.1", "2.2.2.2", 80, 90, "tcp", "netout--log"),
+			rules.NewNetOutDefaultNonUDPLogRule("some-prefix"),
+			rules.NewNetOutDefaultUDPLogRule("some-prefix", 10),
+			rules.NewAcceptRule(),
+			rules.NewInputRelatedEstablishedRule(),
+			rules.NewInputAllowRule("tcp", "10.0.0.1", 53),
+			rules.NewInputDefaultRejectRule(),
+			rules.NewNetOutRelatedEstablishedRule(),
+			rules.NewOverlayTagAcceptRule("10.255.0.2", "ABCD"),
+			rules.NewOverlayDefaultRejectRule("10.255.0.2"),
+			rules.NewOverlayDefaultRejectLogRule("some-handle", "10.255.0.2", 3),
+			rules.NewOverlayAllowEgress("silk-vtep", "10.255.0.2"),
+			rules.NewOverlayRelatedEstablishedRule("10.255.0.2"),
+			rules.NewNetOutDefaultRejectLogRule("some-handle", 3),
+			rules.NewNetOutDefaultRejectRule(),
+		} {
+			exprs, err := nftables.Translate(rule)
+			Expect(err).NotTo(HaveOccurred(), "%v", rule)
+			Expect(exprs).NotTo(BeEmpty(), "%v", rule)
+		}
+	})
+
+	It("translates a mark allow rule", func() {
+		exprs, err := nftables.Translate(rules.NewMarkAllowRule("10.255.0.2", "tcp", 8080, 8090, "ABCD", "src-guid", "dst-guid"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exprs).To(Equal([]nftables.Expr{
+			&nftables.Payload{Register: nftables.Reg1, Base: nftables.BaseNetworkHeader, Offset: 16, Len: 4},
+			&nftables.Cmp{Op: nftables.CmpEq, Register: nftables.Reg1, Data: []byte{10, 255, 0, 2}},
+			&nftables.Meta{Key: nftables.MetaL4Proto, Register: nftables.Reg1},
+			&nftables.Cmp{Op: nftables.CmpEq, Register: nftables.Reg1, Data: []byte{6}},
+			&nftables.Payload{Register: nftables.Reg1, Base: nftables.BaseTransportHeader, Offset: 2, Len: 2},
+			&nftables.Range{Op: nftables.CmpEq, Register: nftables.Reg1, From: []byte{0x1f, 0x90}, To: []byte{0x1f, 0x9a}},
+			&nftables.Meta{Key: nftables.MetaMark, Register: nftables.Reg1},
+			&nftables.Cmp{Op: nftables.CmpEq, Register: nftables.Reg1, Data: native32(0xABCD)},
+			&nftables.Immediate{Verdict: &nftables.Verdict{Code: nftables.VerdictAccept}},
+		}))
+	})
+
+	It("negates the option after '!'", func() {
+		exprs, err := nftables.Translate(rules.NewDefaultEgressRule("10.255.0.0/24", "silk-vtep"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exprs).To(Equal([]nftables.Expr{
+			&nftables.Payload{Register: nftables.Reg1, Base: nftables.BaseNetworkHeader, Offset: 12, Len: 4},
+			&nftables.Bitwise{Register: nftables.Reg1, Len: 4, Mask: []byte{255, 255, 255, 0}, Xor: []byte{0, 0, 0, 0}},
+			&nftables.Cmp{Op: nftables.CmpEq, Register: nftables.Reg1, Data: []byte{10, 255, 0, 0}},
+			&nftables.Meta{Key: nftables.MetaOIFName, Register: nftables.Reg1},
+			&nftables.Cmp{Op: nftables.CmpNeq, Register: nftables.Reg1, Data: []byte("silk-vtep\x00")},
+			&nftables.Masq{},
+		}))
+	})
+
+	It("translates conntrack states, limits and log prefixes", func() {
+		exprs, err := nftables.Translate(rules.IPTablesRule{
+			"-m", "conntrack", "--ctstate", "INVALID,NEW,UNTRACKED",
+			"-m", "limit", "--limit", "2/min", "--limit-burst", "7",
+			"-j", "LOG", "--log-prefix", `"OK_some-prefix "`,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exprs).To(Equal([]nftables.Expr{
+			&nftables.Ct{Key: nftables.CtKeyState, Register: nftables.Reg1},
+			&nftables.Bitwise{Register: nftables.Reg1, Len: 4, Mask: native32(nftables.CtStateInvalid | nftables.CtStateNew | nftables.CtStateUntracked), Xor: []byte{0, 0, 0, 0}},
+			&nftables.Cmp{Op: nftables.CmpNeq, Register: nftables.Reg1, Data: []byte{0, 0, 0, 0}},
+			&nftables.Limit{Rate: 2, Unit: 60, Burst: 7},
+			&nftables.Log{Prefix: "OK_some-prefix "},
+		}))
+	})
+
+	It("translates original destination port matches", func() {
+		exprs, err := nftables.Translate(rules.IPTablesRule{
+			"-p", "tcp", "-m", "conntrack", "--ctorigdstport", "61000", "-j", "ACCEPT",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		original := nftables.CtDirOriginal
+		Expect(exprs[2:4]).To(Equal([]nftables.Expr{
+			&nftables.Ct{Key: nftables.CtKeyProtoDst, Register: nftables.Reg1, Direction: &original},
+			&nftables.Cmp{Op: nftables.CmpEq, Register: nftables.Reg1, Data: []byte{0xee, 0x48}},
+		}))
+	})
+
+	It("translates local destination matches", func() {
+		exprs, err := nftables.Translate(rules.IPTablesRule{
+			"-m", "addrtype", "--dst-type", "LOCAL", "-j", "ACCEPT",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exprs[:2]).To(Equal([]nftables.Expr{
+			&nftables.Fib{Register: nftables.Reg1, Result: nftables.FibResultAddrType, Flags: nftables.FibFlagDaddr},
+			&nftables.Cmp{Op: nftables.CmpEq, Register: nftables.Reg1, Data: native32(nftables.AddrTypeLocal)},
+		}))
+	})
+
+	It("translates port forwarding to dnat", func() {
+		exprs, err := nftables.Translate(rules.NewPortForwardingRule(61000, 8080, "10.0.0.5", "10.255.0.2"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exprs[len(exprs)-3:]).To(Equal([]nftables.Expr{
+			&nftables.Immediate{Register: nftables.Reg1, Data: []byte{10, 255, 0, 2}},
+			&nftables.Immediate{Register: nftables.Reg2, Data: []byte{0x1f, 0x90}},
+			&nftables.Nat{Type: nftables.NatTypeDNAT, AddressRegister: nftables.Reg1, ProtoRegister: nftables.Reg2},
+		}))
+	})
+
+	It("translates icmp types and gotos", func() {
+		exprs, err := nftables.Translate(rules.NewNetOutICMPLogRule("1.1.1.1", "2.2.2.2", 8, 0, "netout--log"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exprs).To(Equal([]nftables.Expr{
+			&nftables.Meta{Key: nftables.MetaL4Proto, Register: nftables.Reg1},
+			&nftables.Cmp{Op: nftables.CmpEq, Register: nftables.Reg1, Data: []byte{1}},
+			&nftables.Payload{Register: nftables.Reg1, Base: nftables.BaseNetworkHeader, Offset: 16, Len: 4},
+			&nftables.Range{Op: nftables.CmpEq, Register: nftables.Reg1, From: []byte{1, 1, 1, 1}, To: []byte{2, 2, 2, 2}},
+			&nftables.Payload{Register: nftables.Reg1, Base: nftables.BaseTransportHeader, Offset: 0, Len: 1},
+			&nftables.Cmp{Op: nftables.CmpEq, Register: nftables.Reg1, Data: []byte{8}},
+			&nftables.Payload{Register: nftables.Reg1, Base: nftables.BaseTransportHeader, Offset: 1, Len: 1},
+			&nftables.Cmp{Op: nftables.CmpEq, Register: nftables.Reg1, Data: []byte{0}},
+			&nftables.Immediate{Verdict: &nftables.Verdict{Code: nftables.VerdictGoto, Chain: "netout--log"}},
+		}))
+	})
+
+	It("translates the proxy redirect rules", func() {
+		exprs, err := nftables.Translate(rules.IPTablesRule{
+			"-d", "0.0.0.0/0", "-p", "tcp",
+			"-m", "owner", "!", "--uid-owner", "1337",
+			"-m", "multiport", "!", "--dports", "22,8080",
+			"-j", "REDIRECT", "--to-port", "15001",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exprs).To(Equal([]nftables.Expr{
+			&nftables.Meta{Key: nftables.MetaL4Proto, Register: nftables.Reg1},
+			&nftables.Cmp{Op: nftables.CmpEq, Register: nftables.Reg1, Data: []byte{6}},
+			&nftables.Meta{Key: nftables.MetaSKUID, Register: nftables.Reg1},
+			&nftables.Cmp{Op: nftables.CmpNeq, Register: nftables.Reg1, Data: native32(1337)},
+			&nftables.Payload{Register: nftables.Reg1, Base: nftables.BaseTransportHeader, Offset: 2, Len: 2},
+			&nftables.Cmp{Op: nftables.CmpNeq, Register: nftables.Reg1, Data: []byte{0, 22}},
+			&nftables.Cmp{Op: nftables.CmpNeq, Register: nftables.Reg1, Data: []byte{0x1f, 0x90}},
+			&nftables.Immediate{Register: nftables.Reg1, Data: []byte{0x3a, 0x99}},
+			&nftables.Redir{ProtoRegister: nftables.Reg1},
+		}))
+	})
+
+	DescribeTable("unsupported rules",
+		func(rule rules.IPTablesRule, message string) {
+			_, err := nftables.Translate(rule)
+			Expect(err).To(MatchError(message))
+		},
+		Entry("unknown option", rules.IPTablesRule{"--potato", "1"}, "unsupported option --potato"),
+		Entry("unknown module", rules.IPTablesRule{"-m", "potato"}, "unsupported match module potato"),
+		Entry("missing value", rules.IPTablesRule{"-d"}, "option -d requires a value"),
+		Entry("dangling negation", rules.IPTablesRule{"-d", "1.2.3.4", "!"}, "dangling '!'"),
+		Entry("port without protocol", rules.IPTablesRule{"--dport", "80"}, "option port match requires protocol tcp or udp"),
+		Entry("invalid address", rules.IPTablesRule{"-d", "potato"}, "invalid address potato/32"),
+		Entry("unknown target", rules.IPTablesRule{"-j", "TPROXY"}, "unsupported target TPROXY"),
+		Entry("mark without value", rules.IPTablesRule{"-j", "MARK"}, "target MARK requires --set-mark or --set-xmark"),
+	)
+})
+
+func native32(v uint32) []byte {
+	b := make([]byte, 4)
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		binary.LittleEndian.PutUint32(b, v)
+	} else {
+		binary.BigEndian.PutUint32(b, v)
+	}
+	return b
+}
@@ -0,0 +1,513 @@
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxCommentLength   = 256
+	maxLogPrefixLength = 29
+	maxInterfaceLength = 15
+	maxMultiportPorts  = 15
+)
+
+// Match is one match condition of a rule. Module is the match module it
+// belongs to, empty for the address, interface and protocol options.
+type Match struct {
+	Module  string
+	Option  string
+	Value   string
+	Negated bool
+}
+
+// PortRange is an inclusive range of ports.
+type PortRange struct {
+	Start int
+	End   int
+}
+
+// Mark is a packet mark and the bits of it that count.
+type Mark struct {
+	Value uint32
+	Mask  uint32
+}
+
+// ParsedRule is the structured form of an IPTablesRule. Options are stored
+// under their short names, e.g. -s for --source, and values without quotes.
+type ParsedRule struct {
+	Matches       []Match
+	Modules       []string
+	Target        string
+	Goto          bool
+	TargetOptions map[string]string
+
+	Protocol         string
+	SourcePorts      []PortRange
+	DestinationPorts []PortRange
+	Mark             *Mark
+	SetMark          *Mark
+	LogPrefix        string
+	Comment          string
+}
+
+var baseOptions = map[string]string{
+	"-s": "-s", "--source": "-s",
+	"-d": "-d", "--destination": "-d",
+	"-i": "-i", "--in-interface": "-i",
+	"-o": "-o", "--out-interface": "-o",
+	"-p": "-p", "--protocol": "-p",
+}
+
+type matchOption struct {
+	module string
+	option string
+}
+
+// matchOptions maps the options of the supported match modules to their
+// module and short name. Port options belong to the protocol's module.
+var matchOptions = map[string]matchOption{
+	"--sport":             {"", "--sport"},
+	"--source-port":       {"", "--sport"},
+	"--dport":             {"", "--dport"},
+	"--destination-port":  {"", "--dport"},
+	"--dports":            {"multiport", "--dports"},
+	"--destination-ports": {"multiport", "--dports"},
+	"--mark":              {"mark", "--mark"},
+	"--ctstate":           {"conntrack", "--ctstate"},
+	"--ctorigdstport":     {"conntrack", "--ctorigdstport"},
+	"--state":             {"state", "--state"},
+	"--limit":             {"limit", "--limit"},
+	"--limit-burst":       {"limit", "--limit-burst"},
+	"--dst-type":          {"addrtype", "--dst-type"},
+	"--src-range":         {"iprange", "--src-range"},
+	"--dst-range":         {"iprange", "--dst-range"},
+	"--icmp-type":         {"icmp", "--icmp-type"},
+	"--uid-owner":         {"owner", "--uid-owner"},
+	"--comment":           {"comment", "--comment"},
+}
+
+// targetOptions maps the options of the supported targets to the target
+// they belong to and their short name.
+var targetOptions = map[string][2]string{
+	"--set-mark":       {"MARK", "--set-mark"},
+	"--set-xmark":      {"MARK", "--set-xmark"},
+	"--to-destination": {"DNAT", "--to-destination"},
+	"--log-prefix":     {"LOG", "--log-prefix"},
+	"--reject-with":    {"REJECT", "--reject-with"},
+	"--to-port":        {"REDIRECT", "--to-ports"},
+	"--to-ports":       {"REDIRECT", "--to-ports"},
+}
+
+var protocolNames = map[string]bool{"tcp": true, "udp": true, "icmp": true, "all": true}
+
+var stateOrder = []string{"INVALID", "NEW", "RELATED", "ESTABLISHED", "UNTRACKED"}
+
+var rejectTypes = map[string]bool{
+	"icmp-net-unreachable":   true,
+	"icmp-host-unreachable":  true,
+	"icmp-proto-unreachable": true,
+	"icmp-port-unreachable":  true,
+	"icmp-net-prohibited":    true,
+	"icmp-host-prohibited":   true,
+	"icmp-admin-prohibited":  true,
+}
+
+func invalidOption(option, format string, args ...interface{}) error {
+	return fmt.Errorf("invalid option '%s': %s", option, fmt.Sprintf(format, args...))
+}
+
+// Parse returns the structured form of a rule, or an error describing the
+// first part of it that iptables would reject or that this package does not
+// know.
+func Parse(rule IPTablesRule) (*ParsedRule, error) {
+	p := &ParsedRule{TargetOptions: map[string]string{}}
+	negate := false
+	for i := 0; i < len(rule); i++ {
+		option := rule[i]
+		if option == "!" {
+			if negate {
+				return nil, fmt.Errorf("double negation")
+			}
+			negate = true
+			continue
+		}
+		if i+1 >= len(rule) {
+			return nil, invalidOption(option, "missing value")
+		}
+		i++
+		value := unquote(rule[i])
+
+		if err := p.add(option, value, negate); err != nil {
+			return nil, err
+		}
+		negate = false
+	}
+	if negate {
+		return nil, fmt.Errorf("negation without an option")
+	}
+	if err := p.validateTarget(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Validate returns an error if the rule is malformed or uses an option this
+// package does not know. The adapters do not call it, so callers can check
+// the rules they build before writing them.
+func (r IPTablesRule) Validate() error {
+	_, err := Parse(r)
+	return err
+}
+
+// Describe returns a one-line English description of the rule.
+func (r IPTablesRule) Describe() string {
+	p, err := Parse(r)
+	if err != nil {
+		return fmt.Sprintf("invalid rule (%s): %s", err, strings.Join(r, " "))
+	}
+	return p.Description()
+}
+
+func (p *ParsedRule) add(option, value string, negate bool) error {
+	if short, ok := baseOptions[option]; ok {
+		if err := validateBase(short, value); err != nil {
+			return err
+		}
+		if short == "-p" {
+			value = strings.ToLower(value)
+			if !negate {
+				p.Protocol = value
+			}
+		}
+		p.Matches = append(p.Matches, Match{Option: short, Value: value, Negated: negate})
+		return nil
+	}
+
+	switch option {
+	case "-m", "--match":
+		if negate {
+			return invalidOption(option, "cannot be negated")
+		}
+		p.loadModule(value)
+		return nil
+	case "-j", "--jump", "-g", "--goto":
+		if negate {
+			return invalidOption(option, "cannot be negated")
+		}
+		if p.Target != "" {
+			return invalidOption(option, "target %s already set", p.Target)
+		}
+		p.Target, p.Goto = value, option == "-g" || option == "--goto"
+		return nil
+	}
+
+	if target, ok := targetOptions[option]; ok {
+		if negate {
+			return invalidOption(option, "cannot be negated")
+		}
+		if err := p.addTargetOption(target[1], value); err != nil {
+			return err
+		}
+		p.TargetOptions[target[1]] = value
+		return nil
+	}
+
+	m, ok := matchOptions[option]
+	if !ok {
+		return fmt.Errorf("unsupported option '%s'", option)
+	}
+	module := m.module
+	if module == "" {
+		if p.Protocol != "tcp" && p.Protocol != "udp" {
+			return invalidOption(m.option, "requires protocol tcp or udp")
+		}
+		module = p.Protocol
+	}
+	if m.option == "--dports" && p.Protocol != "tcp" && p.Protocol != "udp" {
+		return invalidOption(m.option, "requires protocol tcp or udp")
+	}
+	if m.option == "--icmp-type" && p.Protocol != "icmp" {
+		return invalidOption(m.option, "requires protocol icmp")
+	}
+	if err := p.addMatchOption(m.option, value, negate); err != nil {
+		return err
+	}
+	p.loadModule(module)
+	p.Matches = append(p.Matches, Match{Module: module, Option: m.option, Value: value, Negated: negate})
+	return nil
+}
+
+func (p *ParsedRule) loadModule(module string) {
+	for _, loaded := range p.Modules {
+		if loaded == module {
+			return
+		}
+	}
+	p.Modules = append(p.Modules, module)
+}
+
+func validateBase(option, value string) error {
+	switch option {
+	case "-s", "-d":
+		if _, err := parseAddress(value); err != nil {
+			return invalidOption(option, "%s", err)
+		}
+	case "-i", "-o":
+		if value == "" || len(value) > maxInterfaceLength {
+			return invalidOption(option, "interface name '%s' must be 1 to %d characters", value, maxInterfaceLength)
+		}
+	case "-p":
+		if !protocolNames[strings.ToLower(value)] {
+			return invalidOption(option, "unsupported protocol '%s'", value)
+		}
+	}
+	return nil
+}
+
+func (p *ParsedRule) addMatchOption(option, value string, negate bool) error {
+	switch option {
+	case "--sport", "--dport":
+		portRange, err := parsePortRange(value)
+		if err != nil {
+			return invalidOption(option, "%s", err)
+		}
+		if option == "--sport" {
+			p.SourcePorts = append(p.SourcePorts, portRange)
+		} else {
+			p.DestinationPorts = append(p.DestinationPorts, portRange)
+		}
+	case "--dports":
+		ports := strings.Split(value, ",")
+		if len(ports) > maxMultiportPorts {
+			return invalidOption(option, "at most %d ports can be listed", maxMultiportPorts)
+		}
+		for _, port := range ports {
+			portRange, err := parsePortRange(port)
+			if err != nil {
+				return invalidOption(option, "%s", err)
+			}
+			p.DestinationPorts = append(p.DestinationPorts, portRange)
+		}
+	case "--mark":
+		mark, err := parseMark(value)
+		if err != nil {
+			return invalidOption(option, "%s", err)
+		}
+		p.Mark = &mark
+	case "--ctorigdstport":
+		if port, err := strconv.Atoi(value); err != nil || port < 0 || port > 65535 {
+			return invalidOption(option, "'%s' is not a port", value)
+		}
+	case "--ctstate", "--state":
+		for _, state := range strings.Split(value, ",") {
+			if !containsString(stateOrder, state) {
+				return invalidOption(option, "unknown state '%s'", state)
+			}
+		}
+	case "--limit":
+		if _, _, err := parseRate(value); err != nil {
+			return invalidOption(option, "%s", err)
+		}
+	case "--limit-burst":
+		burst, err := strconv.Atoi(value)
+		if err != nil || burst < 1 || burst > 10000 {
+			return invalidOption(option, "burst '%s' must be between 1 and 10000", value)
+		}
+	case "--src-range", "--dst-range":
+		if err := validateAddressRange(value); err != nil {
+			return invalidOption(option, "%s", err)
+		}
+	case "--icmp-type":
+		for _, part := range strings.SplitN(value, "/", 2) {
+			if n, err := strconv.Atoi(part); err != nil || n < 0 || n > 255 {
+				return invalidOption(option, "'%s' is not a numeric icmp type or type/code", value)
+			}
+		}
+	case "--uid-owner":
+		if value == "" {
+			return invalidOption(option, "missing uid")
+		}
+	case "--comment":
+		if len(value) >= maxCommentLength {
+			return invalidOption(option, "comment is longer than %d characters", maxCommentLength-1)
+		}
+		p.Comment = value
+	}
+	return nil
+}
+
+func (p *ParsedRule) addTargetOption(option, value string) error {
+	switch option {
+	case "--set-mark", "--set-xmark":
+		mark, err := parseMark(value)
+		if err != nil {
+			return invalidOption(option, "%s", err)
+		}
+		p.SetMark = &mark
+	case "--log-prefix":
+		if len(value) > maxLogPrefixLength {
+			return invalidOption(option, "prefix '%s' is longer than %d characters", value, maxLogPrefixLength)
+		}
+		p.LogPrefix = value
+	case "--reject-with":
+		if !rejectTypes[value] {
+			return invalidOption(option, "unsupported reject type '%s'", value)
+		}
+	case "--to-destination":
+		host, port := value, ""
+		if strings.Contains(value, ":") {
+			var err error
+			if host, port, err = net.SplitHostPort(value); err != nil {
+				return invalidOption(option, "'%s' is not ip or ip:port", value)
+			}
+		}
+		if net.ParseIP(host).To4() == nil {
+			return invalidOption(option, "'%s' is not ip or ip:port", value)
+		}
+		if port != "" {
+			if _, err := parsePortRange(strings.Replace(port, "-", ":", 1)); err != nil {
+				return invalidOption(option, "%s", err)
+			}
+		}
+	case "--to-ports":
+		if _, err := parsePortRange(strings.Replace(value, "-", ":", 1)); err != nil {
+			return invalidOption(option, "%s", err)
+		}
+	}
+	return nil
+}
+
+func (p *ParsedRule) validateTarget() error {
+	for option := range p.TargetOptions {
+		target := ""
+		for _, t := range targetOptions {
+			if t[1] == option {
+				target = t[0]
+			}
+		}
+		if p.Target != target {
+			return invalidOption(option, "requires target %s", target)
+		}
+	}
+	switch p.Target {
+	case "MARK":
+		if p.SetMark == nil {
+			return fmt.Errorf("target MARK requires --set-mark or --set-xmark")
+		}
+	case "DNAT":
+		if _, ok := p.TargetOptions["--to-destination"]; !ok {
+			return fmt.Errorf("target DNAT requires --to-destination")
+		}
+	}
+	return nil
+}
+
+func parseAddress(value string) (*net.IPNet, error) {
+	cidr := value
+	if !strings.Contains(cidr, "/") {
+		cidr += "/32"
+	}
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil || ipNet.IP.To4() == nil {
+		return nil, fmt.Errorf("'%s' is not an ipv4 address or cidr", value)
+	}
+	return ipNet, nil
+}
+
+func validateAddressRange(value string) error {
+	bounds := strings.Split(value, "-")
+	if len(bounds) != 2 {
+		return fmt.Errorf("'%s' is not an address range", value)
+	}
+	start, end := net.ParseIP(bounds[0]).To4(), net.ParseIP(bounds[1]).To4()
+	if start == nil || end == nil {
+		return fmt.Errorf("'%s' is not an address range", value)
+	}
+	if bytes.Compare(start, end) > 0 {
+		return fmt.Errorf("address range '%s' ends before it starts", value)
+	}
+	return nil
+}
+
+func parsePortRange(value string) (PortRange, error) {
+	bounds := strings.SplitN(value, ":", 2)
+	start, err := strconv.Atoi(bounds[0])
+	if err != nil || start < 0 || start > 65535 {
+		return PortRange{}, fmt.Errorf("'%s' is not a port or port range", value)
+	}
+	end := start
+	if len(bounds) == 2 {
+		end, err = strconv.Atoi(bounds[1])
+		if err != nil || end < 0 || end > 65535 {
+			return PortRange{}, fmt.Errorf("'%s' is not a port or port range", value)
+		}
+	}
+	if start > end {
+		return PortRange{}, fmt.Errorf("port range '%s' ends before it starts", value)
+	}
+	return PortRange{Start: start, End: end}, nil
+}
+
+func parseMark(value string) (Mark, error) {
+	parts := strings.SplitN(value, "/", 2)
+	mark, err := strconv.ParseUint(parts[0], 0, 32)
+	if err != nil {
+		return Mark{}, fmt.Errorf("'%s' is not a 32-bit mark", value)
+	}
+	mask := uint64(0xffffffff)
+	if len(parts) == 2 {
+		if mask, err = strconv.ParseUint(parts[1], 0, 32); err != nil {
+			return Mark{}, fmt.Errorf("'%s' is not a 32-bit mark", value)
+		}
+	}
+	return Mark{Value: uint32(mark), Mask: uint32(mask)}, nil
+}
+
+var rateUnits = map[string]uint64{
+	"s": 1, "sec": 1, "second": 1,
+	"m": 60, "min": 60, "minute": 60,
+	"h": 3600, "hour": 3600,
+	"d": 86400, "day": 86400,
+}
+
+// parseRate returns a rate given as N or N/unit as N per a number of
+// seconds.
+func parseRate(value string) (uint64, uint64, error) {
+	parts := strings.SplitN(value, "/", 2)
+	rate, err := strconv.ParseUint(parts[0], 10, 32)
+	seconds, ok := uint64(1), true
+	if len(parts) == 2 {
+		seconds, ok = rateUnits[parts[1]]
+	}
+	if err != nil || !ok || rate == 0 {
+		return 0, 0, fmt.Errorf("'%s' is not a rate like 10/s or 2/min", value)
+	}
+	return rate, seconds, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (m Mark) String() string {
+	if m.Mask == 0xffffffff {
+		return fmt.Sprintf("0x%x", m.Value)
+	}
+	return fmt.Sprintf("0x%x/0x%x", m.Value, m.Mask)
+}
+
+func (r PortRange) String() string {
+	if r.Start == r.End {
+		return strconv.Itoa(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
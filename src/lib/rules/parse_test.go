@@ -0,0 +1,108 @@
+package rules_test
+
+import (
+	"lib/rules"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Parse", func() {
+	It("returns the matches, target and marks of a rule", func() {
+		parsed, err := rules.Parse(rules.NewMarkAllowRule("10.255.0.2", "tcp", 8080, 8090, "ABCD", "some-source-guid", "some-destination-guid"))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(parsed.Protocol).To(Equal("tcp"))
+		Expect(parsed.DestinationPorts).To(Equal([]rules.PortRange{{Start: 8080, End: 8090}}))
+		Expect(parsed.Mark).To(Equal(&rules.Mark{Value: 0xabcd, Mask: 0xffffffff}))
+		Expect(parsed.Target).To(Equal("ACCEPT"))
+		Expect(parsed.Goto).To(BeFalse())
+		Expect(parsed.Comment).To(Equal("src:some-source-guid_dst:some-destination-guid"))
+		Expect(parsed.Modules).To(Equal([]string{"tcp", "mark", "comment"}))
+		Expect(parsed.Matches).To(ContainElement(rules.Match{Option: "-d", Value: "10.255.0.2"}))
+		Expect(parsed.Matches).To(ContainElement(rules.Match{Module: "tcp", Option: "--dport", Value: "8080:8090"}))
+	})
+
+	It("stores long options and target options under their short names", func() {
+		parsed, err := rules.Parse(rules.IPTablesRule{
+			"--source", "10.0.0.0/8", "--protocol", "udp", "--destination-port", "53",
+			"--jump", "MARK", "--set-xmark", "0x1/0xff",
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(parsed.Matches).To(Equal([]rules.Match{
+			{Option: "-s", Value: "10.0.0.0/8"},
+			{Option: "-p", Value: "udp"},
+			{Module: "udp", Option: "--dport", Value: "53"},
+		}))
+		Expect(parsed.SetMark).To(Equal(&rules.Mark{Value: 1, Mask: 0xff}))
+		Expect(parsed.TargetOptions).To(Equal(map[string]string{"--set-xmark": "0x1/0xff"}))
+	})
+
+	It("removes the quotes around the log prefix", func() {
+		parsed, err := rules.Parse(rules.NewLogRule(rules.IPTablesRule{"-p", "tcp"}, "some-very-very-very-long-app-guid"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed.LogPrefix).To(Equal("some-very-very-very-long-app "))
+	})
+
+	It("records negated matches", func() {
+		parsed, err := rules.Parse(rules.IPTablesRule{"!", "-d", "10.255.0.0/16", "-g", "some-chain"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed.Matches).To(Equal([]rules.Match{{Option: "-d", Value: "10.255.0.0/16", Negated: true}}))
+		Expect(parsed.Target).To(Equal("some-chain"))
+		Expect(parsed.Goto).To(BeTrue())
+	})
+
+	It("accepts the rules built by the constructors", func() {
+		for _, rule := range []rules.IPTablesRule{
+			rules.NewPortForwardingRule(61000, 8080, "10.0.0.1", "10.255.0.2"),
+			rules.NewIngressMarkRule("eth0", 61000, "10.0.0.1", "ABCD"),
+			rules.NewMarkAllowLogRule("10.255.0.2", "udp", 53, 53, "ABCD", "some-guid", 3),
+			rules.NewMarkSetRule("10.255.0.2", "ABCD", "some-guid"),
+			rules.NewDefaultEgressRule("10.255.0.0/24", "eth0"),
+			rules.NewLogRule(rules.IPTablesRule{"-s", "10.255.0.2"}, "some-name"),
+			rules.NewAcceptExistingLocalRule(),
+			rules.NewLogLocalRejectRule("10.255.0.0/24"),
+			rules.NewDefaultDenyLocalRule("10.255.0.0/24"),
+			rules.NewNetOutWithPortsRule("1.1.1.1", "2.2.2.2", 80, 443, "tcp"),
+			rules.NewNetOutICMPRule("1.1.1.1", "2.2.2.2", 8, 0),
+			rules.NewNetOutICMPLogRule("1.1.1.1", "2.2.2.2", 8, 0, "some-chain"),
+			rules.NewNetOutDefaultUDPLogRule("some-prefix", 100),
+			rules.NewInputRelatedEstablishedRule(),
+			rules.NewInputAllowRule("tcp", "10.0.0.1", 8080),
+			rules.NewInputDefaultRejectRule(),
+			rules.NewOverlayDefaultRejectLogRule("some-handle", "10.255.0.2", 3),
+			rules.NewOverlayAllowEgress("silk-vtep", "10.255.0.2"),
+			rules.NewNetOutDefaultRejectRule(),
+		} {
+			Expect(rule.Validate()).To(Succeed(), "rule: %v", rule)
+		}
+	})
+
+	table.DescribeTable("invalid rules",
+		func(rule rules.IPTablesRule, expectedError string) {
+			_, err := rules.Parse(rule)
+			Expect(err).To(MatchError(expectedError))
+			Expect(rule.Validate()).To(MatchError(expectedError))
+		},
+		table.Entry("unknown option", rules.IPTablesRule{"--potato", "1"}, "unsupported option '--potato'"),
+		table.Entry("missing value", rules.IPTablesRule{"-j"}, "invalid option '-j': missing value"),
+		table.Entry("bad cidr", rules.IPTablesRule{"-s", "10.0.0.0/33", "-j", "ACCEPT"}, "invalid option '-s': '10.0.0.0/33' is not an ipv4 address or cidr"),
+		table.Entry("reversed port range", rules.IPTablesRule{"-p", "tcp", "--dport", "90:80"}, "invalid option '--dport': port range '90:80' ends before it starts"),
+		table.Entry("port out of range", rules.IPTablesRule{"-p", "udp", "--sport", "70000"}, "invalid option '--sport': '70000' is not a port or port range"),
+		table.Entry("port without protocol", rules.IPTablesRule{"--dport", "80"}, "invalid option '--dport': requires protocol tcp or udp"),
+		table.Entry("bad mark", rules.IPTablesRule{"-m", "mark", "--mark", "0xpotato"}, "invalid option '--mark': '0xpotato' is not a 32-bit mark"),
+		table.Entry("long log prefix", rules.IPTablesRule{"-j", "LOG", "--log-prefix", "some-prefix-longer-than-the-limit"}, "invalid option '--log-prefix': prefix 'some-prefix-longer-than-the-limit' is longer than 29 characters"),
+		table.Entry("long interface", rules.IPTablesRule{"-o", "some-long-interface", "-j", "ACCEPT"}, "invalid option '-o': interface name 'some-long-interface' must be 1 to 15 characters"),
+		table.Entry("reversed address range", rules.IPTablesRule{"-m", "iprange", "--dst-range", "2.2.2.2-1.1.1.1"}, "invalid option '--dst-range': address range '2.2.2.2-1.1.1.1' ends before it starts"),
+		table.Entry("unknown state", rules.IPTablesRule{"-m", "conntrack", "--ctstate", "NEW,SLEEPY"}, "invalid option '--ctstate': unknown state 'SLEEPY'"),
+		table.Entry("bad rate", rules.IPTablesRule{"-m", "limit", "--limit", "3/fortnight"}, "invalid option '--limit': '3/fortnight' is not a rate like 10/s or 2/min"),
+		table.Entry("target option for another target", rules.IPTablesRule{"-j", "ACCEPT", "--set-mark", "0x1"}, "invalid option '--set-mark': requires target MARK"),
+		table.Entry("mark without a mark", rules.IPTablesRule{"-j", "MARK"}, "target MARK requires --set-mark or --set-xmark"),
+		table.Entry("dnat without a destination", rules.IPTablesRule{"-j", "DNAT"}, "target DNAT requires --to-destination"),
+		table.Entry("two targets", rules.IPTablesRule{"-j", "ACCEPT", "-j", "DROP"}, "invalid option '-j': target ACCEPT already set"),
+		table.Entry("double negation", rules.IPTablesRule{"!", "!", "-s", "1.1.1.1"}, "double negation"),
+		table.Entry("trailing negation", rules.IPTablesRule{"-j", "ACCEPT", "!"}, "negation without an option"),
+	)
+})
@@ -0,0 +1,52 @@
+package rules
+
+// SyncSummary lists the rules that a sync added to and removed from a chain.
+type SyncSummary struct {
+	Added   []IPTablesRule
+	Removed []IPTablesRule
+}
+
+// Diff compares two lists of rules and returns the indexes of the current
+// rules to remove and of the desired rules to add, keeping the longest run
+// of rules that are already in the desired order.
+func Diff(current, desired []string) ([]int, []int) {
+	lengths := make([][]int, len(current)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(desired)+1)
+	}
+	for i := len(current) - 1; i >= 0; i-- {
+		for j := len(desired) - 1; j >= 0; j-- {
+			switch {
+			case current[i] == desired[j]:
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			case lengths[i+1][j] >= lengths[i][j+1]:
+				lengths[i][j] = lengths[i+1][j]
+			default:
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	removed, added := []int{}, []int{}
+	i, j := 0, 0
+	for i < len(current) && j < len(desired) {
+		switch {
+		case current[i] == desired[j]:
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			removed = append(removed, i)
+			i++
+		default:
+			added = append(added, j)
+			j++
+		}
+	}
+	for ; i < len(current); i++ {
+		removed = append(removed, i)
+	}
+	for ; j < len(desired); j++ {
+		added = append(added, j)
+	}
+	return removed, added
+}
@@ -0,0 +1,45 @@
+package rules_test
+
+import (
+	"lib/rules"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sync", func() {
+	Describe("Diff", func() {
+		It("returns the rules to remove and to add, keeping the rules in order", func() {
+			removed, added := rules.Diff(
+				[]string{"a", "b", "c", "d"},
+				[]string{"x", "a", "c", "y", "d", "z"},
+			)
+			Expect(removed).To(Equal([]int{1}))
+			Expect(added).To(Equal([]int{0, 3, 5}))
+		})
+
+		It("removes rules that are out of order", func() {
+			removed, added := rules.Diff([]string{"a", "b", "c"}, []string{"c", "a", "b"})
+			Expect(removed).To(Equal([]int{2}))
+			Expect(added).To(Equal([]int{0}))
+		})
+
+		It("handles duplicate and empty lists", func() {
+			removed, added := rules.Diff([]string{"a", "a"}, []string{"a"})
+			Expect(removed).To(Equal([]int{1}))
+			Expect(added).To(BeEmpty())
+
+			removed, added = rules.Diff(nil, []string{"a"})
+			Expect(removed).To(BeEmpty())
+			Expect(added).To(Equal([]int{0}))
+		})
+	})
+
+	Describe("SplitRuleSpec", func() {
+		It("splits on spaces outside quotes", func() {
+			Expect(rules.SplitRuleSpec(`-s 1.2.3.4/32 -m comment --comment "src:a guid" -j LOG --log-prefix "OK_\"x "`)).To(Equal(rules.IPTablesRule{
+				"-s", "1.2.3.4/32", "-m", "comment", "--comment", `"src:a guid"`, "-j", "LOG", "--log-prefix", `"OK_\"x "`,
+			}))
+		})
+	})
+})
@@ -0,0 +1,202 @@
+package serial
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+//go:generate counterfeiter -o ../fakes/state_store.go --fake-name StateStore . StateStore
+type StateStore interface {
+	Load(outData interface{}) (LoadReport, error)
+	Save(data interface{}) error
+}
+
+// LoadReport says where loaded data came from and what was done to it.
+type LoadReport struct {
+	// Path is the file the data was loaded from, empty if there was none.
+	Path string
+	// Recovered is set when the state file was missing or corrupt and the
+	// backup was loaded instead. Corruption says what was wrong with it.
+	Recovered  bool
+	Corruption error
+	// FromVersion is the version the data was stored with. It differs from
+	// the StateFile Version when the data was migrated.
+	FromVersion int
+}
+
+// StateFile stores data as JSON in a file at Path, along with the format
+// Version of the data and a checksum of it. Save writes a temporary file,
+// syncs it and renames it over Path, so a crash leaves either the old or the
+// new file in place, and keeps the file it replaces as Path.bak. Load falls
+// back to the backup when the file is missing or corrupt.
+//
+// Files written before versioning, which hold the bare JSON data, are loaded
+// as version 0. Data stored with an older version is passed to Migrate,
+// which returns it in the format of the current Version.
+//
+// StateFile does no locking: callers must not Save concurrently.
+type StateFile struct {
+	Path    string
+	Version int
+	Migrate func(fromVersion int, data json.RawMessage) (json.RawMessage, error)
+}
+
+type stateFileEnvelope struct {
+	Version  int             `json:"version"`
+	Checksum string          `json:"checksum"`
+	Data     json.RawMessage `json:"data"`
+}
+
+func (s *StateFile) backupPath() string {
+	return s.Path + ".bak"
+}
+
+func (s *StateFile) Load(outData interface{}) (LoadReport, error) {
+	report := LoadReport{Path: s.Path}
+	version, data, err := s.read(s.Path)
+	if err != nil {
+		backupVersion, backupData, backupErr := s.read(s.backupPath())
+		if backupErr != nil {
+			if os.IsNotExist(err) && os.IsNotExist(backupErr) {
+				return LoadReport{FromVersion: s.Version}, nil
+			}
+			return report, fmt.Errorf("load state file: %s, and backup: %s", err, backupErr)
+		}
+		report.Path = s.backupPath()
+		report.Recovered = true
+		report.Corruption = err
+		version, data = backupVersion, backupData
+	}
+	report.FromVersion = version
+
+	if version > s.Version {
+		return report, fmt.Errorf("state file %s has version %d, newer than %d", report.Path, version, s.Version)
+	}
+	if version < s.Version && len(data) > 0 {
+		if s.Migrate == nil {
+			return report, fmt.Errorf("state file %s has version %d and there is no migration to %d", report.Path, version, s.Version)
+		}
+		data, err = s.Migrate(version, data)
+		if err != nil {
+			return report, fmt.Errorf("migrate state file from version %d: %s", version, err)
+		}
+	}
+
+	if len(data) == 0 {
+		return report, nil
+	}
+	err = json.Unmarshal(data, outData)
+	if err != nil {
+		return report, fmt.Errorf("decode state: %s", err)
+	}
+	return report, nil
+}
+
+// read returns the version and data stored in the file at path, or an error
+// if it is missing or corrupt. An empty file holds no data.
+func (s *StateFile) read(path string) (int, json.RawMessage, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(bytes.TrimSpace(contents)) == 0 {
+		return s.Version, nil, nil
+	}
+
+	var envelope stateFileEnvelope
+	err = json.Unmarshal(contents, &envelope)
+	if err != nil {
+		return 0, nil, fmt.Errorf("corrupt file %s: %s", path, err)
+	}
+	if envelope.Checksum == "" && envelope.Data == nil {
+		return 0, contents, nil
+	}
+	if envelope.Checksum != checksum(envelope.Data) {
+		return 0, nil, fmt.Errorf("corrupt file %s: checksum mismatch", path)
+	}
+	return envelope.Version, envelope.Data, nil
+}
+
+func (s *StateFile) Save(data interface{}) error {
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("encode state: %s", err)
+	}
+	contents, err := json.Marshal(stateFileEnvelope{
+		Version:  s.Version,
+		Checksum: checksum(dataBytes),
+		Data:     dataBytes,
+	})
+	if err != nil {
+		return fmt.Errorf("encode state: %s", err)
+	}
+
+	dir := filepath.Dir(s.Path)
+	tempFile, err := ioutil.TempFile(dir, filepath.Base(s.Path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file: %s", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.Write(contents)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write temp file: %s", err)
+	}
+
+	err = s.backup()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tempFile.Name(), s.Path)
+	if err != nil {
+		return fmt.Errorf("replace state file: %s", err)
+	}
+	return syncDir(dir)
+}
+
+// backup hard links the current file as the backup, so that the file is
+// never missing while it is replaced. A corrupt file is not backed up, so a
+// good backup is not lost to it.
+func (s *StateFile) backup() error {
+	if _, _, err := s.read(s.Path); err != nil {
+		return nil
+	}
+	err := os.Remove(s.backupPath())
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove backup: %s", err)
+	}
+	err = os.Link(s.Path, s.backupPath())
+	if err != nil {
+		return fmt.Errorf("create backup: %s", err)
+	}
+	return nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("open state dir: %s", err)
+	}
+	defer d.Close()
+	err = d.Sync()
+	if err != nil {
+		return fmt.Errorf("sync state dir: %s", err)
+	}
+	return nil
+}
+
+func checksum(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
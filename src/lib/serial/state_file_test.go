@@ -0,0 +1,292 @@
+package serial_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"lib/serial"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StateFile", func() {
+	type state struct {
+		Some string `json:"some"`
+	}
+
+	var (
+		dir        string
+		path       string
+		stateFile  *serial.StateFile
+		outData    state
+		migrations []int
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "state-file")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "state.json")
+		migrations = nil
+		outData = state{}
+
+		stateFile = &serial.StateFile{
+			Path:    path,
+			Version: 2,
+			Migrate: func(fromVersion int, data json.RawMessage) (json.RawMessage, error) {
+				migrations = append(migrations, fromVersion)
+				var old map[string]string
+				if err := json.Unmarshal(data, &old); err != nil {
+					return nil, err
+				}
+				return json.Marshal(state{Some: "migrated " + old["old"]})
+			},
+		}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	writeFile := func(path, contents string) {
+		Expect(ioutil.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+	}
+
+	It("loads the data that was saved", func() {
+		Expect(stateFile.Save(state{Some: "data"})).To(Succeed())
+
+		report, err := stateFile.Load(&outData)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(outData.Some).To(Equal("data"))
+		Expect(report).To(Equal(serial.LoadReport{Path: path, FromVersion: 2}))
+	})
+
+	It("saves the data with its version and checksum", func() {
+		Expect(stateFile.Save(state{Some: "data"})).To(Succeed())
+
+		contents, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(contents).To(MatchJSON(`{
+			"version": 2,
+			"checksum": "sha256:c67cdc294fe06519bd9b7948e27059b643edf540877335b271957ab95dd551b7",
+			"data": {"some": "data"}
+		}`))
+	})
+
+	It("leaves no temporary files behind", func() {
+		Expect(stateFile.Save(state{Some: "data"})).To(Succeed())
+		Expect(stateFile.Save(state{Some: "more data"})).To(Succeed())
+
+		files, err := ioutil.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		var names []string
+		for _, file := range files {
+			names = append(names, file.Name())
+		}
+		Expect(names).To(ConsistOf("state.json", "state.json.bak"))
+	})
+
+	It("keeps the file it replaces as the backup", func() {
+		Expect(stateFile.Save(state{Some: "first"})).To(Succeed())
+		Expect(stateFile.Save(state{Some: "second"})).To(Succeed())
+
+		backup := &serial.StateFile{Path: path + ".bak", Version: 2}
+		_, err := backup.Load(&outData)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(outData.Some).To(Equal("first"))
+	})
+
+	Context("when neither the file nor its backup exist", func() {
+		It("loads nothing", func() {
+			report, err := stateFile.Load(&outData)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outData).To(Equal(state{}))
+			Expect(report).To(Equal(serial.LoadReport{FromVersion: 2}))
+		})
+	})
+
+	Context("when the file is empty", func() {
+		BeforeEach(func() {
+			writeFile(path, "")
+		})
+
+		It("loads nothing", func() {
+			report, err := stateFile.Load(&outData)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outData).To(Equal(state{}))
+			Expect(report.Recovered).To(BeFalse())
+			Expect(migrations).To(BeEmpty())
+		})
+	})
+
+	Context("when the file holds bare JSON from before it was versioned", func() {
+		BeforeEach(func() {
+			writeFile(path, `{"old": "data"}`)
+		})
+
+		It("loads it as version 0 and migrates it", func() {
+			report, err := stateFile.Load(&outData)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outData.Some).To(Equal("migrated data"))
+			Expect(report.FromVersion).To(Equal(0))
+			Expect(migrations).To(Equal([]int{0}))
+		})
+
+		It("is backed up when it is replaced", func() {
+			Expect(stateFile.Save(state{Some: "new"})).To(Succeed())
+
+			contents, err := ioutil.ReadFile(path + ".bak")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contents).To(MatchJSON(`{"old": "data"}`))
+		})
+	})
+
+	Context("when the file has an older version", func() {
+		BeforeEach(func() {
+			old := &serial.StateFile{Path: path, Version: 1}
+			Expect(old.Save(map[string]string{"old": "data"})).To(Succeed())
+		})
+
+		It("migrates the data", func() {
+			report, err := stateFile.Load(&outData)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outData.Some).To(Equal("migrated data"))
+			Expect(report.FromVersion).To(Equal(1))
+			Expect(migrations).To(Equal([]int{1}))
+		})
+
+		Context("when there is no migration", func() {
+			BeforeEach(func() {
+				stateFile.Migrate = nil
+			})
+
+			It("returns an error", func() {
+				_, err := stateFile.Load(&outData)
+				Expect(err).To(MatchError(ContainSubstring("has version 1 and there is no migration to 2")))
+			})
+		})
+
+		Context("when the migration fails", func() {
+			BeforeEach(func() {
+				stateFile.Migrate = func(int, json.RawMessage) (json.RawMessage, error) {
+					return nil, errors.New("potato")
+				}
+			})
+
+			It("returns the error", func() {
+				_, err := stateFile.Load(&outData)
+				Expect(err).To(MatchError("migrate state file from version 1: potato"))
+			})
+		})
+	})
+
+	Context("when the file has a newer version", func() {
+		BeforeEach(func() {
+			newer := &serial.StateFile{Path: path, Version: 3}
+			Expect(newer.Save(state{Some: "data"})).To(Succeed())
+		})
+
+		It("returns an error", func() {
+			_, err := stateFile.Load(&outData)
+			Expect(err).To(MatchError(ContainSubstring("has version 3, newer than 2")))
+		})
+	})
+
+	Context("when the file is corrupt", func() {
+		BeforeEach(func() {
+			Expect(stateFile.Save(state{Some: "good"})).To(Succeed())
+			Expect(stateFile.Save(state{Some: "latest"})).To(Succeed())
+		})
+
+		Context("because it is truncated", func() {
+			BeforeEach(func() {
+				writeFile(path, `{"version": 2, "checks`)
+			})
+
+			It("recovers the backup", func() {
+				report, err := stateFile.Load(&outData)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(outData.Some).To(Equal("good"))
+				Expect(report.Path).To(Equal(path + ".bak"))
+				Expect(report.Recovered).To(BeTrue())
+				Expect(report.Corruption).To(MatchError(ContainSubstring("corrupt file " + path)))
+			})
+
+			It("does not replace the backup with it on save", func() {
+				Expect(stateFile.Save(state{Some: "next"})).To(Succeed())
+
+				backup := &serial.StateFile{Path: path + ".bak", Version: 2}
+				_, err := backup.Load(&outData)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(outData.Some).To(Equal("good"))
+			})
+		})
+
+		Context("because its checksum does not match", func() {
+			BeforeEach(func() {
+				writeFile(path, `{"version": 2, "checksum": "sha256:00", "data": {"some": "latest"}}`)
+			})
+
+			It("recovers the backup", func() {
+				report, err := stateFile.Load(&outData)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(outData.Some).To(Equal("good"))
+				Expect(report.Recovered).To(BeTrue())
+				Expect(report.Corruption).To(MatchError("corrupt file " + path + ": checksum mismatch"))
+			})
+		})
+
+		Context("and so is the backup", func() {
+			BeforeEach(func() {
+				writeFile(path, "{")
+				writeFile(path+".bak", "{")
+			})
+
+			It("returns an error", func() {
+				_, err := stateFile.Load(&outData)
+				Expect(err).To(MatchError(ContainSubstring("load state file: corrupt file")))
+			})
+		})
+	})
+
+	Context("when the file is missing but the backup is not", func() {
+		BeforeEach(func() {
+			Expect(stateFile.Save(state{Some: "good"})).To(Succeed())
+			Expect(stateFile.Save(state{Some: "latest"})).To(Succeed())
+			Expect(os.Remove(path)).To(Succeed())
+		})
+
+		It("recovers the backup", func() {
+			report, err := stateFile.Load(&outData)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outData.Some).To(Equal("good"))
+			Expect(report.Recovered).To(BeTrue())
+			Expect(os.IsNotExist(report.Corruption)).To(BeTrue())
+		})
+	})
+
+	Context("when the data does not decode", func() {
+		BeforeEach(func() {
+			Expect(stateFile.Save(map[string]int{"some": 1})).To(Succeed())
+		})
+
+		It("returns an error", func() {
+			_, err := stateFile.Load(&outData)
+			Expect(err).To(MatchError(ContainSubstring("decode state:")))
+		})
+	})
+
+	Context("when the directory does not exist", func() {
+		BeforeEach(func() {
+			stateFile.Path = filepath.Join(dir, "missing", "state.json")
+		})
+
+		It("returns an error on save", func() {
+			err := stateFile.Save(state{Some: "data"})
+			Expect(err).To(MatchError(ContainSubstring("create temp file:")))
+		})
+	})
+})
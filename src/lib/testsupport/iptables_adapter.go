@@ -0,0 +1,159 @@
+package testsupport
+
+import (
+	"lib/rules"
+	"regexp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// adapterTestChain is the user chain of the filter table that
+// DescribeIPTablesAdapter works in, so that the rules of the host are left
+// alone.
+const adapterTestChain = "cfnet-adapter-test"
+
+var ruleGUID = regexp.MustCompile(`src:([a-z0-9-]+)`)
+
+// DescribeIPTablesAdapter describes the behaviour every firewall backend
+// shares, so that each backend runs the same specs against the adapter that
+// newAdapter returns.
+func DescribeIPTablesAdapter(newAdapter func() rules.IPTablesAdapter) {
+	var adapter rules.IPTablesAdapter
+
+	rule := func(guid string) rules.IPTablesRule {
+		return rules.NewMarkSetRule("10.255.0.1", "A", guid)
+	}
+
+	// listedGUIDs returns the app guids of the listed rules, in order.
+	listedGUIDs := func() []string {
+		listed, err := adapter.List("filter", adapterTestChain)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		guids := []string{}
+		for _, line := range listed {
+			if match := ruleGUID.FindStringSubmatch(line); match != nil {
+				guids = append(guids, match[1])
+			}
+		}
+		return guids
+	}
+
+	BeforeEach(func() {
+		adapter = newAdapter()
+		Expect(adapter.ClearChain("filter", adapterTestChain)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(adapter.ClearChain("filter", adapterTestChain)).To(Succeed())
+		Expect(adapter.DeleteChain("filter", adapterTestChain)).To(Succeed())
+	})
+
+	Describe("BulkAppend", func() {
+		It("appends the rules in order", func() {
+			Expect(adapter.BulkAppend("filter", adapterTestChain, rule("a-guid"))).To(Succeed())
+			Expect(adapter.BulkAppend("filter", adapterTestChain, rule("b-guid"), rule("c-guid"))).To(Succeed())
+
+			Expect(listedGUIDs()).To(Equal([]string{"a-guid", "b-guid", "c-guid"}))
+		})
+	})
+
+	Describe("BulkInsert", func() {
+		BeforeEach(func() {
+			Expect(adapter.BulkAppend("filter", adapterTestChain, rule("a-guid"), rule("b-guid"))).To(Succeed())
+		})
+
+		It("inserts the rules at the position, each in turn", func() {
+			Expect(adapter.BulkInsert("filter", adapterTestChain, 1, rule("c-guid"), rule("d-guid"))).To(Succeed())
+			Expect(listedGUIDs()).To(Equal([]string{"d-guid", "c-guid", "a-guid", "b-guid"}))
+		})
+
+		It("inserts the rules after the rule before the position", func() {
+			Expect(adapter.BulkInsert("filter", adapterTestChain, 2, rule("c-guid"))).To(Succeed())
+			Expect(listedGUIDs()).To(Equal([]string{"a-guid", "c-guid", "b-guid"}))
+		})
+
+		It("fails past the end of the chain", func() {
+			Expect(adapter.BulkInsert("filter", adapterTestChain, 4, rule("c-guid"))).NotTo(Succeed())
+			Expect(listedGUIDs()).To(Equal([]string{"a-guid", "b-guid"}))
+		})
+	})
+
+	Describe("Exists and Delete", func() {
+		BeforeEach(func() {
+			Expect(adapter.BulkAppend("filter", adapterTestChain, rule("a-guid"), rule("b-guid"))).To(Succeed())
+		})
+
+		It("finds and deletes rules by rulespec", func() {
+			Expect(adapter.Exists("filter", adapterTestChain, rule("a-guid"))).To(BeTrue())
+			Expect(adapter.Exists("filter", adapterTestChain, rule("c-guid"))).To(BeFalse())
+
+			Expect(adapter.Delete("filter", adapterTestChain, rule("a-guid"))).To(Succeed())
+			Expect(adapter.Exists("filter", adapterTestChain, rule("a-guid"))).To(BeFalse())
+			Expect(listedGUIDs()).To(Equal([]string{"b-guid"}))
+		})
+
+		It("fails to delete a rule that does not exist", func() {
+			Expect(adapter.Delete("filter", adapterTestChain, rule("c-guid"))).NotTo(Succeed())
+		})
+	})
+
+	Describe("chains", func() {
+		It("lists a user chain with its rules", func() {
+			Expect(adapter.BulkAppend("filter", adapterTestChain, rule("a-guid"))).To(Succeed())
+
+			listed, err := adapter.List("filter", adapterTestChain)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listed).To(HaveLen(2))
+			Expect(listed[0]).To(Equal("-N " + adapterTestChain))
+			Expect(listed[1]).To(HavePrefix("-A " + adapterTestChain + " "))
+		})
+
+		It("fails to create a chain that exists", func() {
+			Expect(adapter.NewChain("filter", adapterTestChain)).NotTo(Succeed())
+		})
+
+		It("clears a chain", func() {
+			Expect(adapter.BulkAppend("filter", adapterTestChain, rule("a-guid"))).To(Succeed())
+			Expect(adapter.ClearChain("filter", adapterTestChain)).To(Succeed())
+			Expect(listedGUIDs()).To(BeEmpty())
+		})
+
+		It("deletes an empty chain", func() {
+			Expect(adapter.DeleteChain("filter", adapterTestChain)).To(Succeed())
+
+			_, err := adapter.List("filter", adapterTestChain)
+			Expect(err).To(HaveOccurred())
+
+			Expect(adapter.NewChain("filter", adapterTestChain)).To(Succeed())
+			Expect(listedGUIDs()).To(BeEmpty())
+		})
+	})
+
+	Describe("SyncChain", func() {
+		BeforeEach(func() {
+			Expect(adapter.BulkAppend("filter", adapterTestChain, rule("a-guid"), rule("b-guid"), rule("c-guid"))).To(Succeed())
+		})
+
+		It("adds and removes only the rules that differ", func() {
+			summary, err := adapter.SyncChain("filter", adapterTestChain, []rules.IPTablesRule{
+				rule("d-guid"), rule("a-guid"), rule("c-guid"), rule("e-guid"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.Added).To(Equal([]rules.IPTablesRule{rule("d-guid"), rule("e-guid")}))
+			Expect(summary.Removed).To(HaveLen(1))
+			Expect(summary.Removed[0]).To(ContainElement(ContainSubstring("src:b-guid")))
+
+			Expect(listedGUIDs()).To(Equal([]string{"d-guid", "a-guid", "c-guid", "e-guid"}))
+		})
+
+		It("changes nothing when the chain is in sync", func() {
+			summary, err := adapter.SyncChain("filter", adapterTestChain, []rules.IPTablesRule{
+				rule("a-guid"), rule("b-guid"), rule("c-guid"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.Added).To(BeEmpty())
+			Expect(summary.Removed).To(BeEmpty())
+			Expect(listedGUIDs()).To(Equal([]string{"a-guid", "b-guid", "c-guid"}))
+		})
+	})
+}
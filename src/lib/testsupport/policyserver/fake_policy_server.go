@@ -0,0 +1,436 @@
+package policyserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"policy-server/api"
+	"policy-server/api/api_v0"
+	"policy-server/api/api_v0_internal"
+	"policy-server/store"
+	"policy-server/uaa_client"
+	"sort"
+	"strings"
+	"sync"
+
+	"code.cloudfoundry.org/cf-networking-helpers/marshal"
+	"code.cloudfoundry.org/lager"
+)
+
+// FakePolicyServer is an in-memory policy server for the tests of its
+// clients. It serves the v0 and v1 external and internal policy routes on a
+// single listener, with the request and response mapping and validation of
+// the real server.
+//
+// When it is given a FakeUAA, external requests need a token that the UAA
+// accepts: tokens with the network.write or network.admin scope may read and
+// write policies, and only network.admin may clean up and read tags. Unlike
+// the real server it does not check which apps a token may access, and it has
+// no policy quota.
+type FakePolicyServer struct {
+	faults
+	URL string
+
+	server    *httptest.Server
+	uaaClient *uaa_client.Client
+
+	mapperV0         api.PolicyMapper
+	mapperV1         api.PolicyMapper
+	mapperV0Internal api.PolicyMapper
+
+	mutex       sync.Mutex
+	policies    []store.Policy
+	tags        map[string]int
+	deletedApps map[string]bool
+	requests    []FakeRequest
+}
+
+type FakeRequest struct {
+	Method   string
+	Path     string
+	RawQuery string
+	Body     []byte
+}
+
+var (
+	errMissingAuthorization = errors.New("missing authorization header")
+
+	writeScopes = []string{"network.admin", "network.write"}
+	adminScopes = []string{"network.admin"}
+)
+
+// NewFakePolicyServer starts a FakePolicyServer and adds its client to uaa.
+// If uaa is nil, external requests are not authenticated.
+func NewFakePolicyServer(uaa *FakeUAA) *FakePolicyServer {
+	unmarshaler := marshal.UnmarshalFunc(json.Unmarshal)
+	marshaler := marshal.MarshalFunc(json.Marshal)
+	s := &FakePolicyServer{
+		mapperV0:         api_v0.NewMapper(unmarshaler, marshaler, &api_v0.Validator{}),
+		mapperV1:         api.NewMapper(unmarshaler, marshaler, &api.Validator{}),
+		mapperV0Internal: api_v0_internal.NewMapper(unmarshaler, marshaler),
+		tags:             map[string]int{},
+		deletedApps:      map[string]bool{},
+	}
+	if uaa != nil {
+		// like policy-server, it checks tokens as a client of the UAA
+		uaa.AddClient("network-policy", "network-policy-secret", "network-policy-token")
+		s.uaaClient = &uaa_client.Client{
+			BaseURL:    uaa.URL,
+			Name:       "network-policy",
+			Secret:     "network-policy-secret",
+			HTTPClient: http.DefaultClient,
+			Logger:     lager.NewLogger("fake-policy-server"),
+		}
+	}
+	s.server = httptest.NewServer(s.record(s.wrap(http.HandlerFunc(s.serveHTTP))))
+	s.URL = s.server.URL
+	return s
+}
+
+func (s *FakePolicyServer) Close() {
+	s.server.Close()
+}
+
+// SetPolicies replaces the policies on the server, without validating them.
+func (s *FakePolicyServer) SetPolicies(policies []api.Policy) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.policies = nil
+	for _, policy := range policies {
+		s.create(store.Policy{
+			Source: store.Source{ID: policy.Source.ID},
+			Destination: store.Destination{
+				ID:       policy.Destination.ID,
+				Protocol: policy.Destination.Protocol,
+				Ports: store.Ports{
+					Start: policy.Destination.Ports.Start,
+					End:   policy.Destination.Ports.End,
+				},
+			},
+		})
+	}
+}
+
+// Policies returns the policies on the server, with their tags, in the order
+// of the internal API.
+func (s *FakePolicyServer) Policies() []api.Policy {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	policies := []api.Policy{}
+	for _, policy := range s.all() {
+		policies = append(policies, api.Policy{
+			Source: api.Source{ID: policy.Source.ID, Tag: policy.Source.Tag},
+			Destination: api.Destination{
+				ID:       policy.Destination.ID,
+				Tag:      policy.Destination.Tag,
+				Protocol: policy.Destination.Protocol,
+				Ports: api.Ports{
+					Start: policy.Destination.Ports.Start,
+					End:   policy.Destination.Ports.End,
+				},
+			},
+		})
+	}
+	return policies
+}
+
+// Tags returns the tags of every app that has been in a policy.
+func (s *FakePolicyServer) Tags() []api.Tag {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.allTags()
+}
+
+// SetDeletedApps marks apps as deleted from Cloud Controller, so that a
+// cleanup deletes their policies.
+func (s *FakePolicyServer) SetDeletedApps(ids ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.deletedApps = map[string]bool{}
+	for _, id := range ids {
+		s.deletedApps[id] = true
+	}
+}
+
+// Requests returns every request served so far, including those that failed.
+func (s *FakePolicyServer) Requests() []FakeRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]FakeRequest{}, s.requests...)
+}
+
+func (s *FakePolicyServer) record(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "failed reading request body")
+			return
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		s.mutex.Lock()
+		s.requests = append(s.requests, FakeRequest{
+			Method:   req.Method,
+			Path:     req.URL.Path,
+			RawQuery: req.URL.RawQuery,
+			Body:     body,
+		})
+		s.mutex.Unlock()
+		handler.ServeHTTP(w, req)
+	})
+}
+
+func (s *FakePolicyServer) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed reading request body")
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/networking/"), "/", 2)
+	if len(parts) != 2 || (parts[0] != "v0" && parts[0] != "v1") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	version, route := parts[0], req.Method+" "+parts[1]
+
+	var tokenData uaa_client.CheckTokenResponse
+	switch route {
+	case "GET external/policies", "POST external/policies", "POST external/policies/delete":
+		tokenData, err = s.authenticate(req, writeScopes)
+	case "POST external/policies/cleanup", "GET external/tags", "GET external/whoami":
+		tokenData, err = s.authenticate(req, adminScopes)
+	}
+	if err == errMissingAuthorization {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	} else if err != nil {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	externalMapper, internalMapper := s.mapperV1, s.mapperV1
+	if version == "v0" {
+		externalMapper, internalMapper = s.mapperV0, s.mapperV0Internal
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch route {
+	case "GET external/policies":
+		s.index(w, req, externalMapper)
+	case "POST external/policies":
+		s.change(w, body, externalMapper, s.create)
+	case "POST external/policies/delete":
+		s.change(w, body, externalMapper, s.delete)
+	case "POST external/policies/cleanup":
+		s.cleanup(w, externalMapper)
+	case "GET external/tags":
+		writeJSON(w, map[string][]api.Tag{"tags": s.allTags()})
+	case "GET external/whoami":
+		writeJSON(w, map[string]string{"user_name": tokenData.UserName})
+	case "GET internal/policies":
+		s.indexInternal(w, req, internalMapper)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// authenticate checks the token of req with the UAA, as the real server
+// does, and that it has one of scopes.
+func (s *FakePolicyServer) authenticate(req *http.Request, scopes []string) (uaa_client.CheckTokenResponse, error) {
+	if s.uaaClient == nil {
+		return uaa_client.CheckTokenResponse{}, nil
+	}
+	authorization := req.Header.Get("Authorization")
+	if authorization == "" {
+		return uaa_client.CheckTokenResponse{}, errMissingAuthorization
+	}
+	token := strings.TrimPrefix(strings.TrimPrefix(authorization, "Bearer "), "bearer ")
+	tokenData, err := s.uaaClient.CheckToken(token)
+	if err != nil {
+		return tokenData, fmt.Errorf("failed to verify token with uaa")
+	}
+	for _, scope := range tokenData.Scope {
+		for _, allowed := range scopes {
+			if scope == allowed {
+				return tokenData, nil
+			}
+		}
+	}
+	return tokenData, fmt.Errorf("provided scopes %s do not include allowed scopes %s", tokenData.Scope, scopes)
+}
+
+func (s *FakePolicyServer) index(w http.ResponseWriter, req *http.Request, mapper api.PolicyMapper) {
+	query := req.URL.Query()
+	ids, sourceIDs, destIDs := queryIDs(query, "id"), queryIDs(query, "source_id"), queryIDs(query, "dest_id")
+
+	var policies []store.Policy
+	switch {
+	case len(ids) > 0:
+		policies = s.byGuids(ids, ids, false)
+	case len(sourceIDs) > 0 && len(destIDs) > 0:
+		policies = s.byGuids(sourceIDs, destIDs, true)
+	case len(sourceIDs) > 0 || len(destIDs) > 0:
+		policies = s.byGuids(sourceIDs, destIDs, false)
+	default:
+		policies = s.all()
+	}
+	s.writePolicies(w, mapper, withoutTags(policies))
+}
+
+func (s *FakePolicyServer) indexInternal(w http.ResponseWriter, req *http.Request, mapper api.PolicyMapper) {
+	policies := s.all()
+	if ids := queryIDs(req.URL.Query(), "id"); len(ids) > 0 {
+		policies = s.byGuids(ids, ids, false)
+	}
+	bytes, err := mapper.AsBytes(policies)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "map policy as bytes failed")
+		return
+	}
+
+	api.WriteWithETag(w, req, bytes)
+}
+
+func (s *FakePolicyServer) change(w http.ResponseWriter, body []byte, mapper api.PolicyMapper, apply func(store.Policy)) {
+	policies, err := mapper.AsStorePolicy(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("mapper: %s", err))
+		return
+	}
+	for _, policy := range policies {
+		apply(policy)
+	}
+	writeJSON(w, struct{}{})
+}
+
+func (s *FakePolicyServer) cleanup(w http.ResponseWriter, mapper api.PolicyMapper) {
+	var kept, deleted []store.Policy
+	for _, policy := range s.policies {
+		if s.deletedApps[policy.Source.ID] || s.deletedApps[policy.Destination.ID] {
+			deleted = append(deleted, policy)
+		} else {
+			kept = append(kept, policy)
+		}
+	}
+	s.policies = kept
+	s.writePolicies(w, mapper, deleted)
+}
+
+func (s *FakePolicyServer) writePolicies(w http.ResponseWriter, mapper api.PolicyMapper, policies []store.Policy) {
+	bytes, err := mapper.AsBytes(policies)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "map policy as bytes failed")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(bytes)
+}
+
+// create adds policy unless it exists, and gives its apps tags.
+func (s *FakePolicyServer) create(policy store.Policy) {
+	policy = policyKey(policy)
+	for _, id := range []string{policy.Source.ID, policy.Destination.ID} {
+		if _, ok := s.tags[id]; !ok {
+			s.tags[id] = len(s.tags) + 1
+		}
+	}
+	for _, existing := range s.policies {
+		if existing == policy {
+			return
+		}
+	}
+	s.policies = append(s.policies, policy)
+}
+
+func (s *FakePolicyServer) delete(policy store.Policy) {
+	policy = policyKey(policy)
+	kept := []store.Policy{}
+	for _, existing := range s.policies {
+		if existing != policy {
+			kept = append(kept, existing)
+		}
+	}
+	s.policies = kept
+}
+
+// all returns the policies with their tags, sorted like the internal API.
+func (s *FakePolicyServer) all() []store.Policy {
+	policies := []store.Policy{}
+	for _, policy := range s.policies {
+		policy.Source.Tag = s.tag(policy.Source.ID)
+		policy.Destination.Tag = s.tag(policy.Destination.ID)
+		if policy.Destination.Ports.Start == policy.Destination.Ports.End {
+			policy.Destination.Port = policy.Destination.Ports.Start
+		}
+		policies = append(policies, policy)
+	}
+	api.SortPolicies(policies)
+	return policies
+}
+
+// byGuids matches the store: policies from sourceIDs or to destIDs, or both
+// when inSourceAndDest is set.
+func (s *FakePolicyServer) byGuids(sourceIDs, destIDs []string, inSourceAndDest bool) []store.Policy {
+	policies := []store.Policy{}
+	for _, policy := range s.all() {
+		fromSource := contains(sourceIDs, policy.Source.ID)
+		toDest := contains(destIDs, policy.Destination.ID)
+		if (inSourceAndDest && fromSource && toDest) || (!inSourceAndDest && (fromSource || toDest)) {
+			policies = append(policies, policy)
+		}
+	}
+	return policies
+}
+
+func (s *FakePolicyServer) allTags() []api.Tag {
+	tags := []api.Tag{}
+	for id := range s.tags {
+		tags = append(tags, api.Tag{ID: id, Tag: s.tag(id)})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Tag < tags[j].Tag })
+	return tags
+}
+
+func (s *FakePolicyServer) tag(id string) string {
+	return fmt.Sprintf("%04X", s.tags[id])
+}
+
+// policyKey returns policy without the fields the store derives, so that
+// policies can be compared.
+func policyKey(policy store.Policy) store.Policy {
+	policy.Source.Tag = ""
+	policy.Destination.Tag = ""
+	policy.Destination.Port = 0
+	return policy
+}
+
+func withoutTags(policies []store.Policy) []store.Policy {
+	for i := range policies {
+		policies[i].Source.Tag = ""
+		policies[i].Destination.Tag = ""
+	}
+	return policies
+}
+
+func queryIDs(query map[string][]string, key string) []string {
+	values, ok := query[key]
+	if !ok || values[0] == "" {
+		return nil
+	}
+	return strings.Split(values[0], ",")
+}
+
+func contains(ids []string, id string) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
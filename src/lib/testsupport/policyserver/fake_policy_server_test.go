@@ -0,0 +1,300 @@
+package policyserver_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"lib/policy_client"
+	"lib/testsupport/policyserver"
+	"net/http"
+	"policy-server/api"
+	"policy-server/api/api_v0"
+	"policy-server/uaa_client"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FakePolicyServer", func() {
+	var (
+		uaa            *policyserver.FakeUAA
+		server         *policyserver.FakePolicyServer
+		externalClient *policy_client.ExternalClient
+		internalClient *policy_client.InternalClient
+	)
+
+	policy := func(source, destination, protocol string, start, end int) api.Policy {
+		return api.Policy{
+			Source: api.Source{ID: source},
+			Destination: api.Destination{
+				ID:       destination,
+				Protocol: protocol,
+				Ports:    api.Ports{Start: start, End: end},
+			},
+		}
+	}
+
+	get := func(path, token string, header http.Header) (*http.Response, string) {
+		req, err := http.NewRequest("GET", server.URL+path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		return resp, string(body)
+	}
+
+	BeforeEach(func() {
+		uaa = policyserver.NewFakeUAA()
+		uaa.AddToken("write-token", uaa_client.CheckTokenResponse{Scope: []string{"network.write"}, UserName: "developer"})
+		uaa.AddToken("admin-token", uaa_client.CheckTokenResponse{Scope: []string{"network.admin"}, UserName: "admin"})
+		server = policyserver.NewFakePolicyServer(uaa)
+
+		logger := lagertest.NewTestLogger("test")
+		externalClient = policy_client.NewExternal(logger, http.DefaultClient, server.URL)
+		internalClient = policy_client.NewInternal(logger, http.DefaultClient, server.URL)
+	})
+
+	AfterEach(func() {
+		server.Close()
+		uaa.Close()
+	})
+
+	Describe("external v1 policies", func() {
+		It("creates, lists and deletes policies", func() {
+			Expect(externalClient.AddPolicies("write-token", []api.Policy{
+				policy("app-a", "app-b", "tcp", 8080, 8090),
+				policy("app-b", "app-c", "udp", 53, 53),
+			})).To(Succeed())
+
+			policies, err := externalClient.GetPolicies("write-token")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policies).To(Equal([]api.Policy{
+				policy("app-a", "app-b", "tcp", 8080, 8090),
+				policy("app-b", "app-c", "udp", 53, 53),
+			}))
+
+			policies, err = externalClient.GetPoliciesByID("write-token", "app-c")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policies).To(Equal([]api.Policy{policy("app-b", "app-c", "udp", 53, 53)}))
+
+			Expect(externalClient.DeletePolicies("write-token", []api.Policy{
+				policy("app-a", "app-b", "tcp", 8080, 8090),
+			})).To(Succeed())
+			Expect(server.Policies()).To(HaveLen(1))
+		})
+
+		It("tags the apps of new policies and ignores duplicates", func() {
+			p := policy("app-a", "app-b", "tcp", 8080, 8080)
+			Expect(externalClient.AddPolicies("write-token", []api.Policy{p})).To(Succeed())
+			Expect(externalClient.AddPolicies("write-token", []api.Policy{p})).To(Succeed())
+
+			p.Source.Tag = "0001"
+			p.Destination.Tag = "0002"
+			Expect(server.Policies()).To(Equal([]api.Policy{p}))
+		})
+
+		It("filters by source and destination", func() {
+			server.SetPolicies([]api.Policy{
+				policy("app-a", "app-b", "tcp", 8080, 8080),
+				policy("app-a", "app-c", "tcp", 8080, 8080),
+				policy("app-b", "app-c", "tcp", 8080, 8080),
+			})
+
+			_, body := get("/networking/v1/external/policies?source_id=app-a&dest_id=app-c", "write-token", nil)
+			Expect(body).To(MatchJSON(`{
+				"total_policies": 1,
+				"policies": [{"source": {"id": "app-a"}, "destination": {"id": "app-c", "protocol": "tcp", "ports": {"start": 8080, "end": 8080}}}]
+			}`))
+		})
+
+		It("validates policies like the real server", func() {
+			err := externalClient.AddPolicies("write-token", []api.Policy{policy("app-a", "app-b", "icmp", 8080, 8080)})
+			Expect(err).To(MatchError(ContainSubstring("400 Bad Request")))
+			Expect(err).To(MatchError(ContainSubstring("invalid destination protocol")))
+			Expect(server.Policies()).To(BeEmpty())
+		})
+	})
+
+	Describe("external v0 policies", func() {
+		It("maps policies to and from the v0 API", func() {
+			Expect(externalClient.AddPoliciesV0("write-token", []api_v0.Policy{{
+				Source:      api_v0.Source{ID: "app-a"},
+				Destination: api_v0.Destination{ID: "app-b", Protocol: "tcp", Port: 8080},
+			}})).To(Succeed())
+			Expect(externalClient.AddPolicies("write-token", []api.Policy{policy("app-c", "app-d", "tcp", 9000, 9010)})).To(Succeed())
+
+			policies, err := externalClient.GetPoliciesV0("write-token")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policies).To(Equal([]api_v0.Policy{{
+				Source:      api_v0.Source{ID: "app-a"},
+				Destination: api_v0.Destination{ID: "app-b", Protocol: "tcp", Port: 8080},
+			}}))
+		})
+	})
+
+	Describe("internal policies", func() {
+		BeforeEach(func() {
+			server.SetPolicies([]api.Policy{
+				policy("app-b", "app-c", "tcp", 9000, 9010),
+				policy("app-a", "app-b", "tcp", 8080, 8080),
+			})
+		})
+
+		It("returns the policies with their tags", func() {
+			policies, err := internalClient.GetPolicies()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policies).To(Equal(server.Policies()))
+			Expect(policies[0].Source).To(Equal(api.Source{ID: "app-a", Tag: "0003"}))
+		})
+
+		It("returns 304 Not Modified for a matching ETag", func() {
+			resp, _ := get("/networking/v1/internal/policies", "", nil)
+			etag := resp.Header.Get("ETag")
+			Expect(etag).NotTo(BeEmpty())
+
+			resp, body := get("/networking/v1/internal/policies", "", http.Header{"If-None-Match": {etag}})
+			Expect(resp.StatusCode).To(Equal(http.StatusNotModified))
+			Expect(body).To(BeEmpty())
+		})
+
+		It("serves the v0 internal API", func() {
+			_, body := get("/networking/v0/internal/policies?id=app-a", "", nil)
+			Expect(body).To(MatchJSON(`{
+				"total_policies": 1,
+				"policies": [{
+					"source": {"id": "app-a", "tag": "0003"},
+					"destination": {"id": "app-b", "tag": "0001", "protocol": "tcp", "port": 8080, "ports": {"start": 8080, "end": 8080}}
+				}]
+			}`))
+		})
+	})
+
+	Describe("authentication", func() {
+		It("requires an authorization header", func() {
+			resp, _ := get("/networking/v1/external/policies", "", nil)
+			Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("rejects tokens the UAA does not know", func() {
+			_, err := externalClient.GetPolicies("some-token")
+			Expect(err).To(MatchError(ContainSubstring("403 Forbidden")))
+			Expect(uaa.Requests()).To(Equal([]string{"POST /check_token"}))
+		})
+
+		It("requires the admin scope for tags", func() {
+			_, err := externalClient.GetTags("write-token")
+			Expect(err).To(MatchError(ContainSubstring("do not include allowed scopes")))
+
+			_, err = externalClient.GetTags("admin-token")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("says who the token belongs to", func() {
+			_, body := get("/networking/v1/external/whoami", "admin-token", nil)
+			Expect(body).To(MatchJSON(`{"user_name": "admin"}`))
+		})
+
+		Context("when there is no UAA", func() {
+			BeforeEach(func() {
+				server.Close()
+				server = policyserver.NewFakePolicyServer(nil)
+			})
+
+			It("does not authenticate requests", func() {
+				resp, _ := get("/networking/v1/external/tags", "", nil)
+				Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			})
+		})
+	})
+
+	Describe("cleanup", func() {
+		It("deletes the policies of deleted apps", func() {
+			server.SetPolicies([]api.Policy{
+				policy("app-a", "app-b", "tcp", 8080, 8080),
+				policy("app-b", "app-c", "tcp", 8080, 8080),
+			})
+			server.SetDeletedApps("app-a")
+
+			deleted, err := externalClient.CleanupPolicies("admin-token")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deleted).To(Equal([]api.Policy{policy("app-a", "app-b", "tcp", 8080, 8080)}))
+			Expect(server.Policies()).To(HaveLen(1))
+			Expect(server.Policies()[0].Source.ID).To(Equal("app-b"))
+		})
+	})
+
+	Describe("faults", func() {
+		It("fails requests to a path the given number of times", func() {
+			server.Fail("/networking/v1/external/policies", http.StatusInternalServerError, 1)
+
+			_, err := externalClient.GetPolicies("write-token")
+			Expect(err).To(MatchError(ContainSubstring("500 Internal Server Error")))
+
+			_, err = externalClient.GetPolicies("write-token")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("lets clients retry injected failures", func() {
+			server.Fail("", http.StatusServiceUnavailable, 2)
+			internalClient = policy_client.NewInternalWithRetries(lagertest.NewTestLogger("test"), http.DefaultClient, server.URL, policy_client.RetryConfig{
+				MaxAttempts: 3,
+			})
+
+			_, err := internalClient.GetPolicies()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(server.Requests()).To(HaveLen(3))
+		})
+
+		It("fails every request until reset when times is 0", func() {
+			server.Fail("", http.StatusBadGateway, 0)
+			for i := 0; i < 3; i++ {
+				resp, body := get("/networking/v1/internal/policies", "", nil)
+				Expect(resp.StatusCode).To(Equal(http.StatusBadGateway))
+				Expect(body).To(MatchJSON(`{"error": "injected failure: 502"}`))
+			}
+
+			server.Reset()
+			resp, _ := get("/networking/v1/internal/policies", "", nil)
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("delays responses", func() {
+			server.SetLatency(200 * time.Millisecond)
+			client := &http.Client{Timeout: 50 * time.Millisecond}
+			_, err := client.Get(server.URL + "/networking/v1/internal/policies")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("fails authentication when the UAA fails", func() {
+			uaa.Fail("/check_token", http.StatusInternalServerError, 1)
+			_, err := externalClient.GetPolicies("write-token")
+			Expect(err).To(MatchError(ContainSubstring("failed to verify token with uaa")))
+		})
+	})
+
+	It("records requests", func() {
+		Expect(externalClient.AddPolicies("write-token", []api.Policy{policy("app-a", "app-b", "tcp", 8080, 8080)})).To(Succeed())
+		_, err := externalClient.GetPoliciesByID("write-token", "app-a")
+		Expect(err).NotTo(HaveOccurred())
+
+		requests := server.Requests()
+		Expect(requests).To(HaveLen(2))
+		Expect(requests[0].Method).To(Equal("POST"))
+		Expect(requests[0].Path).To(Equal("/networking/v1/external/policies"))
+		var body map[string][]api.Policy
+		Expect(json.Unmarshal(requests[0].Body, &body)).To(Succeed())
+		Expect(body["policies"]).To(HaveLen(1))
+		Expect(requests[1].RawQuery).To(Equal("id=app-a"))
+		Expect(strings.ToUpper(requests[1].Method)).To(Equal("GET"))
+	})
+})
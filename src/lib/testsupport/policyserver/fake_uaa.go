@@ -0,0 +1,65 @@
+package policyserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"policy-server/fake_cf"
+	"policy-server/uaa_client"
+	"sync"
+)
+
+// FakeUAA is an in-memory UAA that serves the client credentials grant,
+// check_token and token_keys, for the clients and tokens its test adds. It
+// serves the UAA of fake-cf, so that the two fakes do not drift apart.
+type FakeUAA struct {
+	faults
+	URL string
+
+	server   *httptest.Server
+	uaa      *fake_cf.UAA
+	mutex    sync.Mutex
+	requests []string
+}
+
+func NewFakeUAA() *FakeUAA {
+	uaa := &FakeUAA{uaa: &fake_cf.UAA{}}
+	uaa.server = httptest.NewServer(uaa.record(uaa.wrap(uaa.uaa)))
+	uaa.URL = uaa.server.URL
+	return uaa
+}
+
+func (u *FakeUAA) Close() {
+	u.server.Close()
+}
+
+// AddClient makes a client credentials grant for id and secret return token.
+// The client may also check tokens.
+func (u *FakeUAA) AddClient(id, secret, token string) {
+	u.uaa.AddClient(fake_cf.Client{ID: id, Secret: secret, Token: token})
+}
+
+// AddToken makes check_token accept token and return data for it.
+func (u *FakeUAA) AddToken(token string, data uaa_client.CheckTokenResponse) {
+	u.uaa.AddUser(fake_cf.User{
+		GUID:   data.UserID,
+		Name:   data.UserName,
+		Token:  token,
+		Scopes: data.Scope,
+	})
+}
+
+// Requests returns the method and path of every request served so far.
+func (u *FakeUAA) Requests() []string {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return append([]string{}, u.requests...)
+}
+
+func (u *FakeUAA) record(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		u.mutex.Lock()
+		u.requests = append(u.requests, req.Method+" "+req.URL.Path)
+		u.mutex.Unlock()
+		handler.ServeHTTP(w, req)
+	})
+}
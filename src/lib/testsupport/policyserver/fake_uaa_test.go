@@ -0,0 +1,74 @@
+package policyserver_test
+
+import (
+	"lib/testsupport/policyserver"
+	"net/http"
+	"policy-server/uaa_client"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FakeUAA", func() {
+	var (
+		uaa    *policyserver.FakeUAA
+		client *uaa_client.Client
+	)
+
+	BeforeEach(func() {
+		uaa = policyserver.NewFakeUAA()
+		uaa.AddClient("some-client", "some-secret", "some-token")
+		uaa.AddToken("some-token", uaa_client.CheckTokenResponse{
+			Scope:    []string{"network.admin"},
+			UserName: "some-client",
+		})
+		client = &uaa_client.Client{
+			BaseURL:    uaa.URL,
+			Name:       "some-client",
+			Secret:     "some-secret",
+			HTTPClient: http.DefaultClient,
+			Logger:     lagertest.NewTestLogger("test"),
+		}
+	})
+
+	AfterEach(func() {
+		uaa.Close()
+	})
+
+	It("grants tokens to its clients", func() {
+		token, err := client.GetToken()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token).To(Equal("some-token"))
+	})
+
+	It("rejects bad client credentials", func() {
+		client.Secret = "wrong-secret"
+		_, err := client.GetToken()
+		Expect(err).To(BeAssignableToTypeOf(uaa_client.BadUaaResponse{}))
+		Expect(err.(uaa_client.BadUaaResponse).StatusCode).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("checks the tokens it was given", func() {
+		tokenData, err := client.CheckToken("some-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tokenData).To(Equal(uaa_client.CheckTokenResponse{
+			Scope:    []string{"network.admin"},
+			UserName: "some-client",
+		}))
+
+		_, err = client.CheckToken("other-token")
+		Expect(err).To(MatchError(ContainSubstring("bad uaa response: 400")))
+	})
+
+	It("fails requests as scripted", func() {
+		uaa.Fail("/oauth/token", http.StatusServiceUnavailable, 1)
+
+		_, err := client.GetToken()
+		Expect(err).To(MatchError(ContainSubstring("bad uaa response: 503")))
+
+		_, err = client.GetToken()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(uaa.Requests()).To(Equal([]string{"POST /oauth/token", "POST /oauth/token"}))
+	})
+})
@@ -0,0 +1,89 @@
+package policyserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// faults makes the requests of a fake server slow or fail, as scripted by
+// its tests.
+type faults struct {
+	mutex    sync.Mutex
+	latency  time.Duration
+	failures []*failure
+}
+
+type failure struct {
+	path       string
+	statusCode int
+	remaining  int
+}
+
+// Fail makes the next times requests to path fail with statusCode, or every
+// request to path until Reset if times is 0. An empty path matches every
+// request. Failures are checked in the order they were added.
+func (f *faults) Fail(path string, statusCode, times int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.failures = append(f.failures, &failure{path: path, statusCode: statusCode, remaining: times})
+}
+
+// SetLatency delays every response by latency.
+func (f *faults) SetLatency(latency time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.latency = latency
+}
+
+// Reset removes the failures and latency.
+func (f *faults) Reset() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.latency = 0
+	f.failures = nil
+}
+
+func (f *faults) wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		latency, statusCode := f.next(req.URL.Path)
+		time.Sleep(latency)
+		if statusCode != 0 {
+			writeError(w, statusCode, fmt.Sprintf("injected failure: %d", statusCode))
+			return
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
+
+func (f *faults) next(path string) (time.Duration, int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for i, failure := range f.failures {
+		if failure.path != "" && failure.path != path {
+			continue
+		}
+		if failure.remaining > 0 {
+			failure.remaining--
+			if failure.remaining == 0 {
+				f.failures = append(f.failures[:i], f.failures[i+1:]...)
+			}
+		}
+		return f.latency, failure.statusCode
+	}
+	return f.latency, 0
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func writeJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
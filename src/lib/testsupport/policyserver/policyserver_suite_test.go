@@ -0,0 +1,13 @@
+package policyserver_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestPolicyserver(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Policyserver Suite")
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"policy-server/store"
+	"sort"
+	"strings"
+)
+
+// SortPolicies puts policies in a fixed order so that the same policies
+// always render to the same bytes and ETag, whatever order they are stored
+// in.
+func SortPolicies(policies []store.Policy) {
+	sort.Slice(policies, func(i, j int) bool {
+		a, b := policies[i], policies[j]
+		if a.Source.ID != b.Source.ID {
+			return a.Source.ID < b.Source.ID
+		}
+		if a.Destination.ID != b.Destination.ID {
+			return a.Destination.ID < b.Destination.ID
+		}
+		if a.Destination.Protocol != b.Destination.Protocol {
+			return a.Destination.Protocol < b.Destination.Protocol
+		}
+		if a.Destination.Ports.Start != b.Destination.Ports.Start {
+			return a.Destination.Ports.Start < b.Destination.Ports.Start
+		}
+		return a.Destination.Ports.End < b.Destination.Ports.End
+	})
+}
+
+// WriteWithETag writes body with an ETag computed from it, or only the
+// ETag and 304 Not Modified if the If-None-Match header of the request
+// lists it.
+func WriteWithETag(w http.ResponseWriter, req *http.Request, body []byte) {
+	etag := fmt.Sprintf(`"%x"`, sha256.Sum256(body))
+	w.Header().Set("ETag", etag)
+	if etagMatches(req.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// etagMatches reports whether an If-None-Match header lists the etag,
+// comparing weakly as RFC 7232 requires for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package api_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"policy-server/api"
+	"policy-server/store"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SortPolicies", func() {
+	It("orders policies by source, destination, protocol and ports", func() {
+		policy := func(source, destination, protocol string, start int) store.Policy {
+			return store.Policy{
+				Source: store.Source{ID: source},
+				Destination: store.Destination{
+					ID:       destination,
+					Protocol: protocol,
+					Ports:    store.Ports{Start: start, End: start},
+				},
+			}
+		}
+		policies := []store.Policy{
+			policy("b", "a", "tcp", 80),
+			policy("a", "b", "udp", 80),
+			policy("a", "b", "tcp", 90),
+			policy("a", "b", "tcp", 80),
+		}
+
+		api.SortPolicies(policies)
+
+		Expect(policies).To(Equal([]store.Policy{
+			policy("a", "b", "tcp", 80),
+			policy("a", "b", "tcp", 90),
+			policy("a", "b", "udp", 80),
+			policy("b", "a", "tcp", 80),
+		}))
+	})
+})
+
+var _ = Describe("WriteWithETag", func() {
+	var (
+		resp    *httptest.ResponseRecorder
+		request *http.Request
+		etag    string
+	)
+
+	BeforeEach(func() {
+		resp = httptest.NewRecorder()
+		request = httptest.NewRequest("GET", "/some/path", nil)
+		etag = fmt.Sprintf(`"%x"`, sha256.Sum256([]byte("some-body")))
+	})
+
+	It("writes the body with an ETag computed from it", func() {
+		api.WriteWithETag(resp, request, []byte("some-body"))
+
+		Expect(resp.Code).To(Equal(http.StatusOK))
+		Expect(resp.Header().Get("ETag")).To(Equal(etag))
+		Expect(resp.Body.String()).To(Equal("some-body"))
+	})
+
+	DescribeTable("when If-None-Match matches the ETag",
+		func(ifNoneMatch func() string) {
+			request.Header.Set("If-None-Match", ifNoneMatch())
+
+			api.WriteWithETag(resp, request, []byte("some-body"))
+
+			Expect(resp.Code).To(Equal(http.StatusNotModified))
+			Expect(resp.Header().Get("ETag")).To(Equal(etag))
+			Expect(resp.Body.Len()).To(Equal(0))
+		},
+		Entry("exactly", func() string { return etag }),
+		Entry("weakly, in a list", func() string { return `"some-old-etag", W/` + etag }),
+		Entry("with a wildcard", func() string { return "*" }),
+	)
+
+	Context("when If-None-Match lists other ETags", func() {
+		BeforeEach(func() {
+			request.Header.Set("If-None-Match", `"some-old-etag"`)
+		})
+
+		It("writes the body", func() {
+			api.WriteWithETag(resp, request, []byte("some-body"))
+
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Body.String()).To(Equal("some-body"))
+		})
+	})
+})
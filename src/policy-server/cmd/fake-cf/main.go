@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"policy-server/fake_cf"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// fake-cf stands in for the Cloud Controller and UAA so that policy-server
+// can run on a workstation. Point cc_url, uaa_url and uaa_port of the
+// policy-server config at it.
+func main() {
+	seedFile := flag.String("seed-file", "", "path to the JSON file of orgs, spaces, apps, users and clients")
+	listenAddress := flag.String("listen-address", "127.0.0.1:8090", "address to serve the CC and UAA API on")
+	certFile := flag.String("cert-file", "", "path to a TLS certificate, to serve HTTPS")
+	keyFile := flag.String("key-file", "", "path to the key of -cert-file")
+	verbose := flag.Bool("v", false, "log every request")
+	flag.Parse()
+
+	if *seedFile == "" {
+		log.Fatalf("fake-cf: missing -seed-file")
+	}
+	seed, err := fake_cf.LoadSeed(*seedFile)
+	if err != nil {
+		log.Fatalf("fake-cf: %s", err)
+	}
+
+	logger := lager.NewLogger("fake-cf")
+	logLevel := lager.INFO
+	if *verbose {
+		logLevel = lager.DEBUG
+	}
+	logger.RegisterSink(lager.NewWriterSink(os.Stdout, logLevel))
+
+	server := fake_cf.NewServer(logger, seed)
+	logger.Info("starting", lager.Data{"listen-address": *listenAddress, "seed-file": *seedFile})
+
+	if *certFile != "" {
+		err = http.ListenAndServeTLS(*listenAddress, *certFile, *keyFile, server)
+	} else {
+		err = http.ListenAndServe(*listenAddress, server)
+	}
+	log.Fatalf("fake-cf: %s", err)
+}
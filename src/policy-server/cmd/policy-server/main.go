@@ -293,7 +293,7 @@ func main() {
 
 	metricsEmitter := common.InitMetricsEmitter(logger, wrappedStore)
 	externalServer := common.InitServer(logger, nil, conf.ListenHost, conf.ListenPort, externalHandlers, externalRoutesWithOptions)
-	poller := initPoller(logger, conf, policyCleaner)
+	poller := initPoller(logger, conf, policyCleaner, metricsSender)
 	debugServer := debugserver.Runner(fmt.Sprintf("%s:%d", conf.DebugServerHost, conf.DebugServerPort), reconfigurableSink)
 
 	members := grouper.Members{
@@ -320,12 +320,15 @@ func main() {
 	logger.Info("exited")
 }
 
-func initPoller(logger lager.Logger, conf *config.Config, policyCleaner *cleaner.PolicyCleaner) ifrit.Runner {
+func initPoller(logger lager.Logger, conf *config.Config, policyCleaner *cleaner.PolicyCleaner, metricsSender *metrics.MetricsSender) ifrit.Runner {
 	pollInterval := time.Duration(conf.CleanupInterval) * time.Second
 
 	return &poller.Poller{
 		Logger:          logger.Session("policy-cleaner-poller"),
 		PollInterval:    pollInterval,
 		SingleCycleFunc: policyCleaner.DeleteStalePoliciesWrapper,
+		StartJitter:     pollInterval,
+		MetricsSender:   metricsSender,
+		MetricPrefix:    "PolicyCleanup",
 	}
 }
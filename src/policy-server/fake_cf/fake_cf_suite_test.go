@@ -0,0 +1,13 @@
+package fake_cf_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestFakeCf(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "FakeCf Suite")
+}
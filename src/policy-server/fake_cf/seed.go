@@ -0,0 +1,177 @@
+package fake_cf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Seed is the Cloud Foundry state served by a Server: orgs, spaces, apps,
+// users with their space developer roles, and UAA clients. Tokens are opaque
+// strings, so the seed assigns each user and client its token.
+type Seed struct {
+	Orgs    []Org    `json:"orgs"`
+	Spaces  []Space  `json:"spaces"`
+	Apps    []App    `json:"apps"`
+	Users   []User   `json:"users"`
+	Clients []Client `json:"clients"`
+}
+
+type Org struct {
+	GUID string `json:"guid"`
+	Name string `json:"name"`
+}
+
+type Space struct {
+	GUID    string `json:"guid"`
+	Name    string `json:"name"`
+	OrgGUID string `json:"org_guid"`
+}
+
+type App struct {
+	GUID      string `json:"guid"`
+	SpaceGUID string `json:"space_guid"`
+}
+
+type User struct {
+	GUID            string   `json:"guid"`
+	Name            string   `json:"name"`
+	Token           string   `json:"token"`
+	Scopes          []string `json:"scopes"`
+	DeveloperSpaces []string `json:"developer_spaces"`
+}
+
+type Client struct {
+	ID     string   `json:"id"`
+	Secret string   `json:"secret"`
+	Token  string   `json:"token"`
+	Scopes []string `json:"scopes"`
+}
+
+// LoadSeed reads and validates the seed file at path.
+func LoadSeed(path string) (Seed, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Seed{}, fmt.Errorf("reading seed file: %s", err)
+	}
+
+	var seed Seed
+	err = json.Unmarshal(contents, &seed)
+	if err != nil {
+		return Seed{}, fmt.Errorf("parsing seed file: %s", err)
+	}
+
+	err = seed.Validate()
+	if err != nil {
+		return Seed{}, fmt.Errorf("invalid seed file: %s", err)
+	}
+	return seed, nil
+}
+
+// Validate checks that GUIDs and tokens are unique and that every reference
+// names an existing org or space.
+func (s Seed) Validate() error {
+	orgs := map[string]bool{}
+	for _, org := range s.Orgs {
+		if org.GUID == "" {
+			return fmt.Errorf("org %q: missing guid", org.Name)
+		}
+		if orgs[org.GUID] {
+			return fmt.Errorf("org %s: duplicate guid", org.GUID)
+		}
+		orgs[org.GUID] = true
+	}
+
+	spaces := map[string]bool{}
+	for _, space := range s.Spaces {
+		if space.GUID == "" {
+			return fmt.Errorf("space %q: missing guid", space.Name)
+		}
+		if spaces[space.GUID] {
+			return fmt.Errorf("space %s: duplicate guid", space.GUID)
+		}
+		if !orgs[space.OrgGUID] {
+			return fmt.Errorf("space %s: unknown org %q", space.GUID, space.OrgGUID)
+		}
+		spaces[space.GUID] = true
+	}
+
+	apps := map[string]bool{}
+	for _, app := range s.Apps {
+		err := validateApp(app, apps, spaces)
+		if err != nil {
+			return err
+		}
+		apps[app.GUID] = true
+	}
+
+	users := map[string]bool{}
+	tokens := map[string]bool{}
+	for _, user := range s.Users {
+		if user.GUID == "" {
+			return fmt.Errorf("user %q: missing guid", user.Name)
+		}
+		if users[user.GUID] {
+			return fmt.Errorf("user %s: duplicate guid", user.GUID)
+		}
+		if user.Token == "" || tokens[user.Token] {
+			return fmt.Errorf("user %s: missing or duplicate token", user.GUID)
+		}
+		for _, spaceGUID := range user.DeveloperSpaces {
+			if !spaces[spaceGUID] {
+				return fmt.Errorf("user %s: unknown space %q", user.GUID, spaceGUID)
+			}
+		}
+		users[user.GUID] = true
+		tokens[user.Token] = true
+	}
+
+	clients := map[string]bool{}
+	for _, client := range s.Clients {
+		if client.ID == "" {
+			return fmt.Errorf("client: missing id")
+		}
+		if clients[client.ID] {
+			return fmt.Errorf("client %s: duplicate id", client.ID)
+		}
+		if client.Token == "" || tokens[client.Token] {
+			return fmt.Errorf("client %s: missing or duplicate token", client.ID)
+		}
+		clients[client.ID] = true
+		tokens[client.Token] = true
+	}
+	return nil
+}
+
+func validateApp(app App, apps, spaces map[string]bool) error {
+	if app.GUID == "" {
+		return fmt.Errorf("app: missing guid")
+	}
+	if apps[app.GUID] {
+		return fmt.Errorf("app %s: duplicate guid", app.GUID)
+	}
+	if !spaces[app.SpaceGUID] {
+		return fmt.Errorf("app %s: unknown space %q", app.GUID, app.SpaceGUID)
+	}
+	return nil
+}
+
+func (s Seed) copy() Seed {
+	c := Seed{
+		Orgs:    append([]Org{}, s.Orgs...),
+		Spaces:  append([]Space{}, s.Spaces...),
+		Apps:    append([]App{}, s.Apps...),
+		Users:   make([]User, len(s.Users)),
+		Clients: make([]Client, len(s.Clients)),
+	}
+	for i, user := range s.Users {
+		user.Scopes = append([]string{}, user.Scopes...)
+		user.DeveloperSpaces = append([]string{}, user.DeveloperSpaces...)
+		c.Users[i] = user
+	}
+	for i, client := range s.Clients {
+		client.Scopes = append([]string{}, client.Scopes...)
+		c.Clients[i] = client
+	}
+	return c
+}
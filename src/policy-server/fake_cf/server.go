@@ -0,0 +1,417 @@
+package fake_cf
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	defaultPerPage = 50
+	maxPerPage     = 5000
+)
+
+// Server is a stand-in Cloud Controller and UAA. It serves the endpoints
+// policy-server uses from the state in its Seed, and admin endpoints under
+// /admin that change that state at runtime.
+type Server struct {
+	Logger lager.Logger
+
+	mutex   sync.Mutex
+	initial Seed
+	state   Seed
+	uaa     *UAA
+}
+
+func NewServer(logger lager.Logger, seed Seed) *Server {
+	uaa := &UAA{}
+	uaa.SetAccounts(seed.Users, seed.Clients)
+	return &Server{
+		Logger:  logger,
+		initial: seed.copy(),
+		state:   seed.copy(),
+		uaa:     uaa,
+	}
+}
+
+// State returns a copy of the current state.
+func (s *Server) State() Seed {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.state.copy()
+}
+
+// SetState replaces the current state with seed.
+func (s *Server) SetState(seed Seed) error {
+	err := seed.Validate()
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.state = seed.copy()
+	s.uaa.SetAccounts(s.state.Users, s.state.Clients)
+	return nil
+}
+
+// Reset restores the state the server was created with.
+func (s *Server) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.state = s.initial.copy()
+	s.uaa.SetAccounts(s.state.Users, s.state.Clients)
+}
+
+// AddApp adds app to the current state.
+func (s *Server) AddApp(app App) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	apps := map[string]bool{}
+	for _, a := range s.state.Apps {
+		apps[a.GUID] = true
+	}
+	spaces := map[string]bool{}
+	for _, space := range s.state.Spaces {
+		spaces[space.GUID] = true
+	}
+	err := validateApp(app, apps, spaces)
+	if err != nil {
+		return err
+	}
+	s.state.Apps = append(s.state.Apps, app)
+	return nil
+}
+
+// DeleteApp removes the app with guid from the current state, and returns
+// false if there is no such app.
+func (s *Server) DeleteApp(guid string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, app := range s.state.Apps {
+		if app.GUID == guid {
+			s.state.Apps = append(s.state.Apps[:i], s.state.Apps[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.Logger.Debug("request", lager.Data{"method": req.Method, "url": req.URL.String()})
+
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	switch {
+	case strings.HasPrefix(path, "/admin/"):
+		s.serveAdmin(w, req, strings.TrimPrefix(path, "/admin"))
+	case path == "/oauth/token" || path == "/check_token" || path == "/token_keys":
+		s.uaa.ServeHTTP(w, req)
+	case strings.HasPrefix(path, "/v2/") || strings.HasPrefix(path, "/v3/"):
+		if req.Method != "GET" {
+			writeCCError(w, http.StatusMethodNotAllowed, 10000, "CF-NotFound", "Unknown request")
+			return
+		}
+		if !s.authorized(req) {
+			writeCCError(w, http.StatusUnauthorized, 1000, "CF-InvalidAuthToken", "Invalid Auth Token")
+			return
+		}
+		s.serveCC(w, req, path)
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+func (s *Server) serveAdmin(w http.ResponseWriter, req *http.Request, path string) {
+	switch {
+	case path == "/state" && req.Method == "GET":
+		writeJSON(w, http.StatusOK, s.State())
+	case path == "/state" && req.Method == "PUT":
+		var seed Seed
+		err := json.NewDecoder(req.Body).Decode(&seed)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid state: %s", err)})
+			return
+		}
+		err = s.SetState(seed)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid state: %s", err)})
+			return
+		}
+		s.Logger.Info("state-replaced")
+		w.WriteHeader(http.StatusNoContent)
+	case path == "/reset" && req.Method == "POST":
+		s.Reset()
+		s.Logger.Info("state-reset")
+		w.WriteHeader(http.StatusNoContent)
+	case path == "/apps" && req.Method == "POST":
+		var app App
+		err := json.NewDecoder(req.Body).Decode(&app)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid app: %s", err)})
+			return
+		}
+		err = s.AddApp(app)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid app: %s", err)})
+			return
+		}
+		s.Logger.Info("app-added", lager.Data{"app": app.GUID, "space": app.SpaceGUID})
+		writeJSON(w, http.StatusCreated, app)
+	case strings.HasPrefix(path, "/apps/") && req.Method == "DELETE":
+		guid := strings.TrimPrefix(path, "/apps/")
+		if !s.DeleteApp(guid) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("app %s not found", guid)})
+			return
+		}
+		s.Logger.Info("app-deleted", lager.Data{"app": guid})
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+// authorized accepts the token of any user or client, like the Cloud
+// Controller does for the read-only calls policy-server makes.
+func (s *Server) authorized(req *http.Request) bool {
+	header := req.Header.Get("Authorization")
+	if len(header) < len("bearer ") || !strings.EqualFold(header[:len("bearer ")], "bearer ") {
+		return false
+	}
+	return s.uaa.Known(header[len("bearer "):])
+}
+
+func (s *Server) serveCC(w http.ResponseWriter, req *http.Request, path string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	switch {
+	case path == "/v3/apps":
+		s.serveApps(w, req)
+	case path == "/v2/spaces":
+		s.serveSpaces(w, req)
+	case len(parts) == 3 && parts[0] == "v2" && parts[1] == "spaces":
+		for _, space := range s.state.Spaces {
+			if space.GUID == parts[2] {
+				writeJSON(w, http.StatusOK, spaceResource(space))
+				return
+			}
+		}
+		writeCCError(w, http.StatusNotFound, 40004, "CF-SpaceNotFound",
+			fmt.Sprintf("The app space could not be found: %s", parts[2]))
+	case len(parts) == 4 && parts[0] == "v2" && parts[1] == "users" && parts[3] == "spaces":
+		user, ok := s.user(parts[2])
+		if !ok {
+			writeCCError(w, http.StatusNotFound, 20003, "CF-UserNotFound",
+				fmt.Sprintf("The user could not be found: %s", parts[2]))
+			return
+		}
+		spaces := []Space{}
+		for _, space := range s.state.Spaces {
+			if contains(user.DeveloperSpaces, space.GUID) {
+				spaces = append(spaces, space)
+			}
+		}
+		writeSpaces(w, spaces)
+	default:
+		writeCCError(w, http.StatusNotFound, 10000, "CF-NotFound", "Unknown request")
+	}
+}
+
+type appsV3Link struct {
+	Href string `json:"href"`
+}
+
+func (s *Server) serveApps(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	perPage, err := intParam(query.Get("per_page"), defaultPerPage)
+	if err != nil || perPage < 1 || perPage > maxPerPage {
+		writeCCError(w, http.StatusBadRequest, 10008, "CF-UnprocessableEntity",
+			fmt.Sprintf("Per page must be between 1 and %d", maxPerPage))
+		return
+	}
+	page, err := intParam(query.Get("page"), 1)
+	if err != nil || page < 1 {
+		writeCCError(w, http.StatusBadRequest, 10008, "CF-UnprocessableEntity", "Page must be greater than 0")
+		return
+	}
+
+	apps := s.state.Apps
+	if guids := query.Get("guids"); guids != "" {
+		wanted := strings.Split(guids, ",")
+		apps = []App{}
+		for _, app := range s.state.Apps {
+			if contains(wanted, app.GUID) {
+				apps = append(apps, app)
+			}
+		}
+	}
+
+	totalPages := (len(apps) + perPage - 1) / perPage
+	if totalPages == 0 {
+		totalPages = 1
+	}
+	start := (page - 1) * perPage
+	if start > len(apps) {
+		start = len(apps)
+	}
+	end := start + perPage
+	if end > len(apps) {
+		end = len(apps)
+	}
+
+	baseURL := requestBaseURL(req)
+	pageLink := func(n int) *appsV3Link {
+		query.Set("page", strconv.Itoa(n))
+		query.Set("per_page", strconv.Itoa(perPage))
+		return &appsV3Link{Href: fmt.Sprintf("%s/v3/apps?%s", baseURL, query.Encode())}
+	}
+
+	type resource struct {
+		GUID  string                `json:"guid"`
+		Links map[string]appsV3Link `json:"links"`
+	}
+	resources := []resource{}
+	for _, app := range apps[start:end] {
+		resources = append(resources, resource{
+			GUID: app.GUID,
+			Links: map[string]appsV3Link{
+				"self":  {Href: fmt.Sprintf("%s/v3/apps/%s", baseURL, app.GUID)},
+				"space": {Href: fmt.Sprintf("%s/v3/spaces/%s", baseURL, app.SpaceGUID)},
+			},
+		})
+	}
+
+	pagination := map[string]interface{}{
+		"total_results": len(apps),
+		"total_pages":   totalPages,
+		"first":         pageLink(1),
+		"last":          pageLink(totalPages),
+		"next":          nil,
+		"previous":      nil,
+	}
+	if page < totalPages {
+		pagination["next"] = pageLink(page + 1)
+	}
+	if page > 1 {
+		pagination["previous"] = pageLink(page - 1)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"pagination": pagination,
+		"resources":  resources,
+	})
+}
+
+// serveSpaces filters the spaces by the q parameters policy-server sends,
+// each of the form field:value.
+func (s *Server) serveSpaces(w http.ResponseWriter, req *http.Request) {
+	spaces := []Space{}
+	for _, space := range s.state.Spaces {
+		matches := true
+		for _, filter := range req.URL.Query()["q"] {
+			parts := strings.SplitN(filter, ":", 2)
+			if len(parts) != 2 {
+				writeCCError(w, http.StatusBadRequest, 1001, "CF-InvalidRequest", fmt.Sprintf("Invalid filter: %s", filter))
+				return
+			}
+			switch parts[0] {
+			case "name":
+				matches = matches && space.Name == parts[1]
+			case "organization_guid":
+				matches = matches && space.OrgGUID == parts[1]
+			case "developer_guid":
+				user, ok := s.user(parts[1])
+				matches = matches && ok && contains(user.DeveloperSpaces, space.GUID)
+			default:
+				writeCCError(w, http.StatusBadRequest, 1001, "CF-InvalidRequest", fmt.Sprintf("Unsupported filter: %s", parts[0]))
+				return
+			}
+		}
+		if matches {
+			spaces = append(spaces, space)
+		}
+	}
+	writeSpaces(w, spaces)
+}
+
+func (s *Server) user(guid string) (User, bool) {
+	for _, user := range s.state.Users {
+		if user.GUID == guid {
+			return user, true
+		}
+	}
+	return User{}, false
+}
+
+func spaceResource(space Space) map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": map[string]string{
+			"guid": space.GUID,
+			"url":  fmt.Sprintf("/v2/spaces/%s", space.GUID),
+		},
+		"entity": map[string]string{
+			"name":              space.Name,
+			"organization_guid": space.OrgGUID,
+			"organization_url":  fmt.Sprintf("/v2/organizations/%s", space.OrgGUID),
+		},
+	}
+}
+
+func writeSpaces(w http.ResponseWriter, spaces []Space) {
+	resources := []map[string]interface{}{}
+	for _, space := range spaces {
+		resources = append(resources, spaceResource(space))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"total_results": len(spaces),
+		"total_pages":   1,
+		"prev_url":      nil,
+		"next_url":      nil,
+		"resources":     resources,
+	})
+}
+
+func requestBaseURL(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, req.Host)
+}
+
+func intParam(value string, def int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func writeCCError(w http.ResponseWriter, statusCode, code int, errorCode, description string) {
+	writeJSON(w, statusCode, map[string]interface{}{
+		"code":        code,
+		"description": description,
+		"error_code":  errorCode,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
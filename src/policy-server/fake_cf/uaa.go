@@ -0,0 +1,199 @@
+package fake_cf
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// UAA is a stand-in UAA. It serves the client credentials grant, check_token
+// and token_keys for its users and clients. Tokens are opaque strings, so
+// every user and client comes with its token.
+type UAA struct {
+	mutex   sync.Mutex
+	users   []User
+	clients []Client
+	keyOnce sync.Once
+	key     *rsa.PrivateKey
+	keyErr  error
+}
+
+// SetAccounts replaces the users and clients of the UAA.
+func (u *UAA) SetAccounts(users []User, clients []Client) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.users = append([]User{}, users...)
+	u.clients = append([]Client{}, clients...)
+}
+
+// AddUser adds user, replacing the user with the same token.
+func (u *UAA) AddUser(user User) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	for i, existing := range u.users {
+		if existing.Token == user.Token {
+			u.users[i] = user
+			return
+		}
+	}
+	u.users = append(u.users, user)
+}
+
+// AddClient adds client, replacing the client with the same id.
+func (u *UAA) AddClient(client Client) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	for i, existing := range u.clients {
+		if existing.ID == client.ID {
+			u.clients[i] = client
+			return
+		}
+	}
+	u.clients = append(u.clients, client)
+}
+
+func (u *UAA) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	switch {
+	case path == "/oauth/token" && req.Method == "POST":
+		u.serveOAuthToken(w, req)
+	case path == "/check_token" && req.Method == "POST":
+		u.serveCheckToken(w, req)
+	case path == "/token_keys" && req.Method == "GET":
+		u.serveTokenKeys(w)
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+// Known returns whether token belongs to one of the users or clients.
+func (u *UAA) Known(token string) bool {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	for _, user := range u.users {
+		if user.Token == token {
+			return true
+		}
+	}
+	for _, client := range u.clients {
+		if client.Token == token {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *UAA) serveOAuthToken(w http.ResponseWriter, req *http.Request) {
+	id, secret, _ := req.BasicAuth()
+	client, ok := u.client(id, secret)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{
+			"error":             "unauthorized",
+			"error_description": "Bad credentials",
+		})
+		return
+	}
+	if req.FormValue("grant_type") != "client_credentials" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error":             "unsupported_grant_type",
+			"error_description": "only client_credentials is supported",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": client.Token,
+		"token_type":   "bearer",
+		"expires_in":   43199,
+		"scope":        strings.Join(client.Scopes, " "),
+		"jti":          client.ID + "-jti",
+	})
+}
+
+func (u *UAA) serveCheckToken(w http.ResponseWriter, req *http.Request) {
+	id, secret, _ := req.BasicAuth()
+	if _, ok := u.client(id, secret); !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{
+			"error":             "unauthorized",
+			"error_description": "Bad credentials",
+		})
+		return
+	}
+
+	token := req.FormValue("token")
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	for _, user := range u.users {
+		if user.Token == token {
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"scope":     user.Scopes,
+				"user_id":   user.GUID,
+				"user_name": user.Name,
+			})
+			return
+		}
+	}
+	for _, client := range u.clients {
+		if client.Token == token {
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"scope":     client.Scopes,
+				"client_id": client.ID,
+			})
+			return
+		}
+	}
+	writeJSON(w, http.StatusBadRequest, map[string]string{
+		"error":             "invalid_token",
+		"error_description": "The token is not recognised",
+	})
+}
+
+// serveTokenKeys serves the public half of a key generated on first use.
+// Tokens are opaque strings rather than JWTs signed with it, so this only
+// satisfies clients that fetch the keys.
+func (u *UAA) serveTokenKeys(w http.ResponseWriter) {
+	u.keyOnce.Do(func() {
+		u.key, u.keyErr = rsa.GenerateKey(rand.Reader, 2048)
+	})
+	if u.keyErr != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("generating key: %s", u.keyErr)})
+		return
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&u.key.PublicKey)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("marshaling key: %s", err)})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid":   "fake-cf-key",
+			"alg":   "RS256",
+			"kty":   "RSA",
+			"use":   "sig",
+			"e":     base64.RawURLEncoding.EncodeToString(big.NewInt(int64(u.key.PublicKey.E)).Bytes()),
+			"n":     base64.RawURLEncoding.EncodeToString(u.key.PublicKey.N.Bytes()),
+			"value": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		}},
+	})
+}
+
+func (u *UAA) client(id, secret string) (Client, bool) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	for _, client := range u.clients {
+		if client.ID == id && client.Secret == secret {
+			return client, true
+		}
+	}
+	return Client{}, false
+}
@@ -57,14 +57,14 @@ func (h *PoliciesIndexInternal) ServeHTTP(w http.ResponseWriter, req *http.Reque
 		return
 	}
 
+	api.SortPolicies(policies)
 	bytes, err := h.Mapper.AsBytes(policies)
 	if err != nil {
 		h.ErrorResponse.InternalServerError(logger, w, err, "map policy as bytes failed")
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(bytes)
+	api.WriteWithETag(w, req, bytes)
 }
 
 func parseIds(queryValues url.Values) []string {
@@ -1,7 +1,9 @@
 package handlers_test
 
 import (
+	"crypto/sha256"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"policy-server/handlers"
@@ -125,6 +127,70 @@ var _ = Describe("PoliciesIndexInternal", func() {
 		})
 	})
 
+	It("passes the policies to the mapper in a fixed order", func() {
+		request, err := http.NewRequest("GET", "/networking/v0/internal/policies", nil)
+		Expect(err).NotTo(HaveOccurred())
+		MakeRequestWithLogger(handler.ServeHTTP, resp, request, logger)
+
+		Expect(fakeMapper.AsBytesCallCount()).To(Equal(1))
+		policies := fakeMapper.AsBytesArgsForCall(0)
+		Expect(policies).To(HaveLen(2))
+		Expect(policies[0].Source.ID).To(Equal("another-app-guid"))
+		Expect(policies[1].Source.ID).To(Equal("some-app-guid"))
+	})
+
+	Describe("conditional requests", func() {
+		var expectedETag string
+
+		BeforeEach(func() {
+			expectedETag = fmt.Sprintf(`"%x"`, sha256.Sum256(expectedResponseBody))
+		})
+
+		It("sets an ETag computed from the response body", func() {
+			request, err := http.NewRequest("GET", "/networking/v0/internal/policies?id=some-app-guid", nil)
+			Expect(err).NotTo(HaveOccurred())
+			MakeRequestWithLogger(handler.ServeHTTP, resp, request, logger)
+
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Header().Get("ETag")).To(Equal(expectedETag))
+		})
+
+		It("sets a different ETag when the policies change", func() {
+			fakeMapper.AsBytesReturns([]byte("some-other-response"), nil)
+			request, err := http.NewRequest("GET", "/networking/v0/internal/policies?id=some-app-guid", nil)
+			Expect(err).NotTo(HaveOccurred())
+			MakeRequestWithLogger(handler.ServeHTTP, resp, request, logger)
+
+			Expect(resp.Header().Get("ETag")).NotTo(BeEmpty())
+			Expect(resp.Header().Get("ETag")).NotTo(Equal(expectedETag))
+		})
+
+		Context("when If-None-Match lists the current ETag", func() {
+			It("responds with 304 and no body", func() {
+				request, err := http.NewRequest("GET", "/networking/v0/internal/policies?id=some-app-guid", nil)
+				Expect(err).NotTo(HaveOccurred())
+				request.Header.Set("If-None-Match", `"some-old-etag", W/`+expectedETag)
+				MakeRequestWithLogger(handler.ServeHTTP, resp, request, logger)
+
+				Expect(resp.Code).To(Equal(http.StatusNotModified))
+				Expect(resp.Header().Get("ETag")).To(Equal(expectedETag))
+				Expect(resp.Body.Bytes()).To(BeEmpty())
+			})
+		})
+
+		Context("when If-None-Match does not list the current ETag", func() {
+			It("responds with the policies", func() {
+				request, err := http.NewRequest("GET", "/networking/v0/internal/policies?id=some-app-guid", nil)
+				Expect(err).NotTo(HaveOccurred())
+				request.Header.Set("If-None-Match", `"some-old-etag"`)
+				MakeRequestWithLogger(handler.ServeHTTP, resp, request, logger)
+
+				Expect(resp.Code).To(Equal(http.StatusOK))
+				Expect(resp.Body.Bytes()).To(Equal(expectedResponseBody))
+			})
+		})
+	})
+
 	Context("when rendering the policies as bytes fails", func() {
 		BeforeEach(func() {
 			fakeMapper.AsBytesReturns(nil, errors.New("banana"))
@@ -146,6 +146,11 @@ var _ = Describe("Policy Cleanup", func() {
 			Eventually(fakeMetron.AllEvents, "5s").Should(ContainElement(
 				HaveName("StoreDeleteSuccessTime"),
 			))
+
+			By("emitting poll cycle metrics")
+			Eventually(fakeMetron.AllEvents, "5s").Should(ContainElement(
+				HaveName("PolicyCleanupPollCycleTime"),
+			))
 		})
 	})
 })
@@ -2,6 +2,7 @@ package addresstable
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -24,10 +25,20 @@ type AddressTable struct {
 }
 
 type entry struct {
-	ip         string
+	Instance
 	updateTime time.Time
 }
 
+// Instance is a single registered app instance. Index, GUID, Zone and
+// CellID are empty for registrations that do not carry instance metadata.
+type Instance struct {
+	IP     string
+	Index  string
+	GUID   string
+	Zone   string
+	CellID string
+}
+
 func NewAddressTable(stalenessThreshold, pruningInterval, resumePruningDelay time.Duration, clock clock.Clock, logger lager.Logger) *AddressTable {
 	table := &AddressTable{
 		addresses:          map[string][]entry{},
@@ -44,14 +55,19 @@ func NewAddressTable(stalenessThreshold, pruningInterval, resumePruningDelay tim
 }
 
 func (at *AddressTable) Add(hostnames []string, ip string) {
+	at.AddInstance(hostnames, Instance{IP: ip})
+}
+
+func (at *AddressTable) AddInstance(hostnames []string, instance Instance) {
 	at.mutex.Lock()
 	for _, hostname := range hostnames {
 		fqHostname := fqdn(hostname)
 		entries := at.entriesForHostname(fqHostname)
-		entryIndex := indexOf(entries, ip)
+		entryIndex := indexOf(entries, instance.IP)
 		if entryIndex == -1 {
-			at.addresses[fqHostname] = append(entries, entry{ip: ip, updateTime: at.clock.Now()})
+			at.addresses[fqHostname] = append(entries, entry{Instance: instance, updateTime: at.clock.Now()})
 		} else {
+			at.addresses[fqHostname][entryIndex].Instance = instance
 			at.addresses[fqHostname][entryIndex].updateTime = at.clock.Now()
 		}
 	}
@@ -78,7 +94,7 @@ func (at *AddressTable) Remove(hostnames []string, ip string) {
 func (at *AddressTable) Lookup(hostname string) []string {
 	at.mutex.RLock()
 
-	found := at.entriesForHostname(fqdn(hostname))
+	found := at.entriesForLookup(fqdn(hostname))
 	ips := entriesToIPs(found)
 
 	at.mutex.RUnlock()
@@ -86,6 +102,20 @@ func (at *AddressTable) Lookup(hostname string) []string {
 	return ips
 }
 
+func (at *AddressTable) LookupInstances(hostname string) []Instance {
+	at.mutex.RLock()
+
+	found := at.entriesForLookup(fqdn(hostname))
+	instances := make([]Instance, len(found))
+	for idx, entry := range found {
+		instances[idx] = entry.Instance
+	}
+
+	at.mutex.RUnlock()
+
+	return instances
+}
+
 func (at *AddressTable) GetAllAddresses() map[string][]string {
 	at.mutex.RLock()
 
@@ -140,10 +170,53 @@ func (at *AddressTable) entriesForHostname(hostname string) []entry {
 	}
 }
 
+// entriesForLookup resolves a hostname exactly, falling back to treating a
+// numeric first label as an instance index: "2.app.apps.internal." returns
+// the instance with index 2 of "app.apps.internal.". If an index has been
+// re-registered from a new IP before the old one is pruned, the most
+// recently updated instance wins.
+func (at *AddressTable) entriesForLookup(hostname string) []entry {
+	if existing := at.addresses[hostname]; len(existing) > 0 {
+		return existing
+	}
+
+	labels := strings.SplitN(hostname, ".", 2)
+	if len(labels) != 2 || !isInstanceIndex(labels[0]) {
+		return []entry{}
+	}
+
+	var newest *entry
+	for idx, entry := range at.addresses[labels[1]] {
+		if entry.Index != labels[0] {
+			continue
+		}
+		if newest == nil || entry.updateTime.After(newest.updateTime) {
+			newest = &at.addresses[labels[1]][idx]
+		}
+	}
+
+	if newest == nil {
+		return []entry{}
+	}
+	return []entry{*newest}
+}
+
+func isInstanceIndex(label string) bool {
+	if label == "" {
+		return false
+	}
+	for _, c := range label {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 func entriesToIPs(entries []entry) []string {
 	ips := make([]string, len(entries))
 	for idx, entry := range entries {
-		ips[idx] = entry.ip
+		ips[idx] = entry.IP
 	}
 
 	return ips
@@ -181,7 +254,7 @@ func (at *AddressTable) pruneStaleEntriesWithWriteLock(candidateAddresses []stri
 				if at.clock.Since(entry.updateTime) <= at.stalenessThreshold {
 					freshEntries = append(freshEntries, entry)
 				} else {
-					at.logger.Debug(fmt.Sprintf("pruning address %s from %s", entry.ip, staleAddr))
+					at.logger.Debug(fmt.Sprintf("pruning address %s from %s", entry.IP, staleAddr))
 				}
 			}
 			at.addresses[staleAddr] = freshEntries
@@ -211,7 +284,7 @@ func (at *AddressTable) addressesWithStaleEntriesWithReadLock() []string {
 
 func indexOf(entries []entry, value string) int {
 	for idx, entry := range entries {
-		if entry.ip == value {
+		if entry.IP == value {
 			return idx
 		}
 	}
@@ -78,6 +78,80 @@ var _ = Describe("AddressTable", func() {
 		})
 	})
 
+	Describe("AddInstance", func() {
+		BeforeEach(func() {
+			table.AddInstance([]string{"foo.com"}, addresstable.Instance{IP: "192.0.0.1", Index: "0", GUID: "guid-0"})
+			table.AddInstance([]string{"foo.com"}, addresstable.Instance{IP: "192.0.0.2", Index: "1", GUID: "guid-1"})
+		})
+
+		It("returns every instance for the hostname", func() {
+			Expect(table.Lookup("foo.com.")).To(Equal([]string{"192.0.0.1", "192.0.0.2"}))
+			Expect(table.LookupInstances("foo.com.")).To(Equal([]addresstable.Instance{
+				{IP: "192.0.0.1", Index: "0", GUID: "guid-0"},
+				{IP: "192.0.0.2", Index: "1", GUID: "guid-1"},
+			}))
+		})
+
+		It("returns a single instance for <index>.<hostname>", func() {
+			Expect(table.Lookup("1.foo.com.")).To(Equal([]string{"192.0.0.2"}))
+			Expect(table.LookupInstances("0.foo.com")).To(Equal([]addresstable.Instance{
+				{IP: "192.0.0.1", Index: "0", GUID: "guid-0"},
+			}))
+		})
+
+		It("returns nothing for an unknown index", func() {
+			Expect(table.Lookup("2.foo.com.")).To(Equal([]string{}))
+		})
+
+		It("does not treat non-numeric labels as an index", func() {
+			Expect(table.Lookup("a.foo.com.")).To(Equal([]string{}))
+		})
+
+		Context("when the indexed hostname is registered directly", func() {
+			BeforeEach(func() {
+				table.Add([]string{"0.foo.com"}, "192.0.0.9")
+			})
+
+			It("prefers the exact match", func() {
+				Expect(table.Lookup("0.foo.com.")).To(Equal([]string{"192.0.0.9"}))
+			})
+
+			Context("when the exact match is pruned", func() {
+				BeforeEach(func() {
+					fakeClock.Increment(stalenessThreshold - 1*time.Second)
+					table.AddInstance([]string{"foo.com"}, addresstable.Instance{IP: "192.0.0.1", Index: "0", GUID: "guid-0"})
+					fakeClock.Increment(1001 * time.Millisecond)
+				})
+
+				It("falls back to the instance index", func() {
+					Eventually(func() []string { return table.Lookup("0.foo.com.") }).Should(Equal([]string{"192.0.0.1"}))
+				})
+			})
+		})
+
+		Context("when an index is re-registered from a new ip", func() {
+			BeforeEach(func() {
+				fakeClock.Increment(time.Second)
+				table.AddInstance([]string{"foo.com"}, addresstable.Instance{IP: "192.0.0.3", Index: "0", GUID: "guid-2"})
+			})
+
+			It("returns the most recently updated instance", func() {
+				Expect(table.Lookup("0.foo.com.")).To(Equal([]string{"192.0.0.3"}))
+			})
+		})
+
+		Context("when an existing ip is re-registered with new metadata", func() {
+			BeforeEach(func() {
+				table.AddInstance([]string{"foo.com"}, addresstable.Instance{IP: "192.0.0.1", Index: "3", GUID: "guid-3"})
+			})
+
+			It("updates the instance", func() {
+				Expect(table.Lookup("0.foo.com.")).To(Equal([]string{}))
+				Expect(table.Lookup("3.foo.com.")).To(Equal([]string{"192.0.0.1"}))
+			})
+		})
+	})
+
 	Describe("GetAllAddresses", func() {
 		BeforeEach(func() {
 			table.Add([]string{"foo.com"}, "192.0.0.1")
@@ -0,0 +1,216 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"service-discovery-controller/staticrecords"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/pivotal-cf/paraphernalia/secure/tlsconfig"
+)
+
+const recordsPath = "/v1/static-records"
+
+//go:generate counterfeiter -o fakes/record_store.go --fake-name RecordStore . RecordStore
+type RecordStore interface {
+	Add(record staticrecords.Record) (bool, error)
+	Remove(record staticrecords.Record) (bool, error)
+	List() []staticrecords.Record
+}
+
+type Server struct {
+	address    string
+	port       int
+	token      string
+	serverCert string
+	serverKey  string
+	store      RecordStore
+	logger     lager.Logger
+}
+
+type recordsResponse struct {
+	Records []staticrecords.Record `json:"records"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func NewServer(address string, port int, token, serverCert, serverKey string, store RecordStore, logger lager.Logger) *Server {
+	return &Server{
+		address:    address,
+		port:       port,
+		token:      token,
+		serverCert: serverCert,
+		serverKey:  serverKey,
+		store:      store,
+		logger:     logger,
+	}
+}
+
+func (s *Server) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	cert, err := tls.LoadX509KeyPair(s.serverCert, s.serverKey)
+	if err != nil {
+		return fmt.Errorf("unable to load x509 key pair: %s", err)
+	}
+
+	tlsConfig := tlsconfig.Build(
+		tlsconfig.WithIdentity(cert),
+		tlsconfig.WithInternalServiceDefaults(),
+	).Server()
+
+	mux := http.NewServeMux()
+	mux.Handle(recordsPath, s.Handler())
+
+	httpServer := &http.Server{
+		Addr:      fmt.Sprintf("%s:%d", s.address, s.port),
+		Handler:   mux,
+		TLSConfig: tlsConfig,
+	}
+
+	listener, err := net.Listen("tcp", httpServer.Addr)
+	if err != nil {
+		return err
+	}
+
+	exited := make(chan error)
+	go func() {
+		exited <- httpServer.ServeTLS(listener, "", "")
+	}()
+
+	close(ready)
+	s.logger.Info("admin-server-started", lager.Data{"address": httpServer.Addr})
+
+	select {
+	case err := <-exited:
+		httpServer.Close()
+		return err
+	case <-signals:
+		httpServer.Close()
+		s.logger.Info("admin-server-stopped")
+		return nil
+	}
+}
+
+// Handler serves the static records API. Every request must carry the
+// configured admin token as a bearer token.
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if !s.authorized(req) {
+			s.writeError(resp, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+
+		switch req.Method {
+		case http.MethodGet:
+			s.list(resp)
+		case http.MethodPost:
+			s.add(resp, req)
+		case http.MethodDelete:
+			s.remove(resp, req)
+		default:
+			s.writeError(resp, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	})
+}
+
+func (s *Server) authorized(req *http.Request) bool {
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+
+	token := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func (s *Server) list(resp http.ResponseWriter) {
+	s.writeJSON(resp, http.StatusOK, recordsResponse{Records: s.store.List()})
+}
+
+func (s *Server) add(resp http.ResponseWriter, req *http.Request) {
+	record, ok := s.decodeRecord(resp, req)
+	if !ok {
+		return
+	}
+
+	added, err := s.store.Add(record)
+	if err != nil {
+		s.logger.Error("add-static-record", err, lager.Data{"hostname": record.Hostname, "ip": record.IP})
+		s.writeError(resp, http.StatusInternalServerError, "failed to store record")
+		return
+	}
+
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+		s.logger.Info("added-static-record", lager.Data{"hostname": record.Hostname, "ip": record.IP})
+	}
+	s.writeJSON(resp, status, record)
+}
+
+func (s *Server) remove(resp http.ResponseWriter, req *http.Request) {
+	record, ok := s.decodeRecord(resp, req)
+	if !ok {
+		return
+	}
+
+	removed, err := s.store.Remove(record)
+	if err != nil {
+		s.logger.Error("remove-static-record", err, lager.Data{"hostname": record.Hostname, "ip": record.IP})
+		s.writeError(resp, http.StatusInternalServerError, "failed to remove record")
+		return
+	}
+
+	if !removed {
+		s.writeError(resp, http.StatusNotFound, "record not found")
+		return
+	}
+
+	s.logger.Info("removed-static-record", lager.Data{"hostname": record.Hostname, "ip": record.IP})
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) decodeRecord(resp http.ResponseWriter, req *http.Request) (staticrecords.Record, bool) {
+	var record staticrecords.Record
+	err := json.NewDecoder(req.Body).Decode(&record)
+	if err != nil {
+		s.writeError(resp, http.StatusBadRequest, "invalid json body")
+		return record, false
+	}
+
+	record.Hostname = strings.ToLower(strings.TrimSpace(record.Hostname))
+	if record.Hostname == "" {
+		s.writeError(resp, http.StatusBadRequest, "hostname is required")
+		return record, false
+	}
+
+	if net.ParseIP(record.IP) == nil {
+		s.writeError(resp, http.StatusBadRequest, "ip must be a valid ip address")
+		return record, false
+	}
+
+	if !strings.HasSuffix(record.Hostname, ".") {
+		record.Hostname += "."
+	}
+	return record, true
+}
+
+func (s *Server) writeError(resp http.ResponseWriter, status int, message string) {
+	s.writeJSON(resp, status, errorResponse{Error: message})
+}
+
+func (s *Server) writeJSON(resp http.ResponseWriter, status int, body interface{}) {
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(status)
+	err := json.NewEncoder(resp).Encode(body)
+	if err != nil {
+		s.logger.Debug("write-response", lager.Data{"error": err.Error()})
+	}
+}
@@ -0,0 +1,178 @@
+package admin_test
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"service-discovery-controller/admin"
+	"service-discovery-controller/admin/fakes"
+	"service-discovery-controller/staticrecords"
+	"strings"
+	"test-helpers"
+
+	"code.cloudfoundry.org/cf-networking-helpers/testsupport/ports"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		store  *fakes.RecordStore
+		logger *lagertest.TestLogger
+		server *admin.Server
+	)
+
+	BeforeEach(func() {
+		store = &fakes.RecordStore{}
+		logger = lagertest.NewTestLogger("test")
+		server = admin.NewServer("127.0.0.1", 0, "some-token", "", "", store, logger)
+	})
+
+	serve := func(method, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v1/static-records", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp := httptest.NewRecorder()
+		server.Handler().ServeHTTP(resp, req)
+		return resp
+	}
+
+	Describe("authentication", func() {
+		It("rejects requests without a token", func() {
+			resp := serve("GET", "", "")
+			Expect(resp.Code).To(Equal(http.StatusUnauthorized))
+			Expect(store.ListCallCount()).To(Equal(0))
+		})
+
+		It("rejects requests with the wrong token", func() {
+			resp := serve("GET", "", "wrong-token")
+			Expect(resp.Code).To(Equal(http.StatusUnauthorized))
+			Expect(resp.Body.String()).To(MatchJSON(`{"error": "missing or invalid token"}`))
+		})
+	})
+
+	Describe("GET", func() {
+		It("lists the records", func() {
+			store.ListReturns([]staticrecords.Record{{Hostname: "db.apps.internal.", IP: "10.0.0.1"}})
+
+			resp := serve("GET", "", "some-token")
+			Expect(resp.Code).To(Equal(http.StatusOK))
+			Expect(resp.Body.String()).To(MatchJSON(`{
+				"records": [{"hostname": "db.apps.internal.", "ip": "10.0.0.1"}]
+			}`))
+		})
+	})
+
+	Describe("POST", func() {
+		It("adds the record", func() {
+			store.AddReturns(true, nil)
+
+			resp := serve("POST", `{"hostname": "DB.apps.internal", "ip": "10.0.0.1"}`, "some-token")
+			Expect(resp.Code).To(Equal(http.StatusCreated))
+			Expect(resp.Body.String()).To(MatchJSON(`{"hostname": "db.apps.internal.", "ip": "10.0.0.1"}`))
+			Expect(store.AddArgsForCall(0)).To(Equal(staticrecords.Record{Hostname: "db.apps.internal.", IP: "10.0.0.1"}))
+		})
+
+		It("returns 200 when the record already exists", func() {
+			store.AddReturns(false, nil)
+
+			resp := serve("POST", `{"hostname": "db.apps.internal", "ip": "10.0.0.1"}`, "some-token")
+			Expect(resp.Code).To(Equal(http.StatusOK))
+		})
+
+		It("rejects an invalid ip", func() {
+			resp := serve("POST", `{"hostname": "db.apps.internal", "ip": "banana"}`, "some-token")
+			Expect(resp.Code).To(Equal(http.StatusBadRequest))
+			Expect(resp.Body.String()).To(MatchJSON(`{"error": "ip must be a valid ip address"}`))
+			Expect(store.AddCallCount()).To(Equal(0))
+		})
+
+		It("rejects a missing hostname", func() {
+			resp := serve("POST", `{"ip": "10.0.0.1"}`, "some-token")
+			Expect(resp.Code).To(Equal(http.StatusBadRequest))
+			Expect(resp.Body.String()).To(MatchJSON(`{"error": "hostname is required"}`))
+		})
+
+		It("rejects invalid json", func() {
+			resp := serve("POST", `garbage`, "some-token")
+			Expect(resp.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("returns 500 when the store fails", func() {
+			store.AddReturns(false, errors.New("banana"))
+
+			resp := serve("POST", `{"hostname": "db.apps.internal", "ip": "10.0.0.1"}`, "some-token")
+			Expect(resp.Code).To(Equal(http.StatusInternalServerError))
+			Expect(logger).To(gbytes.Say("add-static-record.*banana"))
+		})
+	})
+
+	Describe("DELETE", func() {
+		It("removes the record", func() {
+			store.RemoveReturns(true, nil)
+
+			resp := serve("DELETE", `{"hostname": "db.apps.internal", "ip": "10.0.0.1"}`, "some-token")
+			Expect(resp.Code).To(Equal(http.StatusNoContent))
+			Expect(store.RemoveArgsForCall(0)).To(Equal(staticrecords.Record{Hostname: "db.apps.internal.", IP: "10.0.0.1"}))
+		})
+
+		It("returns 404 for an unknown record", func() {
+			store.RemoveReturns(false, nil)
+
+			resp := serve("DELETE", `{"hostname": "db.apps.internal", "ip": "10.0.0.1"}`, "some-token")
+			Expect(resp.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	It("rejects other methods", func() {
+		resp := serve("PUT", "", "some-token")
+		Expect(resp.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+	Describe("Run", func() {
+		var (
+			process ifrit.Process
+			port    int
+		)
+
+		BeforeEach(func() {
+			_, serverCert, serverKey, _ := testhelpers.GenerateCaAndMutualTlsCerts()
+			port = ports.PickAPort()
+			server = admin.NewServer("127.0.0.1", port, "some-token", serverCert, serverKey, store, logger)
+			process = ifrit.Invoke(server)
+		})
+
+		AfterEach(func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+		})
+
+		It("serves the api over tls", func() {
+			store.ListReturns([]staticrecords.Record{})
+			client := &http.Client{
+				Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+			}
+
+			req, err := http.NewRequest("GET", fmt.Sprintf("https://127.0.0.1:%d/v1/static-records", port), nil)
+			Expect(err).NotTo(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer some-token")
+
+			resp, err := client.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(body).To(MatchJSON(`{"records": []}`))
+		})
+	})
+})
@@ -24,6 +24,11 @@ type Config struct {
 	MetricsEmitSeconds        int          `json:"metrics_emit_seconds" validate:"min=1"`
 	ResumePruningDelaySeconds int          `json:"resume_pruning_delay_seconds" validate:"min=0"`
 	WarmDurationSeconds       int          `json:"warm_duration_seconds" validate:"min=0"`
+	AdminAddress              string       `json:"admin_address"`
+	AdminPort                 int          `json:"admin_port" validate:"min=0"`
+	AdminToken                string       `json:"admin_token"`
+	StaticRecordsFile         string       `json:"static_records_file"`
+	StaticRecordsPrecedence   string       `json:"static_records_precedence" validate:"regexp=^(static|dynamic|merge)?$"`
 }
 
 type NatsConfig struct {
@@ -43,6 +48,14 @@ func NewConfig(configJSON []byte) (*Config, error) {
 	if err = validator.Validate(sdcConfig); err != nil {
 		return nil, fmt.Errorf("invalid config: %s", err)
 	}
+
+	if sdcConfig.AdminPort > 0 && sdcConfig.AdminToken == "" {
+		return nil, fmt.Errorf("invalid config: AdminToken: required when admin_port is set")
+	}
+
+	if sdcConfig.StaticRecordsPrecedence == "" {
+		sdcConfig.StaticRecordsPrecedence = "static"
+	}
 	return sdcConfig, err
 }
 
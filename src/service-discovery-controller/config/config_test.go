@@ -41,7 +41,12 @@ var _ = Describe("Config", func() {
 				"metrics_emit_seconds": 6,
 				"metron_port": 8080,
 				"resume_pruning_delay_seconds": 2,
-				"warm_duration_seconds": 5
+				"warm_duration_seconds": 5,
+				"admin_address": "127.0.0.1",
+				"admin_port": 8056,
+				"admin_token": "some-token",
+				"static_records_file": "/some/records.json",
+				"static_records_precedence": "merge"
 			}`)
 
 			parsedConfig, err := NewConfig(configJSON)
@@ -63,6 +68,11 @@ var _ = Describe("Config", func() {
 			Expect(parsedConfig.MetricsEmitSeconds).To(Equal(6))
 			Expect(parsedConfig.ResumePruningDelaySeconds).To(Equal(2))
 			Expect(parsedConfig.WarmDurationSeconds).To(Equal(5))
+			Expect(parsedConfig.AdminAddress).To(Equal("127.0.0.1"))
+			Expect(parsedConfig.AdminPort).To(Equal(8056))
+			Expect(parsedConfig.AdminToken).To(Equal("some-token"))
+			Expect(parsedConfig.StaticRecordsFile).To(Equal("/some/records.json"))
+			Expect(parsedConfig.StaticRecordsPrecedence).To(Equal("merge"))
 		})
 	})
 
@@ -113,7 +123,27 @@ var _ = Describe("Config", func() {
 		Entry("invalid ca_cert", "ca_cert", "", "CACert: zero value"),
 		Entry("invalid resume_pruning_delay_seconds", "resume_pruning_delay_seconds", -1, "ResumePruningDelaySeconds: less than min"),
 		Entry("invalid warm_duration_seconds", "warm_duration_seconds", -1, "WarmDurationSeconds: less than min"),
+		Entry("invalid admin_port", "admin_port", -1, "AdminPort: less than min"),
+		Entry("invalid static_records_precedence", "static_records_precedence", "banana", "StaticRecordsPrecedence: regular expression mismatch"),
 	)
+
+	It("defaults static_records_precedence to static", func() {
+		cfgBytes, _ := json.Marshal(requiredFields)
+		parsedConfig, err := NewConfig(cfgBytes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsedConfig.StaticRecordsPrecedence).To(Equal("static"))
+	})
+
+	Context("when admin_port is set without admin_token", func() {
+		It("returns an error", func() {
+			cfg := cloneMap(requiredFields)
+			cfg["admin_port"] = 8056
+
+			cfgBytes, _ := json.Marshal(cfg)
+			_, err := NewConfig(cfgBytes)
+			Expect(err).To(MatchError("invalid config: AdminToken: required when admin_port is set"))
+		})
+	})
 })
 
 func cloneMap(original map[string]interface{}) map[string]interface{} {
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"service-discovery-controller/addresstable"
+	"service-discovery-controller/admin"
 	"service-discovery-controller/config"
 	"service-discovery-controller/mbus"
 	"syscall"
@@ -16,6 +17,7 @@ import (
 	"strings"
 
 	"service-discovery-controller/routes"
+	"service-discovery-controller/staticrecords"
 
 	"code.cloudfoundry.org/cf-networking-helpers/lagerlevel"
 	"code.cloudfoundry.org/cf-networking-helpers/metrics"
@@ -49,6 +51,12 @@ func mainWithError() error {
 
 	addressTable := buildAddressTable(conf, logger)
 
+	staticRecords, err := staticrecords.NewStore(conf.StaticRecordsFile, clock.NewClock())
+	if err != nil {
+		logger.Error("Failed to load static records", err)
+		return err
+	}
+
 	metronAddress := fmt.Sprintf("127.0.0.1:%d", conf.MetronPort)
 	err = dropsonde.Initialize(metronAddress, "service-discovery-controller")
 	if err != nil {
@@ -105,20 +113,40 @@ func mainWithError() error {
 	)
 
 	routesServer := routes.NewServer(
-		addressTable,
+		staticrecords.NewMergedTable(addressTable, staticRecords, conf.StaticRecordsPrecedence),
 		conf,
 		dnsRequestRecorder,
 		metricsSender,
 		logger.Session("routes-server"),
 	)
 
+	staticRecordsReplicator := mbus.NewStaticRecordsReplicator(
+		&mbus.NatsConnWithUrlProvider{Url: strings.Join(conf.NatsServers(), ",")},
+		staticRecords,
+		logger.Session("static-records"),
+	)
+
 	members := grouper.Members{
 		{"subscriber", subscriber},
+		{"static-records-replicator", staticRecordsReplicator},
 		{"metrics-emitter", metricsEmitter},
 		{"log-level-server", logLevelServer},
 		{"routes-server", routesServer},
 	}
 
+	if conf.AdminPort > 0 {
+		adminServer := admin.NewServer(
+			conf.AdminAddress,
+			conf.AdminPort,
+			conf.AdminToken,
+			conf.ServerCert,
+			conf.ServerKey,
+			staticRecordsReplicator,
+			logger.Session("admin-server"),
+		)
+		members = append(members, grouper.Member{Name: "admin-server", Runner: adminServer})
+	}
+
 	group := grouper.NewOrdered(os.Interrupt, members)
 	monitor := ifrit.Invoke(sigmon.New(group))
 
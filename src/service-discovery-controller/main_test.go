@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 
 	"code.cloudfoundry.org/cf-networking-helpers/testsupport/metrics"
 
@@ -13,6 +14,7 @@ import (
 	"time"
 
 	"crypto/tls"
+	"service-discovery-controller/staticrecords"
 	"test-helpers"
 
 	"strings"
@@ -44,6 +46,8 @@ var _ = Describe("Service Discovery Controller process", func() {
 		logLevelEndpointPort      int
 		logLevelEndpointAddress   string
 		fakeMetron                metrics.FakeMetron
+		adminPort                 int
+		staticRecordsFile         string
 	)
 
 	BeforeEach(func() {
@@ -56,6 +60,10 @@ var _ = Describe("Service Discovery Controller process", func() {
 		natsServerPort = ports.PickAPort()
 		natsServer = RunNatsServerOnPort(natsServerPort)
 		port = ports.PickAPort()
+		adminPort = ports.PickAPort()
+		staticRecordsDir, err := ioutil.TempDir("", "static-records")
+		Expect(err).NotTo(HaveOccurred())
+		staticRecordsFile = filepath.Join(staticRecordsDir, "records.json")
 		configPath = writeConfigFile(fmt.Sprintf(`{
 			"address":"127.0.0.1",
 			"port":"%d",
@@ -77,14 +85,19 @@ var _ = Describe("Service Discovery Controller process", func() {
 			"metron_port": %d,
 			"metrics_emit_seconds": 2,
 			"resume_pruning_delay_seconds": 1,
-			"warm_duration_seconds": 0
+			"warm_duration_seconds": 0,
+			"admin_address": "127.0.0.1",
+			"admin_port": %d,
+			"admin_token": "some-admin-token",
+			"static_records_file": "%s"
 		}`,
-			port, caFile, serverCert, serverKey, natsServerPort, stalenessThresholdSeconds, pruningIntervalSeconds, logLevelEndpointAddress, logLevelEndpointPort, fakeMetron.Port()))
+			port, caFile, serverCert, serverKey, natsServerPort, stalenessThresholdSeconds, pruningIntervalSeconds, logLevelEndpointAddress, logLevelEndpointPort, fakeMetron.Port(), adminPort, staticRecordsFile))
 	})
 
 	AfterEach(func() {
 		session.Kill()
 		os.Remove(configPath)
+		os.RemoveAll(filepath.Dir(staticRecordsFile))
 		natsServer.Shutdown()
 	})
 
@@ -226,6 +239,93 @@ var _ = Describe("Service Discovery Controller process", func() {
 			}`))
 		})
 
+		It("should return a single instance for an indexed hostname", func() {
+			Expect(routeEmitter.PublishMsg(&nats.Msg{
+				Subject: "service-discovery.register",
+				Data:    []byte(`{"host": "192.168.0.7","uris":["indexed-id.internal.local."],"private_instance_id":"some-guid","private_instance_index":"1"}`),
+			})).To(Succeed())
+
+			url := fmt.Sprintf("https://127.0.0.1:%d/v1/registration/1.indexed-id.internal.local.", port)
+			Eventually(func() string {
+				resp, err := testhelpers.NewClient(testhelpers.CertPool(caFile), clientCert).Get(url)
+				Expect(err).ToNot(HaveOccurred())
+				respBody, err := ioutil.ReadAll(resp.Body)
+				Expect(err).ToNot(HaveOccurred())
+				return string(respBody)
+			}).Should(MatchJSON(`{
+				"env": "",
+				"hosts": [
+				{
+					"ip_address": "192.168.0.7",
+					"instance_index": "1",
+					"instance_guid": "some-guid",
+					"last_check_in": "",
+					"port": 0,
+					"revision": "",
+					"service": "",
+					"service_repo_name": "",
+					"tags": {}
+				}],
+				"service": ""
+			}`))
+		})
+
+		It("should serve static records created through the admin api", func() {
+			adminClient := &http.Client{
+				Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+			}
+			req, err := http.NewRequest("POST", fmt.Sprintf("https://127.0.0.1:%d/v1/static-records", adminPort),
+				strings.NewReader(`{"hostname": "static-id.internal.local", "ip": "10.0.0.1"}`))
+			Expect(err).ToNot(HaveOccurred())
+			req.Header.Set("Authorization", "Bearer some-admin-token")
+
+			resp, err := adminClient.Do(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+
+			url := fmt.Sprintf("https://127.0.0.1:%d/v1/registration/static-id.internal.local.", port)
+			resp, err = testhelpers.NewClient(testhelpers.CertPool(caFile), clientCert).Get(url)
+			Expect(err).ToNot(HaveOccurred())
+			respBody, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(respBody).To(MatchJSON(`{
+				"env": "",
+				"hosts": [
+				{
+					"ip_address": "10.0.0.1",
+					"last_check_in": "",
+					"port": 0,
+					"revision": "",
+					"service": "",
+					"service_repo_name": "",
+					"tags": {}
+				}],
+				"service": ""
+			}`))
+
+			contents, err := ioutil.ReadFile(staticRecordsFile)
+			Expect(err).ToNot(HaveOccurred())
+			var snapshot staticrecords.Snapshot
+			Expect(json.Unmarshal(contents, &snapshot)).To(Succeed())
+			Expect(snapshot.Records).To(Equal([]staticrecords.Record{{Hostname: "static-id.internal.local.", IP: "10.0.0.1"}}))
+		})
+
+		It("should serve static records replicated from other instances", func() {
+			Expect(routeEmitter.Publish("service-discovery.static-records",
+				[]byte(`{"version": 1, "records": [{"hostname": "replicated-id.internal.local.", "ip": "10.0.0.2"}]}`))).To(Succeed())
+			Expect(routeEmitter.Flush()).To(Succeed())
+
+			url := fmt.Sprintf("https://127.0.0.1:%d/v1/registration/replicated-id.internal.local.", port)
+			client := testhelpers.NewClient(testhelpers.CertPool(caFile), clientCert)
+			Eventually(func() string {
+				resp, err := client.Get(url)
+				Expect(err).ToNot(HaveOccurred())
+				respBody, err := ioutil.ReadAll(resp.Body)
+				Expect(err).ToNot(HaveOccurred())
+				return string(respBody)
+			}).Should(ContainSubstring(`"ip_address":"10.0.0.2"`))
+		})
+
 		It("should return a http large json", func() {
 			url := fmt.Sprintf("https://127.0.0.1:%d/v1/registration/large-id.internal.local.", port)
 			resp, err := testhelpers.NewClient(testhelpers.CertPool(caFile), clientCert).Get(url)
@@ -2,16 +2,17 @@
 package fakes
 
 import (
+	"service-discovery-controller/addresstable"
 	"service-discovery-controller/mbus"
 	"sync"
 )
 
 type AddressTable struct {
-	AddStub        func(infraNames []string, ip string)
-	addMutex       sync.RWMutex
-	addArgsForCall []struct {
+	AddInstanceStub        func(infraNames []string, instance addresstable.Instance)
+	addInstanceMutex       sync.RWMutex
+	addInstanceArgsForCall []struct {
 		infraNames []string
-		ip         string
+		instance   addresstable.Instance
 	}
 	RemoveStub        func(infraNames []string, ip string)
 	removeMutex       sync.RWMutex
@@ -32,34 +33,34 @@ type AddressTable struct {
 	invocationsMutex         sync.RWMutex
 }
 
-func (fake *AddressTable) Add(infraNames []string, ip string) {
+func (fake *AddressTable) AddInstance(infraNames []string, instance addresstable.Instance) {
 	var infraNamesCopy []string
 	if infraNames != nil {
 		infraNamesCopy = make([]string, len(infraNames))
 		copy(infraNamesCopy, infraNames)
 	}
-	fake.addMutex.Lock()
-	fake.addArgsForCall = append(fake.addArgsForCall, struct {
+	fake.addInstanceMutex.Lock()
+	fake.addInstanceArgsForCall = append(fake.addInstanceArgsForCall, struct {
 		infraNames []string
-		ip         string
-	}{infraNamesCopy, ip})
-	fake.recordInvocation("Add", []interface{}{infraNamesCopy, ip})
-	fake.addMutex.Unlock()
-	if fake.AddStub != nil {
-		fake.AddStub(infraNames, ip)
+		instance   addresstable.Instance
+	}{infraNamesCopy, instance})
+	fake.recordInvocation("AddInstance", []interface{}{infraNamesCopy, instance})
+	fake.addInstanceMutex.Unlock()
+	if fake.AddInstanceStub != nil {
+		fake.AddInstanceStub(infraNames, instance)
 	}
 }
 
-func (fake *AddressTable) AddCallCount() int {
-	fake.addMutex.RLock()
-	defer fake.addMutex.RUnlock()
-	return len(fake.addArgsForCall)
+func (fake *AddressTable) AddInstanceCallCount() int {
+	fake.addInstanceMutex.RLock()
+	defer fake.addInstanceMutex.RUnlock()
+	return len(fake.addInstanceArgsForCall)
 }
 
-func (fake *AddressTable) AddArgsForCall(i int) ([]string, string) {
-	fake.addMutex.RLock()
-	defer fake.addMutex.RUnlock()
-	return fake.addArgsForCall[i].infraNames, fake.addArgsForCall[i].ip
+func (fake *AddressTable) AddInstanceArgsForCall(i int) ([]string, addresstable.Instance) {
+	fake.addInstanceMutex.RLock()
+	defer fake.addInstanceMutex.RUnlock()
+	return fake.addInstanceArgsForCall[i].infraNames, fake.addInstanceArgsForCall[i].instance
 }
 
 func (fake *AddressTable) Remove(infraNames []string, ip string) {
@@ -143,8 +144,8 @@ func (fake *AddressTable) SetWarmCallCount() int {
 func (fake *AddressTable) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
-	fake.addMutex.RLock()
-	defer fake.addMutex.RUnlock()
+	fake.addInstanceMutex.RLock()
+	defer fake.addInstanceMutex.RUnlock()
 	fake.removeMutex.RLock()
 	defer fake.removeMutex.RUnlock()
 	fake.pausePruningMutex.RLock()
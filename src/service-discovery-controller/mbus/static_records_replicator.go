@@ -0,0 +1,176 @@
+package mbus
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"service-discovery-controller/staticrecords"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/nats-io/go-nats"
+	"github.com/pkg/errors"
+)
+
+const (
+	StaticRecordsSubject     = "service-discovery.static-records"
+	StaticRecordsSyncSubject = "service-discovery.static-records.sync"
+)
+
+//go:generate counterfeiter -o fakes/static_records_store.go --fake-name StaticRecordsStore . StaticRecordsStore
+type StaticRecordsStore interface {
+	Add(record staticrecords.Record) (bool, error)
+	Remove(record staticrecords.Record) (bool, error)
+	List() []staticrecords.Record
+	Snapshot() staticrecords.Snapshot
+	Replace(snapshot staticrecords.Snapshot) (bool, error)
+}
+
+// StaticRecordsReplicator keeps the static records of every service
+// discovery controller in sync over NATS. Each change publishes the whole set
+// of records with its version, and every controller keeps the newest set it
+// sees. On every (re)connect a controller publishes its own records and asks
+// its peers for theirs, so that it catches up on changes it missed while it
+// was away.
+type StaticRecordsReplicator struct {
+	natsConnProvider NatsConnProvider
+	store            StaticRecordsStore
+	logger           lager.Logger
+	natsClient       NatsConn
+	mutex            sync.Mutex
+}
+
+func NewStaticRecordsReplicator(natsConnProvider NatsConnProvider, store StaticRecordsStore, logger lager.Logger) *StaticRecordsReplicator {
+	return &StaticRecordsReplicator{
+		natsConnProvider: natsConnProvider,
+		store:            store,
+		logger:           logger,
+	}
+}
+
+func (r *StaticRecordsReplicator) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	natsClient, err := r.natsConnProvider.Connection(
+		nats.ReconnectHandler(nats.ConnHandler(func(conn *nats.Conn) {
+			r.sync()
+		})),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return errors.Wrap(err, "unable to create nats connection")
+	}
+	defer natsClient.Close()
+
+	r.mutex.Lock()
+	r.natsClient = natsClient
+	r.mutex.Unlock()
+
+	_, err = natsClient.Subscribe(StaticRecordsSubject, nats.MsgHandler(r.handleSnapshot))
+	if err != nil {
+		return errors.Wrap(err, "unable to subscribe to "+StaticRecordsSubject)
+	}
+
+	_, err = natsClient.Subscribe(StaticRecordsSyncSubject, nats.MsgHandler(r.handleSync))
+	if err != nil {
+		return errors.Wrap(err, "unable to subscribe to "+StaticRecordsSyncSubject)
+	}
+
+	err = natsClient.Flush()
+	if err != nil {
+		return errors.Wrap(err, "unable to flush static records subscriptions")
+	}
+
+	r.sync()
+
+	close(ready)
+	<-signals
+	return nil
+}
+
+// Add adds the record to the store and publishes the new records to the
+// other controllers.
+func (r *StaticRecordsReplicator) Add(record staticrecords.Record) (bool, error) {
+	added, err := r.store.Add(record)
+	if added {
+		r.publish(StaticRecordsSubject)
+	}
+	return added, err
+}
+
+// Remove removes the record from the store and publishes the new records to
+// the other controllers.
+func (r *StaticRecordsReplicator) Remove(record staticrecords.Record) (bool, error) {
+	removed, err := r.store.Remove(record)
+	if removed {
+		r.publish(StaticRecordsSubject)
+	}
+	return removed, err
+}
+
+func (r *StaticRecordsReplicator) List() []staticrecords.Record {
+	return r.store.List()
+}
+
+func (r *StaticRecordsReplicator) sync() {
+	r.publish(StaticRecordsSubject)
+
+	err := r.publishMsg(&nats.Msg{
+		Subject: StaticRecordsSyncSubject,
+		Reply:   StaticRecordsSubject,
+	})
+	if err != nil {
+		r.logger.Error("request-static-records-failed", err)
+	}
+}
+
+func (r *StaticRecordsReplicator) handleSync(msg *nats.Msg) {
+	if msg.Reply == "" {
+		return
+	}
+	r.publish(msg.Reply)
+}
+
+func (r *StaticRecordsReplicator) handleSnapshot(msg *nats.Msg) {
+	var snapshot staticrecords.Snapshot
+	err := json.Unmarshal(msg.Data, &snapshot)
+	if err != nil {
+		r.logger.Info("malformed-static-records", lager.Data{"msgJson": string(msg.Data)})
+		return
+	}
+
+	replaced, err := r.store.Replace(snapshot)
+	if err != nil {
+		r.logger.Error("replace-static-records-failed", err, lager.Data{"version": snapshot.Version})
+		return
+	}
+	if replaced {
+		r.logger.Info("replaced-static-records", lager.Data{
+			"version": snapshot.Version,
+			"records": len(snapshot.Records),
+		})
+	}
+}
+
+func (r *StaticRecordsReplicator) publish(subject string) {
+	snapshot := r.store.Snapshot()
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		r.logger.Error("marshal-static-records-failed", err)
+		return
+	}
+
+	err = r.publishMsg(&nats.Msg{Subject: subject, Data: data})
+	if err != nil {
+		r.logger.Error("publish-static-records-failed", err, lager.Data{"version": snapshot.Version})
+	}
+}
+
+func (r *StaticRecordsReplicator) publishMsg(msg *nats.Msg) error {
+	r.mutex.Lock()
+	natsClient := r.natsClient
+	r.mutex.Unlock()
+
+	if natsClient == nil {
+		return errors.New("not connected to nats")
+	}
+	return natsClient.PublishMsg(msg)
+}
@@ -0,0 +1,206 @@
+package mbus_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	. "service-discovery-controller/mbus"
+
+	"service-discovery-controller/mbus/fakes"
+	"service-discovery-controller/staticrecords"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/nats-io/go-nats"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("StaticRecordsReplicator", func() {
+	var (
+		provider   *fakes.NatsConnProvider
+		natsConn   *fakes.NatsConn
+		store      *fakes.StaticRecordsStore
+		logger     *lagertest.TestLogger
+		replicator *StaticRecordsReplicator
+		snapshot   staticrecords.Snapshot
+		signals    chan os.Signal
+		ready      chan struct{}
+		runErr     chan error
+	)
+
+	handler := func(subject string) nats.MsgHandler {
+		for i := 0; i < natsConn.SubscribeCallCount(); i++ {
+			s, h := natsConn.SubscribeArgsForCall(i)
+			if s == subject {
+				return h
+			}
+		}
+		Fail("no subscription to " + subject)
+		return nil
+	}
+
+	published := func() []*nats.Msg {
+		msgs := []*nats.Msg{}
+		for i := 0; i < natsConn.PublishMsgCallCount(); i++ {
+			msgs = append(msgs, natsConn.PublishMsgArgsForCall(i))
+		}
+		return msgs
+	}
+
+	BeforeEach(func() {
+		natsConn = &fakes.NatsConn{}
+		provider = &fakes.NatsConnProvider{}
+		provider.ConnectionReturns(natsConn, nil)
+
+		snapshot = staticrecords.Snapshot{
+			Version: 1000,
+			Records: []staticrecords.Record{{Hostname: "db.apps.internal.", IP: "10.0.0.1"}},
+		}
+		store = &fakes.StaticRecordsStore{}
+		store.SnapshotReturns(snapshot)
+
+		logger = lagertest.NewTestLogger("test")
+		replicator = NewStaticRecordsReplicator(provider, store, logger)
+
+		signals = make(chan os.Signal)
+		ready = make(chan struct{})
+		runErr = make(chan error, 1)
+	})
+
+	JustBeforeEach(func() {
+		go func() {
+			runErr <- replicator.Run(signals, ready)
+		}()
+	})
+
+	AfterEach(func() {
+		select {
+		case <-ready:
+			signals <- os.Interrupt
+			Eventually(runErr).Should(Receive(BeNil()))
+			Expect(natsConn.CloseCallCount()).To(Equal(1))
+		default:
+		}
+	})
+
+	It("publishes its records and asks its peers for theirs when it starts", func() {
+		Eventually(ready).Should(BeClosed())
+
+		snapshotJSON, err := json.Marshal(snapshot)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(published()).To(Equal([]*nats.Msg{
+			{Subject: StaticRecordsSubject, Data: snapshotJSON},
+			{Subject: StaticRecordsSyncSubject, Reply: StaticRecordsSubject},
+		}))
+	})
+
+	It("answers sync requests with its records", func() {
+		Eventually(ready).Should(BeClosed())
+
+		handler(StaticRecordsSyncSubject)(&nats.Msg{Subject: StaticRecordsSyncSubject, Reply: "some-reply"})
+
+		msgs := published()
+		Expect(msgs[len(msgs)-1].Subject).To(Equal("some-reply"))
+		Expect(msgs[len(msgs)-1].Data).To(MatchJSON(`{"version": 1000, "records": [{"hostname": "db.apps.internal.", "ip": "10.0.0.1"}]}`))
+	})
+
+	It("replaces its records with the records it receives", func() {
+		Eventually(ready).Should(BeClosed())
+
+		store.ReplaceReturns(true, nil)
+		handler(StaticRecordsSubject)(&nats.Msg{
+			Subject: StaticRecordsSubject,
+			Data:    []byte(`{"version": 2000, "records": [{"hostname": "cache.apps.internal.", "ip": "10.0.0.3"}]}`),
+		})
+
+		Expect(store.ReplaceCallCount()).To(Equal(1))
+		Expect(store.ReplaceArgsForCall(0)).To(Equal(staticrecords.Snapshot{
+			Version: 2000,
+			Records: []staticrecords.Record{{Hostname: "cache.apps.internal.", IP: "10.0.0.3"}},
+		}))
+		Expect(logger).To(gbytes.Say("test.replaced-static-records.*\"version\":2000"))
+	})
+
+	Context("when it receives malformed records", func() {
+		It("logs and ignores them", func() {
+			Eventually(ready).Should(BeClosed())
+
+			handler(StaticRecordsSubject)(&nats.Msg{Subject: StaticRecordsSubject, Data: []byte("garbage")})
+
+			Expect(store.ReplaceCallCount()).To(Equal(0))
+			Expect(logger).To(gbytes.Say("test.malformed-static-records"))
+		})
+	})
+
+	Describe("Add and Remove", func() {
+		BeforeEach(func() {
+			store.AddReturns(true, nil)
+			store.RemoveReturns(true, nil)
+		})
+
+		It("publishes the records after every change", func() {
+			Eventually(ready).Should(BeClosed())
+			publishCount := natsConn.PublishMsgCallCount()
+
+			added, err := replicator.Add(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.1"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(added).To(BeTrue())
+			Expect(store.AddArgsForCall(0)).To(Equal(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.1"}))
+
+			removed, err := replicator.Remove(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.1"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(BeTrue())
+
+			msgs := published()[publishCount:]
+			Expect(msgs).To(HaveLen(2))
+			Expect(msgs[0].Subject).To(Equal(StaticRecordsSubject))
+			Expect(msgs[1].Subject).To(Equal(StaticRecordsSubject))
+		})
+
+		Context("when nothing changes", func() {
+			BeforeEach(func() {
+				store.AddReturns(false, nil)
+				store.RemoveReturns(false, errors.New("banana"))
+			})
+
+			It("does not publish", func() {
+				Eventually(ready).Should(BeClosed())
+				publishCount := natsConn.PublishMsgCallCount()
+
+				_, err := replicator.Add(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.1"})
+				Expect(err).NotTo(HaveOccurred())
+				_, err = replicator.Remove(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.1"})
+				Expect(err).To(MatchError("banana"))
+
+				Expect(natsConn.PublishMsgCallCount()).To(Equal(publishCount))
+			})
+		})
+
+		Context("when publishing fails", func() {
+			BeforeEach(func() {
+				natsConn.PublishMsgReturns(errors.New("banana"))
+			})
+
+			It("keeps the change and logs the error", func() {
+				Eventually(ready).Should(BeClosed())
+
+				added, err := replicator.Add(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.1"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(added).To(BeTrue())
+				Expect(logger).To(gbytes.Say("test.publish-static-records-failed.*banana"))
+			})
+		})
+	})
+
+	Context("when it cannot connect to nats", func() {
+		BeforeEach(func() {
+			provider.ConnectionReturns(nil, errors.New("banana"))
+		})
+
+		It("returns an error", func() {
+			Eventually(runErr).Should(Receive(MatchError("unable to create nats connection: banana")))
+		})
+	})
+})
@@ -12,6 +12,8 @@ import (
 
 	"os"
 
+	"service-discovery-controller/addresstable"
+
 	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/lager"
 	"github.com/nats-io/go-nats"
@@ -36,11 +38,15 @@ type RegistryMessage struct {
 	IP                string   `json:"host"`
 	InfraNames        []string `json:"uris"`
 	EndpointUpdatedAt int64    `json:"endpoint_updated_at_ns"`
+	InstanceGUID      string   `json:"private_instance_id"`
+	InstanceIndex     string   `json:"private_instance_index"`
+	AvailabilityZone  string   `json:"availability_zone"`
+	CellID            string   `json:"cell_id"`
 }
 
 //go:generate counterfeiter -o fakes/address_table.go --fake-name AddressTable . AddressTable
 type AddressTable interface {
-	Add(infraNames []string, ip string)
+	AddInstance(infraNames []string, instance addresstable.Instance)
 	Remove(infraNames []string, ip string)
 	PausePruning()
 	ResumePruning()
@@ -272,7 +278,13 @@ func (s *Subscriber) setupAddressMessageHandler() error {
 		s.logger.Debug("AddressMessageHandler register msg received", lager.Data(map[string]interface{}{
 			"msgJson": string(msg.Data),
 		}))
-		s.table.Add(registryMessage.InfraNames, registryMessage.IP)
+		s.table.AddInstance(registryMessage.InfraNames, addresstable.Instance{
+			IP:     registryMessage.IP,
+			Index:  registryMessage.InstanceIndex,
+			GUID:   registryMessage.InstanceGUID,
+			Zone:   registryMessage.AvailabilityZone,
+			CellID: registryMessage.CellID,
+		})
 	}))
 
 	if err != nil {
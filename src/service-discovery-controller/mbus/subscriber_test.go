@@ -7,6 +7,7 @@ import (
 
 	"time"
 
+	"service-discovery-controller/addresstable"
 	"service-discovery-controller/mbus/fakes"
 
 	"code.cloudfoundry.org/cf-networking-helpers/testsupport/ports"
@@ -262,13 +263,42 @@ var _ = Describe("Subscriber", func() {
 
 			Eventually(func() int {
 				fakeRouteEmitter.PublishMsg(&natsRegistryMsg)
-				return addressTable.AddCallCount()
+				return addressTable.AddInstanceCallCount()
 			}).Should(Equal(1))
 
-			hostnames, ip := addressTable.AddArgsForCall(0)
+			hostnames, instance := addressTable.AddInstanceArgsForCall(0)
 
 			Expect(hostnames).To(Equal([]string{"foo.com", "0.foo.com"}))
-			Expect(ip).To(Equal("192.168.0.1"))
+			Expect(instance).To(Equal(addresstable.Instance{IP: "192.168.0.1"}))
+		})
+
+		It("should write the instance metadata to the address table", func() {
+			natsRegistryMsg := nats.Msg{
+				Subject: "service-discovery.register",
+				Data: []byte(`{
+					"host": "192.168.0.1",
+					"uris": ["foo.com"],
+					"private_instance_id": "some-instance-guid",
+					"private_instance_index": "2",
+					"availability_zone": "z1",
+					"cell_id": "some-cell"
+				}`),
+			}
+
+			Eventually(func() int {
+				fakeRouteEmitter.PublishMsg(&natsRegistryMsg)
+				return addressTable.AddInstanceCallCount()
+			}).Should(Equal(1))
+
+			_, instance := addressTable.AddInstanceArgsForCall(0)
+
+			Expect(instance).To(Equal(addresstable.Instance{
+				IP:     "192.168.0.1",
+				Index:  "2",
+				GUID:   "some-instance-guid",
+				Zone:   "z1",
+				CellID: "some-cell",
+			}))
 		})
 
 		It("should record the time it took to get from BBS to the SDC", func() {
@@ -343,7 +373,7 @@ var _ = Describe("Subscriber", func() {
 						Data("msgJson", json),
 					)))
 
-				Expect(addressTable.AddCallCount()).To(Equal(0))
+				Expect(addressTable.AddInstanceCallCount()).To(Equal(0))
 			})
 		})
 
@@ -366,7 +396,7 @@ var _ = Describe("Subscriber", func() {
 						Data("msgJson", json),
 					)))
 
-				Expect(addressTable.AddCallCount()).To(Equal(0))
+				Expect(addressTable.AddInstanceCallCount()).To(Equal(0))
 			})
 		})
 
@@ -389,7 +419,7 @@ var _ = Describe("Subscriber", func() {
 						Data("msgJson", json),
 					)))
 
-				Expect(addressTable.AddCallCount()).To(Equal(0))
+				Expect(addressTable.AddInstanceCallCount()).To(Equal(0))
 			})
 		})
 	})
@@ -2,21 +2,22 @@
 package fakes
 
 import (
+	"service-discovery-controller/addresstable"
 	"service-discovery-controller/routes"
 	"sync"
 )
 
 type AddressTable struct {
-	LookupStub        func(hostname string) []string
-	lookupMutex       sync.RWMutex
-	lookupArgsForCall []struct {
+	LookupInstancesStub        func(hostname string) []addresstable.Instance
+	lookupInstancesMutex       sync.RWMutex
+	lookupInstancesArgsForCall []struct {
 		hostname string
 	}
-	lookupReturns struct {
-		result1 []string
+	lookupInstancesReturns struct {
+		result1 []addresstable.Instance
 	}
-	lookupReturnsOnCall map[int]struct {
-		result1 []string
+	lookupInstancesReturnsOnCall map[int]struct {
+		result1 []addresstable.Instance
 	}
 	GetAllAddressesStub        func() map[string][]string
 	getAllAddressesMutex       sync.RWMutex
@@ -40,51 +41,51 @@ type AddressTable struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *AddressTable) Lookup(hostname string) []string {
-	fake.lookupMutex.Lock()
-	ret, specificReturn := fake.lookupReturnsOnCall[len(fake.lookupArgsForCall)]
-	fake.lookupArgsForCall = append(fake.lookupArgsForCall, struct {
+func (fake *AddressTable) LookupInstances(hostname string) []addresstable.Instance {
+	fake.lookupInstancesMutex.Lock()
+	ret, specificReturn := fake.lookupInstancesReturnsOnCall[len(fake.lookupInstancesArgsForCall)]
+	fake.lookupInstancesArgsForCall = append(fake.lookupInstancesArgsForCall, struct {
 		hostname string
 	}{hostname})
-	fake.recordInvocation("Lookup", []interface{}{hostname})
-	fake.lookupMutex.Unlock()
-	if fake.LookupStub != nil {
-		return fake.LookupStub(hostname)
+	fake.recordInvocation("LookupInstances", []interface{}{hostname})
+	fake.lookupInstancesMutex.Unlock()
+	if fake.LookupInstancesStub != nil {
+		return fake.LookupInstancesStub(hostname)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	return fake.lookupReturns.result1
+	return fake.lookupInstancesReturns.result1
 }
 
-func (fake *AddressTable) LookupCallCount() int {
-	fake.lookupMutex.RLock()
-	defer fake.lookupMutex.RUnlock()
-	return len(fake.lookupArgsForCall)
+func (fake *AddressTable) LookupInstancesCallCount() int {
+	fake.lookupInstancesMutex.RLock()
+	defer fake.lookupInstancesMutex.RUnlock()
+	return len(fake.lookupInstancesArgsForCall)
 }
 
-func (fake *AddressTable) LookupArgsForCall(i int) string {
-	fake.lookupMutex.RLock()
-	defer fake.lookupMutex.RUnlock()
-	return fake.lookupArgsForCall[i].hostname
+func (fake *AddressTable) LookupInstancesArgsForCall(i int) string {
+	fake.lookupInstancesMutex.RLock()
+	defer fake.lookupInstancesMutex.RUnlock()
+	return fake.lookupInstancesArgsForCall[i].hostname
 }
 
-func (fake *AddressTable) LookupReturns(result1 []string) {
-	fake.LookupStub = nil
-	fake.lookupReturns = struct {
-		result1 []string
+func (fake *AddressTable) LookupInstancesReturns(result1 []addresstable.Instance) {
+	fake.LookupInstancesStub = nil
+	fake.lookupInstancesReturns = struct {
+		result1 []addresstable.Instance
 	}{result1}
 }
 
-func (fake *AddressTable) LookupReturnsOnCall(i int, result1 []string) {
-	fake.LookupStub = nil
-	if fake.lookupReturnsOnCall == nil {
-		fake.lookupReturnsOnCall = make(map[int]struct {
-			result1 []string
+func (fake *AddressTable) LookupInstancesReturnsOnCall(i int, result1 []addresstable.Instance) {
+	fake.LookupInstancesStub = nil
+	if fake.lookupInstancesReturnsOnCall == nil {
+		fake.lookupInstancesReturnsOnCall = make(map[int]struct {
+			result1 []addresstable.Instance
 		})
 	}
-	fake.lookupReturnsOnCall[i] = struct {
-		result1 []string
+	fake.lookupInstancesReturnsOnCall[i] = struct {
+		result1 []addresstable.Instance
 	}{result1}
 }
 
@@ -171,8 +172,8 @@ func (fake *AddressTable) IsWarmReturnsOnCall(i int, result1 bool) {
 func (fake *AddressTable) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
-	fake.lookupMutex.RLock()
-	defer fake.lookupMutex.RUnlock()
+	fake.lookupInstancesMutex.RLock()
+	defer fake.lookupInstancesMutex.RUnlock()
 	fake.getAllAddressesMutex.RLock()
 	defer fake.getAllAddressesMutex.RUnlock()
 	fake.isWarmMutex.RLock()
@@ -10,6 +10,7 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"path"
+	"service-discovery-controller/addresstable"
 	"service-discovery-controller/config"
 
 	"code.cloudfoundry.org/cf-networking-helpers/metrics"
@@ -30,6 +31,8 @@ type Server struct {
 
 type host struct {
 	IPAddress       string                 `json:"ip_address"`
+	InstanceIndex   string                 `json:"instance_index,omitempty"`
+	InstanceGUID    string                 `json:"instance_guid,omitempty"`
 	LastCheckIn     string                 `json:"last_check_in"`
 	Port            int32                  `json:"port"`
 	Revision        string                 `json:"revision"`
@@ -55,7 +58,7 @@ type address struct {
 
 //go:generate counterfeiter -o fakes/address_table.go --fake-name AddressTable . AddressTable
 type AddressTable interface {
-	Lookup(hostname string) []string
+	LookupInstances(hostname string) []addresstable.Instance
 	GetAllAddresses() map[string][]string
 	IsWarm() bool
 }
@@ -174,14 +177,16 @@ func (s *Server) handleRegistrationRequest(resp http.ResponseWriter, req *http.R
 	}
 
 	lookupStartTime := time.Now()
-	ips := s.addressTable.Lookup(serviceKey)
+	instances := s.addressTable.LookupInstances(serviceKey)
 	lookupDuration := time.Now().Sub(lookupStartTime)
 	s.metricsSender.SendDuration("addressTableLookupTime", lookupDuration)
-	hosts := make([]host, cap(ips))
-	for index, ip := range ips {
+	hosts := make([]host, len(instances))
+	for index, instance := range instances {
 		hosts[index] = host{
-			IPAddress: ip,
-			Tags:      make(map[string]interface{}),
+			IPAddress:     instance.IP,
+			InstanceIndex: instance.Index,
+			InstanceGUID:  instance.GUID,
+			Tags:          instanceTags(instance),
 		}
 	}
 
@@ -205,6 +210,17 @@ func (s *Server) handleRegistrationRequest(resp http.ResponseWriter, req *http.R
 	}))
 }
 
+func instanceTags(instance addresstable.Instance) map[string]interface{} {
+	tags := make(map[string]interface{})
+	if instance.Zone != "" {
+		tags["zone"] = instance.Zone
+	}
+	if instance.CellID != "" {
+		tags["cell_id"] = instance.CellID
+	}
+	return tags
+}
+
 func (s *Server) handleRoutesRequest(resp http.ResponseWriter, req *http.Request) {
 	availableAddresses := s.addressTable.GetAllAddresses()
 	addresses := []address{}
@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"service-discovery-controller/addresstable"
 	"service-discovery-controller/config"
 	. "service-discovery-controller/routes"
 	"service-discovery-controller/routes/fakes"
@@ -64,11 +65,17 @@ var _ = Describe("Server", func() {
 
 		BeforeEach(func() {
 			serverProc = ifrit.Invoke(server)
-			addressTable.LookupStub = func(hostname string) []string {
+			addressTable.LookupInstancesStub = func(hostname string) []addresstable.Instance {
 				if hostname == "app-id.internal.local." {
-					return []string{"192.168.0.2"}
+					return []addresstable.Instance{{IP: "192.168.0.2"}}
 				}
-				return []string{}
+				if hostname == "instances.internal.local." {
+					return []addresstable.Instance{
+						{IP: "192.168.0.3", Index: "0", GUID: "guid-0"},
+						{IP: "192.168.0.4", Index: "1", GUID: "guid-1", Zone: "z1", CellID: "cell-1"},
+					}
+				}
+				return []addresstable.Instance{}
 			}
 			addressTable.IsWarmReturns(true)
 
@@ -106,6 +113,42 @@ var _ = Describe("Server", func() {
 			}`))
 		})
 
+		It("should list instances with their metadata", func() {
+			resp, err := client.Get(fmt.Sprintf("https://127.0.0.1:%d/v1/registration/instances.internal.local.", port))
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+
+			respBodyBytes, err := ioutil.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(respBodyBytes)).To(MatchJSON(`{
+				"env": "",
+				"hosts": [
+				{
+					"ip_address": "192.168.0.3",
+					"instance_index": "0",
+					"instance_guid": "guid-0",
+					"last_check_in": "",
+					"port": 0,
+					"revision": "",
+					"service": "",
+					"service_repo_name": "",
+					"tags": {}
+				},
+				{
+					"ip_address": "192.168.0.4",
+					"instance_index": "1",
+					"instance_guid": "guid-1",
+					"last_check_in": "",
+					"port": 0,
+					"revision": "",
+					"service": "",
+					"service_repo_name": "",
+					"tags": {"zone": "z1", "cell_id": "cell-1"}
+				}],
+				"service": ""
+			}`))
+		})
+
 		It("invokes the dns request recorder", func() {
 			Expect(dnsRequestRecorder.RecordRequestCallCount()).To(BeNumerically(">=", 1))
 		})
@@ -0,0 +1,106 @@
+package staticrecords
+
+import "service-discovery-controller/addresstable"
+
+const (
+	// PrecedenceStatic answers with the static records for a hostname when
+	// there are any, hiding the dynamic ones.
+	PrecedenceStatic = "static"
+	// PrecedenceDynamic only falls back to static records for a hostname
+	// when nothing has been registered for it.
+	PrecedenceDynamic = "dynamic"
+	// PrecedenceMerge answers with both, static records first.
+	PrecedenceMerge = "merge"
+)
+
+//go:generate counterfeiter -o fakes/dynamic_table.go --fake-name DynamicTable . DynamicTable
+type DynamicTable interface {
+	LookupInstances(hostname string) []addresstable.Instance
+	GetAllAddresses() map[string][]string
+	IsWarm() bool
+}
+
+//go:generate counterfeiter -o fakes/static_table.go --fake-name StaticTable . StaticTable
+type StaticTable interface {
+	Lookup(hostname string) []string
+	GetAllAddresses() map[string][]string
+}
+
+type MergedTable struct {
+	dynamic    DynamicTable
+	static     StaticTable
+	precedence string
+}
+
+func NewMergedTable(dynamic DynamicTable, static StaticTable, precedence string) *MergedTable {
+	return &MergedTable{
+		dynamic:    dynamic,
+		static:     static,
+		precedence: precedence,
+	}
+}
+
+func (t *MergedTable) LookupInstances(hostname string) []addresstable.Instance {
+	staticIPs := t.static.Lookup(hostname)
+	if len(staticIPs) == 0 {
+		return t.dynamic.LookupInstances(hostname)
+	}
+
+	staticInstances := make([]addresstable.Instance, len(staticIPs))
+	for idx, ip := range staticIPs {
+		staticInstances[idx] = addresstable.Instance{IP: ip}
+	}
+
+	if t.precedence == PrecedenceStatic {
+		return staticInstances
+	}
+
+	dynamicInstances := t.dynamic.LookupInstances(hostname)
+	if t.precedence == PrecedenceDynamic {
+		if len(dynamicInstances) > 0 {
+			return dynamicInstances
+		}
+		return staticInstances
+	}
+
+	instances := staticInstances
+	for _, instance := range dynamicInstances {
+		if !containsIP(staticIPs, instance.IP) {
+			instances = append(instances, instance)
+		}
+	}
+	return instances
+}
+
+func (t *MergedTable) GetAllAddresses() map[string][]string {
+	addresses := t.dynamic.GetAllAddresses()
+	for hostname, staticIPs := range t.static.GetAllAddresses() {
+		dynamicIPs := addresses[hostname]
+		switch {
+		case len(dynamicIPs) == 0 || t.precedence == PrecedenceStatic:
+			addresses[hostname] = staticIPs
+		case t.precedence == PrecedenceMerge:
+			ips := staticIPs
+			for _, ip := range dynamicIPs {
+				if !containsIP(staticIPs, ip) {
+					ips = append(ips, ip)
+				}
+			}
+			addresses[hostname] = ips
+		}
+	}
+	return addresses
+}
+
+func (t *MergedTable) IsWarm() bool {
+	return t.dynamic.IsWarm()
+}
+
+func containsIP(ips []string, ip string) bool {
+	for _, candidate := range ips {
+		if candidate == ip {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package staticrecords_test
+
+import (
+	"service-discovery-controller/addresstable"
+	"service-discovery-controller/staticrecords"
+	"service-discovery-controller/staticrecords/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MergedTable", func() {
+	var (
+		dynamicTable *fakes.DynamicTable
+		staticTable  *fakes.StaticTable
+		precedence   string
+		table        *staticrecords.MergedTable
+	)
+
+	BeforeEach(func() {
+		dynamicTable = &fakes.DynamicTable{}
+		dynamicTable.LookupInstancesReturns([]addresstable.Instance{
+			{IP: "192.168.0.1", Index: "0"},
+			{IP: "10.0.0.1", Index: "1"},
+		})
+		dynamicTable.GetAllAddressesReturns(map[string][]string{
+			"app.apps.internal.": {"192.168.0.1", "10.0.0.1"},
+			"dyn.apps.internal.": {"192.168.0.5"},
+		})
+		dynamicTable.IsWarmReturns(true)
+
+		staticTable = &fakes.StaticTable{}
+		staticTable.LookupReturns([]string{"10.0.0.1", "10.0.0.2"})
+		staticTable.GetAllAddressesReturns(map[string][]string{
+			"app.apps.internal.":    {"10.0.0.1", "10.0.0.2"},
+			"static.apps.internal.": {"10.0.0.9"},
+		})
+
+		precedence = staticrecords.PrecedenceStatic
+	})
+
+	JustBeforeEach(func() {
+		table = staticrecords.NewMergedTable(dynamicTable, staticTable, precedence)
+	})
+
+	It("delegates warmth to the dynamic table", func() {
+		Expect(table.IsWarm()).To(BeTrue())
+		dynamicTable.IsWarmReturns(false)
+		Expect(table.IsWarm()).To(BeFalse())
+	})
+
+	Context("when there are no static records for the hostname", func() {
+		BeforeEach(func() {
+			staticTable.LookupReturns([]string{})
+		})
+
+		It("returns the dynamic instances", func() {
+			Expect(table.LookupInstances("app.apps.internal.")).To(Equal([]addresstable.Instance{
+				{IP: "192.168.0.1", Index: "0"},
+				{IP: "10.0.0.1", Index: "1"},
+			}))
+			Expect(staticTable.LookupArgsForCall(0)).To(Equal("app.apps.internal."))
+			Expect(dynamicTable.LookupInstancesArgsForCall(0)).To(Equal("app.apps.internal."))
+		})
+	})
+
+	Context("when static records take precedence", func() {
+		It("hides the dynamic instances", func() {
+			Expect(table.LookupInstances("app.apps.internal.")).To(Equal([]addresstable.Instance{
+				{IP: "10.0.0.1"},
+				{IP: "10.0.0.2"},
+			}))
+			Expect(dynamicTable.LookupInstancesCallCount()).To(Equal(0))
+		})
+
+		It("overrides dynamic addresses in the full listing", func() {
+			Expect(table.GetAllAddresses()).To(Equal(map[string][]string{
+				"app.apps.internal.":    {"10.0.0.1", "10.0.0.2"},
+				"dyn.apps.internal.":    {"192.168.0.5"},
+				"static.apps.internal.": {"10.0.0.9"},
+			}))
+		})
+	})
+
+	Context("when dynamic records take precedence", func() {
+		BeforeEach(func() {
+			precedence = staticrecords.PrecedenceDynamic
+		})
+
+		It("returns the dynamic instances when there are any", func() {
+			Expect(table.LookupInstances("app.apps.internal.")).To(Equal([]addresstable.Instance{
+				{IP: "192.168.0.1", Index: "0"},
+				{IP: "10.0.0.1", Index: "1"},
+			}))
+		})
+
+		It("falls back to the static records", func() {
+			dynamicTable.LookupInstancesReturns([]addresstable.Instance{})
+			Expect(table.LookupInstances("app.apps.internal.")).To(Equal([]addresstable.Instance{
+				{IP: "10.0.0.1"},
+				{IP: "10.0.0.2"},
+			}))
+		})
+
+		It("only adds static hostnames to the full listing", func() {
+			Expect(table.GetAllAddresses()).To(Equal(map[string][]string{
+				"app.apps.internal.":    {"192.168.0.1", "10.0.0.1"},
+				"dyn.apps.internal.":    {"192.168.0.5"},
+				"static.apps.internal.": {"10.0.0.9"},
+			}))
+		})
+	})
+
+	Context("when records are merged", func() {
+		BeforeEach(func() {
+			precedence = staticrecords.PrecedenceMerge
+		})
+
+		It("returns static records first followed by new dynamic instances", func() {
+			Expect(table.LookupInstances("app.apps.internal.")).To(Equal([]addresstable.Instance{
+				{IP: "10.0.0.1"},
+				{IP: "10.0.0.2"},
+				{IP: "192.168.0.1", Index: "0"},
+			}))
+		})
+
+		It("merges the full listing", func() {
+			Expect(table.GetAllAddresses()).To(Equal(map[string][]string{
+				"app.apps.internal.":    {"10.0.0.1", "10.0.0.2", "192.168.0.1"},
+				"dyn.apps.internal.":    {"192.168.0.5"},
+				"static.apps.internal.": {"10.0.0.9"},
+			}))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package staticrecords_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestStaticrecords(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Staticrecords Suite")
+}
@@ -0,0 +1,269 @@
+package staticrecords
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"code.cloudfoundry.org/clock"
+)
+
+type Record struct {
+	Hostname string `json:"hostname"`
+	IP       string `json:"ip"`
+}
+
+// Snapshot is the whole set of records at a version. Every change moves the
+// version forward, so that replicas keep the newest snapshot they see.
+type Snapshot struct {
+	Version int64    `json:"version"`
+	Records []Record `json:"records"`
+}
+
+// Store holds operator-managed records. Unlike the address table, records
+// are never pruned; they live until they are removed through the admin API.
+// When a path is given every change is written through to that file.
+type Store struct {
+	path    string
+	clock   clock.Clock
+	version int64
+	records map[string][]string
+	mutex   sync.RWMutex
+}
+
+func NewStore(path string, clock clock.Clock) (*Store, error) {
+	store := &Store{
+		path:    path,
+		clock:   clock,
+		records: map[string][]string{},
+	}
+
+	if path == "" {
+		return store, nil
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read static records: %s", err)
+	}
+
+	if len(strings.TrimSpace(string(contents))) == 0 {
+		return store, nil
+	}
+
+	var snapshot Snapshot
+	err = json.Unmarshal(contents, &snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("parse static records: %s", err)
+	}
+
+	store.replaceWithLock(snapshot)
+
+	return store, nil
+}
+
+// Add stores the record and reports whether it was not already present.
+func (s *Store) Add(record Record) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.addWithLock(record) {
+		return false, nil
+	}
+
+	version := s.version
+	s.version = s.nextVersionWithLock()
+	err := s.persistWithLock()
+	if err != nil {
+		s.removeWithLock(record)
+		s.version = version
+		return false, err
+	}
+	return true, nil
+}
+
+// Remove deletes the record and reports whether it was present.
+func (s *Store) Remove(record Record) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.removeWithLock(record) {
+		return false, nil
+	}
+
+	version := s.version
+	s.version = s.nextVersionWithLock()
+	err := s.persistWithLock()
+	if err != nil {
+		s.addWithLock(record)
+		s.version = version
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Store) Snapshot() Snapshot {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return Snapshot{Version: s.version, Records: s.listWithLock()}
+}
+
+// Replace swaps in the records of snapshot and reports whether it did. A
+// snapshot that is not newer than the current records is ignored.
+func (s *Store) Replace(snapshot Snapshot) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if snapshot.Version <= s.version {
+		return false, nil
+	}
+
+	previous := Snapshot{Version: s.version, Records: s.listWithLock()}
+	s.replaceWithLock(snapshot)
+	err := s.persistWithLock()
+	if err != nil {
+		s.replaceWithLock(previous)
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Store) List() []Record {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.listWithLock()
+}
+
+func (s *Store) Lookup(hostname string) []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return append([]string{}, s.records[fqdn(hostname)]...)
+}
+
+func (s *Store) GetAllAddresses() map[string][]string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	addresses := map[string][]string{}
+	for hostname, ips := range s.records {
+		addresses[hostname] = append([]string{}, ips...)
+	}
+	return addresses
+}
+
+// nextVersionWithLock returns the current time, or one more than the current
+// version if the clock is behind it.
+func (s *Store) nextVersionWithLock() int64 {
+	version := s.clock.Now().UnixNano()
+	if version <= s.version {
+		version = s.version + 1
+	}
+	return version
+}
+
+func (s *Store) replaceWithLock(snapshot Snapshot) {
+	s.version = snapshot.Version
+	s.records = map[string][]string{}
+	for _, record := range snapshot.Records {
+		s.addWithLock(record)
+	}
+}
+
+func (s *Store) addWithLock(record Record) bool {
+	hostname := fqdn(record.Hostname)
+	for _, ip := range s.records[hostname] {
+		if ip == record.IP {
+			return false
+		}
+	}
+	s.records[hostname] = append(s.records[hostname], record.IP)
+	return true
+}
+
+func (s *Store) removeWithLock(record Record) bool {
+	hostname := fqdn(record.Hostname)
+	ips := s.records[hostname]
+	for idx, ip := range ips {
+		if ip != record.IP {
+			continue
+		}
+		if len(ips) == 1 {
+			delete(s.records, hostname)
+		} else {
+			s.records[hostname] = append(ips[:idx:idx], ips[idx+1:]...)
+		}
+		return true
+	}
+	return false
+}
+
+func (s *Store) listWithLock() []Record {
+	records := []Record{}
+	for hostname, ips := range s.records {
+		for _, ip := range ips {
+			records = append(records, Record{Hostname: hostname, IP: ip})
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Hostname != records[j].Hostname {
+			return records[i].Hostname < records[j].Hostname
+		}
+		return records[i].IP < records[j].IP
+	})
+	return records
+}
+
+// persistWithLock replaces the records file by writing a sibling temp file
+// and renaming it into place, so a crash never leaves a partial file.
+func (s *Store) persistWithLock() error {
+	if s.path == "" {
+		return nil
+	}
+
+	contents, err := json.Marshal(Snapshot{Version: s.version, Records: s.listWithLock()})
+	if err != nil {
+		return fmt.Errorf("marshal static records: %s", err)
+	}
+
+	tempFile, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path))
+	if err != nil {
+		return fmt.Errorf("write static records: %s", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.Write(contents)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	closeErr := tempFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write static records: %s", err)
+	}
+
+	err = os.Rename(tempFile.Name(), s.path)
+	if err != nil {
+		return fmt.Errorf("write static records: %s", err)
+	}
+	return nil
+}
+
+func fqdn(s string) string {
+	if strings.HasSuffix(s, ".") {
+		return s
+	}
+	return s + "."
+}
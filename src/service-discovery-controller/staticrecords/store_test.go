@@ -0,0 +1,209 @@
+package staticrecords_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"service-discovery-controller/staticrecords"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store", func() {
+	var (
+		dir       string
+		path      string
+		fakeClock *fakeclock.FakeClock
+		store     *staticrecords.Store
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "static-records")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "records.json")
+		fakeClock = fakeclock.NewFakeClock(time.Unix(0, 1000))
+
+		store, err = staticrecords.NewStore(path, fakeClock)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("adds, looks up and lists records", func() {
+		added, err := store.Add(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.2"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(added).To(BeTrue())
+		_, err = store.Add(staticrecords.Record{Hostname: "db.apps.internal.", IP: "10.0.0.1"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(store.Lookup("db.apps.internal")).To(Equal([]string{"10.0.0.2", "10.0.0.1"}))
+		Expect(store.List()).To(Equal([]staticrecords.Record{
+			{Hostname: "db.apps.internal.", IP: "10.0.0.1"},
+			{Hostname: "db.apps.internal.", IP: "10.0.0.2"},
+		}))
+		Expect(store.GetAllAddresses()).To(Equal(map[string][]string{
+			"db.apps.internal.": {"10.0.0.2", "10.0.0.1"},
+		}))
+	})
+
+	It("reports duplicate records as not added", func() {
+		_, err := store.Add(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.1"})
+		Expect(err).NotTo(HaveOccurred())
+
+		added, err := store.Add(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(added).To(BeFalse())
+		Expect(store.Lookup("db.apps.internal")).To(Equal([]string{"10.0.0.1"}))
+	})
+
+	It("removes records", func() {
+		_, err := store.Add(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.1"})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = store.Add(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.2"})
+		Expect(err).NotTo(HaveOccurred())
+
+		removed, err := store.Remove(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(removed).To(BeTrue())
+		Expect(store.Lookup("db.apps.internal")).To(Equal([]string{"10.0.0.2"}))
+
+		removed, err = store.Remove(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.1"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(removed).To(BeFalse())
+	})
+
+	It("persists records across restarts", func() {
+		_, err := store.Add(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.1"})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = store.Add(staticrecords.Record{Hostname: "cache.apps.internal", IP: "10.0.0.3"})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = store.Remove(staticrecords.Record{Hostname: "cache.apps.internal", IP: "10.0.0.3"})
+		Expect(err).NotTo(HaveOccurred())
+
+		reloaded, err := staticrecords.NewStore(path, fakeClock)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reloaded.Snapshot()).To(Equal(store.Snapshot()))
+		Expect(reloaded.List()).To(Equal([]staticrecords.Record{
+			{Hostname: "db.apps.internal.", IP: "10.0.0.1"},
+		}))
+	})
+
+	Describe("Snapshot", func() {
+		It("moves the version forward on every change", func() {
+			Expect(store.Snapshot()).To(Equal(staticrecords.Snapshot{Records: []staticrecords.Record{}}))
+
+			_, err := store.Add(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.1"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(store.Snapshot()).To(Equal(staticrecords.Snapshot{
+				Version: 1000,
+				Records: []staticrecords.Record{{Hostname: "db.apps.internal.", IP: "10.0.0.1"}},
+			}))
+
+			_, err = store.Remove(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.1"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(store.Snapshot().Version).To(Equal(int64(1001)))
+
+			fakeClock.Increment(time.Microsecond)
+			_, err = store.Add(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.1"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(store.Snapshot().Version).To(Equal(int64(2000)))
+		})
+	})
+
+	Describe("Replace", func() {
+		BeforeEach(func() {
+			_, err := store.Add(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.1"})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("replaces the records with a newer snapshot and persists it", func() {
+			snapshot := staticrecords.Snapshot{
+				Version: 1001,
+				Records: []staticrecords.Record{{Hostname: "cache.apps.internal.", IP: "10.0.0.3"}},
+			}
+
+			replaced, err := store.Replace(snapshot)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replaced).To(BeTrue())
+			Expect(store.Snapshot()).To(Equal(snapshot))
+			Expect(store.Lookup("db.apps.internal")).To(BeEmpty())
+
+			reloaded, err := staticrecords.NewStore(path, fakeClock)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reloaded.Snapshot()).To(Equal(snapshot))
+		})
+
+		It("ignores a snapshot that is not newer", func() {
+			replaced, err := store.Replace(staticrecords.Snapshot{Version: 1000})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replaced).To(BeFalse())
+			Expect(store.Lookup("db.apps.internal")).To(Equal([]string{"10.0.0.1"}))
+		})
+
+		It("versions later changes after the snapshot", func() {
+			_, err := store.Replace(staticrecords.Snapshot{Version: 5000})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = store.Add(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.2"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(store.Snapshot().Version).To(Equal(int64(5001)))
+		})
+
+		Context("when the records file cannot be written", func() {
+			It("returns an error and keeps the current records", func() {
+				Expect(os.RemoveAll(dir)).To(Succeed())
+
+				_, err := store.Replace(staticrecords.Snapshot{Version: 5000})
+				Expect(err).To(MatchError(ContainSubstring("write static records")))
+				Expect(store.Snapshot().Version).To(Equal(int64(1000)))
+				Expect(store.Lookup("db.apps.internal")).To(Equal([]string{"10.0.0.1"}))
+			})
+		})
+	})
+
+	Context("when no path is configured", func() {
+		It("keeps records in memory", func() {
+			store, err := staticrecords.NewStore("", fakeClock)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = store.Add(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.1"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(store.Lookup("db.apps.internal")).To(Equal([]string{"10.0.0.1"}))
+		})
+	})
+
+	Context("when the records file is empty", func() {
+		It("starts with no records", func() {
+			Expect(ioutil.WriteFile(path, []byte{}, 0600)).To(Succeed())
+
+			store, err := staticrecords.NewStore(path, fakeClock)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(store.List()).To(BeEmpty())
+		})
+	})
+
+	Context("when the records file is corrupt", func() {
+		It("returns an error", func() {
+			Expect(ioutil.WriteFile(path, []byte("garbage"), 0600)).To(Succeed())
+
+			_, err := staticrecords.NewStore(path, fakeClock)
+			Expect(err).To(MatchError(ContainSubstring("parse static records")))
+		})
+	})
+
+	Context("when the records file cannot be written", func() {
+		It("returns an error and does not keep the record", func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+
+			_, err := store.Add(staticrecords.Record{Hostname: "db.apps.internal", IP: "10.0.0.1"})
+			Expect(err).To(MatchError(ContainSubstring("write static records")))
+			Expect(store.Lookup("db.apps.internal")).To(BeEmpty())
+		})
+	})
+})